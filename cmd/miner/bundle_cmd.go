@@ -0,0 +1,231 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"slowdrip-miner/internal/chain"
+	"slowdrip-miner/internal/config"
+	"slowdrip-miner/internal/receipts"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/ethclient"
+)
+
+// bundleCommand handles "miner bundle <export|verify|mark> ...".
+func bundleCommand(args []string) int {
+	return subcommands("bundle", args, map[string]func([]string) error{
+		"export": bundleExport,
+		"verify": bundleVerify,
+		"mark":   bundleMark,
+	})
+}
+
+// bundleExport writes every unclaimed batch, with proofs, as one claim bundle.
+// It needs no key and no RPC: headers are already signed when batches close.
+// Batches stay unclaimed (and are exported again) until "bundle mark" sees the
+// claim confirmed on-chain.
+func bundleExport(args []string) error {
+	fs := flag.NewFlagSet("bundle export", flag.ContinueOnError)
+	open := storeFlags(fs)
+	out := fs.String("out", "", "bundle file to write (default: stdout)")
+	if err := fs.Parse(args); err != nil {
+		return errUsage
+	}
+	store, err := open()
+	if err != nil {
+		return err
+	}
+	batches, err := store.Unclaimed()
+	if err != nil {
+		return err
+	}
+	b, err := receipts.BuildBundle(batches)
+	if err != nil {
+		return err
+	}
+	// Never hand out something the other side would reject.
+	if err := receipts.VerifyBundle(b); err != nil {
+		return fmt.Errorf("refusing to export: %w", err)
+	}
+
+	var w io.Writer = os.Stdout
+	if *out != "" {
+		f, err := os.OpenFile(*out, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		w = f
+	}
+	if err := receipts.WriteBundle(w, b); err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "exported %d batches, %d receipts (miners %s)\n", len(b.Batches), b.Receipts, strings.Join(b.Miners, ", "))
+	return nil
+}
+
+// bundleVerify checks a bundle offline: signatures, proofs and counts.
+func bundleVerify(args []string) error {
+	fs := flag.NewFlagSet("bundle verify", flag.ContinueOnError)
+	expect := fs.String("miner", "", "comma-separated miner addresses every batch must be signed by (optional)")
+	if err := fs.Parse(args); err != nil {
+		return errUsage
+	}
+	var allowed []common.Address
+	if *expect != "" {
+		for _, a := range strings.Split(*expect, ",") {
+			a = strings.TrimSpace(a)
+			if !common.IsHexAddress(a) {
+				return fmt.Errorf("bad -miner %q", a)
+			}
+			allowed = append(allowed, common.HexToAddress(a))
+		}
+	}
+	if fs.NArg() != 1 {
+		return errors.New("usage: miner bundle verify [-miner 0x...] <bundle.json>")
+	}
+	f, err := os.Open(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	b, err := receipts.ReadBundle(f)
+	if err != nil {
+		return err
+	}
+	if err := receipts.VerifyBundle(b); err != nil {
+		return err
+	}
+	if len(allowed) > 0 {
+		for _, m := range b.Miners {
+			if !containsAddress(allowed, common.HexToAddress(m)) {
+				return fmt.Errorf("bundle has batches from %s, expected %s", m, *expect)
+			}
+		}
+	}
+	fmt.Printf("ok: %d batches, %d receipts, miners %s, chain %d\n", len(b.Batches), b.Receipts, strings.Join(b.Miners, ", "), b.ChainID)
+	for _, bb := range b.Batches {
+		fmt.Printf("  batch %d: %d receipts, root %s, miner %s\n", bb.Header.ID, bb.Header.Count, bb.Header.Root.Hex(), bb.Header.Miner)
+	}
+	return nil
+}
+
+func containsAddress(list []common.Address, a common.Address) bool {
+	for _, x := range list {
+		if x == a {
+			return true
+		}
+	}
+	return false
+}
+
+// bundleMark records batches from an exported bundle as claimed, once the claim
+// transaction is final on chain.rpc: mined successfully, to chain.contract,
+// with chain.confirmations blocks on top. Until then they keep being exported.
+func bundleMark(args []string) error {
+	fs := flag.NewFlagSet("bundle mark", flag.ContinueOnError)
+	cfgPath := fs.String("config", defaultConfigPath(), "miner config file")
+	dir := fs.String("dir", "", "batch store (default: receipts.dir from the config)")
+	txFlag := fs.String("tx", "", "hash of the claim transaction (required)")
+	only := fs.String("batches", "", "comma-separated batch IDs the transaction claimed (default: every batch in the bundle)")
+	if err := fs.Parse(args); err != nil {
+		return errUsage
+	}
+	if fs.NArg() != 1 || *txFlag == "" {
+		return errors.New("usage: miner bundle mark -tx 0x... [-batches 1,2] <bundle.json>")
+	}
+	raw, err := hexutil.Decode(*txFlag)
+	if err != nil || len(raw) != common.HashLength {
+		return fmt.Errorf("bad -tx %q", *txFlag)
+	}
+	hash := common.BytesToHash(raw)
+	cfg, err := config.Load(*cfgPath)
+	if err != nil {
+		return err
+	}
+	if *dir == "" {
+		*dir = cfg.Receipts.Dir
+	}
+	if *dir == "" {
+		return errors.New("no batch store: set receipts.dir or -dir")
+	}
+	if cfg.Chain.RPC == "" {
+		return errors.New("chain.rpc is not set: cannot check the claim")
+	}
+
+	f, err := os.Open(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	b, err := receipts.ReadBundle(f)
+	f.Close()
+	if err != nil {
+		return err
+	}
+	ids := make([]uint64, 0, len(b.Batches))
+	if *only == "" {
+		for _, bb := range b.Batches {
+			ids = append(ids, bb.Header.ID)
+		}
+	} else {
+		inBundle := map[uint64]bool{}
+		for _, bb := range b.Batches {
+			inBundle[bb.Header.ID] = true
+		}
+		for _, s := range strings.Split(*only, ",") {
+			id, err := strconv.ParseUint(strings.TrimSpace(s), 10, 64)
+			if err != nil {
+				return fmt.Errorf("bad batch id %q", s)
+			}
+			if !inBundle[id] {
+				return fmt.Errorf("batch %d is not in the bundle", id)
+			}
+			ids = append(ids, id)
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	ec, err := ethclient.DialContext(ctx, cfg.Chain.RPC)
+	if err != nil {
+		return err
+	}
+	defer ec.Close()
+	if c := cfg.Chain.Contract; c != "" {
+		tx, _, err := ec.TransactionByHash(ctx, hash)
+		if err != nil {
+			return fmt.Errorf("claim tx %s: %w", hash.Hex(), err)
+		}
+		if tx.To() == nil || *tx.To() != common.HexToAddress(c) {
+			return fmt.Errorf("claim tx %s is not sent to chain.contract %s", hash.Hex(), c)
+		}
+	}
+	confirmations := cfg.Chain.Confirmations
+	if confirmations == 0 {
+		confirmations = 12
+	}
+	if _, err := chain.Confirmed(ctx, ec, hash, confirmations); err != nil {
+		return err
+	}
+
+	store, err := receipts.OpenStore(*dir)
+	if err != nil {
+		return err
+	}
+	for _, id := range ids {
+		if err := store.MarkClaimed(id, "bundle", hash.Hex()); err != nil {
+			return err
+		}
+	}
+	fmt.Printf("marked %d batches claimed by %s\n", len(ids), hash.Hex())
+	return nil
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+)
+
+const usage = `usage: miner <command> [flags]
+
+Commands:
+  run                      run the miner daemon (default when no command is given)
+  config validate|print|schema
+                           check or show the effective configuration, or its JSON Schema
+  config host-key|seal     create the host key and seal secrets to it
+  wallet new|import|export-address|sign-message|backup|restore
+  receipts list|verify|anchor
+  batches list|show
+  bundle export|verify|mark
+                           offline claim bundles
+  shards verify            check FEC shards from /v1/shards against a segment commitment and rebuild it
+  presence test-challenge  sign and verify a liveness challenge with the miner key
+  mediamtx render|apply    generate mediamtx.yml from miner.yaml, or push it to a running MediaMTX
+
+Every command takes -config (default $MINER_CONFIG or configs/miner.yaml) where
+it needs one. Run "miner <command> <sub> -h" for its flags.
+`
+
+// errUsage is returned by a command whose flags failed to parse; the flag
+// package has already printed the problem and the defaults.
+var errUsage = errors.New("usage")
+
+// dispatch routes argv (without the program name) to a command and returns the exit code.
+func dispatch(args []string) int {
+	if len(args) == 0 || strings.HasPrefix(args[0], "-") && args[0] != "-h" && args[0] != "-help" {
+		return runCommand(args) // "miner" and "miner -config x" keep starting the daemon
+	}
+	switch args[0] {
+	case "run":
+		return runCommand(args[1:])
+	case "config":
+		return configCommand(args[1:])
+	case "wallet":
+		return walletCommand(args[1:])
+	case "receipts":
+		return receiptsCommand(args[1:])
+	case "batches":
+		return batchesCommand(args[1:])
+	case "bundle":
+		return bundleCommand(args[1:])
+	case "shards":
+		return shardsCommand(args[1:])
+	case "presence":
+		return presenceCommand(args[1:])
+	case "mediamtx":
+		return mediamtxCommand(args[1:])
+	case "help", "-h", "-help":
+		fmt.Print(usage)
+		return 0
+	}
+	fmt.Fprintf(os.Stderr, "miner: unknown command %q\n\n%s", args[0], usage)
+	return 2
+}
+
+// subcommands runs one of cmds for "miner <group> <sub> ...", printing errors
+// as "miner <group> <sub>: ...". Exit codes: 0 ok, 1 failure, 2 usage.
+func subcommands(group string, args []string, cmds map[string]func([]string) error) int {
+	names := make([]string, 0, len(cmds))
+	for n := range cmds {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+	if len(args) == 0 || args[0] == "-h" || args[0] == "help" {
+		fmt.Fprintf(os.Stderr, "usage: miner %s <%s> [flags]\n", group, strings.Join(names, "|"))
+		return 2
+	}
+	fn, ok := cmds[args[0]]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "miner %s: unknown command %q\n", group, args[0])
+		return 2
+	}
+	if err := fn(args[1:]); err != nil {
+		if err == errUsage {
+			return 2
+		}
+		fmt.Fprintf(os.Stderr, "miner %s %s: %v\n", group, args[0], err)
+		return 1
+	}
+	return 0
+}
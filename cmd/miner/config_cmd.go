@@ -0,0 +1,193 @@
+package main
+
+import (
+	"crypto/ecdh"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"slowdrip-miner/internal/config"
+
+	"gopkg.in/yaml.v3"
+)
+
+// configCommand handles "miner config <validate|print|schema|host-key|seal>".
+func configCommand(args []string) int {
+	return subcommands("config", args, map[string]func([]string) error{
+		"validate": configValidate,
+		"print":    configPrint,
+		"schema":   configSchema,
+		"host-key": configHostKey,
+		"seal":     configSeal,
+	})
+}
+
+// configValidate loads the config exactly as "miner run" would.
+func configValidate(args []string) error {
+	fs := flag.NewFlagSet("config validate", flag.ContinueOnError)
+	cfgPath := fs.String("config", defaultConfigPath(), "miner config file")
+	if err := fs.Parse(args); err != nil {
+		return errUsage
+	}
+	if _, err := config.Load(*cfgPath); err != nil {
+		return err
+	}
+	fmt.Printf("%s: ok\n", *cfgPath)
+	return nil
+}
+
+// configPrint shows the effective config (includes and profiles merged, env
+// expanded, overlay and defaults applied, secrets redacted), preceded by the env
+// references and what they resolved to. With -sources it lists each field's
+// origin instead; with -merged it shows the merged files as written.
+func configPrint(args []string) error {
+	fs := flag.NewFlagSet("config print", flag.ContinueOnError)
+	cfgPath := fs.String("config", defaultConfigPath(), "miner config file")
+	sources := fs.Bool("sources", false, "list every field with its override variable and where its value came from")
+	merged := fs.Bool("merged", false, "print the merged YAML of the base file, includes and "+config.EnvProfile+" profiles, before env expansion and defaults")
+	if err := fs.Parse(args); err != nil {
+		return errUsage
+	}
+	raw, files, err := config.Merged(*cfgPath)
+	if err != nil {
+		return err
+	}
+	if *merged {
+		fmt.Printf("# merged from (lowest precedence first): %s\n", strings.Join(files, ", "))
+		_, err := os.Stdout.Write(raw)
+		return err
+	}
+	cfg, err := config.Load(*cfgPath)
+	if err != nil {
+		return err
+	}
+
+	if *sources {
+		tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(tw, "KEY\tSOURCE\tVALUE\tOVERRIDE")
+		for _, f := range cfg.Sources() {
+			src := f.Source
+			if f.File != "" {
+				src += " (" + f.File + ")"
+			}
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", f.Key, src, f.Value, f.Env)
+		}
+		return tw.Flush()
+	}
+
+	fmt.Printf("# effective config from %s\n", strings.Join(cfg.Files(), " + "))
+	if refs := config.EnvRefs(raw); len(refs) > 0 {
+		fmt.Println("# env:")
+		for _, r := range refs {
+			src := "default"
+			if r.Set {
+				src = "env"
+			}
+			val := r.Value
+			switch {
+			case val == "":
+				val = `""`
+			case sensitiveName(r.Name):
+				val = "<redacted>"
+			}
+			fmt.Printf("#   %s = %s (%s)\n", r.Name, val, src)
+		}
+	}
+	out, err := yaml.Marshal(cfg)
+	if err != nil {
+		return err
+	}
+	_, err = os.Stdout.Write(out)
+	return err
+}
+
+// sensitiveName guesses whether an env var holds a secret worth hiding.
+func sensitiveName(name string) bool {
+	n := strings.ToUpper(name)
+	for _, s := range []string{"TOKEN", "PASSWORD", "SECRET", "KEY", "PIN"} {
+		if strings.Contains(n, s) {
+			return true
+		}
+	}
+	return false
+}
+
+// configSchema prints the JSON Schema for miner.yaml.
+func configSchema(args []string) error {
+	fs := flag.NewFlagSet("config schema", flag.ContinueOnError)
+	if err := fs.Parse(args); err != nil {
+		return errUsage
+	}
+	b, err := config.JSONSchema()
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Printf("%s\n", b)
+	return err
+}
+
+// configHostKey creates the X25519 host key that opens sealed: secrets and
+// prints its public key.
+func configHostKey(args []string) error {
+	fs := flag.NewFlagSet("config host-key", flag.ContinueOnError)
+	out := fs.String("out", "", "where to write the key (mode 0600; never overwritten)")
+	if err := fs.Parse(args); err != nil {
+		return errUsage
+	}
+	if *out == "" {
+		return errors.New("usage: miner config host-key -out <file>")
+	}
+	pub, err := config.NewHostKey(*out)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "wrote %s; set secrets.hostKeyFile to it and seal values with:\n", *out)
+	fmt.Fprintf(os.Stderr, "  miner config seal -pub %s\n", pub)
+	fmt.Println(pub)
+	return nil
+}
+
+// configSeal reads a secret from stdin and prints a sealed: value for miner.yaml.
+// Sealing only needs the public key, so it can run away from the miner host.
+func configSeal(args []string) error {
+	fs := flag.NewFlagSet("config seal", flag.ContinueOnError)
+	pubFlag := fs.String("pub", "", "host public key (base64, from \"miner config host-key\")")
+	keyFile := fs.String("key", "", "host key file to take the public key from instead")
+	if err := fs.Parse(args); err != nil {
+		return errUsage
+	}
+	var pub *ecdh.PublicKey
+	switch {
+	case *pubFlag != "" && *keyFile == "":
+		var err error
+		if pub, err = config.ParsePublicKey(*pubFlag); err != nil {
+			return err
+		}
+	case *keyFile != "" && *pubFlag == "":
+		k, err := config.LoadHostKey(*keyFile)
+		if err != nil {
+			return err
+		}
+		pub = k.PublicKey()
+	default:
+		return errors.New("usage: miner config seal -pub <key> | -key <file>  (secret on stdin)")
+	}
+	b, err := io.ReadAll(os.Stdin)
+	if err != nil {
+		return err
+	}
+	secret := strings.TrimRight(string(b), "\r\n")
+	if secret == "" {
+		return errors.New("nothing to seal: stdin was empty")
+	}
+	sealed, err := config.Seal(pub, []byte(secret))
+	if err != nil {
+		return err
+	}
+	fmt.Println(sealed)
+	return nil
+}
@@ -2,25 +2,52 @@ package main
 
 import (
 	"context"
+	"flag"
+	"fmt"
 	"log"
+	"math/big"
+	"net"
 	"net/http"
 	"os"
 	"time"
 
+	"slowdrip-miner/internal/admission"
 	"slowdrip-miner/internal/api"
+	"slowdrip-miner/internal/chain"
 	"slowdrip-miner/internal/config"
+	"slowdrip-miner/internal/fec"
+	"slowdrip-miner/internal/hls"
 	"slowdrip-miner/internal/logger"
 	"slowdrip-miner/internal/mediamtx"
+	"slowdrip-miner/internal/policy"
 	"slowdrip-miner/internal/presence"
+	"slowdrip-miner/internal/receipts"
+	"slowdrip-miner/internal/recordings"
 	"slowdrip-miner/internal/service"
+	"slowdrip-miner/internal/tlsutil"
+	"slowdrip-miner/internal/wallet"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/rs/zerolog"
 )
 
 func main() {
-	cfgPath := os.Getenv("MINER_CONFIG")
-	if cfgPath == "" {
-		cfgPath = "configs/miner.yaml"
+	os.Exit(dispatch(os.Args[1:]))
+}
+
+// runCommand handles "miner run": the daemon. It is also what a bare "miner" runs.
+func runCommand(args []string) int {
+	fs := flag.NewFlagSet("run", flag.ContinueOnError)
+	cfgPath := fs.String("config", defaultConfigPath(), "miner config file")
+	if err := fs.Parse(args); err != nil {
+		return 2
 	}
+	run(*cfgPath)
+	return 0
+}
 
+// run starts every configured component and serves the admin API until it fails.
+func run(cfgPath string) {
 	cfg, err := config.Load(cfgPath)
 	if err != nil {
 		log.Fatalf("config: %v", err)
@@ -28,25 +55,475 @@ func main() {
 
 	lg := logger.New(cfg.LogLevel)
 
+	signer, rot, err := openSigner(cfg, lg)
+	if err != nil {
+		lg.Fatal().Err(err).Msg("wallet")
+	}
+	if signer != nil {
+		if c, ok := signer.(interface{ Close() }); ok {
+			defer c.Close()
+		}
+		api.RegisterWallet(signer, rot, wallet.EpochClock{Length: cfg.Wallet.EpochLength.Duration})
+	} else {
+		lg.Warn().Msg("wallet: not configured; nothing will be signed")
+	}
+
+	var store *receipts.Store
+	if signer != nil && cfg.Receipts.Dir != "" {
+		if store, err = startBatching(context.Background(), cfg, signer, lg); err != nil {
+			lg.Fatal().Err(err).Msg("receipts")
+		}
+	}
+
+	if cfg.Chain.Enable {
+		cc, err := openChain(cfg, signer, lg)
+		if err != nil {
+			lg.Fatal().Err(err).Msg("chain")
+		}
+		var st *chain.Settler
+		if store != nil {
+			st = chain.NewSettler(cc, store, lg)
+			go st.Run(context.Background(), cfg.Receipts.BatchInterval.Duration)
+		} else {
+			lg.Warn().Msg("chain: receipts.dir is not set; there are no batches to submit or claim")
+		}
+		api.RegisterStatus("chain", func() interface{} {
+			out := map[string]interface{}{
+				"chain_id": cc.ChainID().String(),
+				"contract": cfg.Chain.Contract,
+				"from":     signer.Address().Hex(),
+			}
+			if st != nil {
+				out["settler"] = st.Status()
+			}
+			return out
+		})
+	}
+
 	mm := mediamtx.NewClient(cfg.MediaMTX.API, lg)
-	go mediamtx.StartWatcher(context.Background(), mm, cfg.MediaMTX.PollInterval)
+	go mediamtx.StartWatcher(context.Background(), mm, cfg.MediaMTX.PollInterval.Duration)
+	if cfg.MediaMTX.Hooks.Enable && !cfg.MediaMTX.Hooks.Token.IsSet() {
+		lg.Warn().Msg("mediamtx: hooks.token not set; anyone reaching the admin listener can post path events")
+	}
+	var pm *mediamtx.PathManager
+	if cfg.MediaMTX.Manage {
+		sc := mediamtxServerConfig(cfg)
+		for _, w := range sc.AuthOverlaps() {
+			lg.Warn().Msg("mediamtx: path auth: " + w)
+		}
+		pm = mediamtx.NewPathManager(mm, sc, cfg.MediaMTX.ReconcileInterval.Duration, nil)
+		go pm.Run(context.Background())
+		api.RegisterStatus("mediamtx_config", func() interface{} { return pm.Status() })
+	}
+	if cfg.Recordings.Enable {
+		var pins recordings.Recorder
+		if pm != nil {
+			pins = pm // runtime record switches survive reconciles
+		}
+		rm, err := recordings.New(recordingOptions(cfg), mm, pins, service.AddReceipt, nil, lg)
+		if err != nil {
+			lg.Fatal().Err(err).Msg("recordings")
+		}
+		go rm.Run(context.Background())
+		api.RegisterRoute(recordings.ListPath, api.RoleViewer, rm)
+		api.RegisterRoute(recordings.RecordPath, api.RoleOperator, rm.RecordHandler())
+		api.RegisterStatus("recordings", func() interface{} { return rm.Status() })
+		if !cfg.Service.Enable {
+			lg.Warn().Msg("recordings: service.enable is off; recorded segments are indexed but not turned into receipts")
+		}
+	}
+	var pe *policy.Engine
+	if cfg.Policy.Enable {
+		audit, err := policy.OpenAudit(cfg.Policy.AuditFile, lg)
+		if err != nil {
+			lg.Fatal().Err(err).Msg("policy")
+		}
+		defer audit.Close()
+		pe = policy.New(mm, policyOptions(cfg), audit, nil, lg)
+		go pe.Run(context.Background())
+		api.RegisterStatus("policy", func() interface{} { return pe.Status() })
+		if (cfg.Policy.BanFor.Duration > 0 || cfg.Policy.ThrottleFor.Duration > 0) && !cfg.Admission.Enable {
+			lg.Warn().Msg("policy: admission is off, so banned and throttled IPs can reconnect; they are kicked again at the next sweep (HLS readers not at all)")
+		}
+	}
+	if cfg.Admission.Enable {
+		o := admissionOptions(cfg)
+		if pe != nil {
+			o.Blocklist = pe // readers kicked by policy are kept out for policy.banFor
+			o.Throttle = pe  // ...or held to policy.throttleReaders for policy.throttleFor
+		}
+		ac, err := admission.New(o, mediamtx.Snapshot, nil, lg)
+		if err != nil {
+			lg.Fatal().Err(err).Msg("admission")
+		}
+		go ac.Run(context.Background(), cfg.MediaMTX.PollInterval.Duration)
+		api.RegisterRoute(admission.HookPath, api.RolePublic, ac) // checks admission.hookToken itself
+		api.RegisterStatus("admission", func() interface{} { return ac.Status() })
+		if !cfg.Admission.HookToken.IsSet() {
+			lg.Warn().Msg("admission: hookToken not set; anyone reaching the admin listener can hold reader slots")
+		}
+		if cfg.Admission.Forward == "" {
+			lg.Warn().Strs("allow_actions", cfg.Admission.AllowActions).
+				Msg("admission: no forward endpoint; MediaMTX lets anyone publish and read within the limits (api, metrics and pprof are denied unless in allowActions)")
+		}
+	}
 
 	if cfg.Presence.Enable {
 		go presence.Start(context.Background(), lg)
+		if signer != nil {
+			api.RegisterRoute(presence.ChallengePath, api.RoleViewer, presence.Handler(cfg.Miner.ID, signer))
+		}
 	}
 	if cfg.Service.Enable {
 		go service.Start(context.Background(), lg)
+		if cfg.Service.Segments.Enable {
+			ob, err := hls.New(segmentOptions(cfg), mediamtx.Snapshot, service.AddReceipt, lg)
+			if err != nil {
+				lg.Fatal().Err(err).Msg("segments")
+			}
+			go ob.Run(context.Background())
+			api.RegisterStatus("segments", func() interface{} { return ob.Status() })
+			if cfg.Service.Segments.FEC.Data > 0 {
+				api.RegisterRoute(hls.ShardsPath, api.RoleViewer, ob)
+			}
+		}
 	}
 
-	mux := api.Router(cfg)
+	mux, err := api.Router(cfg, lg)
+	if err != nil {
+		lg.Fatal().Err(err).Msg("api router")
+	}
+	listen := adminListen(cfg.Miner.Listen, cfg, lg)
+	if hl := cfg.Miner.HooksListen; hl != "" {
+		hooks := &http.Server{Addr: hl, Handler: api.HooksRouter(cfg), ReadHeaderTimeout: 5 * time.Second}
+		go func() {
+			lg.Info().Msgf("mediamtx hooks listening on %s", hl)
+			if err := hooks.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+				lg.Fatal().Err(err).Msg("hooks server failed")
+			}
+		}()
+	} else if listen != cfg.Miner.Listen && (cfg.MediaMTX.Hooks.Enable || cfg.Admission.Enable) {
+		lg.Warn().Str("listen", listen).Msg("mediamtx hooks and admission are served on loopback only; set miner.hooksListen if MediaMTX runs in a container or on another host")
+	}
 	srv := &http.Server{
-		Addr:              cfg.Miner.Listen,
+		Addr:              listen,
 		Handler:           mux,
 		ReadHeaderTimeout: 5 * time.Second,
 	}
 
-	lg.Info().Msgf("miner %s listening on %s", cfg.Miner.ID, cfg.Miner.Listen)
-	if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+	if !cfg.Miner.TLS.Enable {
+		lg.Info().Msgf("miner %s listening on %s", cfg.Miner.ID, listen)
+		if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			lg.Fatal().Err(err).Msg("server failed")
+		}
+		return
+	}
+
+	// HTTPS. Plain HTTP on the TLS port is answered with a 400 by net/http;
+	// plaintextListen optionally redirects or refuses on a separate port.
+	tlsConf, reloader, err := tlsutil.ServerConfig(cfg.Miner.TLS, selfSignedHosts(cfg.Miner.Listen), lg)
+	if err != nil {
+		lg.Fatal().Err(err).Msg("tls config")
+	}
+	srv.TLSConfig = tlsConf
+	if reloader != nil {
+		go reloader.Watch(context.Background(), cfg.Miner.TLS.ReloadInterval.Duration)
+	}
+	if pl := cfg.Miner.TLS.PlaintextListen; pl != "" {
+		pl = adminListen(pl, cfg, lg)
+		h, err := tlsutil.PlaintextHandler(cfg.Miner.TLS.Plaintext, cfg.Miner.Listen)
+		if err != nil {
+			lg.Fatal().Err(err).Msg("tls plaintext listener")
+		}
+		plain := &http.Server{Addr: pl, Handler: h, ReadHeaderTimeout: 5 * time.Second}
+		go func() {
+			lg.Info().Msgf("plaintext (%s) listening on %s", cfg.Miner.TLS.Plaintext, pl)
+			if err := plain.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+				lg.Fatal().Err(err).Msg("plaintext server failed")
+			}
+		}()
+	}
+
+	lg.Info().Msgf("miner %s listening on %s (tls %s)", cfg.Miner.ID, listen, cfg.Miner.TLS.MinVersion)
+	if err := srv.ListenAndServeTLS("", ""); err != nil && err != http.ErrServerClosed {
 		lg.Fatal().Err(err).Msg("server failed")
 	}
 }
+
+// defaultConfigPath is MINER_CONFIG or configs/miner.yaml.
+func defaultConfigPath() string {
+	if p := os.Getenv("MINER_CONFIG"); p != "" {
+		return p
+	}
+	return "configs/miner.yaml"
+}
+
+// openSigner sets up the miner's signer from the wallet block. Local keys are
+// wrapped in a Rotator (rotation needs a keystore file); remote signers are used as-is.
+// Returns (nil, nil, nil) when no wallet is configured.
+func openSigner(cfg *config.Config, lg zerolog.Logger) (wallet.Signer, *wallet.Rotator, error) {
+	switch cfg.Wallet.Source {
+	case "":
+		return nil, nil, nil
+	case wallet.SourceRemote:
+		rs, err := wallet.DialRemote(context.Background(), wallet.RemoteOptions{
+			URL:             cfg.Wallet.Remote.URL,
+			Address:         common.HexToAddress(cfg.Wallet.Remote.Address),
+			Timeout:         cfg.Wallet.Remote.Timeout.Duration,
+			TypedDataMethod: cfg.Wallet.Remote.TypedDataMethod,
+		})
+		if err != nil {
+			return nil, nil, err
+		}
+		lg.Info().Str("address", rs.Address().Hex()).Str("signer", cfg.Wallet.Remote.URL).Msg("wallet: using remote signer")
+		return rs, nil, nil
+	case wallet.SourcePKCS11:
+		p := cfg.Wallet.PKCS11
+		pin := p.PIN.Value()
+		if !p.PIN.IsSet() {
+			var err error
+			if pin, err = wallet.ReadPassword(p.PINFile, p.PINEnv); err != nil {
+				return nil, nil, err
+			}
+		}
+		hs, err := wallet.OpenPKCS11(wallet.PKCS11Options{
+			Module:     p.Module,
+			TokenLabel: p.TokenLabel,
+			KeyLabel:   p.KeyLabel,
+			KeyID:      p.KeyID,
+			PIN:        pin,
+		})
+		if err != nil {
+			return nil, nil, err
+		}
+		lg.Info().Str("address", hs.Address().Hex()).Str("token", p.TokenLabel).Msg("wallet: using PKCS#11 signer")
+		return hs, nil, nil
+	}
+
+	w, generated, err := wallet.Open(walletOptions(cfg))
+	if err != nil {
+		return nil, nil, err
+	}
+	lg.Info().
+		Str("address", w.Address().Hex()).
+		Str("source", cfg.Wallet.Source).
+		Bool("generated", generated).
+		Msg("wallet loaded")
+
+	// Rotation is only possible when the key lives in a keystore file.
+	var ksPath, ksPass string
+	if cfg.Wallet.Source != wallet.SourceEnv && cfg.Wallet.KeystorePath != "" {
+		ksPath = cfg.Wallet.KeystorePath
+		if ksPass, err = walletPassword(cfg); err != nil {
+			w.Close()
+			return nil, nil, err
+		}
+	}
+	rot, err := wallet.NewRotator(w, wallet.EpochClock{Length: cfg.Wallet.EpochLength.Duration}, ksPath, ksPass, lg)
+	if err != nil {
+		w.Close()
+		return nil, nil, err
+	}
+	return rot, rot, nil
+}
+
+// startBatching signs on-time segment receipts with an ephemeral session key
+// and seals them into miner-signed batches under receipts.dir, which it returns.
+func startBatching(ctx context.Context, cfg *config.Config, signer wallet.Signer, lg zerolog.Logger) (*receipts.Store, error) {
+	store, err := receipts.OpenStore(cfg.Receipts.Dir)
+	if err != nil {
+		return nil, err
+	}
+	b, err := receipts.NewBatcher(store, signer, receipts.BatcherOptions{
+		ChainID:  cfg.Wallet.ChainID,
+		Interval: cfg.Receipts.BatchInterval.Duration,
+		MaxSize:  cfg.Receipts.MaxBatch,
+	}, lg)
+	if err != nil {
+		return nil, err
+	}
+	ss, err := receipts.NewSessionSigner(cfg.Miner.ID)
+	if err != nil {
+		return nil, err
+	}
+	segs := make(chan service.SegmentReceipt, 1024)
+	signed := make(chan receipts.Receipt, 1024)
+	service.Tap(segs)
+	go func() {
+		if err := receipts.Pump(ctx, ss, segs, signed); err != nil && ctx.Err() == nil {
+			lg.Error().Err(err).Msg("receipts: pump stopped")
+		}
+	}()
+	go b.Run(ctx, signed)
+	lg.Info().Str("dir", store.Dir()).Dur("interval", cfg.Receipts.BatchInterval.Duration).Msg("receipts: batching enabled")
+	return store, nil
+}
+
+// openChain dials the configured RPC endpoint. The node's chain ID must match
+// wallet.chainId when one is set, so signatures and transactions agree on the network.
+func openChain(cfg *config.Config, signer wallet.Signer, lg zerolog.Logger) (*chain.Client, error) {
+	c := cfg.Chain
+	o := chain.Options{
+		Contract:      common.HexToAddress(c.Contract),
+		ABIFile:       c.ABIFile,
+		SubmitMethod:  c.SubmitMethod,
+		ClaimMethod:   c.ClaimMethod,
+		Confirmations: c.Confirmations,
+		GasBufferPct:  c.GasBufferPct,
+		PollInterval:  c.PollInterval.Duration,
+		ResendAfter:   c.ResendAfter.Duration,
+	}
+	if c.MaxTipGwei != 0 {
+		o.MaxTipCap = gwei(c.MaxTipGwei)
+	}
+	if c.MaxFeeGwei != 0 {
+		o.MaxFeeCap = gwei(c.MaxFeeGwei)
+	}
+	cc, err := chain.Dial(context.Background(), c.RPC, o, signer, lg)
+	if err != nil {
+		return nil, err
+	}
+	if id := cfg.Wallet.ChainID; id != 0 && cc.ChainID().Cmp(big.NewInt(id)) != 0 {
+		return nil, fmt.Errorf("chain: rpc reports chain id %s, wallet.chainId is %d", cc.ChainID(), id)
+	}
+	lg.Info().Str("chain_id", cc.ChainID().String()).Str("contract", c.Contract).Msg("chain: connected")
+	return cc, nil
+}
+
+func gwei(n uint64) *big.Int {
+	return new(big.Int).Mul(new(big.Int).SetUint64(n), big.NewInt(1e9))
+}
+
+// walletOptions maps the wallet block of miner.yaml onto wallet.Options.
+func walletOptions(cfg *config.Config) wallet.Options {
+	var chainID *big.Int
+	if cfg.Wallet.ChainID != 0 {
+		chainID = big.NewInt(cfg.Wallet.ChainID)
+	}
+	return wallet.Options{
+		Source:        cfg.Wallet.Source,
+		Env:           cfg.Wallet.Env,
+		KeystorePath:  cfg.Wallet.KeystorePath,
+		PasswordFile:  cfg.Wallet.PasswordFile,
+		PasswordEnv:   cfg.Wallet.PasswordEnv,
+		Password:      cfg.Wallet.Password.Value(),
+		ChainID:       chainID,
+		AllowGenerate: cfg.Wallet.AllowGenerate,
+	}
+}
+
+// admissionOptions maps the admission block of miner.yaml onto admission.Options.
+func admissionOptions(cfg *config.Config) admission.Options {
+	a := cfg.Admission
+	o := admission.Options{
+		Global: admission.Limits{
+			MaxReaders:     a.MaxReaders,
+			MaxEgressMbps:  a.MaxEgressMbps,
+			MaxPerIdentity: a.MaxPerIdentity,
+		},
+		Grace:        a.Grace.Duration,
+		HookToken:    a.HookToken.Value(),
+		Forward:      a.Forward,
+		AllowActions: append([]string(nil), a.AllowActions...),
+	}
+	for _, p := range a.Paths {
+		o.Rules = append(o.Rules, admission.Rule{
+			Name: p.Name,
+			Limits: admission.Limits{
+				MaxReaders:     p.MaxReaders,
+				MaxEgressMbps:  p.MaxEgressMbps,
+				MaxPerIdentity: p.MaxPerIdentity,
+			},
+		})
+	}
+	return o
+}
+
+// policyOptions maps the policy block of miner.yaml onto policy.Options.
+func policyOptions(cfg *config.Config) policy.Options {
+	p := cfg.Policy
+	o := policy.Options{
+		Interval:        p.Interval.Duration,
+		DryRun:          p.DryRun,
+		MaxSessionMbps:  p.MaxSessionMbps,
+		Sustain:         p.Sustain.Duration,
+		MaxDuration:     p.MaxSessionDuration.Duration,
+		MaxIPsPerToken:  p.MaxIPsPerToken,
+		TokenParams:     p.TokenParams,
+		BanFor:          p.BanFor.Duration,
+		ThrottleFor:     p.ThrottleFor.Duration,
+		ThrottleReaders: p.ThrottleReaders,
+	}
+	for _, c := range p.Exempt {
+		if _, n, err := net.ParseCIDR(c); err == nil { // validated at load
+			o.Exempt = append(o.Exempt, n)
+		}
+	}
+	return o
+}
+
+// segmentOptions maps service.segments of miner.yaml onto hls.Options.
+func segmentOptions(cfg *config.Config) hls.Options {
+	sg := cfg.Service.Segments
+	return hls.Options{
+		HLS:      sg.HLS,
+		Dir:      sg.Dir,
+		Interval: sg.Interval.Duration,
+		Paths:    sg.Paths,
+
+		MaxSegment: int64(sg.MaxSegmentMB) << 20,
+		FEC:        fec.Params{Data: sg.FEC.Data, Parity: sg.FEC.Parity},
+		Keep:       sg.FEC.Keep,
+	}
+}
+
+// recordingOptions maps the recordings block of miner.yaml onto recordings.Options.
+func recordingOptions(cfg *config.Config) recordings.Options {
+	r := cfg.Recordings
+	return recordings.Options{
+		Dir:          r.Dir,
+		IndexFile:    r.IndexFile,
+		MaxAge:       r.MaxAge.Duration,
+		MaxBytes:     int64(r.MaxSizeGB * (1 << 30)),
+		ScanInterval: r.ScanInterval.Duration,
+		AttestEvery:  r.AttestEvery.Duration,
+	}
+}
+
+// walletPassword returns the keystore password from whichever source the wallet block sets.
+func walletPassword(cfg *config.Config) (string, error) {
+	if cfg.Wallet.Password.IsSet() {
+		return cfg.Wallet.Password.Value(), nil
+	}
+	return wallet.ReadPassword(cfg.Wallet.PasswordFile, cfg.Wallet.PasswordEnv)
+}
+
+// adminListen returns addr as configured when admin auth is on. With auth off
+// every caller is admin, so the listener is pinned to loopback.
+func adminListen(addr string, cfg *config.Config, lg zerolog.Logger) string {
+	if cfg.Admin.Auth.Enable {
+		return addr
+	}
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil || host == "localhost" {
+		return addr
+	}
+	if ip := net.ParseIP(host); ip != nil && ip.IsLoopback() {
+		return addr
+	}
+	lo := net.JoinHostPort("127.0.0.1", port)
+	lg.Warn().Str("listen", addr).Str("bound", lo).Msg("admin auth disabled: listening on loopback only; enable admin.auth to serve other hosts")
+	return lo
+}
+
+// selfSignedHosts lists names for a dev certificate: the listen host (if any) and the hostname.
+func selfSignedHosts(listen string) []string {
+	var hosts []string
+	if h, _, err := net.SplitHostPort(listen); err == nil && h != "" {
+		hosts = append(hosts, h)
+	}
+	if h, err := os.Hostname(); err == nil {
+		hosts = append(hosts, h)
+	}
+	return hosts
+}
@@ -0,0 +1,178 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"slowdrip-miner/internal/config"
+	"slowdrip-miner/internal/mediamtx"
+
+	"github.com/rs/zerolog"
+)
+
+// mediamtxCommand handles "miner mediamtx <render|apply>".
+func mediamtxCommand(args []string) int {
+	return subcommands("mediamtx", args, map[string]func([]string) error{
+		"render": mediamtxRender,
+		"apply":  mediamtxApply,
+	})
+}
+
+// mediamtxServerConfig maps the mediamtx block of miner.yaml onto what the
+// generator renders and applies.
+func mediamtxServerConfig(cfg *config.Config) mediamtx.ServerConfig {
+	m := cfg.MediaMTX
+	g := mediamtx.GlobalConf{
+		WebRTCAdditionalHosts: append([]string(nil), m.PublicHosts...),
+		AuthMethod:            m.Auth.Method,
+		AuthJWTJWKS:           m.Auth.JWKS,
+		AuthJWTClaimKey:       m.Auth.JWTClaimKey,
+		AuthHTTPAddress:       m.Auth.HTTPAddress,
+		HLSVariant:            m.HLS.Variant,
+		HLSSegmentCount:       m.HLS.SegmentCount,
+		HLSAlwaysRemux:        m.HLS.AlwaysRemux,
+		HLSDirectory:          m.HLS.Directory,
+	}
+	if d := m.HLS.SegmentDuration.Duration; d > 0 {
+		g.HLSSegmentDuration = d.String()
+	}
+	if d := m.HLS.PartDuration.Duration; d > 0 {
+		g.HLSPartDuration = d.String()
+	}
+	for _, s := range m.ICEServers {
+		g.WebRTCICEServers2 = append(g.WebRTCICEServers2, mediamtx.ICEServer{
+			URL:        s.URL,
+			Username:   s.Username,
+			Password:   s.Password.Value(),
+			ClientOnly: s.ClientOnly,
+		})
+	}
+	sc := mediamtx.ServerConfig{Global: g, PrunePaths: m.PrunePaths}
+	if m.Hooks.Enable {
+		sc.HookCommand = m.Hooks.Command
+	}
+	if r := cfg.Recordings; r.Enable {
+		// The miner indexes <dir>/<path>/<start>.<ext> and enforces retention itself.
+		sc.Record = mediamtx.RecordConf{
+			Path:            strings.TrimSuffix(r.MediaMTXDir, "/") + "/%path/%Y-%m-%d_%H-%M-%S-%f",
+			Format:          r.Format,
+			SegmentDuration: r.SegmentDuration.Duration.String(),
+			DeleteAfter:     "0s",
+		}
+	}
+	for _, p := range m.Paths {
+		sc.Paths = append(sc.Paths, mediamtx.PathRule{
+			Name: p.Name,
+			Conf: mediamtx.PathConf{
+				Source:         p.Source,
+				SourceOnDemand: p.SourceOnDemand,
+				MaxReaders:     p.MaxReaders,
+				Record:         p.Record,
+			},
+			Auth: mediamtx.PathAuth{
+				PublishUser: p.Auth.PublishUser,
+				PublishPass: p.Auth.PublishPass.Value(),
+				ReadUser:    p.Auth.ReadUser,
+				ReadPass:    p.Auth.ReadPass.Value(),
+			},
+		})
+	}
+	return sc
+}
+
+// mediamtxRender writes mediamtx.yml generated from miner.yaml. TURN and path
+// passwords are written resolved, so the output is created with mode 0600.
+func mediamtxRender(args []string) error {
+	fs := flag.NewFlagSet("mediamtx render", flag.ContinueOnError)
+	cfgPath := fs.String("config", defaultConfigPath(), "miner config file")
+	out := fs.String("out", "", "write here instead of stdout (e.g. configs/mediamtx.yml)")
+	if err := fs.Parse(args); err != nil {
+		return errUsage
+	}
+	cfg, err := config.Load(*cfgPath)
+	if err != nil {
+		return err
+	}
+	sc := mediamtxServerConfig(cfg)
+	// Don't bake a ${VAR:default} placeholder (the example JWKS URL) into a
+	// file that gets checked in: leave it to MTX_AUTHJWTJWKS.
+	if raw, _, err := config.Merged(*cfgPath); err == nil && cfg.MediaMTX.Auth.JWKS != "" {
+		for _, ref := range config.EnvRefs(raw) {
+			if !ref.Set && ref.Default == cfg.MediaMTX.Auth.JWKS {
+				sc.Unset = map[string]string{"authJWTJWKS": ref.Name}
+				fmt.Fprintf(os.Stderr, "note: $%s is unset, so authJWTJWKS is left out; MediaMTX needs MTX_AUTHJWTJWKS\n", ref.Name)
+				break
+			}
+		}
+	}
+	for _, w := range sc.AuthOverlaps() {
+		fmt.Fprintf(os.Stderr, "warning: %s\n", w)
+	}
+	b, err := mediamtx.Render(sc)
+	if err != nil {
+		return err
+	}
+	if *out == "" {
+		_, err = os.Stdout.Write(b)
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(*out), ".mediamtx-*.yml")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(b); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp.Name(), *out); err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "wrote %s\n", *out)
+	return nil
+}
+
+// mediamtxApply pushes the generated settings to the running MediaMTX at mediamtx.api.
+func mediamtxApply(args []string) error {
+	fs := flag.NewFlagSet("mediamtx apply", flag.ContinueOnError)
+	cfgPath := fs.String("config", defaultConfigPath(), "miner config file")
+	timeout := fs.Duration("timeout", 15*time.Second, "give up after")
+	if err := fs.Parse(args); err != nil {
+		return errUsage
+	}
+	cfg, err := config.Load(*cfgPath)
+	if err != nil {
+		return err
+	}
+	sc := mediamtxServerConfig(cfg)
+	ctx, cancel := context.WithTimeout(context.Background(), *timeout)
+	defer cancel()
+	ch, err := mediamtx.Apply(ctx, mediamtx.NewClient(cfg.MediaMTX.API, zerolog.Nop()), sc)
+	if err != nil {
+		if errors.Is(err, context.DeadlineExceeded) {
+			return fmt.Errorf("%s did not answer within %s", cfg.MediaMTX.API, *timeout)
+		}
+		return err
+	}
+	fmt.Printf("ok: applied global settings to %s; paths: %d added, %d patched, %d deleted, %d unchanged\n",
+		cfg.MediaMTX.API, len(ch.Added), len(ch.Patched), len(ch.Deleted), len(sc.Paths)-len(ch.Added)-len(ch.Patched))
+	for _, n := range ch.Added {
+		fmt.Printf("  + %s\n", n)
+	}
+	for _, n := range ch.Patched {
+		fmt.Printf("  ~ %s\n", n)
+	}
+	for _, n := range ch.Deleted {
+		fmt.Printf("  - %s\n", n)
+	}
+	return nil
+}
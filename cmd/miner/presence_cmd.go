@@ -0,0 +1,53 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"time"
+
+	"slowdrip-miner/internal/config"
+	"slowdrip-miner/internal/presence"
+)
+
+// presenceCommand handles "miner presence <test-challenge>".
+func presenceCommand(args []string) int {
+	return subcommands("presence", args, map[string]func([]string) error{
+		"test-challenge": presenceTestChallenge,
+	})
+}
+
+// presenceTestChallenge issues a challenge, answers it with the configured key and
+// verifies the answer — a quick check that the key (local, HSM or remote) is usable
+// within the deadline.
+func presenceTestChallenge(args []string) error {
+	fs := flag.NewFlagSet("presence test-challenge", flag.ContinueOnError)
+	cfgPath := fs.String("config", defaultConfigPath(), "miner config file")
+	ttl := fs.Duration("ttl", 5*time.Second, "answer deadline")
+	if err := fs.Parse(args); err != nil {
+		return errUsage
+	}
+	cfg, err := config.Load(*cfgPath)
+	if err != nil {
+		return err
+	}
+	s, err := openCLISigner(cfg)
+	if err != nil {
+		return err
+	}
+	defer closeSigner(s)
+
+	c, err := presence.NewChallenge(cfg.Miner.ID, *ttl)
+	if err != nil {
+		return err
+	}
+	r, err := presence.Respond(s, c)
+	if err != nil {
+		return err
+	}
+	received := time.Now()
+	if err := presence.VerifyResponse(c, r, s.Address(), received); err != nil {
+		return err
+	}
+	fmt.Printf("ok: %s answered nonce %s… in %s\n", r.Address, c.Nonce[:16], received.Sub(c.Issued).Round(time.Microsecond))
+	return nil
+}
@@ -0,0 +1,344 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"slowdrip-miner/internal/config"
+	"slowdrip-miner/internal/logger"
+	"slowdrip-miner/internal/receipts"
+)
+
+// receiptsCommand handles "miner receipts <list|verify|anchor>".
+func receiptsCommand(args []string) int {
+	return subcommands("receipts", args, map[string]func([]string) error{
+		"list":   receiptsList,
+		"verify": receiptsVerify,
+		"anchor": receiptsAnchor,
+	})
+}
+
+// batchesCommand handles "miner batches <list|show>".
+func batchesCommand(args []string) int {
+	return subcommands("batches", args, map[string]func([]string) error{
+		"list": batchesList,
+		"show": batchesShow,
+	})
+}
+
+// storeFlags adds -config/-dir and returns a func opening the batch store.
+func storeFlags(fs *flag.FlagSet) func() (*receipts.Store, error) {
+	cfgPath := fs.String("config", defaultConfigPath(), "miner config file")
+	dir := fs.String("dir", "", "batch store (default: receipts.dir from the config)")
+	return func() (*receipts.Store, error) {
+		d := *dir
+		if d == "" {
+			cfg, err := config.Load(*cfgPath)
+			if err != nil {
+				return nil, err
+			}
+			d = cfg.Receipts.Dir
+		}
+		if d == "" {
+			return nil, errors.New("no batch store: set receipts.dir or -dir")
+		}
+		if _, err := os.Stat(d); err != nil {
+			return nil, err
+		}
+		return receipts.OpenStore(d)
+	}
+}
+
+// loadBatches returns one batch (id > 0) or all of them.
+func loadBatches(s *receipts.Store, id uint64) ([]*receipts.Batch, error) {
+	if id > 0 {
+		b, err := s.Load(id)
+		if err != nil {
+			return nil, err
+		}
+		return []*receipts.Batch{b}, nil
+	}
+	ids, err := s.IDs()
+	if err != nil {
+		return nil, err
+	}
+	out := make([]*receipts.Batch, 0, len(ids))
+	for _, id := range ids {
+		b, err := s.Load(id)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, b)
+	}
+	return out, nil
+}
+
+// readReceiptsFile accepts a stored batch file or a JSON array of receipts.
+func readReceiptsFile(path string) (*receipts.Batch, []receipts.Receipt, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	var b receipts.Batch
+	if err := json.Unmarshal(raw, &b); err == nil && b.Header.Version != 0 {
+		return &b, b.Receipts, nil
+	}
+	var rs []receipts.Receipt
+	if err := json.Unmarshal(raw, &rs); err != nil {
+		return nil, nil, fmt.Errorf("%s: neither a batch nor a receipt array: %w", path, err)
+	}
+	return nil, rs, nil
+}
+
+func receiptsList(args []string) error {
+	fs := flag.NewFlagSet("receipts list", flag.ContinueOnError)
+	open := storeFlags(fs)
+	batch := fs.Uint64("batch", 0, "only this batch")
+	path := fs.String("path", "", "only this stream path")
+	if err := fs.Parse(args); err != nil {
+		return errUsage
+	}
+	s, err := open()
+	if err != nil {
+		return err
+	}
+	batches, err := loadBatches(s, *batch)
+	if err != nil {
+		return err
+	}
+	tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "BATCH\tPATH\tSEQ\tSIZE\tDELIVERED\tRECEIVED\tMARGIN")
+	for _, b := range batches {
+		for _, r := range b.Receipts {
+			if *path != "" && r.Path != *path {
+				continue
+			}
+			fmt.Fprintf(tw, "%d\t%s\t%d\t%d\t%.0f%%\t%s\t%s\n", b.Header.ID, r.Path, r.Seq, r.Size, 100*r.Delivered(),
+				time.Unix(0, r.Recv).UTC().Format(time.RFC3339), time.Duration(r.Deadline-r.Recv))
+		}
+	}
+	return tw.Flush()
+}
+
+// receiptsVerify checks receipt signatures in a file, or whole batches in the store.
+func receiptsVerify(args []string) error {
+	fs := flag.NewFlagSet("receipts verify", flag.ContinueOnError)
+	open := storeFlags(fs)
+	batch := fs.Uint64("batch", 0, "only this batch (store mode)")
+	if err := fs.Parse(args); err != nil {
+		return errUsage
+	}
+
+	if fs.NArg() == 1 {
+		b, rs, err := readReceiptsFile(fs.Arg(0))
+		if err != nil {
+			return err
+		}
+		if b != nil {
+			if err := receipts.VerifyBatch(b); err != nil {
+				return err
+			}
+			fmt.Printf("ok: batch %d, %d receipts, signed by %s\n", b.Header.ID, len(rs), b.Header.Miner)
+			return nil
+		}
+		for i, r := range rs {
+			if err := receipts.Verify(r); err != nil {
+				return fmt.Errorf("receipt %d (%s #%d): %w", i, r.Path, r.Seq, err)
+			}
+		}
+		fmt.Printf("ok: %d receipts\n", len(rs))
+		return nil
+	}
+
+	s, err := open()
+	if err != nil {
+		return err
+	}
+	batches, err := loadBatches(s, *batch)
+	if err != nil {
+		return err
+	}
+	n := 0
+	for _, b := range batches {
+		if err := receipts.VerifyBatch(b); err != nil {
+			return err
+		}
+		n += len(b.Receipts)
+	}
+	fmt.Printf("ok: %d batches, %d receipts\n", len(batches), n)
+	return nil
+}
+
+// receiptsAnchor prints the Merkle root over receipts in a file (or a stored batch).
+// With -submit it also submits a stored batch's root to the payout contract,
+// waits for chain.confirmations and records the anchor in the batch file.
+func receiptsAnchor(args []string) error {
+	fs := flag.NewFlagSet("receipts anchor", flag.ContinueOnError)
+	open := storeFlags(fs)
+	batch := fs.Uint64("batch", 0, "recompute the root of this stored batch")
+	submit := fs.Bool("submit", false, "submit the batch root on-chain (needs -batch, chain.* and a wallet)")
+	if err := fs.Parse(args); err != nil {
+		return errUsage
+	}
+	if *submit && (*batch == 0 || fs.NArg() != 0) {
+		return errors.New("-submit needs -batch <id>")
+	}
+	var b *receipts.Batch
+	var rs []receipts.Receipt
+	switch {
+	case fs.NArg() == 1:
+		var err error
+		if b, rs, err = readReceiptsFile(fs.Arg(0)); err != nil {
+			return err
+		}
+	case *batch > 0:
+		s, err := open()
+		if err != nil {
+			return err
+		}
+		if b, err = s.Load(*batch); err != nil {
+			return err
+		}
+		rs = b.Receipts
+	default:
+		return errors.New("usage: miner receipts anchor <file> | -batch <id>")
+	}
+	if len(rs) == 0 {
+		return receipts.ErrEmptyBatch
+	}
+	if b != nil {
+		// Stored batches are already in canonical order.
+		root := receipts.Hash(receipts.MerkleRoot(rs))
+		fmt.Printf("%s (%d receipts)\n", root.Hex(), len(rs))
+		if root != b.Header.Root {
+			return fmt.Errorf("header root is %s", b.Header.Root.Hex())
+		}
+		if *submit {
+			return submitAnchor(fs.Lookup("config").Value.String(), open, b)
+		}
+		return nil
+	}
+	fmt.Printf("0x%s (%d receipts)\n", receipts.AggregateAnchor(rs), len(rs))
+	return nil
+}
+
+// submitAnchor sends b's root with the configured wallet and records it once final.
+func submitAnchor(cfgPath string, open func() (*receipts.Store, error), b *receipts.Batch) error {
+	if b.Anchor != nil {
+		return fmt.Errorf("batch %d is already anchored by %s", b.Header.ID, b.Anchor.Tx)
+	}
+	cfg, err := config.Load(cfgPath)
+	if err != nil {
+		return err
+	}
+	if !cfg.Chain.Enable {
+		return errors.New("chain.enable is off")
+	}
+	s, err := openCLISigner(cfg)
+	if err != nil {
+		return err
+	}
+	defer closeSigner(s)
+	if !strings.EqualFold(s.Address().Hex(), b.Header.Miner) {
+		return fmt.Errorf("batch %d is signed by %s, the wallet is %s", b.Header.ID, b.Header.Miner, s.Address().Hex())
+	}
+	lg := logger.New(cfg.LogLevel)
+	cc, err := openChain(cfg, s, lg)
+	if err != nil {
+		return err
+	}
+	ctx := context.Background()
+	p, err := cc.SubmitRoot(ctx, b.Header.ID, b.Header.Root, b.Header.Count)
+	if err != nil {
+		return err
+	}
+	rcpt, err := cc.WaitConfirmed(ctx, p)
+	if err != nil {
+		return err
+	}
+	store, err := open()
+	if err != nil {
+		return err
+	}
+	if err := store.MarkAnchored(b.Header.ID, rcpt.TxHash.Hex(), rcpt.BlockNumber.Uint64()); err != nil {
+		return err
+	}
+	fmt.Printf("anchored batch %d in %s (block %s)\n", b.Header.ID, rcpt.TxHash.Hex(), rcpt.BlockNumber)
+	return nil
+}
+
+func batchesList(args []string) error {
+	fs := flag.NewFlagSet("batches list", flag.ContinueOnError)
+	open := storeFlags(fs)
+	unclaimed := fs.Bool("unclaimed", false, "only batches not yet claimed")
+	if err := fs.Parse(args); err != nil {
+		return errUsage
+	}
+	s, err := open()
+	if err != nil {
+		return err
+	}
+	batches, err := loadBatches(s, 0)
+	if err != nil {
+		return err
+	}
+	tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "ID\tCOUNT\tROOT\tCLOSED\tANCHOR\tCLAIM")
+	for _, b := range batches {
+		anchor := "-"
+		if b.Anchor != nil {
+			anchor = b.Anchor.Tx
+		}
+		claim := "-"
+		if b.Claim != nil {
+			if *unclaimed {
+				continue
+			}
+			claim = b.Claim.By
+			if b.Claim.Tx != "" {
+				claim += " " + b.Claim.Tx
+			}
+		}
+		fmt.Fprintf(tw, "%d\t%d\t%s\t%s\t%s\t%s\n", b.Header.ID, b.Header.Count, b.Header.Root.Hex(),
+			time.Unix(0, b.Header.Closed).UTC().Format(time.RFC3339), anchor, claim)
+	}
+	return tw.Flush()
+}
+
+func batchesShow(args []string) error {
+	fs := flag.NewFlagSet("batches show", flag.ContinueOnError)
+	open := storeFlags(fs)
+	withReceipts := fs.Bool("receipts", false, "include the receipts")
+	if err := fs.Parse(args); err != nil {
+		return errUsage
+	}
+	if fs.NArg() != 1 {
+		return errors.New("usage: miner batches show [-receipts] <id>")
+	}
+	id, err := strconv.ParseUint(fs.Arg(0), 10, 64)
+	if err != nil {
+		return fmt.Errorf("bad batch id %q", fs.Arg(0))
+	}
+	s, err := open()
+	if err != nil {
+		return err
+	}
+	b, err := s.Load(id)
+	if err != nil {
+		return err
+	}
+	if !*withReceipts {
+		b.Receipts = nil
+	}
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	return enc.Encode(b)
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"slowdrip-miner/internal/fec"
+	"slowdrip-miner/internal/hls"
+)
+
+// shardsCommand handles "miner shards verify".
+func shardsCommand(args []string) int {
+	return subcommands("shards", args, map[string]func([]string) error{
+		"verify": shardsVerify,
+	})
+}
+
+// shardsVerify checks shards saved from /v1/shards?...&index=i against a
+// segment commitment and, given enough of them, rebuilds the segment.
+func shardsVerify(args []string) error {
+	fs := flag.NewFlagSet("shards verify", flag.ContinueOnError)
+	commitHex := fs.String("commit", "", "segment commitment from the signed receipt (default: the one in the shard files)")
+	out := fs.String("out", "", "write the rebuilt segment here (needs data-many valid shards)")
+	if err := fs.Parse(args); err != nil {
+		return errUsage
+	}
+	if fs.NArg() == 0 {
+		return errors.New("usage: miner shards verify [-commit hex] [-out file] <shard.json>...")
+	}
+
+	var (
+		commit [32]byte
+		set    bool
+		params fec.Params
+		size   int64
+		shards [][]byte
+		valid  int
+	)
+	if *commitHex != "" {
+		if err := decodeCommit(*commitHex, &commit); err != nil {
+			return fmt.Errorf("bad -commit: %w", err)
+		}
+		set = true
+	}
+	for _, name := range fs.Args() {
+		b, err := os.ReadFile(name)
+		if err != nil {
+			return err
+		}
+		var sh hls.Shard
+		if err := json.Unmarshal(b, &sh); err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+		if !set {
+			if err := decodeCommit(sh.Commit, &commit); err != nil {
+				return fmt.Errorf("%s: commit: %w", name, err)
+			}
+			set = true
+		}
+		if err := sh.Proof.Verify(commit, sh.Data); err != nil {
+			fmt.Printf("  %s: shard %d: %v\n", name, sh.Proof.Index, err)
+			continue
+		}
+		if shards == nil {
+			params, size = sh.Proof.Params, sh.Proof.Size
+			shards = make([][]byte, params.Total())
+		}
+		if shards[sh.Proof.Index] == nil {
+			shards[sh.Proof.Index] = sh.Data
+			valid++
+		}
+		fmt.Printf("  %s: shard %d ok\n", name, sh.Proof.Index)
+	}
+	if valid == 0 {
+		return errors.New("no valid shards")
+	}
+	fmt.Printf("ok: %d of %d shards valid (%s), %d needed to rebuild %d bytes\n", valid, params.Total(), params, params.Data, size)
+
+	if *out == "" {
+		return nil
+	}
+	seg, err := fec.Reconstruct(params, size, shards)
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(*out, seg, 0o644); err != nil {
+		return err
+	}
+	fmt.Printf("wrote %s\n", *out)
+	return nil
+}
+
+func decodeCommit(s string, out *[32]byte) error {
+	b, err := hex.DecodeString(strings.TrimPrefix(s, "0x"))
+	if err != nil {
+		return err
+	}
+	if len(b) != len(out) {
+		return fmt.Errorf("want 32 bytes, got %d", len(b))
+	}
+	copy(out[:], b)
+	return nil
+}
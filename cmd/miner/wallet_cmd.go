@@ -0,0 +1,387 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"slowdrip-miner/internal/config"
+	"slowdrip-miner/internal/wallet"
+
+	gethks "github.com/ethereum/go-ethereum/accounts/keystore"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/rs/zerolog"
+)
+
+// walletCommand handles "miner wallet <sub> ...".
+func walletCommand(args []string) int {
+	return subcommands("wallet", args, map[string]func([]string) error{
+		"new":            walletNew,
+		"import":         walletImport,
+		"export-address": walletExportAddress,
+		"sign-message":   walletSignMessage,
+		"backup":         walletBackup,
+		"restore":        walletRestore,
+	})
+}
+
+// walletNew generates a key and writes it as a keystore file.
+func walletNew(args []string) error {
+	fs := flag.NewFlagSet("wallet new", flag.ContinueOnError)
+	ks := keystoreFlags(fs)
+	if err := fs.Parse(args); err != nil {
+		return errUsage
+	}
+	w, err := wallet.NewRandom(nil)
+	if err != nil {
+		return err
+	}
+	defer w.Close()
+	return ks.write(w)
+}
+
+// walletImport encrypts an existing hex private key into a keystore file.
+// The key is read from a file, an env var or stdin, never from argv.
+func walletImport(args []string) error {
+	fs := flag.NewFlagSet("wallet import", flag.ContinueOnError)
+	ks := keystoreFlags(fs)
+	keyFile := fs.String("key-file", "", "file holding the hex private key (default: read stdin)")
+	keyEnv := fs.String("key-env", "", "env var holding the hex private key")
+	if err := fs.Parse(args); err != nil {
+		return errUsage
+	}
+	var hexKey string
+	switch {
+	case *keyFile != "" && *keyEnv != "":
+		return errors.New("set only one of -key-file and -key-env")
+	case *keyFile != "":
+		b, err := os.ReadFile(*keyFile)
+		if err != nil {
+			return err
+		}
+		hexKey = string(b)
+	case *keyEnv != "":
+		hexKey = os.Getenv(*keyEnv)
+	default:
+		b, err := io.ReadAll(io.LimitReader(os.Stdin, 1024))
+		if err != nil {
+			return err
+		}
+		hexKey = string(b)
+	}
+	w, err := wallet.FromHex(hexKey, nil)
+	if err != nil {
+		return err
+	}
+	defer w.Close()
+	return ks.write(w)
+}
+
+// walletExportAddress prints the miner address without unlocking anything when
+// it can: keystore files carry their address in clear, remote signers are configured by address.
+func walletExportAddress(args []string) error {
+	fs := flag.NewFlagSet("wallet export-address", flag.ContinueOnError)
+	cfgPath := fs.String("config", defaultConfigPath(), "miner config file")
+	ksPath := fs.String("keystore", "", "read the address from this keystore file instead of the config")
+	if err := fs.Parse(args); err != nil {
+		return errUsage
+	}
+	if *ksPath != "" {
+		addr, err := wallet.KeystoreAddress(*ksPath)
+		if err != nil {
+			return err
+		}
+		fmt.Println(addr.Hex())
+		return nil
+	}
+
+	cfg, err := config.Load(*cfgPath)
+	if err != nil {
+		return err
+	}
+	switch cfg.Wallet.Source {
+	case "":
+		return errors.New("no wallet configured")
+	case wallet.SourceRemote:
+		fmt.Println(common.HexToAddress(cfg.Wallet.Remote.Address).Hex())
+		return nil
+	case wallet.SourceKeystoreFile, wallet.SourceGenerate:
+		if cfg.Wallet.KeystorePath != "" {
+			addr, err := wallet.KeystoreAddress(cfg.Wallet.KeystorePath)
+			if err == nil {
+				fmt.Println(addr.Hex())
+				return nil
+			}
+			if !errors.Is(err, os.ErrNotExist) {
+				return err
+			}
+		}
+		return errors.New("no keystore yet; run the miner once or use \"miner wallet new\"")
+	}
+	s, err := openCLISigner(cfg)
+	if err != nil {
+		return err
+	}
+	defer closeSigner(s)
+	fmt.Println(s.Address().Hex())
+	return nil
+}
+
+// walletSignMessage signs a message (EIP-191 personal_sign) with the configured key
+// and prints it in the common {address, msg, sig} JSON form.
+func walletSignMessage(args []string) error {
+	fs := flag.NewFlagSet("wallet sign-message", flag.ContinueOnError)
+	cfgPath := fs.String("config", defaultConfigPath(), "miner config file")
+	msg := fs.String("message", "", "message to sign (default: read stdin)")
+	if err := fs.Parse(args); err != nil {
+		return errUsage
+	}
+	text := *msg
+	if text == "" {
+		b, err := io.ReadAll(os.Stdin)
+		if err != nil {
+			return err
+		}
+		text = string(b)
+	}
+
+	cfg, err := config.Load(*cfgPath)
+	if err != nil {
+		return err
+	}
+	s, err := openCLISigner(cfg)
+	if err != nil {
+		return err
+	}
+	defer closeSigner(s)
+
+	sig, err := s.SignEIP191([]byte(text))
+	if err != nil {
+		return err
+	}
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	return enc.Encode(map[string]string{
+		"address": s.Address().Hex(),
+		"msg":     text,
+		"sig":     hexutil.Encode(sig),
+		"version": "2",
+	})
+}
+
+// openCLISigner opens the configured key for a one-off command. Unlike the daemon
+// it never generates a key and does not start key rotation.
+func openCLISigner(cfg *config.Config) (wallet.Signer, error) {
+	switch cfg.Wallet.Source {
+	case "":
+		return nil, errors.New("no wallet configured")
+	case wallet.SourceRemote, wallet.SourcePKCS11:
+		s, _, err := openSigner(cfg, zerolog.Nop())
+		return s, err
+	}
+	opts := walletOptions(cfg)
+	opts.AllowGenerate = false
+	w, _, err := wallet.Open(opts)
+	if err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+func closeSigner(s wallet.Signer) {
+	if c, ok := s.(interface{ Close() }); ok {
+		c.Close()
+	}
+}
+
+// ksFlags are the output flags shared by "wallet new" and "wallet import".
+type ksFlags struct {
+	path, dir, passFile, passEnv *string
+}
+
+func keystoreFlags(fs *flag.FlagSet) *ksFlags {
+	return &ksFlags{
+		path:     fs.String("keystore", "", "keystore file to write (must not exist)"),
+		dir:      fs.String("dir", "", "write a geth-style UTC--... file into this directory instead"),
+		passFile: fs.String("password-file", "", "file holding the keystore password"),
+		passEnv:  fs.String("password-env", "", "env var holding the keystore password"),
+	}
+}
+
+func (f *ksFlags) write(w *wallet.Keystore) error {
+	if (*f.path == "") == (*f.dir == "") {
+		return errors.New("set exactly one of -keystore and -dir")
+	}
+	pass, err := wallet.ReadPassword(*f.passFile, *f.passEnv)
+	if err != nil {
+		return err
+	}
+	path := *f.path
+	if path != "" {
+		if _, err := os.Stat(path); err == nil {
+			return fmt.Errorf("%s already exists", path)
+		}
+		err = wallet.WriteKeystoreFile(path, w, pass, gethks.StandardScryptN, gethks.StandardScryptP)
+	} else {
+		path, err = wallet.NewKeyDir(*f.dir).Write(w, pass)
+	}
+	if err != nil {
+		return err
+	}
+	fmt.Printf("%s -> %s\n", w.Address().Hex(), path)
+	return nil
+}
+
+// walletBackup splits the configured local key into M-of-N Shamir shares.
+func walletBackup(args []string) error {
+	fs := flag.NewFlagSet("wallet backup", flag.ContinueOnError)
+	cfgPath := fs.String("config", defaultConfigPath(), "miner config file")
+	n := fs.Int("shares", 5, "number of shares to create (N)")
+	k := fs.Int("threshold", 3, "shares needed to restore (M)")
+	out := fs.String("out", "", "write one file per share into this directory instead of printing")
+	if err := fs.Parse(args); err != nil {
+		return errUsage
+	}
+
+	cfg, err := config.Load(*cfgPath)
+	if err != nil {
+		return err
+	}
+	opts := walletOptions(cfg)
+	opts.AllowGenerate = false // never create a key while backing one up
+	w, _, err := wallet.Open(opts)
+	if err != nil {
+		return err
+	}
+	defer w.Close()
+
+	shares, err := w.Backup(*n, *k)
+	if err != nil {
+		return err
+	}
+	addr := w.Address().Hex()
+
+	if *out == "" {
+		fmt.Printf("# miner %s: %d-of-%d backup. Store each share separately.\n\n", addr, *k, *n)
+		for _, s := range shares {
+			fmt.Printf("share %d/%d:\n%s\n\n", s.Index, *n, s.Words())
+		}
+		return nil
+	}
+
+	if err := os.MkdirAll(*out, 0o700); err != nil {
+		return err
+	}
+	for _, s := range shares {
+		name := filepath.Join(*out, fmt.Sprintf("share-%s-%d-of-%d.txt", strings.ToLower(addr[2:10]), s.Index, *n))
+		body := fmt.Sprintf("# miner %s share %d/%d (threshold %d)\n%s\n", addr, s.Index, *n, *k, s.Words())
+		f, err := os.OpenFile(name, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o600)
+		if err != nil {
+			return err
+		}
+		if _, err := f.WriteString(body); err != nil {
+			f.Close()
+			return err
+		}
+		if err := f.Close(); err != nil {
+			return err
+		}
+		fmt.Println(name)
+	}
+	return nil
+}
+
+// walletRestore rebuilds a key from shares, checks its address and writes a keystore.
+// The keystore password comes from a file or env var, never from argv.
+func walletRestore(args []string) error {
+	fs := flag.NewFlagSet("wallet restore", flag.ContinueOnError)
+	in := fs.String("in", "", "comma-separated share files (default: read shares from stdin, one per line)")
+	expect := fs.String("address", "", "expected miner address (required); restore fails if the rebuilt key differs")
+	ksPath := fs.String("keystore", "", "keystore file to write (must not exist)")
+	passFile := fs.String("password-file", "", "file holding the new keystore password")
+	passEnv := fs.String("password-env", "", "env var holding the new keystore password")
+	if err := fs.Parse(args); err != nil {
+		return errUsage
+	}
+	if *ksPath == "" {
+		return errors.New("-keystore is required")
+	}
+	if _, err := os.Stat(*ksPath); err == nil {
+		return fmt.Errorf("%s already exists", *ksPath)
+	}
+	if *expect == "" {
+		return errors.New("-address is required")
+	}
+	if !common.IsHexAddress(*expect) {
+		return fmt.Errorf("bad -address %q", *expect)
+	}
+	pass, err := wallet.ReadPassword(*passFile, *passEnv)
+	if err != nil {
+		return err
+	}
+
+	var lines []string
+	if *in == "" {
+		lines, err = readShareLines(os.Stdin)
+		if err != nil {
+			return err
+		}
+	} else {
+		for _, p := range strings.Split(*in, ",") {
+			f, err := os.Open(strings.TrimSpace(p))
+			if err != nil {
+				return err
+			}
+			ls, err := readShareLines(f)
+			f.Close()
+			if err != nil {
+				return err
+			}
+			lines = append(lines, ls...)
+		}
+	}
+
+	shares := make([]wallet.Share, 0, len(lines))
+	for i, l := range lines {
+		s, err := wallet.ParseShare(l)
+		if err != nil {
+			return fmt.Errorf("share #%d: %w", i+1, err)
+		}
+		shares = append(shares, s)
+	}
+
+	w, err := wallet.Restore(shares, nil)
+	if err != nil {
+		return err
+	}
+	defer w.Close()
+	if w.Address() != common.HexToAddress(*expect) {
+		return fmt.Errorf("rebuilt address %s does not match %s", w.Address().Hex(), *expect)
+	}
+	if err := wallet.WriteKeystoreFile(*ksPath, w, pass, gethks.StandardScryptN, gethks.StandardScryptP); err != nil {
+		return err
+	}
+	fmt.Printf("restored %s -> %s\n", w.Address().Hex(), *ksPath)
+	return nil
+}
+
+// readShareLines returns non-empty, non-comment lines.
+func readShareLines(r io.Reader) ([]string, error) {
+	var out []string
+	sc := bufio.NewScanner(r)
+	for sc.Scan() {
+		l := strings.TrimSpace(sc.Text())
+		if l == "" || strings.HasPrefix(l, "#") || strings.HasPrefix(l, "share ") {
+			continue
+		}
+		out = append(out, l)
+	}
+	return out, sc.Err()
+}
@@ -0,0 +1,84 @@
+// Command mtx-hook forwards a MediaMTX runOn* hook to the miner. MediaMTX runs it
+// with the MTX_* variables of the event set, e.g. in mediamtx.yml:
+//
+//	runOnRead: mtx-hook read
+//
+// It posts the event to $MINER_HOOK_URL (default
+// http://127.0.0.1:8080/hooks/mediamtx/event) with $MINER_HOOK_TOKEN as a
+// bearer token. Build it static (CGO_ENABLED=0): the MediaMTX image has no libc.
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"time"
+
+	"slowdrip-miner/internal/mediamtx"
+)
+
+const defaultURL = "http://127.0.0.1:8080/hooks/mediamtx/event"
+
+func main() {
+	if len(os.Args) != 2 {
+		fmt.Fprintln(os.Stderr, "usage: mtx-hook ready|notready|read|unread")
+		os.Exit(2)
+	}
+	if err := send(os.Args[1]); err != nil {
+		// MediaMTX logs the exit status; the miner's poll catches up anyway.
+		fmt.Fprintf(os.Stderr, "mtx-hook: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func send(event string) error {
+	ev := mediamtx.HookEvent{
+		Event:  event,
+		Path:   os.Getenv("MTX_PATH"),
+		Source: ref("MTX_SOURCE_TYPE", "MTX_SOURCE_ID"),
+		Reader: ref("MTX_READER_TYPE", "MTX_READER_ID"),
+		Query:  os.Getenv("MTX_QUERY"),
+	}
+	body, err := json.Marshal(ev)
+	if err != nil {
+		return err
+	}
+	url := os.Getenv("MINER_HOOK_URL")
+	if url == "" {
+		url = defaultURL
+	}
+
+	// MediaMTX kills runOnRead/runOnReady commands when the reader or source
+	// goes away, so keep this short.
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if t := os.Getenv("MINER_HOOK_TOKEN"); t != "" {
+		req.Header.Set("Authorization", "Bearer "+t)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("%s: %s", url, resp.Status)
+	}
+	return nil
+}
+
+// ref reads a type/ID pair of MTX_* variables; nil when the ID is unset.
+func ref(typeVar, idVar string) *mediamtx.Ref {
+	id := os.Getenv(idVar)
+	if id == "" {
+		return nil
+	}
+	return &mediamtx.Ref{Type: os.Getenv(typeVar), ID: id}
+}
@@ -0,0 +1,476 @@
+// internal/admission/admission.go
+package admission
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"slowdrip-miner/internal/events"
+	"slowdrip-miner/internal/mediamtx"
+
+	"github.com/rs/zerolog"
+)
+
+// Reasons a reader is turned away. They are stable: they appear in hook
+// responses, logs, events and the decisions metric.
+const (
+	ReasonAdmitted       = "admitted"
+	ReasonPathReaders    = "path_reader_limit"
+	ReasonGlobalReaders  = "global_reader_limit"
+	ReasonPathEgress     = "path_bandwidth_limit"
+	ReasonGlobalEgress   = "global_bandwidth_limit"
+	ReasonIdentityLimit  = "identity_limit"
+	ReasonUpstreamDenied = "upstream_denied"
+	ReasonUpstreamError  = "upstream_error"
+	ReasonBlocked        = "blocked"       // by the Blocklist, e.g. after a policy kick
+	ReasonThrottled      = "throttled"     // over the Throttle's reader cap for the IP
+	ReasonNotRead        = "not_read"      // publish, playback: not subject to limits
+	ReasonActionDenied   = "action_denied" // api, metrics, pprof without forward or AllowActions
+)
+
+// controlActions reach MediaMTX's API, metrics and profiler rather than a
+// stream. They are denied unless the forward endpoint vouched for the request
+// or Options.AllowActions lists them.
+var controlActions = map[string]bool{"api": true, "metrics": true, "pprof": true}
+
+// Limits caps readers; zero fields are unlimited.
+type Limits struct {
+	MaxReaders     int     `json:"max_readers"`
+	MaxEgressMbps  float64 `json:"max_egress_mbps"`
+	MaxPerIdentity int     `json:"max_per_identity"`
+}
+
+// Rule applies Limits to the paths matching Name (exact, or "~regex").
+type Rule struct {
+	Name string
+	Limits
+
+	re *regexp.Regexp
+}
+
+func (r *Rule) match(path string) bool {
+	if r.re != nil {
+		return r.re.MatchString(path)
+	}
+	return r.Name == path
+}
+
+// Options configures a Controller.
+type Options struct {
+	Global    Limits
+	Rules     []Rule        // first match wins
+	Grace     time.Duration // how long an admitted, not yet polled reader still counts
+	HookToken string        // "" = the hook accepts any caller
+	Forward   string        // auth endpoint that must allow a request before limits are checked
+	Blocklist Blocklist     // optional
+	Throttle  Throttle      // optional
+
+	// AllowActions lists control actions (api, metrics, pprof) admitted without
+	// a forward endpoint. MediaMTX's default authHTTPExclude keeps them from
+	// reaching the hook at all; list them only if that was changed.
+	AllowActions []string
+}
+
+// Blocklist reports IPs that may not read for now (see policy.Engine).
+type Blocklist interface {
+	Blocked(ip string, now time.Time) (why string, blocked bool)
+}
+
+// Throttle reports IPs limited to a few concurrent reads for now (see
+// policy.Engine).
+type Throttle interface {
+	Throttled(ip string, now time.Time) (limit int, why string, throttled bool)
+}
+
+// Request is the body MediaMTX posts to authHTTPAddress.
+type Request struct {
+	User     string `json:"user"`
+	Password string `json:"password"`
+	Token    string `json:"token"`
+	IP       string `json:"ip"`
+	Action   string `json:"action"` // publish | read | playback | api | metrics | pprof
+	Path     string `json:"path"`
+	Protocol string `json:"protocol"` // rtsp | rtmp | hls | webrtc | srt
+	ID       string `json:"id"`       // session ID, empty for some protocols (HLS)
+	Query    string `json:"query"`
+}
+
+// sessionKey is what an admitted reader is remembered by: MediaMTX's session
+// ID, or for HLS (which has none) the IP and path. An HLS player asks again for
+// every playlist and segment, so its key is refreshed while it plays and lapses
+// Options.Grace after it stops. The watcher cannot see HLS players one by one
+// (MediaMTX lists a single muxer per path), so these keys are only ever pending:
+// they count towards reader and identity limits for as long as they are fresh.
+// Other requests without an ID are not remembered.
+func (r Request) sessionKey() string {
+	if r.ID != "" {
+		return r.ID
+	}
+	if r.Protocol == "hls" {
+		return "hls:" + r.IP + ":" + r.Path
+	}
+	return ""
+}
+
+// identity is who a concurrency limit counts against: the user, or the IP for
+// anonymous readers.
+func (r Request) identity() string {
+	if r.User != "" {
+		return "user:" + r.User
+	}
+	return "ip:" + r.IP
+}
+
+// Decision is the outcome for one request.
+type Decision struct {
+	Allow   bool   `json:"allow"`
+	Reason  string `json:"reason"`
+	Message string `json:"message,omitempty"`
+	Rule    string `json:"rule,omitempty"` // admission.paths entry that applied
+}
+
+// session is a reader the controller admitted.
+type session struct {
+	path     string
+	ip       string
+	identity string
+	admitted time.Time
+	seen     bool // the watcher has reported it
+}
+
+// pathLoad is the last polled view of one path.
+type pathLoad struct {
+	readers   int
+	bytesSent uint64
+	egress    float64 // bytes/s over the last poll interval
+}
+
+// Controller decides whether MediaMTX may admit a reader, from the watcher's
+// snapshot (readers, bytes sent) plus the readers it admitted since.
+type Controller struct {
+	opts     Options
+	snapshot func() []mediamtx.Path
+	bus      *events.Bus
+	log      zerolog.Logger
+	http     *http.Client
+
+	mu       sync.Mutex
+	paths    map[string]*pathLoad
+	polled   time.Time
+	sessions map[string]*session // by MediaMTX session ID
+	admitted int64
+	rejected map[string]int64 // by reason
+}
+
+// New validates the rules and creates a controller. snapshot is usually
+// mediamtx.Snapshot; bus may be nil to use events.Default.
+func New(o Options, snapshot func() []mediamtx.Path, bus *events.Bus, log zerolog.Logger) (*Controller, error) {
+	for i := range o.Rules {
+		r := &o.Rules[i]
+		if strings.HasPrefix(r.Name, "~") {
+			re, err := regexp.Compile(r.Name[1:])
+			if err != nil {
+				return nil, fmt.Errorf("admission: rule %q: %w", r.Name, err)
+			}
+			r.re = re
+		}
+	}
+	if bus == nil {
+		bus = events.Default
+	}
+	return &Controller{
+		opts:     o,
+		snapshot: snapshot,
+		bus:      bus,
+		log:      log.With().Str("module", "admission").Logger(),
+		http:     &http.Client{Timeout: 5 * time.Second},
+		paths:    map[string]*pathLoad{},
+		sessions: map[string]*session{},
+		rejected: map[string]int64{},
+	}, nil
+}
+
+// Run refreshes the load from the watcher every interval until ctx is done.
+func (c *Controller) Run(ctx context.Context, interval time.Duration) {
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		c.refresh(time.Now())
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+		}
+	}
+}
+
+// refresh takes a new snapshot: egress rates are derived from bytesSent since
+// the previous one, and admitted sessions are matched against polled readers.
+func (c *Controller) refresh(now time.Time) {
+	snap := c.snapshot()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	dt := now.Sub(c.polled).Seconds()
+	live := map[string]bool{}
+	next := make(map[string]*pathLoad, len(snap))
+	for _, p := range snap {
+		l := &pathLoad{readers: len(p.Readers), bytesSent: p.BytesSent}
+		if prev, ok := c.paths[p.Name]; ok && !c.polled.IsZero() && dt > 0 && p.BytesSent >= prev.bytesSent {
+			l.egress = float64(p.BytesSent-prev.bytesSent) / dt
+		}
+		next[p.Name] = l
+		for _, r := range p.Readers {
+			live[r.ID] = true
+		}
+	}
+	c.paths, c.polled = next, now
+
+	for id, s := range c.sessions {
+		switch {
+		case live[id]:
+			s.seen = true
+		case s.seen, now.Sub(s.admitted) > c.opts.Grace:
+			delete(c.sessions, id) // left, or never showed up
+		}
+	}
+	setGauges(c.totalsLocked(now))
+}
+
+// Admit decides on r and remembers admitted readers (see Request.sessionKey)
+// until they leave. Control actions are denied unless listed in
+// Options.AllowActions; other non-read actions are not limited.
+func (c *Controller) Admit(r Request, now time.Time) Decision {
+	if controlActions[r.Action] {
+		for _, a := range c.opts.AllowActions {
+			if a == r.Action {
+				return Decision{Allow: true, Reason: ReasonNotRead}
+			}
+		}
+		return Decision{Reason: ReasonActionDenied, Message: r.Action + " is not allowed through the admission hook"}
+	}
+	if r.Action != "read" {
+		return Decision{Allow: true, Reason: ReasonNotRead}
+	}
+	if c.opts.Blocklist != nil {
+		if why, ok := c.opts.Blocklist.Blocked(r.IP, now); ok {
+			return Decision{Reason: ReasonBlocked, Message: why}
+		}
+	}
+	var throttle int
+	var throttleWhy string
+	if c.opts.Throttle != nil {
+		if limit, why, ok := c.opts.Throttle.Throttled(r.IP, now); ok {
+			throttle, throttleWhy = limit, why
+		}
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	key := r.sessionKey()
+	if s, ok := c.sessions[key]; ok && key != "" {
+		// MediaMTX asks again for the same session (on reconnect, or every
+		// HLS request)
+		if r.ID == "" {
+			s.admitted = now
+		}
+		return Decision{Allow: true, Reason: ReasonAdmitted}
+	}
+	if throttleWhy != "" {
+		if n := c.ipCountLocked(r.IP); n >= throttle {
+			return Decision{Reason: ReasonThrottled, Message: fmt.Sprintf("%s (has %d)", throttleWhy, n)}
+		}
+	}
+	d := c.checkLocked(r, now)
+	if d.Allow && key != "" {
+		c.sessions[key] = &session{path: r.Path, ip: r.IP, identity: r.identity(), admitted: now}
+	}
+	return d
+}
+
+func (c *Controller) checkLocked(r Request, now time.Time) Decision {
+	rule := c.ruleFor(r.Path)
+	var lim Limits
+	if rule != nil {
+		lim = rule.Limits
+	}
+	d := Decision{Rule: ruleName(rule)}
+	reject := func(reason, format string, args ...interface{}) Decision {
+		d.Reason, d.Message = reason, fmt.Sprintf(format, args...)
+		return d
+	}
+
+	pathReaders, pathEgress := c.loadLocked(r.Path, now)
+	tot := c.totalsLocked(now)
+	switch {
+	case lim.MaxReaders > 0 && pathReaders >= lim.MaxReaders:
+		return reject(ReasonPathReaders, "path %s has %d of %d readers", r.Path, pathReaders, lim.MaxReaders)
+	case c.opts.Global.MaxReaders > 0 && tot.Readers >= c.opts.Global.MaxReaders:
+		return reject(ReasonGlobalReaders, "miner has %d of %d readers", tot.Readers, c.opts.Global.MaxReaders)
+	}
+
+	// One more reader is expected to cost what the current readers average.
+	var perReader float64
+	if pathReaders > 0 {
+		perReader = pathEgress / float64(pathReaders)
+	}
+	switch {
+	case lim.MaxEgressMbps > 0 && mbps(pathEgress+perReader) > lim.MaxEgressMbps:
+		return reject(ReasonPathEgress, "path %s sends %.1f Mbps, budget %g Mbps", r.Path, mbps(pathEgress), lim.MaxEgressMbps)
+	case c.opts.Global.MaxEgressMbps > 0 && tot.EgressMbps+mbps(perReader) > c.opts.Global.MaxEgressMbps:
+		return reject(ReasonGlobalEgress, "miner sends %.1f Mbps, budget %g Mbps", tot.EgressMbps, c.opts.Global.MaxEgressMbps)
+	}
+
+	id := r.identity()
+	if n := c.identityCountLocked(id, ""); c.opts.Global.MaxPerIdentity > 0 && n >= c.opts.Global.MaxPerIdentity {
+		return reject(ReasonIdentityLimit, "%s already has %d of %d streams", id, n, c.opts.Global.MaxPerIdentity)
+	}
+	if n := c.identityCountLocked(id, r.Path); lim.MaxPerIdentity > 0 && n >= lim.MaxPerIdentity {
+		return reject(ReasonIdentityLimit, "%s already reads %s %d of %d times", id, r.Path, n, lim.MaxPerIdentity)
+	}
+	d.Allow, d.Reason = true, ReasonAdmitted
+	return d
+}
+
+func (c *Controller) ruleFor(path string) *Rule {
+	for i := range c.opts.Rules {
+		if c.opts.Rules[i].match(path) {
+			return &c.opts.Rules[i]
+		}
+	}
+	return nil
+}
+
+func ruleName(r *Rule) string {
+	if r == nil {
+		return ""
+	}
+	return r.Name
+}
+
+// loadLocked returns the readers of path (polled plus admitted since) and its
+// egress in bytes/s.
+func (c *Controller) loadLocked(path string, now time.Time) (int, float64) {
+	var readers int
+	var egress float64
+	if l, ok := c.paths[path]; ok {
+		readers, egress = l.readers, l.egress
+	}
+	for _, s := range c.sessions {
+		if s.path == path && c.pendingLocked(s, now) {
+			readers++
+		}
+	}
+	return readers, egress
+}
+
+// pendingLocked reports whether s is admitted but not yet in a snapshot.
+func (c *Controller) pendingLocked(s *session, now time.Time) bool {
+	return !s.seen && now.Sub(s.admitted) <= c.opts.Grace
+}
+
+// identityCountLocked counts the live sessions of id, on path or ("") anywhere.
+func (c *Controller) identityCountLocked(id, path string) int {
+	n := 0
+	for _, s := range c.sessions {
+		if s.identity == id && (path == "" || s.path == path) {
+			n++
+		}
+	}
+	return n
+}
+
+// ipCountLocked counts the live sessions read from ip.
+func (c *Controller) ipCountLocked(ip string) int {
+	n := 0
+	for _, s := range c.sessions {
+		if s.ip == ip {
+			n++
+		}
+	}
+	return n
+}
+
+// Totals is the box-wide load.
+type Totals struct {
+	Readers    int     `json:"readers"`
+	Pending    int     `json:"pending"` // admitted, not yet polled (included in readers)
+	Sessions   int     `json:"sessions"`
+	EgressMbps float64 `json:"egress_mbps"`
+}
+
+func (c *Controller) totalsLocked(now time.Time) Totals {
+	var t Totals
+	var egress float64
+	for _, l := range c.paths {
+		t.Readers += l.readers
+		egress += l.egress
+	}
+	for _, s := range c.sessions {
+		if c.pendingLocked(s, now) {
+			t.Pending++
+		}
+	}
+	t.Readers += t.Pending
+	t.Sessions = len(c.sessions)
+	t.EgressMbps = mbps(egress)
+	return t
+}
+
+func mbps(bytesPerSec float64) float64 { return bytesPerSec * 8 / 1e6 }
+
+// PathStatus is one path's load and the limits that apply to it.
+type PathStatus struct {
+	Path       string  `json:"path"`
+	Readers    int     `json:"readers"`
+	EgressMbps float64 `json:"egress_mbps"`
+	Rule       string  `json:"rule,omitempty"`
+	Limits     *Limits `json:"limits,omitempty"`
+}
+
+// Status is the controller's view for /v1/status.
+type Status struct {
+	Totals
+	Limits   Limits           `json:"limits"`
+	Admitted int64            `json:"admitted"`
+	Rejected map[string]int64 `json:"rejected"` // by reason
+	Paths    []PathStatus     `json:"paths"`
+}
+
+// Status reports the current load, limits and decision counts.
+func (c *Controller) Status() Status {
+	now := time.Now()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	st := Status{
+		Totals:   c.totalsLocked(now),
+		Limits:   c.opts.Global,
+		Admitted: c.admitted,
+		Rejected: make(map[string]int64, len(c.rejected)),
+		Paths:    []PathStatus{},
+	}
+	for k, v := range c.rejected {
+		st.Rejected[k] = v
+	}
+	names := make([]string, 0, len(c.paths))
+	for n := range c.paths {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+	for _, n := range names {
+		readers, egress := c.loadLocked(n, now)
+		ps := PathStatus{Path: n, Readers: readers, EgressMbps: mbps(egress)}
+		if r := c.ruleFor(n); r != nil {
+			lim := r.Limits
+			ps.Rule, ps.Limits = r.Name, &lim
+		}
+		st.Paths = append(st.Paths, ps)
+	}
+	return st
+}
@@ -0,0 +1,216 @@
+package admission
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"slowdrip-miner/internal/events"
+	"slowdrip-miner/internal/mediamtx"
+
+	"github.com/rs/zerolog"
+)
+
+// readers returns a path with n polled readers.
+func readers(name string, n int, bytesSent uint64) mediamtx.Path {
+	p := mediamtx.Path{Name: name, Ready: true, BytesSent: bytesSent}
+	for i := 0; i < n; i++ {
+		p.Readers = append(p.Readers, mediamtx.Ref{Type: "rtspSession", ID: fmt.Sprintf("%s-%d", name, i)})
+	}
+	return p
+}
+
+func newController(t *testing.T, o Options, snap *[]mediamtx.Path) *Controller {
+	t.Helper()
+	if o.Grace == 0 {
+		o.Grace = 10 * time.Second
+	}
+	c, err := New(o, func() []mediamtx.Path { return *snap }, events.NewBus(16), zerolog.Nop())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+type blocklist map[string]string
+
+func (b blocklist) Blocked(ip string, _ time.Time) (string, bool) {
+	why, ok := b[ip]
+	return why, ok
+}
+
+func TestAdmit(t *testing.T) {
+	read := func(path, ip, id string) Request {
+		return Request{Action: "read", Path: path, IP: ip, ID: id, Protocol: "rtsp"}
+	}
+	tests := []struct {
+		name string
+		opts Options
+		snap []mediamtx.Path
+		prev []Request // admitted first, in order
+		req  Request
+		want string
+	}{
+		{name: "publish is not limited", opts: Options{Global: Limits{MaxReaders: 1}},
+			snap: []mediamtx.Path{readers("live", 5, 0)},
+			req:  Request{Action: "publish", Path: "live"}, want: ReasonNotRead},
+		{name: "api denied by default", req: Request{Action: "api", IP: "10.0.0.1"}, want: ReasonActionDenied},
+		{name: "metrics denied by default", req: Request{Action: "metrics"}, want: ReasonActionDenied},
+		{name: "pprof allowed when listed", opts: Options{AllowActions: []string{"pprof"}},
+			req: Request{Action: "pprof"}, want: ReasonNotRead},
+		{name: "api still denied when only pprof listed", opts: Options{AllowActions: []string{"pprof"}},
+			req: Request{Action: "api"}, want: ReasonActionDenied},
+		{name: "under every limit", opts: Options{Global: Limits{MaxReaders: 10}},
+			snap: []mediamtx.Path{readers("live", 3, 0)},
+			req:  read("live", "10.0.0.1", "s1"), want: ReasonAdmitted},
+		{name: "path reader limit", opts: Options{Rules: []Rule{{Name: "live", Limits: Limits{MaxReaders: 3}}}},
+			snap: []mediamtx.Path{readers("live", 3, 0)},
+			req:  read("live", "10.0.0.1", "s1"), want: ReasonPathReaders},
+		{name: "regex rule", opts: Options{Rules: []Rule{{Name: "~^cam/", Limits: Limits{MaxReaders: 1}}}},
+			snap: []mediamtx.Path{readers("cam/1", 1, 0)},
+			req:  read("cam/1", "10.0.0.1", "s1"), want: ReasonPathReaders},
+		{name: "first rule wins", opts: Options{Rules: []Rule{{Name: "live"}, {Name: "~.*", Limits: Limits{MaxReaders: 1}}}},
+			snap: []mediamtx.Path{readers("live", 4, 0)},
+			req:  read("live", "10.0.0.1", "s1"), want: ReasonAdmitted},
+		{name: "global reader limit across paths", opts: Options{Global: Limits{MaxReaders: 4}},
+			snap: []mediamtx.Path{readers("a", 2, 0), readers("b", 2, 0)},
+			req:  read("a", "10.0.0.1", "s1"), want: ReasonGlobalReaders},
+		{name: "pending admissions count", opts: Options{Global: Limits{MaxReaders: 2}},
+			prev: []Request{read("live", "10.0.0.1", "p1"), read("live", "10.0.0.2", "p2")},
+			req:  read("live", "10.0.0.3", "s1"), want: ReasonGlobalReaders},
+		{name: "same session asks again", opts: Options{Global: Limits{MaxReaders: 1}},
+			prev: []Request{read("live", "10.0.0.1", "s1")},
+			req:  read("live", "10.0.0.1", "s1"), want: ReasonAdmitted},
+		{name: "identity limit for anonymous IP", opts: Options{Global: Limits{MaxPerIdentity: 1}},
+			prev: []Request{read("a", "10.0.0.1", "p1")},
+			req:  read("b", "10.0.0.1", "s1"), want: ReasonIdentityLimit},
+		{name: "identity limit per user, not IP", opts: Options{Global: Limits{MaxPerIdentity: 1}},
+			prev: []Request{{Action: "read", Path: "a", IP: "10.0.0.1", ID: "p1", User: "ann"}},
+			req:  Request{Action: "read", Path: "b", IP: "10.0.0.2", ID: "s1", User: "ann"}, want: ReasonIdentityLimit},
+		{name: "path identity limit", opts: Options{Rules: []Rule{{Name: "a", Limits: Limits{MaxPerIdentity: 1}}}},
+			prev: []Request{read("a", "10.0.0.1", "p1"), read("b", "10.0.0.1", "p2")},
+			req:  read("a", "10.0.0.1", "s1"), want: ReasonIdentityLimit},
+		{name: "hls reader keyed on IP counts for identity", opts: Options{Global: Limits{MaxPerIdentity: 1}},
+			prev: []Request{{Action: "read", Path: "a", IP: "10.0.0.1", Protocol: "hls"}},
+			req:  read("b", "10.0.0.1", "s1"), want: ReasonIdentityLimit},
+		{name: "hls reader asking again is the same reader", opts: Options{Global: Limits{MaxReaders: 1}},
+			prev: []Request{{Action: "read", Path: "a", IP: "10.0.0.1", Protocol: "hls"}},
+			req:  Request{Action: "read", Path: "a", IP: "10.0.0.1", Protocol: "hls"}, want: ReasonAdmitted},
+		{name: "blocked IP", opts: Options{Blocklist: blocklist{"10.0.0.9": "kicked"}},
+			req: read("live", "10.0.0.9", "s1"), want: ReasonBlocked},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			snap := tt.snap
+			c := newController(t, tt.opts, &snap)
+			now := time.Now()
+			c.refresh(now)
+			for _, r := range tt.prev {
+				if d := c.Admit(r, now); !d.Allow {
+					t.Fatalf("setup: %+v rejected: %s", r, d.Reason)
+				}
+			}
+			d := c.Admit(tt.req, now)
+			if d.Reason != tt.want {
+				t.Fatalf("reason %q (%s), want %q", d.Reason, d.Message, tt.want)
+			}
+			if d.Allow != (tt.want == ReasonAdmitted || tt.want == ReasonNotRead) {
+				t.Fatalf("allow = %v for reason %q", d.Allow, d.Reason)
+			}
+		})
+	}
+}
+
+func TestAdmitEgress(t *testing.T) {
+	snap := []mediamtx.Path{readers("live", 2, 0)}
+	c := newController(t, Options{Rules: []Rule{{Name: "live", Limits: Limits{MaxEgressMbps: 10}}}}, &snap)
+	start := time.Now()
+	c.refresh(start)
+	// 2 readers sending 1 MB/s together (8 Mbps); a third would make 12.
+	snap = []mediamtx.Path{readers("live", 2, 1e6)}
+	c.refresh(start.Add(time.Second))
+	if d := c.Admit(Request{Action: "read", Path: "live", ID: "s1"}, start.Add(time.Second)); d.Reason != ReasonPathEgress {
+		t.Fatalf("reason %q, want %q", d.Reason, ReasonPathEgress)
+	}
+}
+
+func TestSessionsExpire(t *testing.T) {
+	var snap []mediamtx.Path
+	c := newController(t, Options{Grace: 5 * time.Second, Global: Limits{MaxPerIdentity: 1}}, &snap)
+	now := time.Now()
+	c.refresh(now)
+	hls := Request{Action: "read", Path: "a", IP: "10.0.0.1", Protocol: "hls"}
+	c.Admit(hls, now)
+
+	// Still playing: every request refreshes the entry.
+	for i := 1; i <= 3; i++ {
+		now = now.Add(3 * time.Second)
+		c.Admit(hls, now)
+		c.refresh(now)
+	}
+	if d := c.Admit(Request{Action: "read", Path: "b", IP: "10.0.0.1", Protocol: "hls"}, now); d.Reason != ReasonIdentityLimit {
+		t.Fatalf("while playing: reason %q, want %q", d.Reason, ReasonIdentityLimit)
+	}
+	// Stopped: the entry lapses after the grace period.
+	now = now.Add(6 * time.Second)
+	c.refresh(now)
+	if n := c.Status().Sessions; n != 0 {
+		t.Fatalf("%d sessions left after the player stopped", n)
+	}
+}
+
+func TestHookForward(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req Request
+		json.NewDecoder(r.Body).Decode(&req)
+		if req.User != "ops" {
+			w.WriteHeader(http.StatusUnauthorized)
+		}
+	}))
+	defer upstream.Close()
+	var snap []mediamtx.Path
+	c := newController(t, Options{Forward: upstream.URL, HookToken: "tok"}, &snap)
+	srv := httptest.NewServer(c)
+	defer srv.Close()
+
+	tests := []struct {
+		name   string
+		token  string
+		req    Request
+		status int
+		reason string
+	}{
+		{name: "bad hook token", token: "nope", req: Request{Action: "read"}, status: http.StatusUnauthorized},
+		{name: "upstream denies", token: "tok", req: Request{Action: "read", Path: "live"}, status: http.StatusForbidden, reason: ReasonUpstreamDenied},
+		{name: "upstream allows a read", token: "tok", req: Request{Action: "read", Path: "live", User: "ops", ID: "s1"}, status: http.StatusOK, reason: ReasonAdmitted},
+		{name: "upstream vouches for api", token: "tok", req: Request{Action: "api", User: "ops"}, status: http.StatusOK, reason: ReasonNotRead},
+		{name: "upstream denies api", token: "tok", req: Request{Action: "api"}, status: http.StatusForbidden, reason: ReasonUpstreamDenied},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b, _ := json.Marshal(tt.req)
+			resp, err := http.Post(srv.URL+HookPath+"?token="+tt.token, "application/json", strings.NewReader(string(b)))
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer resp.Body.Close()
+			if resp.StatusCode != tt.status {
+				t.Fatalf("status %d, want %d", resp.StatusCode, tt.status)
+			}
+			if tt.reason == "" {
+				return
+			}
+			var d Decision
+			if err := json.NewDecoder(resp.Body).Decode(&d); err != nil {
+				t.Fatal(err)
+			}
+			if d.Reason != tt.reason {
+				t.Fatalf("reason %q, want %q", d.Reason, tt.reason)
+			}
+		})
+	}
+}
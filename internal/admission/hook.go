@@ -0,0 +1,144 @@
+// internal/admission/hook.go
+package admission
+
+import (
+	"bytes"
+	"crypto/subtle"
+	"encoding/json"
+	"io"
+	"net/http"
+	"time"
+
+	"slowdrip-miner/internal/events"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// HookPath is where the controller serves MediaMTX's external auth requests;
+// point mediamtx.auth.httpAddress at it.
+const HookPath = "/hooks/mediamtx/auth"
+
+// maxHookBody caps an auth request; MediaMTX sends a few hundred bytes.
+const maxHookBody = 64 << 10
+
+var (
+	decisions = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "miner_admission_decisions_total",
+		Help: "Reader admission decisions by outcome and reason.",
+	}, []string{"decision", "reason"})
+	readersGauge = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "miner_admission_readers",
+		Help: "Readers on all paths, including admitted ones not yet polled.",
+	})
+	egressGauge = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "miner_admission_egress_mbps",
+		Help: "Egress across all paths over the last poll interval, in Mbps.",
+	})
+)
+
+func setGauges(t Totals) {
+	readersGauge.Set(float64(t.Readers))
+	egressGauge.Set(t.EgressMbps)
+}
+
+// ServeHTTP answers MediaMTX: 200 admits, 403 rejects with the decision as JSON.
+// With Options.Forward, the upstream endpoint must allow a request first.
+func (c *Controller) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if c.opts.HookToken != "" &&
+		subtle.ConstantTimeCompare([]byte(r.URL.Query().Get("token")), []byte(c.opts.HookToken)) != 1 {
+		http.Error(w, "bad hook token", http.StatusUnauthorized)
+		return
+	}
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxHookBody))
+	if err != nil {
+		http.Error(w, "read body", http.StatusBadRequest)
+		return
+	}
+	var req Request
+	if err := json.Unmarshal(body, &req); err != nil {
+		http.Error(w, "bad auth request", http.StatusBadRequest)
+		return
+	}
+
+	// Upstream auth decides who may do what; admission then decides whether
+	// there is room for them.
+	var d Decision
+	if c.opts.Forward != "" {
+		d = c.forward(r, body)
+		if d.Reason == "" && req.Action != "read" {
+			d = Decision{Allow: true, Reason: ReasonNotRead} // upstream vouched for it
+		}
+	}
+	if d.Reason == "" {
+		d = c.Admit(req, time.Now())
+	}
+	c.record(req, d)
+
+	w.Header().Set("Content-Type", "application/json")
+	if d.Allow {
+		w.WriteHeader(http.StatusOK)
+	} else {
+		w.WriteHeader(http.StatusForbidden)
+	}
+	_ = json.NewEncoder(w).Encode(d)
+}
+
+// forward asks the upstream auth endpoint. It returns a rejection, or the zero
+// Decision when upstream allows the request.
+func (c *Controller) forward(r *http.Request, body []byte) Decision {
+	req, err := http.NewRequestWithContext(r.Context(), http.MethodPost, c.opts.Forward, bytes.NewReader(body))
+	if err != nil {
+		return Decision{Reason: ReasonUpstreamError, Message: err.Error()}
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return Decision{Reason: ReasonUpstreamError, Message: err.Error()}
+	}
+	resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return Decision{Reason: ReasonUpstreamDenied, Message: "upstream auth: " + resp.Status}
+	}
+	return Decision{}
+}
+
+// record logs, counts and publishes a decision. Credentials are never logged.
+func (c *Controller) record(req Request, d Decision) {
+	outcome := "allow"
+	if !d.Allow {
+		outcome = "reject"
+	}
+	decisions.WithLabelValues(outcome, d.Reason).Inc()
+	c.mu.Lock()
+	switch {
+	case !d.Allow:
+		c.rejected[d.Reason]++
+	case d.Reason == ReasonAdmitted:
+		c.admitted++
+	}
+	c.mu.Unlock()
+	if d.Allow {
+		c.log.Debug().Str("path", req.Path).Str("action", req.Action).Str("protocol", req.Protocol).Msg("admitted")
+		return
+	}
+	c.log.Info().
+		Str("path", req.Path).
+		Str("action", req.Action).
+		Str("protocol", req.Protocol).
+		Str("ip", req.IP).
+		Str("user", req.User).
+		Str("rule", d.Rule).
+		Str("reason", d.Reason).
+		Msg(d.Message)
+	c.bus.Publish(events.AdmissionRejected, req.Path, map[string]interface{}{
+		"reason":   d.Reason,
+		"message":  d.Message,
+		"protocol": req.Protocol,
+		"id":       req.ID,
+	})
+}
@@ -0,0 +1,356 @@
+package api
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"slowdrip-miner/internal/config"
+	"slowdrip-miner/internal/wallet"
+
+	"github.com/rs/zerolog"
+)
+
+// Role is the privilege level a route requires. Roles are ordered:
+// admin implies operator, operator implies viewer.
+type Role int
+
+const (
+	RolePublic Role = iota // no credentials needed (health probes)
+	RoleViewer
+	RoleOperator
+	RoleAdmin
+)
+
+func (r Role) String() string {
+	switch r {
+	case RolePublic:
+		return "public"
+	case RoleViewer:
+		return "viewer"
+	case RoleOperator:
+		return "operator"
+	case RoleAdmin:
+		return "admin"
+	}
+	return "unknown"
+}
+
+// ParseRole maps a config role name to a Role.
+func ParseRole(s string) (Role, error) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "viewer":
+		return RoleViewer, nil
+	case "operator":
+		return RoleOperator, nil
+	case "admin":
+		return RoleAdmin, nil
+	}
+	return RolePublic, fmt.Errorf("unknown role %q", s)
+}
+
+// Principal is an authenticated caller.
+type Principal struct {
+	Name   string `json:"name"`
+	Role   Role   `json:"-"`
+	Method string `json:"method"` // token | eip191 | mtls | none
+}
+
+// Authenticator inspects a request for one kind of credential.
+// ok=false means the request carries no credential of this kind;
+// a non-nil error means it carried one and it was invalid.
+type Authenticator interface {
+	Authenticate(r *http.Request) (p Principal, ok bool, err error)
+}
+
+// ErrNoCredentials is returned when no authenticator recognised the request.
+var ErrNoCredentials = errors.New("no credentials")
+
+// ------------------------
+// Static bearer tokens
+// ------------------------
+
+type tokenEntry struct {
+	name  string
+	token []byte
+	role  Role
+}
+
+type tokenAuth struct{ tokens []tokenEntry }
+
+func (a *tokenAuth) Authenticate(r *http.Request) (Principal, bool, error) {
+	h := r.Header.Get("Authorization")
+	if !strings.HasPrefix(h, "Bearer ") {
+		return Principal{}, false, nil
+	}
+	got := []byte(strings.TrimSpace(strings.TrimPrefix(h, "Bearer ")))
+	for _, t := range a.tokens {
+		if subtle.ConstantTimeCompare(got, t.token) == 1 {
+			return Principal{Name: t.name, Role: t.role, Method: "token"}, true, nil
+		}
+	}
+	return Principal{}, true, errors.New("unknown bearer token")
+}
+
+// ------------------------
+// EIP-191 signed requests
+// ------------------------
+
+// Headers carried by signed requests. The signature is an EIP-191 personal_sign over
+// SignedRequestMessage(method, requestURI, timestamp, nonce, body).
+const (
+	HeaderAddress   = "X-Slowdrip-Address"
+	HeaderTimestamp = "X-Slowdrip-Timestamp" // unix seconds
+	HeaderNonce     = "X-Slowdrip-Nonce"     // random, single use per signer
+	HeaderSignature = "X-Slowdrip-Signature" // 0x-hex, 65 bytes
+)
+
+// maxSignedBody caps how much of the body we buffer to hash for signed requests.
+const maxSignedBody = 1 << 20
+
+// maxNonceLen bounds the nonce header; 16 random bytes hex-encoded is plenty.
+const maxNonceLen = 64
+
+// SignedRequestMessage builds the message a client signs for EIP-191 auth:
+//
+//	"slowdrip-admin\n" + METHOD + "\n" + requestURI + "\n" + ts + "\n" + nonce + "\n" + hex(sha256(body))
+func SignedRequestMessage(method, requestURI string, ts int64, nonce string, body []byte) []byte {
+	sum := sha256.Sum256(body)
+	return []byte(fmt.Sprintf("slowdrip-admin\n%s\n%s\n%d\n%s\n%s",
+		strings.ToUpper(method), requestURI, ts, nonce, hex.EncodeToString(sum[:])))
+}
+
+type eip191Auth struct {
+	roles   map[string]Role // lower-cased 0x address -> role
+	maxSkew time.Duration
+	now     func() time.Time
+	seen    nonceCache
+}
+
+// nonceCache remembers (signer, nonce) pairs until their timestamp falls out of
+// the skew window, after which the timestamp check rejects a replay anyway.
+type nonceCache struct {
+	mu     sync.Mutex
+	seen   map[string]time.Time // signer + "/" + nonce -> expiry
+	sweepN int
+}
+
+// use records key and reports false if it was already used.
+func (c *nonceCache) use(key string, expires, now time.Time) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.seen == nil {
+		c.seen = make(map[string]time.Time)
+	}
+	if c.sweepN++; c.sweepN >= 256 {
+		c.sweepN = 0
+		for k, exp := range c.seen {
+			if now.After(exp) {
+				delete(c.seen, k)
+			}
+		}
+	}
+	if exp, ok := c.seen[key]; ok && !now.After(exp) {
+		return false
+	}
+	c.seen[key] = expires
+	return true
+}
+
+func (a *eip191Auth) Authenticate(r *http.Request) (Principal, bool, error) {
+	addrHdr := r.Header.Get(HeaderAddress)
+	sigHdr := r.Header.Get(HeaderSignature)
+	if addrHdr == "" && sigHdr == "" {
+		return Principal{}, false, nil
+	}
+	addr := strings.ToLower(strings.TrimSpace(addrHdr))
+	role, known := a.roles[addr]
+	if !known {
+		return Principal{}, true, fmt.Errorf("unknown signer %s", addr)
+	}
+
+	ts, err := strconv.ParseInt(r.Header.Get(HeaderTimestamp), 10, 64)
+	if err != nil {
+		return Principal{}, true, errors.New("bad or missing timestamp")
+	}
+	skew := a.now().Sub(time.Unix(ts, 0))
+	if skew < -a.maxSkew || skew > a.maxSkew {
+		return Principal{}, true, fmt.Errorf("timestamp outside allowed skew (%s)", skew.Round(time.Second))
+	}
+
+	nonce := r.Header.Get(HeaderNonce)
+	if nonce == "" || len(nonce) > maxNonceLen {
+		return Principal{}, true, errors.New("bad or missing nonce")
+	}
+
+	sig, err := hex.DecodeString(strings.TrimPrefix(strings.TrimSpace(sigHdr), "0x"))
+	if err != nil {
+		return Principal{}, true, errors.New("signature is not hex")
+	}
+
+	// Hash the body, then put it back for the handler.
+	var body []byte
+	if r.Body != nil {
+		body, err = io.ReadAll(io.LimitReader(r.Body, maxSignedBody+1))
+		r.Body.Close()
+		if err != nil {
+			return Principal{}, true, fmt.Errorf("read body: %w", err)
+		}
+		if len(body) > maxSignedBody {
+			return Principal{}, true, errors.New("signed body too large")
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+	}
+
+	rec, err := wallet.RecoverEIP191(SignedRequestMessage(r.Method, r.URL.RequestURI(), ts, nonce, body), sig)
+	if err != nil {
+		return Principal{}, true, err
+	}
+	if strings.ToLower(rec.Hex()) != addr {
+		return Principal{}, true, errors.New("signature does not match address")
+	}
+	// Only a valid signature may burn a nonce, so forgeries can't block real requests.
+	if !a.seen.use(addr+"/"+nonce, time.Unix(ts, 0).Add(a.maxSkew), a.now()) {
+		return Principal{}, true, errors.New("nonce already used")
+	}
+	return Principal{Name: addr, Role: role, Method: "eip191"}, true, nil
+}
+
+// ------------------------
+// mTLS client certificates
+// ------------------------
+
+type mtlsAuth struct{ roles map[string]Role } // CN -> role
+
+func (a *mtlsAuth) Authenticate(r *http.Request) (Principal, bool, error) {
+	// Only chains verified against the configured client CA count.
+	if r.TLS == nil || len(r.TLS.VerifiedChains) == 0 || len(r.TLS.VerifiedChains[0]) == 0 {
+		return Principal{}, false, nil
+	}
+	cn := r.TLS.VerifiedChains[0][0].Subject.CommonName
+	role, ok := a.roles[cn]
+	if !ok {
+		return Principal{}, true, fmt.Errorf("no role for client certificate %q", cn)
+	}
+	return Principal{Name: cn, Role: role, Method: "mtls"}, true, nil
+}
+
+// ------------------------
+// Guard (per-route RBAC)
+// ------------------------
+
+// guard wraps handlers with authentication and role checks.
+type guard struct {
+	enabled bool
+	auths   []Authenticator
+	log     zerolog.Logger
+}
+
+// newGuard builds the authenticator chain from config.
+func newGuard(cfg *config.Config, log zerolog.Logger) (*guard, error) {
+	ac := cfg.Admin.Auth
+	g := &guard{enabled: ac.Enable, log: log.With().Str("module", "api").Logger()}
+
+	if len(ac.Certs) > 0 {
+		m := &mtlsAuth{roles: make(map[string]Role, len(ac.Certs))}
+		for _, c := range ac.Certs {
+			role, err := ParseRole(c.Role)
+			if err != nil {
+				return nil, fmt.Errorf("admin.auth.certs %s: %w", c.CommonName, err)
+			}
+			m.roles[c.CommonName] = role
+		}
+		g.auths = append(g.auths, m)
+	}
+	if len(ac.Signers) > 0 {
+		e := &eip191Auth{roles: make(map[string]Role, len(ac.Signers)), maxSkew: ac.MaxSkew.Duration, now: time.Now}
+		for _, s := range ac.Signers {
+			role, err := ParseRole(s.Role)
+			if err != nil {
+				return nil, fmt.Errorf("admin.auth.signers %s: %w", s.Address, err)
+			}
+			e.roles[strings.ToLower(s.Address)] = role
+		}
+		g.auths = append(g.auths, e)
+	}
+	if len(ac.Tokens) > 0 {
+		t := &tokenAuth{}
+		for i, tk := range ac.Tokens {
+			role, err := ParseRole(tk.Role)
+			if err != nil {
+				return nil, fmt.Errorf("admin.auth.tokens[%d]: %w", i, err)
+			}
+			name := tk.Name
+			if name == "" {
+				name = fmt.Sprintf("token-%d", i)
+			}
+			t.tokens = append(t.tokens, tokenEntry{name: name, token: []byte(tk.Token.Value()), role: role})
+		}
+		g.auths = append(g.auths, t)
+	}
+
+	if !g.enabled {
+		g.log.Warn().Msg("admin auth disabled: every request is treated as admin")
+	}
+	return g, nil
+}
+
+// authenticate runs the chain; the first authenticator that recognises a credential decides.
+func (g *guard) authenticate(r *http.Request) (Principal, error) {
+	if !g.enabled {
+		return Principal{Name: "anonymous", Role: RoleAdmin, Method: "none"}, nil
+	}
+	for _, a := range g.auths {
+		p, ok, err := a.Authenticate(r)
+		if !ok {
+			continue
+		}
+		return p, err
+	}
+	return Principal{}, ErrNoCredentials
+}
+
+// require wraps h so only principals with at least role may call it.
+func (g *guard) require(role Role, h http.Handler) http.Handler {
+	if role == RolePublic {
+		return h
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		p, err := g.authenticate(r)
+		if err != nil {
+			g.audit(r, role, p, err.Error())
+			w.Header().Set("WWW-Authenticate", `Bearer realm="slowdrip-miner"`)
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		if p.Role < role {
+			g.audit(r, role, p, "insufficient role")
+			http.Error(w, "forbidden", http.StatusForbidden)
+			return
+		}
+		h.ServeHTTP(w, r.WithContext(withPrincipal(r.Context(), p)))
+	})
+}
+
+// audit records a rejected request. Credentials themselves are never logged.
+func (g *guard) audit(r *http.Request, need Role, p Principal, reason string) {
+	g.log.Warn().
+		Str("audit", "auth_reject").
+		Str("remote", r.RemoteAddr).
+		Str("method", r.Method).
+		Str("route", r.URL.Path).
+		Str("required_role", need.String()).
+		Str("principal", p.Name).
+		Str("auth_method", p.Method).
+		Str("reason", reason).
+		Msg("api: request rejected")
+}
@@ -0,0 +1,287 @@
+package api
+
+import (
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"slowdrip-miner/internal/config"
+	"slowdrip-miner/internal/wallet"
+
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/rs/zerolog"
+)
+
+func newKey(t *testing.T) *wallet.Keystore {
+	t.Helper()
+	k, err := wallet.NewRandom(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(k.Close)
+	return k
+}
+
+// testGuard builds a guard with auth on: tokens "v", "o" and "a" for the three
+// roles, the given signers, and the "ops" client certificate CN as operator.
+// Signed-request time is frozen at now.
+func testGuard(t *testing.T, now time.Time, signers ...config.AdminSigner) *guard {
+	t.Helper()
+	var cfg config.Config
+	cfg.Admin.Auth.Enable = true
+	cfg.Admin.Auth.MaxSkew = config.Duration{Duration: 30 * time.Second}
+	cfg.Admin.Auth.Signers = signers
+	cfg.Admin.Auth.Certs = []config.AdminCert{{CommonName: "ops", Role: "operator"}}
+	for _, role := range []string{"viewer", "operator", "admin"} {
+		cfg.Admin.Auth.Tokens = append(cfg.Admin.Auth.Tokens, config.AdminToken{Name: role, Token: config.NewSecret(role[:1]), Role: role})
+	}
+	g, err := newGuard(&cfg, zerolog.Nop())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, a := range g.auths {
+		if e, ok := a.(*eip191Auth); ok {
+			e.now = func() time.Time { return now }
+		}
+	}
+	return g
+}
+
+// signed builds a request signed by k (as addr, which may differ to forge one).
+func signed(t *testing.T, k *wallet.Keystore, addr, method, uri, body string, ts time.Time, nonce string) *http.Request {
+	t.Helper()
+	r := httptest.NewRequest(method, uri, strings.NewReader(body))
+	sig, err := k.SignEIP191(SignedRequestMessage(method, r.URL.RequestURI(), ts.Unix(), nonce, []byte(body)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.Header.Set(HeaderAddress, addr)
+	r.Header.Set(HeaderTimestamp, strconv.FormatInt(ts.Unix(), 10))
+	r.Header.Set(HeaderNonce, nonce)
+	r.Header.Set(HeaderSignature, hexutil.Encode(sig))
+	return r
+}
+
+// serve runs r through g.require(role) and returns the status and, when
+// admitted, the principal and body the handler saw.
+func serve(g *guard, role Role, r *http.Request) (int, Principal, string) {
+	var (
+		p    Principal
+		body string
+	)
+	h := g.require(role, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		p, _ = PrincipalFrom(r.Context())
+		b, _ := io.ReadAll(r.Body)
+		body = string(b)
+	}))
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, r)
+	return rec.Code, p, body
+}
+
+func TestSignedRequests(t *testing.T) {
+	now := time.Unix(1_700_000_000, 0)
+	op, stranger := newKey(t), newKey(t)
+	addr := op.Address().Hex()
+	tests := []struct {
+		name   string
+		req    func() *http.Request
+		status int
+	}{
+		{name: "valid", status: http.StatusOK,
+			req: func() *http.Request { return signed(t, op, addr, "POST", "/v1/x?a=1", `{"k":1}`, now, "n1") }},
+		{name: "address in another case", status: http.StatusOK,
+			req: func() *http.Request { return signed(t, op, strings.ToLower(addr), "GET", "/v1/x", "", now, "n1") }},
+		{name: "inside the skew window", status: http.StatusOK,
+			req: func() *http.Request { return signed(t, op, addr, "GET", "/v1/x", "", now.Add(-30*time.Second), "n1") }},
+		{name: "too old", status: http.StatusUnauthorized,
+			req: func() *http.Request { return signed(t, op, addr, "GET", "/v1/x", "", now.Add(-31*time.Second), "n1") }},
+		{name: "from the future", status: http.StatusUnauthorized,
+			req: func() *http.Request { return signed(t, op, addr, "GET", "/v1/x", "", now.Add(31*time.Second), "n1") }},
+		{name: "unknown signer", status: http.StatusUnauthorized,
+			req: func() *http.Request {
+				return signed(t, stranger, stranger.Address().Hex(), "GET", "/v1/x", "", now, "n1")
+			}},
+		{name: "signed by another key", status: http.StatusUnauthorized,
+			req: func() *http.Request { return signed(t, stranger, addr, "GET", "/v1/x", "", now, "n1") }},
+		{name: "body swapped", status: http.StatusUnauthorized,
+			req: func() *http.Request {
+				r := signed(t, op, addr, "POST", "/v1/x", `{"k":1}`, now, "n1")
+				r.Body = io.NopCloser(strings.NewReader(`{"k":2}`))
+				return r
+			}},
+		{name: "query swapped", status: http.StatusUnauthorized,
+			req: func() *http.Request {
+				r := signed(t, op, addr, "GET", "/v1/x?a=1", "", now, "n1")
+				r.URL.RawQuery = "a=2"
+				return r
+			}},
+		{name: "missing nonce", status: http.StatusUnauthorized,
+			req: func() *http.Request { return signed(t, op, addr, "GET", "/v1/x", "", now, "") }},
+		{name: "oversized nonce", status: http.StatusUnauthorized,
+			req: func() *http.Request { return signed(t, op, addr, "GET", "/v1/x", "", now, strings.Repeat("n", 65)) }},
+		{name: "signature not hex", status: http.StatusUnauthorized,
+			req: func() *http.Request {
+				r := signed(t, op, addr, "GET", "/v1/x", "", now, "n1")
+				r.Header.Set(HeaderSignature, "0xzz")
+				return r
+			}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := testGuard(t, now, config.AdminSigner{Address: addr, Role: "operator"})
+			status, p, _ := serve(g, RoleOperator, tt.req())
+			if status != tt.status {
+				t.Fatalf("status %d, want %d", status, tt.status)
+			}
+			if status == http.StatusOK && (p.Method != "eip191" || p.Name != strings.ToLower(addr)) {
+				t.Fatalf("principal %+v", p)
+			}
+		})
+	}
+}
+
+func TestSignedRequestNonces(t *testing.T) {
+	now := time.Unix(1_700_000_000, 0)
+	op, stranger := newKey(t), newKey(t)
+	addr := op.Address().Hex()
+	g := testGuard(t, now, config.AdminSigner{Address: addr, Role: "admin"})
+
+	// A forgery carrying the nonce must not burn it.
+	if status, _, _ := serve(g, RoleViewer, signed(t, stranger, addr, "GET", "/v1/x", "", now, "n1")); status != http.StatusUnauthorized {
+		t.Fatalf("forgery: status %d", status)
+	}
+	if status, _, _ := serve(g, RoleViewer, signed(t, op, addr, "GET", "/v1/x", "", now, "n1")); status != http.StatusOK {
+		t.Fatalf("first use after a forgery: status %d", status)
+	}
+	// The same request again is a replay.
+	if status, _, _ := serve(g, RoleViewer, signed(t, op, addr, "GET", "/v1/x", "", now, "n1")); status != http.StatusUnauthorized {
+		t.Fatalf("replay: status %d", status)
+	}
+	if status, _, _ := serve(g, RoleViewer, signed(t, op, addr, "GET", "/v1/x", "", now, "n2")); status != http.StatusOK {
+		t.Fatalf("fresh nonce: status %d", status)
+	}
+}
+
+func TestNonceCacheExpiry(t *testing.T) {
+	var c nonceCache
+	now := time.Unix(1_700_000_000, 0)
+	if !c.use("a/n", now.Add(time.Minute), now) {
+		t.Fatal("first use refused")
+	}
+	if c.use("a/n", now.Add(time.Minute), now.Add(time.Minute)) {
+		t.Fatal("reused at the expiry")
+	}
+	if !c.use("a/n", now.Add(3*time.Minute), now.Add(2*time.Minute)) {
+		t.Fatal("refused after the expiry")
+	}
+	for i := 0; i < 300; i++ { // crosses a sweep
+		c.use(fmt.Sprintf("a/x%d", i), now, now.Add(time.Hour))
+	}
+	if len(c.seen) >= 256 { // expired entries were swept
+		t.Fatalf("%d entries after a sweep", len(c.seen))
+	}
+}
+
+func TestSignedBodyReachesHandler(t *testing.T) {
+	now := time.Unix(1_700_000_000, 0)
+	op := newKey(t)
+	g := testGuard(t, now, config.AdminSigner{Address: op.Address().Hex(), Role: "admin"})
+	body := `{"in_epochs":1,"reason":"test"}`
+	status, _, got := serve(g, RoleAdmin, signed(t, op, op.Address().Hex(), "POST", "/v1/wallet/rotate", body, now, "n1"))
+	if status != http.StatusOK || got != body {
+		t.Fatalf("status %d, handler read %q", status, got)
+	}
+}
+
+func TestGuardRoles(t *testing.T) {
+	bearer := func(tok string) *http.Request {
+		r := httptest.NewRequest("GET", "/v1/x", nil)
+		r.Header.Set("Authorization", "Bearer "+tok)
+		return r
+	}
+	tests := []struct {
+		name   string
+		need   Role
+		req    *http.Request
+		status int
+	}{
+		{name: "public needs nothing", need: RolePublic, req: httptest.NewRequest("GET", "/healthz", nil), status: http.StatusOK},
+		{name: "no credentials", need: RoleViewer, req: httptest.NewRequest("GET", "/v1/x", nil), status: http.StatusUnauthorized},
+		{name: "unknown token", need: RoleViewer, req: bearer("nope"), status: http.StatusUnauthorized},
+		{name: "viewer reads", need: RoleViewer, req: bearer("v"), status: http.StatusOK},
+		{name: "viewer can't operate", need: RoleOperator, req: bearer("v"), status: http.StatusForbidden},
+		{name: "operator operates", need: RoleOperator, req: bearer("o"), status: http.StatusOK},
+		{name: "operator can't administer", need: RoleAdmin, req: bearer("o"), status: http.StatusForbidden},
+		{name: "admin implies operator", need: RoleOperator, req: bearer("a"), status: http.StatusOK},
+		{name: "admin implies viewer", need: RoleViewer, req: bearer("a"), status: http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := testGuard(t, time.Now())
+			if status, _, _ := serve(g, tt.need, tt.req); status != tt.status {
+				t.Fatalf("status %d, want %d", status, tt.status)
+			}
+		})
+	}
+}
+
+func TestGuardFirstCredentialDecides(t *testing.T) {
+	now := time.Unix(1_700_000_000, 0)
+	op := newKey(t)
+	g := testGuard(t, now, config.AdminSigner{Address: op.Address().Hex(), Role: "admin"})
+	// A broken signature is not rescued by a valid admin token.
+	r := signed(t, op, op.Address().Hex(), "GET", "/v1/x", "", now.Add(time.Hour), "n1")
+	r.Header.Set("Authorization", "Bearer a")
+	if status, _, _ := serve(g, RoleViewer, r); status != http.StatusUnauthorized {
+		t.Fatalf("status %d, want 401", status)
+	}
+}
+
+func TestGuardDisabled(t *testing.T) {
+	g, err := newGuard(&config.Config{}, zerolog.Nop())
+	if err != nil {
+		t.Fatal(err)
+	}
+	status, p, _ := serve(g, RoleAdmin, httptest.NewRequest("GET", "/v1/config", nil))
+	if status != http.StatusOK || p.Role != RoleAdmin || p.Method != "none" {
+		t.Fatalf("status %d, principal %+v", status, p)
+	}
+}
+
+func TestMTLS(t *testing.T) {
+	cert := func(cn string) *x509.Certificate { return &x509.Certificate{Subject: pkix.Name{CommonName: cn}} }
+	tests := []struct {
+		name   string
+		state  *tls.ConnectionState
+		status int
+	}{
+		{name: "verified, known CN", state: &tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{cert("ops")}}}, status: http.StatusOK},
+		{name: "verified, unknown CN", state: &tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{cert("eve")}}}, status: http.StatusUnauthorized},
+		{name: "presented but not verified", state: &tls.ConnectionState{PeerCertificates: []*x509.Certificate{cert("ops")}}, status: http.StatusUnauthorized},
+		{name: "plain HTTP", status: http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := testGuard(t, time.Now())
+			r := httptest.NewRequest("GET", "/v1/x", bytes.NewReader(nil))
+			r.TLS = tt.state
+			status, p, _ := serve(g, RoleOperator, r)
+			if status != tt.status {
+				t.Fatalf("status %d, want %d", status, tt.status)
+			}
+			if status == http.StatusOK && (p.Method != "mtls" || p.Name != "ops") {
+				t.Fatalf("principal %+v", p)
+			}
+		})
+	}
+}
@@ -0,0 +1,24 @@
+package api
+
+import (
+	"embed"
+	"io/fs"
+	"net/http"
+)
+
+// dashboardFiles is the local operator dashboard: plain HTML/JS/CSS with no external
+// assets, so it works on air-gapped boxes. It reads everything from /v1/*.
+//
+//go:embed dashboard
+var dashboardFiles embed.FS
+
+// dashboardHandler serves the embedded UI under /ui/.
+// The static files carry no miner data, so they are public; the API calls they make
+// carry the operator's credentials and go through the normal role checks.
+func dashboardHandler() http.Handler {
+	sub, err := fs.Sub(dashboardFiles, "dashboard")
+	if err != nil {
+		panic(err) // embedded at build time; cannot fail
+	}
+	return http.StripPrefix("/ui/", http.FileServer(http.FS(sub)))
+}
@@ -0,0 +1,272 @@
+// SlowDrip miner dashboard. No dependencies: everything comes from /v1/*.
+// The event stream is read with fetch() rather than EventSource so the bearer
+// token can travel in a header instead of the URL.
+"use strict";
+
+const state = {
+  token: sessionStorage.getItem("miner-token") || "",
+  lastId: 0,
+  paths: [],
+  qos: {},            // path -> latest service.flush data
+  lateHistory: {},    // path -> [late ratio per flush]
+  receiptTimes: [],   // ms timestamps of receipt.signed events (last hour)
+  batches: [],
+};
+
+const $ = (id) => document.getElementById(id);
+
+function headers(extra) {
+  const h = Object.assign({}, extra || {});
+  if (state.token) h["Authorization"] = "Bearer " + state.token;
+  return h;
+}
+
+async function api(path) {
+  const r = await fetch(path, { headers: headers() });
+  if (!r.ok) throw new Error(path + ": " + r.status);
+  return r.json();
+}
+
+// ---------- formatting ----------
+
+function fmtBytes(n) {
+  const u = ["B", "KB", "MB", "GB", "TB"];
+  let i = 0;
+  while (n >= 1024 && i < u.length - 1) { n /= 1024; i++; }
+  return n.toFixed(i ? 1 : 0) + " " + u[i];
+}
+
+function fmtDur(s) {
+  const d = Math.floor(s / 86400), h = Math.floor(s % 86400 / 3600), m = Math.floor(s % 3600 / 60);
+  return (d ? d + "d " : "") + h + "h " + m + "m";
+}
+
+function ratio(late, accepted) {
+  const t = late + accepted;
+  return t ? late / t : 0;
+}
+
+function pct(r) { return (r * 100).toFixed(1) + "%"; }
+
+function cls(r) { return r < 0.02 ? "ok" : r < 0.1 ? "warn" : "bad"; }
+
+function esc(s) {
+  return String(s).replace(/[&<>"']/g, (c) => ({ "&": "&amp;", "<": "&lt;", ">": "&gt;", '"': "&quot;", "'": "&#39;" }[c]));
+}
+
+// ---------- charts ----------
+
+function sparkline(canvas, values, max) {
+  const ctx = canvas.getContext("2d");
+  const w = canvas.width, h = canvas.height;
+  ctx.clearRect(0, 0, w, h);
+  if (values.length < 2) return;
+  const top = max || Math.max(1, ...values);
+  ctx.strokeStyle = "#5aa7ff";
+  ctx.lineWidth = 1.5;
+  ctx.beginPath();
+  values.forEach((v, i) => {
+    const x = (i / (values.length - 1)) * (w - 2) + 1;
+    const y = h - 1 - (v / top) * (h - 2);
+    i ? ctx.lineTo(x, y) : ctx.moveTo(x, y);
+  });
+  ctx.stroke();
+}
+
+// receipts per minute over the last 30 minutes
+function rpmSeries(now) {
+  const buckets = new Array(30).fill(0);
+  for (const t of state.receiptTimes) {
+    const age = Math.floor((now - t) / 60000);
+    if (age >= 0 && age < 30) buckets[29 - age]++;
+  }
+  return buckets;
+}
+
+// ---------- rendering ----------
+
+function renderStatus(s) {
+  $("miner-id").textContent = s.miner_id || "";
+  $("region").textContent = s.region || "–";
+  $("uptime").textContent = fmtDur(s.uptime_seconds || 0);
+  $("wallet").textContent = (s.wallet && s.wallet.address) || "not loaded";
+  const p = s.presence;
+  $("presence").textContent = p ? (p.state || "enabled") : (s.modules && s.modules.presence ? "enabled" : "disabled");
+}
+
+function renderPaths() {
+  const rows = state.paths.map((p) => {
+    const q = state.qos[p.name] || { accepted: 0, late: 0 };
+    const r = ratio(q.late, q.accepted);
+    return `<tr>
+      <td class="mono">${esc(p.name)}</td>
+      <td class="${p.ready ? "ok" : "muted"}">${p.ready ? "yes" : "no"}</td>
+      <td>${(p.readers || []).length}</td>
+      <td>${fmtBytes(p.bytesSent || 0)}</td>
+      <td>${q.accepted}</td>
+      <td>${q.late}</td>
+      <td class="${cls(r)}">${pct(r)}</td>
+      <td><canvas data-path="${esc(p.name)}" width="160" height="24"></canvas></td>
+    </tr>`;
+  });
+  $("paths").innerHTML = rows.join("") || `<tr><td colspan="8" class="muted">no paths</td></tr>`;
+  document.querySelectorAll("#paths canvas").forEach((c) => {
+    sparkline(c, state.lateHistory[c.dataset.path] || [], 1);
+  });
+
+  $("npaths").textContent = state.paths.length;
+  $("nready").textContent = state.paths.filter((p) => p.ready).length;
+  $("nreaders").textContent = state.paths.reduce((n, p) => n + (p.readers || []).length, 0);
+
+  let acc = 0, late = 0;
+  for (const k in state.qos) { acc += state.qos[k].accepted; late += state.qos[k].late; }
+  const r = ratio(late, acc);
+  $("late").textContent = pct(r);
+  $("late").className = "big " + cls(r);
+  $("accepted").textContent = acc;
+  $("lateN").textContent = late;
+}
+
+function renderRPM() {
+  const series = rpmSeries(Date.now());
+  $("rpm").textContent = series[series.length - 1];
+  sparkline($("rpm-chart"), series);
+}
+
+function renderBatches() {
+  if (!state.batches.length) return;
+  $("batches").innerHTML = state.batches.slice(-20).reverse().map((e) => {
+    const d = e.data || {};
+    return `<tr>
+      <td>${new Date(e.ts).toLocaleTimeString()}</td>
+      <td class="mono">${esc(d.id !== undefined ? d.id : "–")}</td>
+      <td>${esc(d.count !== undefined ? d.count : "–")}</td>
+      <td class="mono">${esc(String(d.root || "").slice(0, 18))}…</td>
+    </tr>`;
+  }).join("");
+}
+
+function logEvent(e) {
+  const li = document.createElement("li");
+  li.textContent = `${new Date(e.ts).toLocaleTimeString()} ${e.type}${e.path ? " " + e.path : ""}`;
+  const log = $("log");
+  log.prepend(li);
+  while (log.children.length > 200) log.lastChild.remove();
+}
+
+// ---------- events ----------
+
+function ingest(e, live) {
+  if (e.id > state.lastId) state.lastId = e.id;
+  switch (e.type) {
+    case "receipt.signed":
+      state.receiptTimes.push(Date.parse(e.ts));
+      break;
+    case "service.flush": {
+      const d = e.data || {};
+      state.qos[e.path] = { accepted: d.accepted || 0, late: d.late || 0 };
+      const h = state.lateHistory[e.path] || (state.lateHistory[e.path] = []);
+      h.push(ratio(d.late || 0, d.accepted || 0));
+      if (h.length > 60) h.shift();
+      break;
+    }
+    case "batch.closed":
+      state.batches.push(e);
+      if (state.batches.length > 200) state.batches.shift();
+      break;
+  }
+  if (live) logEvent(e);
+}
+
+async function stream() {
+  for (;;) {
+    try {
+      const h = headers({ Accept: "text/event-stream" });
+      if (state.lastId) h["Last-Event-ID"] = String(state.lastId);
+      const r = await fetch("/v1/events", { headers: h });
+      if (!r.ok || !r.body) throw new Error("events: " + r.status);
+      setConn(true);
+
+      const reader = r.body.getReader();
+      const dec = new TextDecoder();
+      let buf = "";
+      for (;;) {
+        const { value, done } = await reader.read();
+        if (done) break;
+        buf += dec.decode(value, { stream: true });
+        let i;
+        while ((i = buf.indexOf("\n\n")) >= 0) {
+          const block = buf.slice(0, i);
+          buf = buf.slice(i + 2);
+          const data = block.split("\n").filter((l) => l.startsWith("data: ")).map((l) => l.slice(6)).join("\n");
+          if (data) {
+            ingest(JSON.parse(data), true);
+            scheduleRender();
+          }
+        }
+      }
+    } catch (err) {
+      console.warn(err);
+    }
+    setConn(false);
+    await new Promise((res) => setTimeout(res, 2000));
+  }
+}
+
+function setConn(on) {
+  const el = $("conn");
+  el.textContent = on ? "live" : "offline";
+  el.className = "pill " + (on ? "on" : "off");
+}
+
+let renderPending = false;
+function scheduleRender() {
+  if (renderPending) return;
+  renderPending = true;
+  requestAnimationFrame(() => {
+    renderPending = false;
+    renderPaths();
+    renderRPM();
+    renderBatches();
+  });
+}
+
+// ---------- polling ----------
+
+async function refresh() {
+  try {
+    const [status, paths, qos] = await Promise.all([api("/v1/status"), api("/v1/paths"), api("/v1/qos")]);
+    renderStatus(status);
+    state.paths = paths.items;
+    for (const q of qos.items) state.qos[q.path] = { accepted: q.accepted, late: q.late };
+    scheduleRender();
+  } catch (err) {
+    console.warn(err);
+  }
+  const cutoff = Date.now() - 3600 * 1000;
+  state.receiptTimes = state.receiptTimes.filter((t) => t >= cutoff);
+}
+
+async function backfill() {
+  try {
+    const r = await api("/v1/events/recent?type=receipt.signed,service.flush,batch.closed&n=4000");
+    r.items.forEach((e) => ingest(e, false));
+  } catch (err) {
+    console.warn(err);
+  }
+}
+
+$("auth").addEventListener("submit", (ev) => {
+  ev.preventDefault();
+  state.token = $("token").value.trim();
+  sessionStorage.setItem("miner-token", state.token);
+  $("token").value = "";
+  refresh();
+});
+
+(async function main() {
+  await backfill();
+  await refresh();
+  setInterval(refresh, 5000);
+  stream();
+})();
@@ -0,0 +1,126 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"slowdrip-miner/internal/events"
+)
+
+// sseKeepAlive is how often an idle stream gets a comment line so proxies don't cut it.
+const sseKeepAlive = 15 * time.Second
+
+// eventStream serves /v1/events as Server-Sent Events.
+//
+// Query params:
+//
+//	type=path.added,receipt.*   comma-separated types (".*" suffix = prefix match)
+//	path=live/stream            comma-separated paths
+//
+// Resume by sending the Last-Event-ID header (or ?lastEventId=) with the last ID seen;
+// events still in the ring buffer are replayed first. If some were already evicted,
+// a "stream.gap" event (without an ID) comes before the replay.
+func eventStream(bus *events.Bus) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		flusher, ok := w.(http.Flusher)
+		if !ok {
+			http.Error(w, "streaming unsupported", http.StatusInternalServerError)
+			return
+		}
+
+		q := r.URL.Query()
+		f := events.Filter{Types: splitList(q.Get("type")), Paths: splitList(q.Get("path"))}
+
+		lastID := r.Header.Get("Last-Event-ID")
+		if lastID == "" {
+			lastID = q.Get("lastEventId")
+		}
+		var after uint64
+		if lastID != "" {
+			n, err := strconv.ParseUint(lastID, 10, 64)
+			if err != nil {
+				http.Error(w, "bad Last-Event-ID", http.StatusBadRequest)
+				return
+			}
+			after = n
+		}
+
+		sub, backlog, gap := bus.Subscribe(f, after)
+		defer sub.Close()
+
+		h := w.Header()
+		h.Set("Content-Type", "text/event-stream")
+		h.Set("Cache-Control", "no-cache")
+		h.Set("Connection", "keep-alive")
+		h.Set("X-Accel-Buffering", "no")
+		w.WriteHeader(http.StatusOK)
+		fmt.Fprint(w, "retry: 2000\n\n")
+
+		if gap {
+			first := bus.LastID() + 1
+			if len(backlog) > 0 {
+				first = backlog[0].ID
+			}
+			b, _ := json.Marshal(map[string]interface{}{"type": events.StreamGap, "last_event_id": after, "resumed_at": first})
+			if _, err := fmt.Fprintf(w, "event: %s\ndata: %s\n\n", events.StreamGap, b); err != nil {
+				return
+			}
+		}
+
+		for _, e := range backlog {
+			if err := writeSSE(w, e); err != nil {
+				return
+			}
+		}
+		flusher.Flush()
+
+		ka := time.NewTicker(sseKeepAlive)
+		defer ka.Stop()
+		for {
+			select {
+			case <-r.Context().Done():
+				return
+			case e, ok := <-sub.C:
+				if !ok {
+					// Dropped for being too slow; the client reconnects with Last-Event-ID.
+					return
+				}
+				if err := writeSSE(w, e); err != nil {
+					return
+				}
+				flusher.Flush()
+			case <-ka.C:
+				if _, err := fmt.Fprint(w, ": keep-alive\n\n"); err != nil {
+					return
+				}
+				flusher.Flush()
+			}
+		}
+	}
+}
+
+func writeSSE(w http.ResponseWriter, e events.Event) error {
+	b, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", e.ID, e.Type, b)
+	return err
+}
+
+func splitList(s string) []string {
+	if s == "" {
+		return nil
+	}
+	var out []string
+	for _, p := range strings.Split(s, ",") {
+		if p = strings.TrimSpace(p); p != "" {
+			out = append(out, p)
+		}
+	}
+	return out
+}
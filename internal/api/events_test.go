@@ -0,0 +1,116 @@
+package api
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"slowdrip-miner/internal/events"
+)
+
+// readFrame reads one SSE frame (up to a blank line) as field -> value.
+func readFrame(t *testing.T, br *bufio.Reader) map[string]string {
+	t.Helper()
+	f := map[string]string{}
+	for {
+		line, err := br.ReadString('\n')
+		if err != nil {
+			t.Fatalf("read: %v (frame so far %v)", err, f)
+		}
+		line = strings.TrimSuffix(line, "\n")
+		if line == "" {
+			if len(f) == 0 {
+				continue
+			}
+			return f
+		}
+		k, v, _ := strings.Cut(line, ": ")
+		f[k] = v
+	}
+}
+
+func TestEventStream(t *testing.T) {
+	tests := []struct {
+		name   string
+		header string // Last-Event-ID
+		query  string
+		gap    bool
+		replay []string // ids
+	}{
+		{name: "resume inside the ring", header: "4", replay: []string{"5", "6"}},
+		{name: "resume by query parameter", query: "?lastEventId=5", replay: []string{"6"}},
+		{name: "resume past the ring", header: "1", gap: true, replay: []string{"3", "4", "5", "6"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			bus := events.NewBus(4)
+			for i := 0; i < 6; i++ { // IDs 3..6 stay buffered
+				bus.Publish(events.PathAdded, "live/a", map[string]int{"n": i + 1})
+			}
+			srv := httptest.NewServer(eventStream(bus))
+			defer srv.Close()
+
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			req, _ := http.NewRequestWithContext(ctx, http.MethodGet, srv.URL+tt.query, nil)
+			if tt.header != "" {
+				req.Header.Set("Last-Event-ID", tt.header)
+			}
+			resp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer resp.Body.Close()
+			if ct := resp.Header.Get("Content-Type"); ct != "text/event-stream" {
+				t.Fatalf("content type %q", ct)
+			}
+			br := bufio.NewReader(resp.Body)
+			if f := readFrame(t, br); f["retry"] != "2000" {
+				t.Fatalf("first frame %v, want retry", f)
+			}
+			if tt.gap {
+				f := readFrame(t, br)
+				if f["event"] != string(events.StreamGap) || f["id"] != "" {
+					t.Fatalf("gap frame %v", f)
+				}
+				var d map[string]interface{}
+				if err := json.Unmarshal([]byte(f["data"]), &d); err != nil || d["last_event_id"] != 1.0 || d["resumed_at"] != 3.0 {
+					t.Fatalf("gap data %q", f["data"])
+				}
+			}
+			for _, id := range tt.replay {
+				f := readFrame(t, br)
+				if f["id"] != id || f["event"] != string(events.PathAdded) {
+					t.Fatalf("frame %v, want id %s", f, id)
+				}
+				var e events.Event
+				if err := json.Unmarshal([]byte(f["data"]), &e); err != nil || e.Path != "live/a" {
+					t.Fatalf("data %q: %v", f["data"], err)
+				}
+			}
+
+			// Live events follow the replay.
+			e := bus.Publish(events.ReaderJoined, "live/a", nil)
+			f := readFrame(t, br)
+			if f["event"] != string(events.ReaderJoined) || f["id"] != strconv.FormatUint(e.ID, 10) {
+				t.Fatalf("live frame %v, want id %d", f, e.ID)
+			}
+		})
+	}
+}
+
+func TestEventStreamBadCursor(t *testing.T) {
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/v1/events", nil)
+	req.Header.Set("Last-Event-ID", "nope")
+	eventStream(events.NewBus(4))(rec, req)
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("status %d, want 400", rec.Code)
+	}
+}
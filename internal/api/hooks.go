@@ -0,0 +1,75 @@
+package api
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+
+	"slowdrip-miner/internal/config"
+	"slowdrip-miner/internal/mediamtx"
+)
+
+// HooksPrefix starts the routes MediaMTX calls: runOn* events and admission.
+// Each checks its own token, so HooksRouter serves them without admin auth.
+const HooksPrefix = "/hooks/"
+
+// HookEventPath receives MediaMTX runOn* hook events (see cmd/mtx-hook).
+const HookEventPath = HooksPrefix + "mediamtx/event"
+
+// HooksRouter serves only the public HooksPrefix routes, for miner.hooksListen.
+// Modules register theirs with RegisterRoute before it is built, as for Router.
+func HooksRouter(cfg *config.Config) http.Handler {
+	mux := http.NewServeMux()
+	if cfg.MediaMTX.Hooks.Enable {
+		mux.Handle(HookEventPath, mediamtxHook(cfg.MediaMTX.Hooks.Token.Value()))
+	}
+	extraMu.RLock()
+	defer extraMu.RUnlock()
+	for _, e := range extraRoutes {
+		if e.role == RolePublic && strings.HasPrefix(e.pattern, HooksPrefix) {
+			mux.Handle(e.pattern, e.h)
+		}
+	}
+	return mux
+}
+
+// mediamtxHook serves HookEventPath. It is a public route guarded by its own
+// token, since MediaMTX can't present admin credentials.
+func mediamtxHook(token string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		if token != "" && subtle.ConstantTimeCompare([]byte(hookToken(r)), []byte(token)) != 1 {
+			http.Error(w, "bad hook token", http.StatusUnauthorized)
+			return
+		}
+		var ev mediamtx.HookEvent
+		if err := json.NewDecoder(io.LimitReader(r.Body, 64<<10)).Decode(&ev); err != nil {
+			http.Error(w, "bad hook event", http.StatusBadRequest)
+			return
+		}
+		err := mediamtx.PushHook(ev)
+		switch {
+		case errors.Is(err, mediamtx.ErrNoWatcher):
+			http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		case err != nil:
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		default:
+			w.WriteHeader(http.StatusNoContent)
+		}
+	}
+}
+
+// hookToken takes the token from "Authorization: Bearer" or, for hook commands
+// that can't set headers, the token query parameter.
+func hookToken(r *http.Request) string {
+	if h := r.Header.Get("Authorization"); strings.HasPrefix(h, "Bearer ") {
+		return strings.TrimSpace(strings.TrimPrefix(h, "Bearer "))
+	}
+	return r.URL.Query().Get("token")
+}
@@ -1,19 +1,112 @@
 package api
 
 import (
+	"context"
+	"encoding/json"
 	"net/http"
+	"sync"
 
 	"slowdrip-miner/internal/config"
+	"slowdrip-miner/internal/events"
 
 	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/rs/zerolog"
 )
 
-func Router(cfg *config.Config) http.Handler {
+// Router builds the admin API. Every route declares the role it requires;
+// health probes are public.
+func Router(cfg *config.Config, log zerolog.Logger) (http.Handler, error) {
+	g, err := newGuard(cfg, log)
+	if err != nil {
+		return nil, err
+	}
+
 	mux := http.NewServeMux()
-	mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(200); w.Write([]byte("ok")) })
-	mux.HandleFunc("/readyz", func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(200); w.Write([]byte("ready")) })
+	handle := func(pattern string, role Role, h http.Handler) { mux.Handle(pattern, g.require(role, h)) }
+
+	handle("/healthz", RolePublic, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(200); w.Write([]byte("ok")) }))
+	handle("/readyz", RolePublic, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(200); w.Write([]byte("ready")) }))
 	if cfg.Metrics.Enable {
-		mux.Handle("/metrics", promhttp.Handler())
+		handle(cfg.Metrics.Path, metricsRole(cfg.Metrics.Role), promhttp.Handler())
+	}
+	handle("/v1/whoami", RoleViewer, http.HandlerFunc(whoami))
+	handle("/v1/status", RoleViewer, statusHandler(cfg))
+	handle("/v1/config", RoleAdmin, configHandler(cfg))
+	handle("/v1/paths", RoleViewer, http.HandlerFunc(pathsHandler))
+	handle("/v1/qos", RoleViewer, http.HandlerFunc(qosHandler))
+	handle("/v1/events", RoleViewer, eventStream(events.Default))
+	handle("/v1/events/recent", RoleViewer, recentEvents(events.Default))
+	if cfg.MediaMTX.Hooks.Enable {
+		handle(HookEventPath, RolePublic, mediamtxHook(cfg.MediaMTX.Hooks.Token.Value()))
 	}
-	return mux
+
+	extraMu.RLock()
+	for _, e := range extraRoutes {
+		handle(e.pattern, e.role, e.h)
+	}
+	extraMu.RUnlock()
+
+	handle("/ui/", RolePublic, dashboardHandler())
+	handle("/", RolePublic, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/" {
+			http.NotFound(w, r)
+			return
+		}
+		http.Redirect(w, r, "/ui/", http.StatusFound)
+	}))
+	return mux, nil
+}
+
+// metricsRole maps metrics.role; "public" lets scrapers in without credentials.
+func metricsRole(s string) Role {
+	if s == "public" {
+		return RolePublic
+	}
+	if r, err := ParseRole(s); err == nil {
+		return r
+	}
+	return RoleViewer
+}
+
+type extraRoute struct {
+	pattern string
+	role    Role
+	h       http.Handler
+}
+
+var (
+	extraMu     sync.RWMutex
+	extraRoutes []extraRoute
+)
+
+// RegisterRoute adds a module route to routers built afterwards. Like built-in
+// routes it must declare the role it requires.
+func RegisterRoute(pattern string, role Role, h http.Handler) {
+	extraMu.Lock()
+	defer extraMu.Unlock()
+	extraRoutes = append(extraRoutes, extraRoute{pattern: pattern, role: role, h: h})
+}
+
+// whoami echoes the authenticated principal (handy for checking credentials).
+func whoami(w http.ResponseWriter, r *http.Request) {
+	p, _ := PrincipalFrom(r.Context())
+	writeJSON(w, http.StatusOK, map[string]string{"name": p.Name, "role": p.Role.String(), "method": p.Method})
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+type principalKey struct{}
+
+func withPrincipal(ctx context.Context, p Principal) context.Context {
+	return context.WithValue(ctx, principalKey{}, p)
+}
+
+// PrincipalFrom returns the caller attached by the auth guard, if any.
+func PrincipalFrom(ctx context.Context) (Principal, bool) {
+	p, ok := ctx.Value(principalKey{}).(Principal)
+	return p, ok
 }
@@ -0,0 +1,118 @@
+package api
+
+import (
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"slowdrip-miner/internal/config"
+	"slowdrip-miner/internal/events"
+	"slowdrip-miner/internal/mediamtx"
+	"slowdrip-miner/internal/service"
+
+	"gopkg.in/yaml.v3"
+)
+
+// StatusFunc reports a module's current status for /v1/status.
+type StatusFunc func() interface{}
+
+var (
+	statusMu        sync.RWMutex
+	statusProviders = map[string]StatusFunc{}
+	started         = time.Now()
+)
+
+// RegisterStatus adds a named section to /v1/status (e.g. "wallet", "presence").
+// Registering the same name twice replaces the previous provider.
+func RegisterStatus(name string, fn StatusFunc) {
+	statusMu.Lock()
+	defer statusMu.Unlock()
+	statusProviders[name] = fn
+}
+
+// statusHandler serves /v1/status: identity, uptime and every registered section.
+func statusHandler(cfg *config.Config) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		out := map[string]interface{}{
+			"miner_id":       cfg.Miner.ID,
+			"region":         cfg.Miner.Region,
+			"started":        started.UTC(),
+			"uptime_seconds": int64(time.Since(started).Seconds()),
+			"modules": map[string]bool{
+				"presence": cfg.Presence.Enable,
+				"service":  cfg.Service.Enable,
+				"metrics":  cfg.Metrics.Enable,
+			},
+		}
+
+		statusMu.RLock()
+		names := make([]string, 0, len(statusProviders))
+		for n := range statusProviders {
+			names = append(names, n)
+		}
+		sort.Strings(names)
+		for _, n := range names {
+			out[n] = statusProviders[n]()
+		}
+		statusMu.RUnlock()
+
+		writeJSON(w, http.StatusOK, out)
+	}
+}
+
+// configHandler serves /v1/config: the effective config as YAML. Secret fields
+// marshal as their file:/env:/sealed: reference (or "<redacted>"), never the value.
+func configHandler(cfg *config.Config) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		out, err := yaml.Marshal(cfg)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/yaml")
+		w.Write(out)
+	}
+}
+
+// pathsHandler serves /v1/paths from the watcher's last poll.
+func pathsHandler(w http.ResponseWriter, r *http.Request) {
+	paths := mediamtx.Snapshot()
+	if paths == nil {
+		paths = []mediamtx.Path{}
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"items": paths})
+}
+
+// qosHandler serves /v1/qos from the service agent's counters.
+func qosHandler(w http.ResponseWriter, r *http.Request) {
+	stats := service.Snapshot()
+	if stats == nil {
+		stats = []service.PathStats{}
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"items": stats})
+}
+
+// recentEvents serves /v1/events/recent?type=..&path=..&n=100 from the ring buffer,
+// so a client can backfill history before subscribing to the live stream.
+func recentEvents(bus *events.Bus) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		n := 100
+		if s := q.Get("n"); s != "" {
+			v, err := strconv.Atoi(s)
+			if err != nil || v <= 0 {
+				http.Error(w, "bad n", http.StatusBadRequest)
+				return
+			}
+			n = v
+		}
+		f := events.Filter{Types: splitList(q.Get("type")), Paths: splitList(q.Get("path"))}
+		items := bus.Recent(f, n)
+		if items == nil {
+			items = []events.Event{}
+		}
+		writeJSON(w, http.StatusOK, map[string]interface{}{"items": items})
+	}
+}
@@ -0,0 +1,78 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"slowdrip-miner/internal/wallet"
+)
+
+// RegisterWallet exposes the miner key's status and, for local keys, rotation:
+//
+//	GET  /v1/wallet         address, epoch, pending handover (viewer)
+//	POST /v1/wallet/rotate  {"epoch": N} or {"in_epochs": N}, "reason" (admin)
+//
+// rot is nil for remote signers; key management then happens on the signer.
+func RegisterWallet(s wallet.Signer, rot *wallet.Rotator, clock wallet.EpochClock) {
+	RegisterStatus("wallet", func() interface{} { return walletStatus(s, rot, clock) })
+
+	RegisterRoute("/v1/wallet", RoleViewer, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, walletStatus(s, rot, clock))
+	}))
+	if rot == nil {
+		return
+	}
+
+	RegisterRoute("/v1/wallet/rotate", RoleAdmin, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		var req struct {
+			Epoch    uint64 `json:"epoch"`
+			InEpochs uint64 `json:"in_epochs"`
+			Reason   string `json:"reason"`
+		}
+		if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 4096)).Decode(&req); err != nil {
+			http.Error(w, "bad request: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		epoch := req.Epoch
+		if epoch == 0 {
+			if req.InEpochs == 0 {
+				req.InEpochs = 1
+			}
+			epoch = clock.Current() + req.InEpochs
+		}
+		h, err := rot.Rotate(epoch, req.Reason)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		}
+		writeJSON(w, http.StatusOK, h)
+	}))
+}
+
+func walletStatus(s wallet.Signer, rot *wallet.Rotator, clock wallet.EpochClock) map[string]interface{} {
+	out := map[string]interface{}{
+		"address": s.Address().Hex(),
+		"epoch":   clock.Current(),
+	}
+	if _, ok := s.(*wallet.RemoteSigner); ok {
+		out["signer"] = "remote"
+	} else {
+		out["signer"] = "local"
+	}
+	if rot == nil {
+		return out
+	}
+	if id := rot.ChainID(); id != nil {
+		out["chain_id"] = id.String()
+	}
+	if h := rot.Pending(); h != nil {
+		out["handover"] = h
+		out["handover_at"] = clock.Start(h.Epoch)
+	}
+	return out
+}
@@ -0,0 +1,346 @@
+// internal/chain/client.go
+package chain
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"slowdrip-miner/internal/wallet"
+
+	ethereum "github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/rs/zerolog"
+)
+
+// Backend is the slice of an Ethereum client this package needs.
+// *ethclient.Client and go-ethereum's simulated backend client both satisfy it.
+type Backend interface {
+	ChainID(ctx context.Context) (*big.Int, error)
+	BlockNumber(ctx context.Context) (uint64, error)
+	PendingNonceAt(ctx context.Context, account common.Address) (uint64, error)
+	SuggestGasTipCap(ctx context.Context) (*big.Int, error)
+	HeaderByNumber(ctx context.Context, number *big.Int) (*types.Header, error)
+	EstimateGas(ctx context.Context, call ethereum.CallMsg) (uint64, error)
+	SendTransaction(ctx context.Context, tx *types.Transaction) error
+	TransactionReceipt(ctx context.Context, txHash common.Hash) (*types.Receipt, error)
+}
+
+// DefaultABI is used when no ABI file is configured. It describes the minimal
+// payout contract surface; point chain.abiFile at the real ABI if yours differs.
+const DefaultABI = `[
+  {"type":"function","name":"submitRoot","stateMutability":"nonpayable",
+   "inputs":[{"name":"batchId","type":"uint256"},{"name":"root","type":"bytes32"},{"name":"count","type":"uint32"}],"outputs":[]},
+  {"type":"function","name":"claim","stateMutability":"nonpayable",
+   "inputs":[{"name":"batchId","type":"uint256"}],"outputs":[]}
+]`
+
+// Options configures a Client.
+type Options struct {
+	Contract      common.Address
+	ABIFile       string // "" = DefaultABI
+	SubmitMethod  string // default "submitRoot"
+	ClaimMethod   string // default "claim"
+	Confirmations uint64 // blocks on top of inclusion before a tx counts as final
+	GasBufferPct  uint64 // added to the gas estimate, percent
+	MaxTipCap     *big.Int
+	MaxFeeCap     *big.Int
+	PollInterval  time.Duration
+	ResendAfter   time.Duration // rebroadcast a still-unseen tx after this long
+}
+
+func (o *Options) defaults() {
+	if o.SubmitMethod == "" {
+		o.SubmitMethod = "submitRoot"
+	}
+	if o.ClaimMethod == "" {
+		o.ClaimMethod = "claim"
+	}
+	if o.Confirmations == 0 {
+		o.Confirmations = 12
+	}
+	if o.GasBufferPct == 0 {
+		o.GasBufferPct = 20
+	}
+	if o.PollInterval <= 0 {
+		o.PollInterval = 4 * time.Second
+	}
+	if o.ResendAfter <= 0 {
+		o.ResendAfter = 2 * time.Minute
+	}
+}
+
+// Client builds, signs and submits EIP-1559 transactions to the payout contract.
+// Nonces are tracked locally per sender (seeded from the pending nonce) so several
+// transactions can be in flight and a key rotation starts from the new key's own
+// nonce; a send failure resynchronises that sender from the node.
+type Client struct {
+	b        Backend
+	signer   wallet.Signer
+	chainID  *big.Int
+	contract common.Address
+	abi      abi.ABI
+	opts     Options
+	log      zerolog.Logger
+
+	mu     sync.Mutex // serialises nonce allocation + send
+	nonces map[common.Address]uint64
+}
+
+// Dial connects to an RPC endpoint and returns a Client.
+func Dial(ctx context.Context, rpcURL string, o Options, s wallet.Signer, log zerolog.Logger) (*Client, error) {
+	ec, err := ethclient.DialContext(ctx, rpcURL)
+	if err != nil {
+		return nil, fmt.Errorf("chain: dial %s: %w", rpcURL, err)
+	}
+	return New(ctx, ec, o, s, log)
+}
+
+// New wraps an existing backend (e.g. a simulated backend in tests).
+func New(ctx context.Context, b Backend, o Options, s wallet.Signer, log zerolog.Logger) (*Client, error) {
+	o.defaults()
+	spec := DefaultABI
+	if o.ABIFile != "" {
+		raw, err := os.ReadFile(o.ABIFile)
+		if err != nil {
+			return nil, fmt.Errorf("chain: read abi: %w", err)
+		}
+		spec = string(raw)
+	}
+	parsed, err := abi.JSON(strings.NewReader(spec))
+	if err != nil {
+		return nil, fmt.Errorf("chain: parse abi: %w", err)
+	}
+	for _, m := range []string{o.SubmitMethod, o.ClaimMethod} {
+		if _, ok := parsed.Methods[m]; !ok {
+			return nil, fmt.Errorf("chain: abi has no method %q", m)
+		}
+	}
+	id, err := b.ChainID(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("chain: chain id: %w", err)
+	}
+	return &Client{
+		b:        b,
+		signer:   s,
+		chainID:  id,
+		contract: o.Contract,
+		abi:      parsed,
+		opts:     o,
+		log:      log.With().Str("module", "chain").Logger(),
+		nonces:   make(map[common.Address]uint64),
+	}, nil
+}
+
+// ChainID of the connected network.
+func (c *Client) ChainID() *big.Int { return new(big.Int).Set(c.chainID) }
+
+// Pending is a signed, broadcast transaction awaiting confirmation. Tx is the
+// latest version sent; WaitConfirmed replaces it with a fee-bumped copy (same
+// nonce) when it sits unmined, and keeps the earlier versions in Replaced since
+// any of them may still be the one that gets mined.
+type Pending struct {
+	Method   string
+	From     common.Address
+	Tx       *types.Transaction
+	Replaced []*types.Transaction
+	SentAt   time.Time
+}
+
+// hashes lists every version of the transaction, newest first.
+func (p *Pending) hashes() []common.Hash {
+	out := []common.Hash{p.Tx.Hash()}
+	for i := len(p.Replaced) - 1; i >= 0; i-- {
+		out = append(out, p.Replaced[i].Hash())
+	}
+	return out
+}
+
+// SubmitRoot anchors a batch Merkle root on the payout contract.
+func (c *Client) SubmitRoot(ctx context.Context, batchID uint64, root [32]byte, count uint32) (*Pending, error) {
+	return c.Transact(ctx, c.opts.SubmitMethod, new(big.Int).SetUint64(batchID), root, count)
+}
+
+// Claim claims rewards for a batch. Extra arguments are passed through for
+// contracts whose claim takes more than the batch ID (e.g. proofs).
+func (c *Client) Claim(ctx context.Context, batchID uint64, extra ...interface{}) (*Pending, error) {
+	args := append([]interface{}{new(big.Int).SetUint64(batchID)}, extra...)
+	return c.Transact(ctx, c.opts.ClaimMethod, args...)
+}
+
+// Transact packs a call to method, prices and signs it as an EIP-1559 tx, and sends it.
+func (c *Client) Transact(ctx context.Context, method string, args ...interface{}) (*Pending, error) {
+	data, err := c.abi.Pack(method, args...)
+	if err != nil {
+		return nil, fmt.Errorf("chain: pack %s: %w", method, err)
+	}
+	from := c.signer.Address()
+
+	tip, feeCap, err := c.fees(ctx)
+	if err != nil {
+		return nil, err
+	}
+	gas, err := c.b.EstimateGas(ctx, ethereum.CallMsg{
+		From:      from,
+		To:        &c.contract,
+		GasTipCap: tip,
+		GasFeeCap: feeCap,
+		Data:      data,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("chain: estimate gas for %s: %w", method, err)
+	}
+	gas += gas * c.opts.GasBufferPct / 100
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	nonce, err := c.nextNonce(ctx, from)
+	if err != nil {
+		return nil, err
+	}
+	tx := types.NewTx(&types.DynamicFeeTx{
+		ChainID:   c.chainID,
+		Nonce:     nonce,
+		GasTipCap: tip,
+		GasFeeCap: feeCap,
+		Gas:       gas,
+		To:        &c.contract,
+		Value:     new(big.Int),
+		Data:      data,
+	})
+	signed, err := c.sign(tx)
+	if err != nil {
+		return nil, err
+	}
+	if err := c.b.SendTransaction(ctx, signed); err != nil {
+		// The node may know better (another process, dropped tx): resync next time.
+		delete(c.nonces, from)
+		return nil, fmt.Errorf("chain: send %s: %w", method, err)
+	}
+	c.nonces[from] = nonce + 1
+
+	c.log.Info().
+		Str("method", method).
+		Str("tx", signed.Hash().Hex()).
+		Uint64("nonce", nonce).
+		Uint64("gas", gas).
+		Str("tip", tip.String()).
+		Str("fee_cap", feeCap.String()).
+		Msg("chain: transaction sent")
+	return &Pending{Method: method, From: from, Tx: signed, SentAt: time.Now()}, nil
+}
+
+// fees picks tip = suggested (capped) and feeCap = 2*baseFee + tip (capped).
+func (c *Client) fees(ctx context.Context) (tip, feeCap *big.Int, err error) {
+	tip, err = c.b.SuggestGasTipCap(ctx)
+	if err != nil {
+		return nil, nil, fmt.Errorf("chain: suggest tip: %w", err)
+	}
+	if c.opts.MaxTipCap != nil && tip.Cmp(c.opts.MaxTipCap) > 0 {
+		tip = new(big.Int).Set(c.opts.MaxTipCap)
+	}
+	head, err := c.b.HeaderByNumber(ctx, nil)
+	if err != nil {
+		return nil, nil, fmt.Errorf("chain: head: %w", err)
+	}
+	if head.BaseFee == nil {
+		return nil, nil, errors.New("chain: network does not support EIP-1559")
+	}
+	feeCap = new(big.Int).Add(new(big.Int).Mul(head.BaseFee, big.NewInt(2)), tip)
+	if c.opts.MaxFeeCap != nil && feeCap.Cmp(c.opts.MaxFeeCap) > 0 {
+		feeCap = new(big.Int).Set(c.opts.MaxFeeCap)
+		if feeCap.Cmp(tip) < 0 {
+			tip = new(big.Int).Set(feeCap)
+		}
+	}
+	return tip, feeCap, nil
+}
+
+// nextNonce returns the next nonce to use for from. Caller holds c.mu.
+func (c *Client) nextNonce(ctx context.Context, from common.Address) (uint64, error) {
+	if n, ok := c.nonces[from]; ok {
+		return n, nil
+	}
+	n, err := c.b.PendingNonceAt(ctx, from)
+	if err != nil {
+		return 0, fmt.Errorf("chain: pending nonce: %w", err)
+	}
+	c.nonces[from] = n
+	return n, nil
+}
+
+// bumpPct is the minimum fee increase nodes accept for a same-nonce replacement.
+const bumpPct = 10
+
+// errCannotBump is returned by bump when the replacement would exceed MaxFeeCap.
+var errCannotBump = errors.New("chain: fee cap reached; cannot bump")
+
+// bump re-signs p.Tx with the same nonce, gas and data and both fees raised by at
+// least bumpPct (or to the current suggestion, if higher). It does not send it.
+func (c *Client) bump(ctx context.Context, p *Pending) (*types.Transaction, error) {
+	if c.signer.Address() != p.From {
+		return nil, fmt.Errorf("chain: signer is now %s, transaction is from %s; cannot re-sign", c.signer.Address().Hex(), p.From.Hex())
+	}
+	tip, feeCap, err := c.fees(ctx)
+	if err != nil {
+		return nil, err
+	}
+	old := p.Tx
+	if min := raise(old.GasTipCap()); tip.Cmp(min) < 0 {
+		tip = min
+	}
+	if min := raise(old.GasFeeCap()); feeCap.Cmp(min) < 0 {
+		feeCap = min
+	}
+	if feeCap.Cmp(tip) < 0 {
+		feeCap = new(big.Int).Set(tip)
+	}
+	if c.opts.MaxFeeCap != nil && feeCap.Cmp(c.opts.MaxFeeCap) > 0 {
+		return nil, errCannotBump
+	}
+	return c.sign(types.NewTx(&types.DynamicFeeTx{
+		ChainID:   c.chainID,
+		Nonce:     old.Nonce(),
+		GasTipCap: tip,
+		GasFeeCap: feeCap,
+		Gas:       old.Gas(),
+		To:        old.To(),
+		Value:     old.Value(),
+		Data:      old.Data(),
+	}))
+}
+
+// raise returns v increased by bumpPct percent, rounded up.
+func raise(v *big.Int) *big.Int {
+	n := new(big.Int).Mul(v, big.NewInt(100+bumpPct))
+	n.Add(n, big.NewInt(99))
+	return n.Div(n, big.NewInt(100))
+}
+
+// sign hashes tx for this chain and signs the digest with the wallet.
+func (c *Client) sign(tx *types.Transaction) (*types.Transaction, error) {
+	ls := types.LatestSignerForChainID(c.chainID)
+	h := ls.Hash(tx)
+	sig, err := c.signer.SignHash(h[:])
+	if errors.Is(err, wallet.ErrRawHashUnsupported) {
+		return nil, errors.New("chain: the configured signer cannot sign transactions (no raw hash signing)")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("chain: sign tx: %w", err)
+	}
+	// wallet signatures carry V in {27,28}; transactions want the bare recovery id.
+	sig = append([]byte(nil), sig...)
+	if sig[64] >= 27 {
+		sig[64] -= 27
+	}
+	return tx.WithSignature(ls, sig)
+}
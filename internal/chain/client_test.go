@@ -0,0 +1,403 @@
+package chain
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"math/big"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"slowdrip-miner/internal/wallet"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethclient/simulated"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/rs/zerolog"
+)
+
+// payout has no code, so calls to it succeed and cost only intrinsic gas.
+var payout = common.HexToAddress("0x00000000000000000000000000000000000c1a1d")
+
+// switchSigner lets a test swap the key under a Client, like a rotation does.
+type switchSigner struct {
+	mu sync.Mutex
+	wallet.Signer
+}
+
+func (s *switchSigner) set(k wallet.Signer) {
+	s.mu.Lock()
+	s.Signer = k
+	s.mu.Unlock()
+}
+
+func (s *switchSigner) Address() common.Address {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.Signer.Address()
+}
+
+func (s *switchSigner) SignHash(h []byte) ([]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.Signer.SignHash(h)
+}
+
+type testChain struct {
+	sim    *simulated.Backend
+	c      *Client
+	signer *switchSigner
+	keys   []*wallet.Keystore
+}
+
+// newTestChain starts a simulated chain funding n fresh keys and a Client signing
+// with the first one.
+func newTestChain(t *testing.T, n int, o Options) *testChain {
+	t.Helper()
+	alloc := types.GenesisAlloc{}
+	var keys []*wallet.Keystore
+	for i := 0; i < n; i++ {
+		k, err := wallet.NewRandom(nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(k.Close)
+		keys = append(keys, k)
+		alloc[k.Address()] = types.Account{Balance: new(big.Int).Mul(big.NewInt(1000), big.NewInt(params.Ether))}
+	}
+	sim := simulated.NewBackend(alloc)
+	t.Cleanup(func() { sim.Close() })
+	sim.Commit() // until the first block, receipt lookups fail with "indexing in progress"
+
+	if o.Contract == (common.Address{}) {
+		o.Contract = payout
+	}
+	if o.PollInterval == 0 {
+		o.PollInterval = 5 * time.Millisecond
+	}
+	if o.Confirmations == 0 {
+		o.Confirmations = 1
+	}
+	ss := &switchSigner{Signer: keys[0]}
+	c, err := New(context.Background(), sim.Client(), o, ss, zerolog.Nop())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &testChain{sim: sim, c: c, signer: ss, keys: keys}
+}
+
+// mine commits a block every few milliseconds until the returned stop is called.
+func (tc *testChain) mine() (stop func()) {
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		t := time.NewTicker(5 * time.Millisecond)
+		defer t.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-t.C:
+				tc.sim.Commit()
+			}
+		}
+	}()
+	return func() { close(done); wg.Wait() }
+}
+
+func waitCtx(t *testing.T) context.Context {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	t.Cleanup(cancel)
+	return ctx
+}
+
+func TestTransactNonces(t *testing.T) {
+	tc := newTestChain(t, 2, Options{})
+	ctx := waitCtx(t)
+
+	steps := []struct {
+		name      string
+		key       int
+		wantNonce uint64
+	}{
+		{name: "first from key 0", key: 0, wantNonce: 0},
+		{name: "second from key 0", key: 0, wantNonce: 1},
+		{name: "rotated to key 1", key: 1, wantNonce: 0},
+		{name: "key 1 again", key: 1, wantNonce: 1},
+		{name: "back to key 0", key: 0, wantNonce: 2},
+	}
+	for _, st := range steps {
+		tc.signer.set(tc.keys[st.key])
+		p, err := tc.c.SubmitRoot(ctx, 1, [32]byte{1}, 1)
+		if err != nil {
+			t.Fatalf("%s: %v", st.name, err)
+		}
+		if p.Tx.Nonce() != st.wantNonce {
+			t.Fatalf("%s: nonce %d, want %d", st.name, p.Tx.Nonce(), st.wantNonce)
+		}
+		if p.From != tc.keys[st.key].Address() {
+			t.Fatalf("%s: from %s", st.name, p.From.Hex())
+		}
+	}
+}
+
+func TestWaitConfirmed(t *testing.T) {
+	tc := newTestChain(t, 1, Options{Confirmations: 3, ResendAfter: time.Hour})
+	ctx := waitCtx(t)
+
+	p, err := tc.c.Claim(ctx, 7)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stop := tc.mine()
+	rcpt, err := tc.c.WaitConfirmed(ctx, p)
+	stop()
+	if err != nil {
+		t.Fatalf("WaitConfirmed: %v", err)
+	}
+	if rcpt.TxHash != p.Tx.Hash() || rcpt.Status != types.ReceiptStatusSuccessful {
+		t.Fatalf("receipt %s status %d", rcpt.TxHash.Hex(), rcpt.Status)
+	}
+	head, err := tc.sim.Client().BlockNumber(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := head - rcpt.BlockNumber.Uint64() + 1; got < 3 {
+		t.Fatalf("returned after %d confirmations, want 3", got)
+	}
+}
+
+// logBuffer collects log lines written from the WaitConfirmed goroutine.
+type logBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *logBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *logBuffer) count(msg string) int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return strings.Count(b.buf.String(), msg)
+}
+
+// waitLog waits until msg has been logged n times.
+func (b *logBuffer) waitLog(t *testing.T, msg string, n int) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for b.count(msg) < n {
+		if time.Now().After(deadline) {
+			t.Fatalf("%q not logged", msg)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestWaitConfirmedReorg(t *testing.T) {
+	tc := newTestChain(t, 1, Options{Confirmations: 3, ResendAfter: time.Hour})
+	ctx := waitCtx(t)
+	logs := &logBuffer{}
+	tc.c.log = zerolog.New(logs)
+	client := tc.sim.Client()
+
+	parent, err := client.HeaderByNumber(ctx, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p, err := tc.c.Claim(ctx, 7)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tc.sim.Commit()
+	tc.sim.Commit() // two of the three confirmations, on the branch about to be orphaned
+	orphan, err := client.TransactionReceipt(ctx, p.Tx.Hash())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	type result struct {
+		rcpt *types.Receipt
+		err  error
+	}
+	done := make(chan result, 1)
+	go func() {
+		rcpt, err := tc.c.WaitConfirmed(ctx, p)
+		done <- result{rcpt, err}
+	}()
+	logs.waitLog(t, "chain: transaction included", 1)
+
+	// Rewind to before the transaction; it goes back to the pool.
+	if err := tc.sim.Fork(parent.Hash()); err != nil {
+		t.Fatal(err)
+	}
+	logs.waitLog(t, "reorged out; waiting for re-inclusion", 1)
+	if n := logs.count("chain: transaction confirmed"); n != 0 {
+		t.Fatal("confirmed on the orphaned block")
+	}
+
+	stop := tc.mine()
+	res := <-done
+	stop()
+	if res.err != nil {
+		t.Fatalf("WaitConfirmed: %v", res.err)
+	}
+	if res.rcpt.BlockHash == orphan.BlockHash {
+		t.Fatalf("confirmed in orphaned block %s", orphan.BlockHash.Hex())
+	}
+	hdr, err := client.HeaderByNumber(ctx, res.rcpt.BlockNumber)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hdr.Hash() != res.rcpt.BlockHash {
+		t.Fatalf("returned receipt from non-canonical block %s", res.rcpt.BlockHash.Hex())
+	}
+	head, err := client.BlockNumber(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := head - res.rcpt.BlockNumber.Uint64() + 1; got < 3 {
+		t.Fatalf("returned after %d confirmations on the new branch, want 3", got)
+	}
+	if n := logs.count("chain: transaction included"); n != 2 {
+		t.Fatalf("logged inclusion %d times, want once per branch", n)
+	}
+}
+
+func TestWaitConfirmedBumpsFees(t *testing.T) {
+	tc := newTestChain(t, 1, Options{ResendAfter: time.Millisecond})
+	ctx := waitCtx(t)
+
+	p, err := tc.c.SubmitRoot(ctx, 1, [32]byte{1}, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	first := p.Tx
+	go func() {
+		// Nothing is mined for a while, so WaitConfirmed has to replace the tx.
+		time.Sleep(60 * time.Millisecond)
+		tc.sim.Commit()
+	}()
+	rcpt, err := tc.c.WaitConfirmed(ctx, p)
+	if err != nil {
+		t.Fatalf("WaitConfirmed: %v", err)
+	}
+	if len(p.Replaced) == 0 || p.Replaced[0] != first {
+		t.Fatalf("no replacement recorded (replaced=%d)", len(p.Replaced))
+	}
+	if p.Tx.Nonce() != first.Nonce() {
+		t.Fatalf("replacement nonce %d, want %d", p.Tx.Nonce(), first.Nonce())
+	}
+	prev := p.Replaced[len(p.Replaced)-1]
+	if p.Tx.GasFeeCap().Cmp(raise(prev.GasFeeCap())) < 0 || p.Tx.GasTipCap().Cmp(raise(prev.GasTipCap())) < 0 {
+		t.Fatalf("fees %s/%s not bumped %d%% over %s/%s", p.Tx.GasTipCap(), p.Tx.GasFeeCap(), bumpPct, prev.GasTipCap(), prev.GasFeeCap())
+	}
+	// Usually the latest version is mined, but a commit can race a bump.
+	mined := false
+	for _, h := range p.hashes() {
+		mined = mined || h == rcpt.TxHash
+	}
+	if !mined {
+		t.Fatalf("mined %s, which is none of the versions sent", rcpt.TxHash.Hex())
+	}
+}
+
+func TestBumpRespectsMaxFeeCap(t *testing.T) {
+	tc := newTestChain(t, 2, Options{})
+	ctx := waitCtx(t)
+
+	p, err := tc.c.SubmitRoot(ctx, 1, [32]byte{1}, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name    string
+		setup   func()
+		wantErr error
+	}{
+		{name: "bumps", setup: func() {}},
+		{name: "capped", setup: func() { tc.c.opts.MaxFeeCap = p.Tx.GasFeeCap() }, wantErr: errCannotBump},
+		{name: "signer rotated", setup: func() { tc.c.opts.MaxFeeCap = nil; tc.signer.set(tc.keys[1]) }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.setup()
+			tx, err := tc.c.bump(ctx, p)
+			switch {
+			case tt.wantErr != nil:
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("err = %v, want %v", err, tt.wantErr)
+				}
+			case tt.name == "signer rotated":
+				if err == nil {
+					t.Fatal("bump re-signed with a different key")
+				}
+			case err != nil:
+				t.Fatalf("bump: %v", err)
+			case tx.Nonce() != p.Tx.Nonce() || tx.GasFeeCap().Cmp(raise(p.Tx.GasFeeCap())) < 0:
+				t.Fatalf("nonce %d fee cap %s", tx.Nonce(), tx.GasFeeCap())
+			}
+		})
+	}
+}
+
+func TestWaitConfirmedReplacedByOtherTx(t *testing.T) {
+	tc := newTestChain(t, 1, Options{ResendAfter: time.Millisecond})
+	ctx := waitCtx(t)
+
+	p, err := tc.c.SubmitRoot(ctx, 1, [32]byte{1}, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Another process with the same key uses the nonce for something else.
+	other, err := tc.c.sign(types.NewTx(&types.DynamicFeeTx{
+		ChainID:   tc.c.chainID,
+		Nonce:     p.Tx.Nonce(),
+		GasTipCap: new(big.Int).Mul(p.Tx.GasTipCap(), big.NewInt(3)),
+		GasFeeCap: new(big.Int).Mul(p.Tx.GasFeeCap(), big.NewInt(3)),
+		Gas:       21000,
+		To:        &common.Address{1},
+		Value:     big.NewInt(1),
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := tc.sim.Client().SendTransaction(ctx, other); err != nil {
+		t.Fatal(err)
+	}
+	tc.sim.Commit()
+
+	if _, err := tc.c.WaitConfirmed(ctx, p); !errors.Is(err, ErrReplaced) {
+		t.Fatalf("err = %v, want ErrReplaced", err)
+	}
+}
+
+func TestNodeErrorClassification(t *testing.T) {
+	tests := []struct {
+		msg           string
+		known, tooLow bool
+	}{
+		{msg: "already known", known: true},
+		{msg: "known transaction: 0xabc", known: true},
+		{msg: "nonce too low: next nonce 5, tx nonce 4", tooLow: true},
+		{msg: "replacement transaction underpriced"},
+		{msg: "insufficient funds for gas * price + value"},
+	}
+	for _, tt := range tests {
+		err := errors.New(tt.msg)
+		if got := isKnownTx(err); got != tt.known {
+			t.Errorf("isKnownTx(%q) = %v", tt.msg, got)
+		}
+		if got := isNonceTooLow(err); got != tt.tooLow {
+			t.Errorf("isNonceTooLow(%q) = %v", tt.msg, got)
+		}
+	}
+}
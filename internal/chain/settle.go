@@ -0,0 +1,166 @@
+// internal/chain/settle.go
+package chain
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"time"
+
+	"slowdrip-miner/internal/receipts"
+
+	"github.com/rs/zerolog"
+)
+
+// Settler takes closed batches from a receipts.Store through the payout contract:
+// it submits each batch root, waits for confirmation, then claims the batch and
+// waits again. Progress is recorded in the store (Batch.Anchor, Batch.Claim), so
+// a restart resumes where it left off instead of submitting a root twice.
+//
+// Batches signed by another key (before a rotation) are left for an offline claim
+// bundle: the contract would attribute them to the wrong sender. A batch whose
+// transaction reverts or is replaced is not retried until restart.
+type Settler struct {
+	c     *Client
+	store *receipts.Store
+	log   zerolog.Logger
+
+	mu      sync.Mutex
+	failed  map[uint64]string // batch ID -> last error
+	claimed uint64            // last batch claimed this run
+	lastErr string
+}
+
+// NewSettler returns a Settler for the batches in store.
+func NewSettler(c *Client, store *receipts.Store, log zerolog.Logger) *Settler {
+	return &Settler{
+		c:      c,
+		store:  store,
+		log:    log.With().Str("module", "settler").Logger(),
+		failed: make(map[uint64]string),
+	}
+}
+
+// Run settles outstanding batches every interval until ctx ends.
+func (s *Settler) Run(ctx context.Context, interval time.Duration) {
+	if interval <= 0 {
+		interval = time.Minute
+	}
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		if err := s.SettleOnce(ctx); err != nil && ctx.Err() == nil {
+			s.log.Error().Err(err).Msg("settler: pass failed")
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+		}
+	}
+}
+
+// SettleOnce walks the unclaimed batches oldest first and settles each one it can.
+// It stops at the first transport error (the node is probably unreachable).
+func (s *Settler) SettleOnce(ctx context.Context) error {
+	batches, err := s.store.Unclaimed()
+	if err != nil {
+		return err
+	}
+	me := s.c.signer.Address()
+	for _, b := range batches {
+		id := b.Header.ID
+		if !strings.EqualFold(b.Header.Miner, me.Hex()) {
+			continue
+		}
+		s.mu.Lock()
+		_, skip := s.failed[id]
+		s.mu.Unlock()
+		if skip {
+			continue
+		}
+		err := s.settle(ctx, b)
+		switch {
+		case err == nil:
+		case errors.Is(err, ErrReverted), errors.Is(err, ErrReplaced):
+			s.fail(id, err)
+		default:
+			s.setErr(err)
+			return err
+		}
+	}
+	return nil
+}
+
+// settle submits (unless already anchored) and claims one batch.
+func (s *Settler) settle(ctx context.Context, b *receipts.Batch) error {
+	h := b.Header
+	log := s.log.With().Uint64("batch", h.ID).Logger()
+	if b.Anchor == nil {
+		p, err := s.c.SubmitRoot(ctx, h.ID, h.Root, h.Count)
+		if err != nil {
+			return err
+		}
+		rcpt, err := s.c.WaitConfirmed(ctx, p)
+		if err != nil {
+			return err
+		}
+		if err := s.store.MarkAnchored(h.ID, rcpt.TxHash.Hex(), rcpt.BlockNumber.Uint64()); err != nil {
+			return err
+		}
+		log.Info().Str("audit", "batch_anchored").Str("tx", rcpt.TxHash.Hex()).Str("root", h.Root.Hex()).Msg("settler: root anchored")
+	}
+
+	p, err := s.c.Claim(ctx, h.ID)
+	if err != nil {
+		return err
+	}
+	rcpt, err := s.c.WaitConfirmed(ctx, p)
+	if err != nil {
+		return err
+	}
+	if err := s.store.MarkClaimed(h.ID, "chain", rcpt.TxHash.Hex()); err != nil {
+		return err
+	}
+	log.Info().Str("audit", "batch_claimed").Str("tx", rcpt.TxHash.Hex()).Msg("settler: batch claimed")
+	s.mu.Lock()
+	s.claimed, s.lastErr = h.ID, ""
+	s.mu.Unlock()
+	return nil
+}
+
+func (s *Settler) fail(id uint64, err error) {
+	s.log.Error().Err(err).Uint64("batch", id).Msg("settler: giving up on batch until restart")
+	s.mu.Lock()
+	s.failed[id] = err.Error()
+	s.lastErr = err.Error()
+	s.mu.Unlock()
+}
+
+func (s *Settler) setErr(err error) {
+	s.mu.Lock()
+	s.lastErr = err.Error()
+	s.mu.Unlock()
+}
+
+// SettlerStatus is the settler's state for /v1/status.
+type SettlerStatus struct {
+	LastClaimed uint64            `json:"last_claimed,omitempty"`
+	LastError   string            `json:"last_error,omitempty"`
+	Failed      map[uint64]string `json:"failed,omitempty"`
+}
+
+// Status returns a snapshot of the settler's progress.
+func (s *Settler) Status() SettlerStatus {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	st := SettlerStatus{LastClaimed: s.claimed, LastError: s.lastErr}
+	if len(s.failed) > 0 {
+		st.Failed = make(map[uint64]string, len(s.failed))
+		for k, v := range s.failed {
+			st.Failed[k] = v
+		}
+	}
+	return st
+}
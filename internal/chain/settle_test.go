@@ -0,0 +1,75 @@
+package chain
+
+import (
+	"testing"
+	"time"
+
+	"slowdrip-miner/internal/receipts"
+
+	"github.com/rs/zerolog"
+)
+
+func TestSettleOnce(t *testing.T) {
+	tc := newTestChain(t, 2, Options{ResendAfter: time.Hour})
+	ctx := waitCtx(t)
+	store, err := receipts.OpenStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	me, other := tc.keys[0].Address().Hex(), tc.keys[1].Address().Hex()
+	batches := []*receipts.Batch{
+		{Header: receipts.BatchHeader{Version: receipts.BatchVersion, ID: 1, Miner: me, Count: 1, Root: receipts.Hash{1}}},
+		// anchored before a restart: only the claim is left
+		{Header: receipts.BatchHeader{Version: receipts.BatchVersion, ID: 2, Miner: me, Count: 1, Root: receipts.Hash{2}},
+			Anchor: &receipts.AnchorInfo{Tx: "0xprev", Block: 1}},
+		// signed by the pre-rotation key: left for a bundle
+		{Header: receipts.BatchHeader{Version: receipts.BatchVersion, ID: 3, Miner: other, Count: 1, Root: receipts.Hash{3}}},
+		{Header: receipts.BatchHeader{Version: receipts.BatchVersion, ID: 4, Miner: me, Count: 1, Root: receipts.Hash{4}},
+			Claim: &receipts.ClaimInfo{By: "bundle"}},
+	}
+	for _, b := range batches {
+		if err := store.Save(b); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	stop := tc.mine()
+	err = NewSettler(tc.c, store, zerolog.Nop()).SettleOnce(ctx)
+	stop()
+	if err != nil {
+		t.Fatalf("SettleOnce: %v", err)
+	}
+
+	tests := []struct {
+		id        uint64
+		anchorTx  string // "" = any new tx, "-" = none
+		claimedBy string // "" = unclaimed
+	}{
+		{id: 1, claimedBy: "chain"},
+		{id: 2, anchorTx: "0xprev", claimedBy: "chain"},
+		{id: 3, anchorTx: "-"},
+		{id: 4, anchorTx: "-", claimedBy: "bundle"},
+	}
+	for _, tt := range tests {
+		b, err := store.Load(tt.id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		switch {
+		case tt.anchorTx == "-" && b.Anchor != nil:
+			t.Errorf("batch %d: anchored by %s", tt.id, b.Anchor.Tx)
+		case tt.anchorTx != "-" && b.Anchor == nil:
+			t.Errorf("batch %d: not anchored", tt.id)
+		case tt.anchorTx != "-" && tt.anchorTx != "" && b.Anchor.Tx != tt.anchorTx:
+			t.Errorf("batch %d: re-anchored (%s)", tt.id, b.Anchor.Tx)
+		}
+		switch {
+		case tt.claimedBy == "" && b.Claim != nil:
+			t.Errorf("batch %d: claimed by %s", tt.id, b.Claim.By)
+		case tt.claimedBy != "" && (b.Claim == nil || b.Claim.By != tt.claimedBy):
+			t.Errorf("batch %d: claim %+v, want by %s", tt.id, b.Claim, tt.claimedBy)
+		case tt.claimedBy == "chain" && b.Claim.Tx == "":
+			t.Errorf("batch %d: claim without tx", tt.id)
+		}
+	}
+}
@@ -0,0 +1,198 @@
+// internal/chain/tracker.go
+package chain
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	ethereum "github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/rs/zerolog"
+)
+
+// ErrReverted is returned by WaitConfirmed when the transaction was mined but failed.
+var ErrReverted = errors.New("chain: transaction reverted")
+
+// ErrReplaced is returned by WaitConfirmed when the transaction's nonce was used
+// by a transaction it did not send (another process with the same key, or a
+// manual replacement). The call may or may not have happened; check the contract.
+var ErrReplaced = errors.New("chain: nonce consumed by another transaction")
+
+// WaitConfirmed polls until p has opts.Confirmations blocks on top of it in the
+// canonical chain, then returns its receipt.
+//
+// Reorgs are handled by re-checking, on every poll, that the block the receipt
+// points at is still canonical; if it isn't (or the receipt disappears) the
+// confirmation count starts over. A transaction nobody has mined after
+// opts.ResendAfter is replaced by a copy with the same nonce and fees bumped by
+// bumpPct (or rebroadcast as-is once maxFeeGwei is reached). Every version sent is
+// watched, since any of them may be the one that gets mined.
+func (c *Client) WaitConfirmed(ctx context.Context, p *Pending) (*types.Receipt, error) {
+	log := c.log.With().Str("tx", p.Tx.Hash().Hex()).Str("method", p.Method).Uint64("nonce", p.Tx.Nonce()).Logger()
+
+	t := time.NewTicker(c.opts.PollInterval)
+	defer t.Stop()
+
+	lastSeen := p.SentAt
+	nonceGone := false
+	var included *types.Receipt
+	for {
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-t.C:
+		}
+
+		rcpt, err := c.findReceipt(ctx, p)
+		switch {
+		case errors.Is(err, ethereum.NotFound):
+			if nonceGone {
+				// The node said the nonce is used, and a poll later none of our
+				// versions has a receipt: someone else's transaction took it.
+				log.Error().Msg("chain: nonce used by a different transaction")
+				return nil, fmt.Errorf("%w: %s", ErrReplaced, p.Tx.Hash().Hex())
+			}
+			if included != nil {
+				log.Warn().Str("block", included.BlockHash.Hex()).Msg("chain: transaction reorged out; waiting for re-inclusion")
+				included = nil
+				lastSeen = time.Now()
+			}
+			if time.Since(lastSeen) > c.opts.ResendAfter {
+				nonceGone = c.resend(ctx, p, &log)
+				lastSeen = time.Now()
+			}
+			continue
+		case err != nil:
+			log.Debug().Err(err).Msg("chain: receipt lookup failed")
+			continue
+		}
+		nonceGone = false
+
+		// Is the receipt's block still on the canonical chain?
+		hdr, err := c.b.HeaderByNumber(ctx, rcpt.BlockNumber)
+		if err != nil {
+			log.Debug().Err(err).Msg("chain: header lookup failed")
+			continue
+		}
+		if hdr.Hash() != rcpt.BlockHash {
+			log.Warn().Str("stale_block", rcpt.BlockHash.Hex()).Msg("chain: receipt from non-canonical block; waiting")
+			included = nil
+			continue
+		}
+		if included == nil || included.BlockHash != rcpt.BlockHash {
+			log.Info().Str("block", rcpt.BlockHash.Hex()).Str("number", rcpt.BlockNumber.String()).Str("mined_tx", rcpt.TxHash.Hex()).Msg("chain: transaction included")
+		}
+		included = rcpt
+
+		head, err := c.b.BlockNumber(ctx)
+		if err != nil {
+			continue
+		}
+		n := rcpt.BlockNumber.Uint64()
+		if head < n || head-n+1 < c.opts.Confirmations {
+			continue
+		}
+
+		if rcpt.Status == types.ReceiptStatusFailed {
+			log.Error().Uint64("gas_used", rcpt.GasUsed).Msg("chain: transaction reverted")
+			return rcpt, fmt.Errorf("%w: %s", ErrReverted, rcpt.TxHash.Hex())
+		}
+		log.Info().Uint64("confirmations", head-n+1).Uint64("gas_used", rcpt.GasUsed).Msg("chain: transaction confirmed")
+		return rcpt, nil
+	}
+}
+
+// findReceipt returns the receipt of whichever version of p was mined, or
+// ethereum.NotFound if none was.
+func (c *Client) findReceipt(ctx context.Context, p *Pending) (*types.Receipt, error) {
+	for _, h := range p.hashes() {
+		rcpt, err := c.b.TransactionReceipt(ctx, h)
+		if errors.Is(err, ethereum.NotFound) {
+			continue
+		}
+		return rcpt, err
+	}
+	return nil, ethereum.NotFound
+}
+
+// resend replaces p.Tx with a fee-bumped copy, or rebroadcasts it unchanged when
+// it cannot be bumped. It reports whether the node says the nonce is already used.
+func (c *Client) resend(ctx context.Context, p *Pending, log *zerolog.Logger) (nonceGone bool) {
+	tx, err := c.bump(ctx, p)
+	if err != nil {
+		log.Warn().Err(err).Msg("chain: rebroadcasting without a fee bump")
+		tx = p.Tx
+	}
+	err = c.b.SendTransaction(ctx, tx)
+	switch {
+	case err == nil || isKnownTx(err):
+	case isNonceTooLow(err):
+		log.Warn().Msg("chain: nonce already used; checking whether one of ours was mined")
+		return true
+	default:
+		log.Warn().Err(err).Msg("chain: rebroadcast failed")
+		return false
+	}
+	if tx != p.Tx {
+		p.Replaced = append(p.Replaced, p.Tx)
+		p.Tx = tx
+		log.Info().Str("replacement", tx.Hash().Hex()).Str("tip", tx.GasTipCap().String()).Str("fee_cap", tx.GasFeeCap().String()).Msg("chain: rebroadcast with bumped fees")
+	} else {
+		log.Info().Msg("chain: rebroadcast")
+	}
+	return false
+}
+
+// isKnownTx matches the "already known" family of node errors on rebroadcast.
+func isKnownTx(err error) bool {
+	s := strings.ToLower(err.Error())
+	return strings.Contains(s, "already known") || strings.Contains(s, "known transaction")
+}
+
+// isNonceTooLow matches the node error for a nonce that is already mined.
+func isNonceTooLow(err error) bool {
+	return strings.Contains(strings.ToLower(err.Error()), "nonce too low")
+}
+
+// ErrNotFinal is returned by Confirmed for a transaction that is unknown, pending,
+// or has fewer than the required confirmations.
+var ErrNotFinal = errors.New("chain: transaction not final")
+
+// Confirmed checks, once, that hash was mined successfully in a canonical block
+// with at least confirmations blocks on top. It needs no signer, so it also
+// serves transactions sent elsewhere (claims submitted from a bundle).
+func Confirmed(ctx context.Context, b Backend, hash common.Hash, confirmations uint64) (*types.Receipt, error) {
+	rcpt, err := b.TransactionReceipt(ctx, hash)
+	if errors.Is(err, ethereum.NotFound) {
+		return nil, fmt.Errorf("%w: %s not mined", ErrNotFinal, hash.Hex())
+	}
+	if err != nil {
+		return nil, fmt.Errorf("chain: receipt %s: %w", hash.Hex(), err)
+	}
+	hdr, err := b.HeaderByNumber(ctx, rcpt.BlockNumber)
+	if err != nil {
+		return nil, fmt.Errorf("chain: header %s: %w", rcpt.BlockNumber, err)
+	}
+	if hdr.Hash() != rcpt.BlockHash {
+		return nil, fmt.Errorf("%w: %s is in a non-canonical block", ErrNotFinal, hash.Hex())
+	}
+	head, err := b.BlockNumber(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("chain: head: %w", err)
+	}
+	var have uint64
+	if n := rcpt.BlockNumber.Uint64(); head >= n {
+		have = head - n + 1
+	}
+	if have < confirmations {
+		return nil, fmt.Errorf("%w: %s has %d of %d confirmations", ErrNotFinal, hash.Hex(), have, confirmations)
+	}
+	if rcpt.Status == types.ReceiptStatusFailed {
+		return rcpt, fmt.Errorf("%w: %s", ErrReverted, hash.Hex())
+	}
+	return rcpt, nil
+}
@@ -2,11 +2,16 @@
 package config
 
 import (
+	"bytes"
 	"errors"
 	"fmt"
-	"io/ioutil"
+	"io"
 	"os"
+	"path/filepath"
+	"reflect"
 	"regexp"
+	"strconv"
+	"strings"
 	"time"
 
 	"gopkg.in/yaml.v3"
@@ -15,10 +20,12 @@ import (
 // Duration wraps time.Duration for YAML "1s"/"500ms" strings.
 type Duration struct{ time.Duration }
 
+// Errors are *yaml.TypeError so decoding carries on and every bad value in a
+// file is reported, not just the first.
 func (d *Duration) UnmarshalYAML(value *yaml.Node) error {
 	var s string
 	if err := value.Decode(&s); err != nil {
-		return fmt.Errorf("duration must be a string (e.g., \"2s\"): %w", err)
+		return typeError(value, "duration must be a string (e.g., \"2s\")")
 	}
 	// env expansion (rare, but supported)
 	s = expandEnvDefault(s)
@@ -28,12 +35,22 @@ func (d *Duration) UnmarshalYAML(value *yaml.Node) error {
 	}
 	dd, err := time.ParseDuration(s)
 	if err != nil {
-		return fmt.Errorf("invalid duration %q: %w", s, err)
+		return typeError(value, "invalid duration %q", s)
 	}
 	d.Duration = dd
 	return nil
 }
 
+// typeError reports a bad value at n the way yaml.v3 reports its own type errors.
+func typeError(n *yaml.Node, format string, args ...interface{}) error {
+	return &yaml.TypeError{Errors: []string{fmt.Sprintf("line %d: ", n.Line) + fmt.Sprintf(format, args...)}}
+}
+
+// MarshalYAML writes the duration back as a "2s"-style string.
+func (d Duration) MarshalYAML() (interface{}, error) {
+	return d.Duration.String(), nil
+}
+
 type Config struct {
 	LogLevel string `yaml:"logLevel"` // info | debug | warn | error
 
@@ -41,16 +58,88 @@ type Config struct {
 		ID     string `yaml:"id"`
 		Listen string `yaml:"listen"` // e.g., ":8080"
 		Region string `yaml:"region"`
+		TLS    TLS    `yaml:"tls"`
+
+		// HooksListen serves only the /hooks/ routes MediaMTX calls, over plain
+		// HTTP. Each checks its own token, so it is never pinned to loopback.
+		HooksListen string `yaml:"hooksListen"` // e.g. ":8090"; "" = hooks on listen only
 	} `yaml:"miner"`
 
 	MediaMTX struct {
 		API          string   `yaml:"api"`          // e.g., http://127.0.0.1:9997
 		PollInterval Duration `yaml:"pollInterval"` // e.g., "2s"
+
+		// The settings below render mediamtx.yml ("miner mediamtx render") and,
+		// with manage, are pushed to MediaMTX's config API at startup.
+		Manage      bool        `yaml:"manage"`      // apply them live via /v3/config/global/patch and /v3/config/paths
+		PublicHosts []string    `yaml:"publicHosts"` // IPs/hostnames advertised to WebRTC clients
+		ICEServers  []ICEServer `yaml:"iceServers"`  // STUN/TURN servers handed to WebRTC clients
+		Auth        struct {
+			Method      string `yaml:"method"`      // "" (MediaMTX default) | internal | http | jwt
+			JWKS        string `yaml:"jwks"`        // method=jwt: JWKS URL
+			JWTClaimKey string `yaml:"jwtClaimKey"` // claim holding the permissions, e.g. mediamtx_permissions
+			HTTPAddress string `yaml:"httpAddress"` // method=http: external auth endpoint
+		} `yaml:"auth"`
+		HLS struct {
+			Variant         string   `yaml:"variant"`         // mpegts | fmp4 | lowLatency
+			SegmentCount    int      `yaml:"segmentCount"`    // segments kept in the playlist
+			SegmentDuration Duration `yaml:"segmentDuration"` // e.g. "2s"
+			PartDuration    Duration `yaml:"partDuration"`    // lowLatency parts, e.g. "200ms"
+			AlwaysRemux     bool     `yaml:"alwaysRemux"`     // mux even without readers (instant start)
+			Directory       string   `yaml:"directory"`       // keep segments on disk here instead of in RAM (hlsDirectory)
+		} `yaml:"hls"`
+		Paths []MediaPath `yaml:"paths"` // path rules, matched in order; "~regex" names allowed
+
+		// Hooks make MediaMTX push path and reader changes (runOnReady, runOnNotReady,
+		// runOnRead, runOnUnread) to /hooks/mediamtx/event; polling stays as a fallback.
+		Hooks struct {
+			Enable  bool   `yaml:"enable"`  // render/apply the runOn* commands and accept hook events
+			Command string `yaml:"command"` // run by MediaMTX; "{event}" becomes ready|notready|read|unread, default "mtx-hook {event}"
+			Token   Secret `yaml:"token"`   // hook requests must carry it (Authorization: Bearer, or ?token=)
+		} `yaml:"hooks"`
+
+		// With manage, the miner reconciles MediaMTX's path configuration against
+		// paths: missing rules are added and drifted ones patched back.
+		ReconcileInterval Duration `yaml:"reconcileInterval"` // default 30s
+		PrunePaths        bool     `yaml:"prunePaths"`        // also delete paths configured outside miner.yaml
 	} `yaml:"mediamtx"`
 
+	// Admission answers MediaMTX's external auth hook (mediamtx.auth.method: http)
+	// and turns readers away once a path or the box is at its limits.
+	Admission struct {
+		Enable         bool            `yaml:"enable"`
+		HookToken      Secret          `yaml:"hookToken"`      // if set, MediaMTX must call the hook with ?token=<value>
+		Forward        string          `yaml:"forward"`        // auth endpoint that must allow a request first; "" = anyone may publish and read
+		AllowActions   []string        `yaml:"allowActions"`   // api | metrics | pprof admitted without forward; default none
+		MaxReaders     int             `yaml:"maxReaders"`     // across all paths, 0 = unlimited
+		MaxEgressMbps  float64         `yaml:"maxEgressMbps"`  // across all paths, 0 = unlimited
+		MaxPerIdentity int             `yaml:"maxPerIdentity"` // concurrent reads per user (or IP when anonymous), 0 = unlimited
+		Grace          Duration        `yaml:"grace"`          // an admitted reader counts this long before the watcher sees it, default 10s
+		Paths          []AdmissionPath `yaml:"paths"`          // per-path limits, first match wins; "~regex" names allowed
+	} `yaml:"admission"`
+
+	// Policy polls MediaMTX's sessions and kicks readers that break a rule.
+	// Every action is audited.
+	Policy struct {
+		Enable             bool     `yaml:"enable"`
+		Interval           Duration `yaml:"interval"`           // session poll interval, default 5s
+		DryRun             bool     `yaml:"dryRun"`             // audit what would be kicked, kick nothing
+		MaxSessionMbps     float64  `yaml:"maxSessionMbps"`     // per-reader egress ceiling, 0 = off
+		Sustain            Duration `yaml:"sustain"`            // ...exceeded for this long, default 15s
+		MaxSessionDuration Duration `yaml:"maxSessionDuration"` // 0 = off
+		MaxIPsPerToken     int      `yaml:"maxIPsPerToken"`     // a token read from more IPs at once is replayed; 0 = off
+		TokenParams        []string `yaml:"tokenParams"`        // query parameters holding reader tokens, default [jwt, token]
+		BanFor             Duration `yaml:"banFor"`             // a kicked IP is refused and kicked again this long; 0 = kick only
+		ThrottleFor        Duration `yaml:"throttleFor"`        // before a ban: a kicked IP may read throttleReaders streams this long; 0 = ban at once
+		ThrottleReaders    int      `yaml:"throttleReaders"`    // concurrent reads of a throttled IP, default 1
+		Exempt             []string `yaml:"exempt"`             // CIDRs never kicked
+		AuditFile          string   `yaml:"auditFile"`          // append actions as JSON lines; "" = log only
+	} `yaml:"policy"`
+
 	Metrics struct {
 		Enable bool   `yaml:"enable"`
 		Path   string `yaml:"path"` // e.g., "/metrics"
+		Role   string `yaml:"role"` // role scrapers need with admin auth on: public | viewer (default) | operator | admin
 	} `yaml:"metrics"`
 
 	Presence struct {
@@ -59,39 +148,260 @@ type Config struct {
 
 	Service struct {
 		Enable bool `yaml:"enable"`
+
+		// Segments observes MediaMTX's HLS output and turns every new segment into
+		// a receipt committing to its bytes (needs service.enable).
+		Segments struct {
+			Enable   bool     `yaml:"enable"`
+			HLS      string   `yaml:"hls"`      // MediaMTX HLS server, default http://127.0.0.1:8888; user:pass@ for basic auth
+			Dir      string   `yaml:"dir"`      // mediamtx.hls.directory as seen by the miner: read segments there instead of fetching them
+			Interval Duration `yaml:"interval"` // playlist poll, default 1s
+			Paths    []string `yaml:"paths"`    // paths to observe, "~regex" allowed; empty = every ready path
+
+			MaxSegmentMB int `yaml:"maxSegmentMB"` // larger segments are not committed, default 64
+
+			// FEC splits each segment into data+parity Reed-Solomon shards and
+			// commits to their Merkle root; any "data" shards rebuild it.
+			FEC struct {
+				Data   int `yaml:"data"`   // k, 0 = off
+				Parity int `yaml:"parity"` // m
+				Keep   int `yaml:"keep"`   // recent segments per path served on /v1/shards, default 8
+			} `yaml:"fec"`
+		} `yaml:"segments"`
 	} `yaml:"service"`
+
+	Receipts struct {
+		Dir           string   `yaml:"dir"`           // batch store; "" = receipts are not batched
+		BatchInterval Duration `yaml:"batchInterval"` // close a batch this often, default 5m
+		MaxBatch      int      `yaml:"maxBatch"`      // ...or once it holds this many receipts, default 10000
+	} `yaml:"receipts"`
+
+	// Recordings sets how MediaMTX records paths (mediamtx.paths[].record), and
+	// makes the miner index, hash and expire the recorded segments.
+	Recordings struct {
+		Enable          bool     `yaml:"enable"`
+		Dir             string   `yaml:"dir"`             // recordings root as seen by the miner
+		MediaMTXDir     string   `yaml:"mediamtxDir"`     // the same directory as MediaMTX sees it, default dir
+		Format          string   `yaml:"format"`          // fmp4 (default) | mpegts
+		SegmentDuration Duration `yaml:"segmentDuration"` // one file per this much media, default 10m
+		MaxAge          Duration `yaml:"maxAge"`          // delete segments older than this; 0 = keep
+		MaxSizeGB       float64  `yaml:"maxSizeGB"`       // delete the oldest segments beyond this total; 0 = unlimited
+		ScanInterval    Duration `yaml:"scanInterval"`    // index and retention pass, default 30s
+		AttestEvery     Duration `yaml:"attestEvery"`     // re-hash kept segments into new receipts this often; 0 = once
+		IndexFile       string   `yaml:"indexFile"`       // hashes survive restarts here, default <dir>/.index.json
+	} `yaml:"recordings"`
+
+	Wallet struct {
+		Source        string   `yaml:"source"`        // "" (disabled) | env | keystore-file | generate
+		Env           string   `yaml:"env"`           // env var holding a hex key (source=env)
+		KeystorePath  string   `yaml:"keystorePath"`  // keystore JSON file (keystore-file, generate)
+		PasswordFile  string   `yaml:"passwordFile"`  // keystore password, read from a file...
+		PasswordEnv   string   `yaml:"passwordEnv"`   // ...or from an env var...
+		Password      Secret   `yaml:"password"`      // ...or given as a secret (file:, env:, sealed:)
+		ChainID       int64    `yaml:"chainId"`       // EVM chain ID used for signing domains
+		AllowGenerate bool     `yaml:"allowGenerate"` // source=generate may create a key on first boot
+		EpochLength   Duration `yaml:"epochLength"`   // epoch size for key handovers, e.g. "1h"
+
+		Remote struct {
+			URL             string   `yaml:"url"`             // external signer JSON-RPC, e.g. http://127.0.0.1:8550
+			Address         string   `yaml:"address"`         // account held by the signer
+			Timeout         Duration `yaml:"timeout"`         // per request (signers may wait for approval)
+			TypedDataMethod string   `yaml:"typedDataMethod"` // account_signTypedData | eth_signTypedData
+		} `yaml:"remote"`
+
+		PKCS11 struct {
+			Module     string `yaml:"module"`     // PKCS#11 library, e.g. /usr/lib/softhsm/libsofthsm2.so
+			TokenLabel string `yaml:"tokenLabel"` // "" = first token
+			KeyLabel   string `yaml:"keyLabel"`
+			KeyID      string `yaml:"keyID"`   // hex CKA_ID
+			PINFile    string `yaml:"pinFile"` // user PIN from a file...
+			PINEnv     string `yaml:"pinEnv"`  // ...or an env var...
+			PIN        Secret `yaml:"pin"`     // ...or a secret (file:, env:, sealed:)
+		} `yaml:"pkcs11"`
+	} `yaml:"wallet"`
+
+	Chain struct {
+		Enable        bool     `yaml:"enable"`
+		RPC           string   `yaml:"rpc"`           // execution client JSON-RPC, http(s):// or ws(s)://
+		Contract      string   `yaml:"contract"`      // payout contract address
+		ABIFile       string   `yaml:"abiFile"`       // "" = built-in submitRoot/claim ABI
+		SubmitMethod  string   `yaml:"submitMethod"`  // default "submitRoot"
+		ClaimMethod   string   `yaml:"claimMethod"`   // default "claim"
+		Confirmations uint64   `yaml:"confirmations"` // blocks before a tx is final, default 12
+		GasBufferPct  uint64   `yaml:"gasBufferPct"`  // headroom over eth_estimateGas, default 20
+		MaxTipGwei    uint64   `yaml:"maxTipGwei"`    // 0 = no cap
+		MaxFeeGwei    uint64   `yaml:"maxFeeGwei"`    // 0 = no cap
+		PollInterval  Duration `yaml:"pollInterval"`  // receipt polling, default 4s
+		ResendAfter   Duration `yaml:"resendAfter"`   // rebroadcast an unmined tx after, default 2m
+	} `yaml:"chain"`
+
+	Admin struct {
+		Auth struct {
+			Enable  bool          `yaml:"enable"`  // false = every request is treated as admin (dev only)
+			Tokens  []AdminToken  `yaml:"tokens"`  // static bearer tokens
+			Signers []AdminSigner `yaml:"signers"` // EIP-191 signed requests
+			Certs   []AdminCert   `yaml:"certs"`   // mTLS client certificates
+			MaxSkew Duration      `yaml:"maxSkew"` // allowed clock skew for signed requests, e.g. "30s"
+		} `yaml:"auth"`
+	} `yaml:"admin"`
+
+	Secrets struct {
+		HostKeyFile string `yaml:"hostKeyFile"` // X25519 key that opens sealed: values ("miner config host-key")
+	} `yaml:"secrets"`
+
+	sources map[string]origin // key -> where the value came from, set by Load
+	files   []string          // files merged by Load, lowest precedence first
+}
+
+// TLS configures HTTPS (and optionally mTLS) for the admin API.
+type TLS struct {
+	Enable            bool     `yaml:"enable"`
+	CertFile          string   `yaml:"certFile"`
+	KeyFile           string   `yaml:"keyFile"`
+	ReloadInterval    Duration `yaml:"reloadInterval"`    // how often cert/key files are checked for changes
+	MinVersion        string   `yaml:"minVersion"`        // "1.2" | "1.3"
+	ClientCAFile      string   `yaml:"clientCAFile"`      // enables client certificate verification (mTLS)
+	RequireClientCert bool     `yaml:"requireClientCert"` // reject handshakes without a verified client cert
+	SelfSigned        bool     `yaml:"selfSigned"`        // dev only: generate an in-memory certificate
+	Plaintext         string   `yaml:"plaintext"`         // refuse | redirect (what plaintextListen does)
+	PlaintextListen   string   `yaml:"plaintextListen"`   // optional plain HTTP listener, e.g. ":8081"
+}
+
+// ICEServer is a STUN or TURN server for WebRTC clients.
+type ICEServer struct {
+	URL        string `yaml:"url"`        // stun:host:port | turn(s):host:port?transport=udp
+	Username   string `yaml:"username"`   // TURN long-term credentials
+	Password   Secret `yaml:"password"`   // inline, or file:/env:/sealed: reference
+	ClientOnly bool   `yaml:"clientOnly"` // only handed to clients, not used by MediaMTX itself
 }
 
-// Load reads, environment-expands, parses YAML, applies defaults, and validates.
+// MediaPath is a MediaMTX path rule.
+type MediaPath struct {
+	Name           string   `yaml:"name"`           // path name, or "~regex"
+	Source         string   `yaml:"source"`         // publisher (default) | rtsp://… | rtmp://… | redirect | …
+	SourceOnDemand bool     `yaml:"sourceOnDemand"` // pull the source only while someone reads
+	MaxReaders     int      `yaml:"maxReaders"`     // 0 = unlimited
+	Record         bool     `yaml:"record"`         // record to disk (MediaMTX recordPath)
+	Auth           PathAuth `yaml:"auth"`           // needs mediamtx.auth.method: internal
+}
+
+// PathAuth restricts who may publish to or read from a path. Empty users leave
+// that action open to anyone.
+type PathAuth struct {
+	PublishUser string `yaml:"publishUser"`
+	PublishPass Secret `yaml:"publishPass"`
+	ReadUser    string `yaml:"readUser"`
+	ReadPass    Secret `yaml:"readPass"`
+}
+
+// AdmissionPath limits readers of the paths matching Name (0 = unlimited).
+type AdmissionPath struct {
+	Name           string  `yaml:"name"` // path name, or "~regex"
+	MaxReaders     int     `yaml:"maxReaders"`
+	MaxEgressMbps  float64 `yaml:"maxEgressMbps"`
+	MaxPerIdentity int     `yaml:"maxPerIdentity"`
+}
+
+// AdminToken grants a role to callers presenting "Authorization: Bearer <token>".
+type AdminToken struct {
+	Name  string `yaml:"name"`  // label used in audit logs (never the token itself)
+	Token Secret `yaml:"token"` // inline, or file:/env:/sealed: reference
+	Role  string `yaml:"role"`  // viewer | operator | admin
+}
+
+// AdminSigner grants a role to requests signed (EIP-191) by an EVM address.
+type AdminSigner struct {
+	Address string `yaml:"address"` // 0x-prefixed EVM address
+	Role    string `yaml:"role"`
+}
+
+// AdminCert grants a role to mTLS clients whose certificate CN matches.
+type AdminCert struct {
+	CommonName string `yaml:"commonName"`
+	Role       string `yaml:"role"`
+}
+
+// Load reads path (with its includes and MINER_PROFILE overlays, see
+// loadDocument), environment-expands, applies defaults, resolves secrets, and validates.
 func Load(path string) (*Config, error) {
-	b, err := ioutil.ReadFile(path)
+	// Each file is strictly decoded on its own: unknown keys are errors so typos
+	// like "pollIntervall" don't pass silently. Strings may still contain ${} tokens.
+	doc, err := loadDocument(path, profilesFromEnv())
 	if err != nil {
-		return nil, fmt.Errorf("read config %s: %w", path, err)
+		return nil, err
 	}
-
-	// First pass: basic YAML → struct (strings may still contain ${} tokens)
 	var cfg Config
-	if err := yaml.Unmarshal(b, &cfg); err != nil {
-		return nil, fmt.Errorf("parse yaml: %w", err)
+	if doc.root != nil {
+		// Type errors were already collected, with their files, in doc.problems.
+		var te *yaml.TypeError
+		if err := doc.root.Decode(&cfg); err != nil && !(errors.As(err, &te) && len(doc.problems) > 0) {
+			return nil, fmt.Errorf("parse %s: %w", path, err)
+		}
 	}
+	cfg.files = doc.files
 
-	// Expand environment variables (with defaults) on known string fields.
-	cfg.LogLevel = expandEnvDefault(cfg.LogLevel)
+	// Expand ${VAR} / ${VAR:default} in every string field, then let
+	// MINER_<SECTION>_<FIELD> variables override any field.
+	expandAllEnv(reflect.ValueOf(&cfg).Elem())
+	fromEnv, err := applyEnvOverlay(&cfg, os.LookupEnv)
+	if err != nil {
+		return nil, err
+	}
 
-	cfg.Miner.ID = expandEnvDefault(cfg.Miner.ID)
-	cfg.Miner.Listen = expandEnvDefault(cfg.Miner.Listen)
-	cfg.Miner.Region = expandEnvDefault(cfg.Miner.Region)
+	pre := cfg
+	cloneLists(reflect.ValueOf(&pre).Elem())
+	applyDefaults(&cfg)
+	recordSources(&cfg, &pre, doc, fromEnv)
 
-	cfg.MediaMTX.API = expandEnvDefault(cfg.MediaMTX.API)
+	// Secret fields (tokens, passwords, PINs) may be file:, env: or sealed:
+	// references; resolve them last so the overlay can point them elsewhere.
+	if sp := resolveSecrets(&cfg); len(sp) > 0 {
+		return nil, append(doc.problems, sp...).err(doc)
+	}
 
-	cfg.Metrics.Path = expandEnvDefault(cfg.Metrics.Path)
+	// Unknown keys and bad values are reported together with validation.
+	if err := validate(&cfg, doc, doc.problems); err != nil {
+		return nil, err
+	}
+	return &cfg, nil
+}
 
-	applyDefaults(&cfg)
+var (
+	unknownFieldRe = regexp.MustCompile(`^line (\d+): field (\S+) not found in type .*$`)
+	typeErrorRe    = regexp.MustCompile(`^line (\d+): (.*)$`)
+)
 
-	if err := validate(&cfg); err != nil {
+// decodeStrict decodes with unknown-key detection. Unknown keys and values of
+// the wrong type are returned as problems located in root (the same file parsed
+// as a node tree), e.g. `line 12: miner.presense: unknown key`, so they can be
+// reported together with validation. Syntax errors are returned as err.
+func decodeStrict(b []byte, root *yaml.Node, out interface{}) (problems, error) {
+	dec := yaml.NewDecoder(bytes.NewReader(b))
+	dec.KnownFields(true)
+	err := dec.Decode(out)
+	if err == io.EOF { // empty file: let validation report what's missing
+		return nil, nil
+	}
+	var te *yaml.TypeError
+	if !errors.As(err, &te) {
 		return nil, err
 	}
-	return &cfg, nil
+	out2 := make(problems, 0, len(te.Errors))
+	for _, m := range te.Errors {
+		pr := Problem{Msg: m}
+		if sm := unknownFieldRe.FindStringSubmatch(m); sm != nil {
+			pr.Line, _ = strconv.Atoi(sm[1])
+			pr.Key, pr.Msg = keyAt(root, pr.Line, sm[2]), "unknown key"
+			if pr.Key == "" {
+				pr.Key = sm[2]
+			}
+		} else if sm := typeErrorRe.FindStringSubmatch(m); sm != nil {
+			pr.Line, _ = strconv.Atoi(sm[1])
+			pr.Key, pr.Msg = keyAt(root, pr.Line, ""), sm[2]
+		}
+		out2 = append(out2, pr)
+	}
+	return out2, nil
 }
 
 func applyDefaults(c *Config) {
@@ -108,26 +418,113 @@ func applyDefaults(c *Config) {
 	if c.MediaMTX.PollInterval.Duration == 0 {
 		c.MediaMTX.PollInterval = Duration{Duration: 2 * time.Second}
 	}
+	if c.MediaMTX.ReconcileInterval.Duration == 0 {
+		c.MediaMTX.ReconcileInterval = Duration{Duration: 30 * time.Second}
+	}
+	if c.MediaMTX.Hooks.Command == "" {
+		c.MediaMTX.Hooks.Command = "mtx-hook {event}"
+	}
+	for i := range c.MediaMTX.Paths {
+		if c.MediaMTX.Paths[i].Source == "" {
+			c.MediaMTX.Paths[i].Source = "publisher"
+		}
+	}
+	if c.Admission.Grace.Duration == 0 {
+		c.Admission.Grace = Duration{Duration: 10 * time.Second}
+	}
+	if c.Policy.Interval.Duration == 0 {
+		c.Policy.Interval = Duration{Duration: 5 * time.Second}
+	}
+	if c.Policy.Sustain.Duration == 0 {
+		c.Policy.Sustain = Duration{Duration: 15 * time.Second}
+	}
+	if c.Policy.ThrottleReaders == 0 {
+		c.Policy.ThrottleReaders = 1
+	}
+	if c.Policy.TokenParams == nil {
+		c.Policy.TokenParams = []string{"jwt", "token"}
+	}
+	if c.Service.Segments.HLS == "" {
+		c.Service.Segments.HLS = "http://127.0.0.1:8888"
+	}
+	if c.Service.Segments.Interval.Duration == 0 {
+		c.Service.Segments.Interval = Duration{Duration: time.Second}
+	}
+	if c.Service.Segments.MaxSegmentMB == 0 {
+		c.Service.Segments.MaxSegmentMB = 64
+	}
+	if c.Service.Segments.FEC.Keep == 0 {
+		c.Service.Segments.FEC.Keep = 8
+	}
 	if c.Metrics.Path == "" {
 		c.Metrics.Path = "/metrics"
 	}
-}
-
-func validate(c *Config) error {
-	if c.Miner.ID == "" {
-		return errors.New("miner.id is required")
+	if c.Metrics.Role == "" {
+		c.Metrics.Role = "viewer"
 	}
-	if c.Miner.Listen == "" {
-		return errors.New("miner.listen is required")
+	if c.Miner.TLS.MinVersion == "" {
+		c.Miner.TLS.MinVersion = "1.2"
 	}
-	if c.MediaMTX.API == "" {
-		return errors.New("mediamtx.api is required")
+	if c.Miner.TLS.ReloadInterval.Duration == 0 {
+		c.Miner.TLS.ReloadInterval = Duration{Duration: 30 * time.Second}
 	}
-	// simple sanity: reasonable poll interval
-	if c.MediaMTX.PollInterval.Duration < 200*time.Millisecond {
-		return fmt.Errorf("mediamtx.pollInterval too small: %s", c.MediaMTX.PollInterval.Duration)
+	if c.Miner.TLS.Plaintext == "" {
+		c.Miner.TLS.Plaintext = "refuse"
+	}
+	if c.Receipts.BatchInterval.Duration == 0 {
+		c.Receipts.BatchInterval = Duration{Duration: 5 * time.Minute}
+	}
+	if c.Receipts.MaxBatch == 0 {
+		c.Receipts.MaxBatch = 10000
+	}
+	if c.Recordings.MediaMTXDir == "" {
+		c.Recordings.MediaMTXDir = c.Recordings.Dir
+	}
+	if c.Recordings.Format == "" {
+		c.Recordings.Format = "fmp4"
+	}
+	if c.Recordings.SegmentDuration.Duration == 0 {
+		c.Recordings.SegmentDuration = Duration{Duration: 10 * time.Minute}
+	}
+	if c.Recordings.ScanInterval.Duration == 0 {
+		c.Recordings.ScanInterval = Duration{Duration: 30 * time.Second}
+	}
+	if c.Recordings.IndexFile == "" && c.Recordings.Dir != "" {
+		c.Recordings.IndexFile = filepath.Join(c.Recordings.Dir, ".index.json")
+	}
+	if c.Wallet.EpochLength.Duration == 0 {
+		c.Wallet.EpochLength = Duration{Duration: time.Hour}
+	}
+	if c.Wallet.Remote.Timeout.Duration == 0 {
+		c.Wallet.Remote.Timeout = Duration{Duration: 30 * time.Second}
+	}
+	if c.Wallet.Remote.TypedDataMethod == "" {
+		c.Wallet.Remote.TypedDataMethod = "account_signTypedData"
+	}
+	if c.Wallet.Source == "env" && c.Wallet.Env == "" {
+		c.Wallet.Env = "SLOWDRIP_MINER_KEY"
+	}
+	if c.Chain.SubmitMethod == "" {
+		c.Chain.SubmitMethod = "submitRoot"
+	}
+	if c.Chain.ClaimMethod == "" {
+		c.Chain.ClaimMethod = "claim"
+	}
+	if c.Chain.Confirmations == 0 {
+		c.Chain.Confirmations = 12
+	}
+	if c.Chain.GasBufferPct == 0 {
+		c.Chain.GasBufferPct = 20
+	}
+	if c.Chain.PollInterval.Duration == 0 {
+		c.Chain.PollInterval = Duration{Duration: 4 * time.Second}
+	}
+	if c.Chain.ResendAfter.Duration == 0 {
+		c.Chain.ResendAfter = Duration{Duration: 2 * time.Minute}
+	}
+	if c.Admin.Auth.MaxSkew.Duration == 0 {
+		c.Admin.Auth.MaxSkew = Duration{Duration: 30 * time.Second}
 	}
-	return nil
 }
 
 // --- env expansion with ${VAR} and ${VAR:default} ---
@@ -153,3 +550,36 @@ func expandEnvDefault(s string) string {
 		return def
 	})
 }
+
+// EnvRef is one ${VAR} / ${VAR:default} reference found in a config file.
+type EnvRef struct {
+	Name    string
+	Default string
+	Set     bool   // VAR is present in the environment
+	Value   string // what the reference expands to
+}
+
+// EnvRefs lists the env references in raw config bytes, in order of first use.
+// Commented-out lines are skipped.
+func EnvRefs(raw []byte) []EnvRef {
+	var out []EnvRef
+	seen := map[string]bool{}
+	var live []string
+	for _, l := range strings.Split(string(raw), "\n") {
+		if !strings.HasPrefix(strings.TrimSpace(l), "#") {
+			live = append(live, l)
+		}
+	}
+	for _, m := range envRe.FindAllStringSubmatch(strings.Join(live, "\n"), -1) {
+		if seen[m[1]] {
+			continue
+		}
+		seen[m[1]] = true
+		val, ok := os.LookupEnv(m[1])
+		if !ok {
+			val = m[2]
+		}
+		out = append(out, EnvRef{Name: m[1], Default: m[2], Set: ok, Value: val})
+	}
+	return out
+}
@@ -0,0 +1,305 @@
+// internal/config/layers.go
+package config
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// EnvProfile selects profile overlays: MINER_PROFILE=testnet loads
+// miner.testnet.yaml (next to miner.yaml) on top of miner.yaml.
+// Several profiles apply left to right: MINER_PROFILE=testnet,eu.
+const EnvProfile = "MINER_PROFILE"
+
+// includeKey lists fragments merged underneath the file that names them,
+// in order, with paths relative to that file.
+const includeKey = "include"
+
+var profileRe = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+// layerFile is what a single file may contain: any part of Config plus includes.
+type layerFile struct {
+	Include []string `yaml:"include"`
+	Config  `yaml:",inline"`
+}
+
+// document is the merged YAML of a base file, its includes and its profiles.
+type document struct {
+	root     *yaml.Node            // merged mapping; nil when every file is empty
+	files    []string              // files read, in merge order
+	from     map[*yaml.Node]string // node -> file it was read from
+	problems problems              // unknown keys and bad values, already located
+}
+
+// Merge order, lowest precedence first:
+//
+//	base includes, base, profile includes, profile (for each profile in order)
+//
+// Mappings merge key by key; scalars and lists replace what is below them, and an
+// explicit null (key: ~) removes the key so its default applies again.
+func loadDocument(path string, profiles []string) (*document, error) {
+	d := &document{from: map[*yaml.Node]string{}}
+	layers := []string{path}
+	for _, p := range profiles {
+		if !profileRe.MatchString(p) {
+			return nil, fmt.Errorf("%s: bad profile name %q", EnvProfile, p)
+		}
+		layers = append(layers, profilePath(path, p))
+	}
+	for _, f := range layers {
+		n, err := d.load(f, nil)
+		if err != nil {
+			return nil, err
+		}
+		d.root = mergeNodes(d.root, n)
+	}
+	dropNulls(d.root)
+	return d, nil
+}
+
+// profilePath maps ("configs/miner.yaml", "testnet") to "configs/miner.testnet.yaml".
+func profilePath(base, profile string) string {
+	ext := filepath.Ext(base)
+	return strings.TrimSuffix(base, ext) + "." + profile + ext
+}
+
+// profilesFromEnv splits MINER_PROFILE on commas.
+func profilesFromEnv() []string {
+	var out []string
+	for _, p := range strings.Split(os.Getenv(EnvProfile), ",") {
+		if p = strings.TrimSpace(p); p != "" {
+			out = append(out, p)
+		}
+	}
+	return out
+}
+
+// load reads one file with its includes already merged underneath it.
+// stack holds the files being included, to report cycles.
+func (d *document) load(path string, stack []string) (*yaml.Node, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return nil, err
+	}
+	for _, s := range stack {
+		if s == abs {
+			return nil, fmt.Errorf("include cycle: %s -> %s", strings.Join(stack, " -> "), abs)
+		}
+	}
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read config %s: %w", path, err)
+	}
+
+	var doc yaml.Node
+	if err := yaml.Unmarshal(b, &doc); err != nil {
+		return nil, fmt.Errorf("parse %s: %w", path, err)
+	}
+	// Strict-decode each file on its own, so unknown keys are reported against
+	// the file and line they are in. Only the includes are used from the result.
+	var lf layerFile
+	probs, err := decodeStrict(b, &doc, &lf)
+	if err != nil {
+		return nil, fmt.Errorf("parse %s: %w", path, err)
+	}
+	for _, pr := range probs {
+		pr.File = path
+		d.problems = append(d.problems, pr)
+	}
+	var root *yaml.Node
+	if doc.Kind == yaml.DocumentNode && len(doc.Content) > 0 {
+		root = doc.Content[0]
+	}
+	if root != nil && root.Kind != yaml.MappingNode {
+		return nil, fmt.Errorf("parse %s: top level must be a mapping", path)
+	}
+	d.mark(root, path)
+
+	var merged *yaml.Node
+	for _, inc := range lf.Include {
+		inc = expandEnvDefault(inc)
+		if !filepath.IsAbs(inc) {
+			inc = filepath.Join(filepath.Dir(path), inc)
+		}
+		n, err := d.load(inc, append(stack, abs))
+		if err != nil {
+			return nil, err
+		}
+		merged = mergeNodes(merged, n)
+	}
+	d.files = append(d.files, path)
+	return mergeNodes(merged, withoutKey(root, includeKey)), nil
+}
+
+// mark records which file every node under n came from.
+func (d *document) mark(n *yaml.Node, file string) {
+	if n == nil {
+		return
+	}
+	d.from[n] = file
+	for _, c := range n.Content {
+		d.mark(c, file)
+	}
+}
+
+// withoutKey drops key from a mapping node (in place).
+func withoutKey(m *yaml.Node, key string) *yaml.Node {
+	if m == nil {
+		return nil
+	}
+	for i := 0; i+1 < len(m.Content); i += 2 {
+		if m.Content[i].Value == key {
+			m.Content = append(m.Content[:i], m.Content[i+2:]...)
+			break
+		}
+	}
+	return m
+}
+
+// mergeNodes lays src over dst (both mappings or nil) and returns the result.
+// dst is modified; nodes are moved, not copied, so they keep their file and line.
+func mergeNodes(dst, src *yaml.Node) *yaml.Node {
+	if src == nil {
+		return dst
+	}
+	if dst == nil {
+		dst = &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+	}
+	for i := 0; i+1 < len(src.Content); i += 2 {
+		k, v := src.Content[i], resolveAlias(src.Content[i+1])
+		j := mappingIndex(dst, k.Value)
+		switch {
+		case isNull(v) && j >= 0:
+			dst.Content = append(dst.Content[:j], dst.Content[j+2:]...)
+		case j < 0:
+			// nulls are kept so they can still remove the key from layers merged
+			// later underneath this one; dropNulls clears them at the end
+			dst.Content = append(dst.Content, k, v)
+		case v.Kind == yaml.MappingNode && resolveAlias(dst.Content[j+1]).Kind == yaml.MappingNode:
+			dst.Content[j+1] = mergeNodes(resolveAlias(dst.Content[j+1]), v)
+		default:
+			dst.Content[j], dst.Content[j+1] = k, v
+		}
+	}
+	return dst
+}
+
+// dropNulls removes keys whose value is null, at any depth.
+func dropNulls(m *yaml.Node) {
+	if m == nil || m.Kind != yaml.MappingNode {
+		return
+	}
+	out := m.Content[:0]
+	for i := 0; i+1 < len(m.Content); i += 2 {
+		if isNull(m.Content[i+1]) {
+			continue
+		}
+		dropNulls(m.Content[i+1])
+		out = append(out, m.Content[i], m.Content[i+1])
+	}
+	m.Content = out
+}
+
+func mappingIndex(m *yaml.Node, key string) int {
+	for i := 0; i+1 < len(m.Content); i += 2 {
+		if m.Content[i].Value == key {
+			return i
+		}
+	}
+	return -1
+}
+
+func resolveAlias(n *yaml.Node) *yaml.Node {
+	for n.Kind == yaml.AliasNode && n.Alias != nil {
+		n = n.Alias
+	}
+	return n
+}
+
+func isNull(n *yaml.Node) bool {
+	return n.Kind == yaml.ScalarNode && n.ShortTag() == "!!null"
+}
+
+// bytes renders the merged document (comments included) for display.
+func (d *document) bytes() ([]byte, error) {
+	if d.root == nil {
+		return nil, nil
+	}
+	var buf bytes.Buffer
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(2)
+	if err := enc.Encode(d.root); err != nil {
+		return nil, err
+	}
+	err := enc.Close()
+	return buf.Bytes(), err
+}
+
+// redactNode replaces inline values of Secret fields (as typed by t) with
+// "<redacted>"; file:, env: and sealed: references are left as written.
+func redactNode(n *yaml.Node, t reflect.Type) {
+	if n == nil {
+		return
+	}
+	n = resolveAlias(n)
+	switch {
+	case t == secretType:
+		if n.Kind == yaml.ScalarNode && secretKind(n.Value) == "" && n.Value != "" {
+			n.Value, n.Style = redacted, 0
+		}
+	case t.Kind() == reflect.Slice && n.Kind == yaml.SequenceNode:
+		for _, c := range n.Content {
+			redactNode(c, t.Elem())
+		}
+	case t.Kind() == reflect.Struct && n.Kind == yaml.MappingNode:
+		for i := 0; i+1 < len(n.Content); i += 2 {
+			if f, ok := fieldByTag(t, n.Content[i].Value); ok {
+				redactNode(n.Content[i+1], f.Type)
+			}
+		}
+	}
+}
+
+// fieldByTag finds the field of t with the given yaml name.
+func fieldByTag(t reflect.Type, name string) (reflect.StructField, bool) {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.IsExported() && strings.Split(f.Tag.Get("yaml"), ",")[0] == name {
+			return f, true
+		}
+	}
+	return reflect.StructField{}, false
+}
+
+// fileOf reports the file n came from, or "" for single-file configs
+// (where messages keep their plain "line N" form).
+func (d *document) fileOf(n *yaml.Node) string {
+	if d == nil || n == nil || len(d.files) < 2 {
+		return ""
+	}
+	return d.from[n]
+}
+
+// Merged returns the merged YAML for path and MINER_PROFILE — before env
+// expansion, overlays and defaults — and the files it was built from, in
+// merge order. It is what "miner config print -merged" shows.
+func Merged(path string) ([]byte, []string, error) {
+	d, err := loadDocument(path, profilesFromEnv())
+	if err != nil {
+		return nil, nil, err
+	}
+	redactNode(d.root, reflect.TypeOf(Config{}))
+	b, err := d.bytes()
+	return b, d.files, err
+}
+
+// Files lists the config files Load merged, lowest precedence first.
+func (c *Config) Files() []string { return append([]string(nil), c.files...) }
@@ -0,0 +1,282 @@
+// internal/config/overlay.go
+package config
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"gopkg.in/yaml.v3"
+)
+
+// EnvPrefix starts every overlay variable: MINER_<SECTION>_<FIELD>, e.g.
+// MINER_METRICS_ENABLE=false or MINER_MEDIAMTX_POLL_INTERVAL=5s.
+const EnvPrefix = "MINER_"
+
+// Where a config value came from.
+const (
+	FromFile    = "file"
+	FromEnv     = "env"
+	FromDefault = "default"
+	FromUnset   = "unset"
+)
+
+// FieldSource describes one leaf of the effective config.
+type FieldSource struct {
+	Key    string // dotted yaml key, e.g. "mediamtx.pollInterval"
+	Env    string // overlay variable for the key
+	Source string // FromFile | FromEnv | FromDefault | FromUnset
+	File   string // for FromFile when several files were merged: which one set it
+	Value  string // effective value, for display
+}
+
+// Sources lists every config field with its overlay variable and origin, in key order.
+// Only configs returned by Load carry origins; for others every field is FromUnset.
+func (c *Config) Sources() []FieldSource {
+	var out []FieldSource
+	walkLeaves(reflect.ValueOf(c).Elem(), "", func(key string, f reflect.Value) {
+		o := c.sources[key]
+		if o.source == "" {
+			o.source = FromUnset
+		}
+		out = append(out, FieldSource{Key: key, Env: EnvName(key), Source: o.source, File: o.file, Value: displayValue(f)})
+	})
+	sort.Slice(out, func(i, j int) bool { return out[i].Key < out[j].Key })
+	return out
+}
+
+func displayValue(f reflect.Value) string {
+	switch {
+	case f.Type() == durationType:
+		return f.Interface().(Duration).String()
+	case f.Type() == secretType:
+		return f.Interface().(Secret).String()
+	case f.Kind() == reflect.Slice && f.Type().Elem().Kind() == reflect.Struct:
+		return fmt.Sprintf("[%d entries]", f.Len())
+	}
+	return fmt.Sprint(f.Interface())
+}
+
+// EnvName maps a dotted key to its overlay variable:
+// "miner.tls.clientCAFile" -> "MINER_MINER_TLS_CLIENT_CA_FILE".
+func EnvName(key string) string {
+	parts := strings.Split(key, ".")
+	for i, p := range parts {
+		parts[i] = upperSnake(p)
+	}
+	return EnvPrefix + strings.Join(parts, "_")
+}
+
+// upperSnake converts camelCase (with acronyms) to UPPER_SNAKE: "keyID" -> "KEY_ID",
+// "maxIPsPerToken" -> "MAX_IPS_PER_TOKEN".
+func upperSnake(s string) string {
+	r := []rune(s)
+	var b strings.Builder
+	for i, c := range r {
+		if i > 0 && unicode.IsUpper(c) {
+			prev := r[i-1]
+			nextLower := i+1 < len(r) && unicode.IsLower(r[i+1]) && !pluralAcronym(r, i)
+			if unicode.IsLower(prev) || unicode.IsDigit(prev) || (unicode.IsUpper(prev) && nextLower) {
+				b.WriteByte('_')
+			}
+		}
+		b.WriteRune(unicode.ToUpper(c))
+	}
+	return b.String()
+}
+
+// pluralAcronym reports whether r[i] is the last letter of an acronym followed
+// by a plural "s", as in "maxIPsPerToken": the "s" ends a word, not starts one.
+func pluralAcronym(r []rune, i int) bool {
+	if i+1 >= len(r) || r[i+1] != 's' || !unicode.IsUpper(r[i-1]) {
+		return false
+	}
+	return i+2 == len(r) || !unicode.IsLower(r[i+2])
+}
+
+// walkLeaves calls fn for every settable leaf under v: scalars, Durations,
+// Secrets and whole slices. Keys use the yaml tag names.
+func walkLeaves(v reflect.Value, key string, fn func(key string, f reflect.Value)) {
+	t := v.Type()
+	if t.Kind() != reflect.Struct || t == durationType || t == secretType {
+		fn(key, v)
+		return
+	}
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if !f.IsExported() {
+			continue
+		}
+		name := strings.Split(f.Tag.Get("yaml"), ",")[0]
+		if name == "" || name == "-" {
+			continue
+		}
+		walkLeaves(v.Field(i), joinKey(key, name), fn)
+	}
+}
+
+// expandAllEnv applies ${VAR} / ${VAR:default} expansion to every string in the
+// config, including strings inside lists.
+func expandAllEnv(v reflect.Value) {
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(expandEnvDefault(v.String()))
+	case reflect.Struct:
+		for i := 0; i < v.NumField(); i++ {
+			if v.Type().Field(i).IsExported() {
+				expandAllEnv(v.Field(i))
+			}
+		}
+	case reflect.Slice:
+		for i := 0; i < v.Len(); i++ {
+			expandAllEnv(v.Index(i))
+		}
+	}
+}
+
+// applyEnvOverlay sets every field whose MINER_* variable is present and
+// returns the keys it set. All parse failures are reported together.
+func applyEnvOverlay(c *Config, lookup func(string) (string, bool)) (map[string]bool, error) {
+	set := map[string]bool{}
+	var bad []string
+	walkLeaves(reflect.ValueOf(c).Elem(), "", func(key string, f reflect.Value) {
+		name := EnvName(key)
+		val, ok := lookup(name)
+		if !ok {
+			return
+		}
+		if val == "" && f.Kind() != reflect.String {
+			return // an empty non-string override means "not set" (common in k8s manifests)
+		}
+		if err := setFromString(f, val); err != nil {
+			bad = append(bad, fmt.Sprintf("%s (%s): %v", name, key, err))
+			return
+		}
+		set[key] = true
+	})
+	if len(bad) > 0 {
+		return nil, fmt.Errorf("env overlay: %s", strings.Join(bad, "; "))
+	}
+	return set, nil
+}
+
+// setFromString parses s according to f's type.
+func setFromString(f reflect.Value, s string) error {
+	if f.Type() == durationType {
+		d, err := time.ParseDuration(strings.TrimSpace(s))
+		if err != nil {
+			return err
+		}
+		f.Set(reflect.ValueOf(Duration{Duration: d}))
+		return nil
+	}
+	if f.Type() == secretType {
+		f.Set(reflect.ValueOf(Secret{ref: s}))
+		return nil
+	}
+	switch f.Kind() {
+	case reflect.String:
+		f.SetString(s)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(strings.TrimSpace(s))
+		if err != nil {
+			return fmt.Errorf("not a bool: %q", s)
+		}
+		f.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(strings.TrimSpace(s), 10, f.Type().Bits())
+		if err != nil {
+			return fmt.Errorf("not an integer: %q", s)
+		}
+		f.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(strings.TrimSpace(s), 10, f.Type().Bits())
+		if err != nil {
+			return fmt.Errorf("not an unsigned integer: %q", s)
+		}
+		f.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		n, err := strconv.ParseFloat(strings.TrimSpace(s), f.Type().Bits())
+		if err != nil {
+			return fmt.Errorf("not a number: %q", s)
+		}
+		f.SetFloat(n)
+	case reflect.Slice:
+		t := strings.TrimSpace(s)
+		// Plain comma lists for []string; anything else (or "[...]") is YAML flow syntax,
+		// e.g. MINER_ADMIN_AUTH_TOKENS='[{name: ops, token: x, role: admin}]'.
+		if f.Type().Elem().Kind() == reflect.String && !strings.HasPrefix(t, "[") {
+			var items []string
+			for _, it := range strings.Split(t, ",") {
+				if it = strings.TrimSpace(it); it != "" {
+					items = append(items, it)
+				}
+			}
+			f.Set(reflect.ValueOf(items).Convert(f.Type()))
+			return nil
+		}
+		nv := reflect.New(f.Type())
+		dec := yaml.NewDecoder(strings.NewReader(t))
+		dec.KnownFields(true)
+		if err := dec.Decode(nv.Interface()); err != nil {
+			return fmt.Errorf("not a YAML list: %v", err)
+		}
+		expandAllEnv(nv.Elem())
+		f.Set(nv.Elem())
+	default:
+		return fmt.Errorf("unsupported field type %s", f.Type())
+	}
+	return nil
+}
+
+// cloneLists gives every slice under v its own backing array, so a copy of a
+// Config made with plain assignment no longer shares list elements with the
+// original. Defaults filled into a list element then show up as a difference.
+func cloneLists(v reflect.Value) {
+	switch v.Kind() {
+	case reflect.Struct:
+		for i := 0; i < v.NumField(); i++ {
+			if v.Type().Field(i).IsExported() {
+				cloneLists(v.Field(i))
+			}
+		}
+	case reflect.Slice:
+		if v.IsNil() {
+			return
+		}
+		c := reflect.MakeSlice(v.Type(), v.Len(), v.Len())
+		reflect.Copy(c, v)
+		for i := 0; i < c.Len(); i++ {
+			cloneLists(c.Index(i))
+		}
+		v.Set(c)
+	}
+}
+
+// origin is where Load found one field.
+type origin struct {
+	source string // FromFile | FromEnv | FromDefault
+	file   string // for FromFile, when several files were merged
+}
+
+// recordSources fills c.sources by comparing the config before and after defaults.
+func recordSources(c *Config, pre *Config, doc *document, fromEnv map[string]bool) {
+	c.sources = map[string]origin{}
+	preLeaves := map[string]reflect.Value{}
+	walkLeaves(reflect.ValueOf(pre).Elem(), "", func(key string, f reflect.Value) { preLeaves[key] = f })
+	walkLeaves(reflect.ValueOf(c).Elem(), "", func(key string, f reflect.Value) {
+		n, inFile := findKey(doc.root, key)
+		switch {
+		case fromEnv[key]:
+			c.sources[key] = origin{source: FromEnv}
+		case !reflect.DeepEqual(preLeaves[key].Interface(), f.Interface()):
+			c.sources[key] = origin{source: FromDefault}
+		case inFile:
+			c.sources[key] = origin{source: FromFile, file: doc.fileOf(n)}
+		}
+	})
+}
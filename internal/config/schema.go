@@ -0,0 +1,144 @@
+// internal/config/schema.go
+package config
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+)
+
+// envRefPattern matches a value that still holds a ${VAR} reference; such values
+// are accepted wherever a constrained string is expected, since they expand at load.
+const envRefPattern = `\$\{[^}]+\}`
+
+// schemaEnums and schemaPatterns constrain string fields by dotted key.
+var schemaEnums = map[string][]string{
+	"logLevel":                      {"trace", "debug", "info", "warn", "warning", "error"},
+	"miner.tls.minVersion":          {"1.2", "1.3"},
+	"miner.tls.plaintext":           {"refuse", "redirect"},
+	"wallet.source":                 {"", "env", "keystore-file", "generate", "remote", "pkcs11"},
+	"wallet.remote.typedDataMethod": {"account_signTypedData", "eth_signTypedData"},
+	"admin.auth.tokens.role":        {"viewer", "operator", "admin"},
+	"mediamtx.auth.method":          {"", "internal", "http", "jwt"},
+	"mediamtx.hls.variant":          {"", "mpegts", "fmp4", "lowLatency"},
+	"recordings.format":             {"", "fmp4", "mpegts"},
+	"admission.allowActions":        {"api", "metrics", "pprof"},
+	"admin.auth.signers.role":       {"viewer", "operator", "admin"},
+	"admin.auth.certs.role":         {"viewer", "operator", "admin"},
+	"metrics.role":                  {"public", "viewer", "operator", "admin"},
+}
+
+var schemaPatterns = map[string]string{
+	"miner.listen":               listenPattern,
+	"miner.tls.plaintextListen":  `^$|` + listenPattern,
+	"miner.hooksListen":          `^$|` + listenPattern,
+	"mediamtx.api":               `^https?://[^/]+`,
+	"metrics.path":               `^/`,
+	"wallet.remote.address":      `^$|^0x[0-9a-fA-F]{40}$`,
+	"chain.contract":             `^$|^0x[0-9a-fA-F]{40}$`,
+	"admin.auth.signers.address": `^0x[0-9a-fA-F]{40}$`,
+	"wallet.remote.url":          `^$|^https?://`,
+	"chain.rpc":                  `^$|^(https?|wss?)://|^/`,
+	"mediamtx.iceServers.url":    `^(stun|turns?):`,
+	"mediamtx.auth.jwks":         `^$|^https?://`,
+	"mediamtx.auth.httpAddress":  `^$|^https?://`,
+	"admission.forward":          `^$|^https?://`,
+	"service.segments.hls":       `^https?://`,
+	"wallet.pkcs11.keyID":        `^$|^(0x)?[0-9a-fA-F]+$`,
+	"wallet.env":                 `^$|^[A-Za-z_][A-Za-z0-9_]*$`,
+	"wallet.passwordEnv":         `^$|^[A-Za-z_][A-Za-z0-9_]*$`,
+	"wallet.pkcs11.pinEnv":       `^$|^[A-Za-z_][A-Za-z0-9_]*$`,
+	"chain.submitMethod":         `^[A-Za-z_][A-Za-z0-9_]*$`,
+	"chain.claimMethod":          `^[A-Za-z_][A-Za-z0-9_]*$`,
+}
+
+// listenPattern is host:port as checkHostPort accepts it: an empty host, a name
+// or IPv4 address, or a bracketed IPv6 address ("[::]:8080").
+const listenPattern = `^(\[[0-9A-Fa-f.]*:[0-9A-Fa-f:.]*(%[^\]]+)?\]|[^:\[\]]*):[0-9]{1,5}$`
+
+const durationPattern = `^([0-9]+(\.[0-9]+)?(ns|us|µs|ms|s|m|h))+$`
+
+var durationType = reflect.TypeOf(Duration{})
+
+// JSONSchema describes miner.yaml (draft 2020-12), generated from Config so it
+// can't drift: unknown keys are rejected just like the strict loader does.
+// Point editors at it with "# yaml-language-server: $schema=./miner.schema.json".
+func JSONSchema() ([]byte, error) {
+	root := schemaFor(reflect.TypeOf(Config{}), "")
+	root["properties"].(map[string]interface{})[includeKey] = map[string]interface{}{
+		"type":        "array",
+		"items":       map[string]interface{}{"type": "string"},
+		"description": "fragments merged underneath this file, paths relative to it",
+	}
+	root["$schema"] = "https://json-schema.org/draft/2020-12/schema"
+	root["$id"] = "https://slowdrip.network/schemas/miner.schema.json"
+	root["title"] = "slowdrip miner configuration (miner.yaml)"
+	return json.MarshalIndent(root, "", "  ")
+}
+
+func schemaFor(t reflect.Type, key string) map[string]interface{} {
+	if t == durationType {
+		return constrained(key, map[string]interface{}{"type": "string", "pattern": durationPattern})
+	}
+	if t == secretType {
+		return map[string]interface{}{
+			"type":        "string",
+			"description": "inline value, or a reference: file:/path, env:NAME or sealed:<base64>",
+		}
+	}
+	switch t.Kind() {
+	case reflect.Struct:
+		props := map[string]interface{}{}
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			name := strings.Split(f.Tag.Get("yaml"), ",")[0]
+			if name == "" || name == "-" || !f.IsExported() {
+				continue
+			}
+			props[name] = schemaFor(f.Type, joinKey(key, name))
+		}
+		return map[string]interface{}{
+			"type":                 "object",
+			"properties":           props,
+			"additionalProperties": false,
+		}
+	case reflect.Slice:
+		return map[string]interface{}{"type": "array", "items": schemaFor(t.Elem(), key)}
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return map[string]interface{}{"type": "integer"}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]interface{}{"type": "integer", "minimum": 0}
+	case reflect.Float32, reflect.Float64:
+		return map[string]interface{}{"type": "number"}
+	case reflect.String:
+		return constrained(key, map[string]interface{}{"type": "string"})
+	}
+	return map[string]interface{}{}
+}
+
+// constrained adds the enum/pattern for key, still allowing ${VAR} references.
+func constrained(key string, s map[string]interface{}) map[string]interface{} {
+	var rule map[string]interface{}
+	if e, ok := schemaEnums[key]; ok {
+		rule = map[string]interface{}{"enum": e}
+	} else if p, ok := schemaPatterns[key]; ok {
+		rule = map[string]interface{}{"pattern": p}
+	} else if p, ok := s["pattern"]; ok {
+		rule = map[string]interface{}{"pattern": p}
+	}
+	if rule == nil {
+		return s
+	}
+	delete(s, "pattern")
+	s["anyOf"] = []interface{}{rule, map[string]interface{}{"pattern": envRefPattern}}
+	return s
+}
+
+func joinKey(parent, name string) string {
+	if parent == "" {
+		return name
+	}
+	return parent + "." + name
+}
@@ -0,0 +1,307 @@
+// internal/config/secret.go
+package config
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdh"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"reflect"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Secret is a credential field. In miner.yaml it is written inline or as a reference:
+//
+//	file:/run/secrets/admin_token   contents of a file (trailing newline dropped)
+//	env:ADMIN_TOKEN                 an environment variable
+//	sealed:<base64>                 encrypted to the host key (see "miner config seal")
+//
+// Load resolves references. The resolved value is only available through Value:
+// marshaling and printing show the reference, or "<redacted>" for inline values.
+type Secret struct {
+	ref string // as written, after ${VAR} expansion
+	val string // resolved by Load
+}
+
+// Secret reference prefixes.
+const (
+	SecretFile   = "file:"
+	SecretEnv    = "env:"
+	SecretSealed = "sealed:"
+)
+
+const redacted = "<redacted>"
+
+var secretType = reflect.TypeOf(Secret{})
+
+// NewSecret returns an already-resolved inline secret (for tools and tests).
+func NewSecret(v string) Secret { return Secret{ref: v, val: v} }
+
+// IsSet reports whether the field was given at all.
+func (s Secret) IsSet() bool { return s.ref != "" }
+
+// Value returns the resolved secret.
+func (s Secret) Value() string { return s.val }
+
+// IsRef reports whether the field is a file:, env: or sealed: reference.
+func (s Secret) IsRef() bool { return secretKind(s.ref) != "" }
+
+// String is safe to log: references are shown as written, inline values are not.
+func (s Secret) String() string {
+	switch {
+	case s.ref == "":
+		return ""
+	case s.IsRef():
+		return s.ref
+	}
+	return redacted
+}
+
+func (s Secret) GoString() string { return fmt.Sprintf("config.Secret(%q)", s.String()) }
+
+func (s *Secret) UnmarshalYAML(value *yaml.Node) error {
+	var v string
+	if err := value.Decode(&v); err != nil {
+		return typeError(value, "secret must be a string")
+	}
+	*s = Secret{ref: expandEnvDefault(v)}
+	return nil
+}
+
+func (s Secret) MarshalYAML() (interface{}, error) { return s.String(), nil }
+
+func (s Secret) MarshalJSON() ([]byte, error) { return []byte(fmt.Sprintf("%q", s.String())), nil }
+
+func secretKind(ref string) string {
+	for _, k := range []string{SecretFile, SecretEnv, SecretSealed} {
+		if strings.HasPrefix(ref, k) {
+			return k
+		}
+	}
+	return ""
+}
+
+// resolveSecrets fills in every Secret in c. Sealed values need
+// secrets.hostKeyFile; the key is only read if one is present.
+func resolveSecrets(c *Config) problems {
+	var p problems
+	var hostKey *ecdh.PrivateKey
+	var hostKeyErr error
+	walkSecrets(reflect.ValueOf(c).Elem(), "", func(key string, s *Secret) {
+		if !s.IsSet() {
+			return
+		}
+		var err error
+		switch secretKind(s.ref) {
+		case SecretFile:
+			var b []byte
+			if b, err = os.ReadFile(strings.TrimPrefix(s.ref, SecretFile)); err == nil {
+				s.val = strings.TrimRight(string(b), "\r\n")
+			}
+		case SecretEnv:
+			name := strings.TrimPrefix(s.ref, SecretEnv)
+			v, ok := os.LookupEnv(name)
+			if !ok {
+				err = fmt.Errorf("env %s not set", name)
+			}
+			s.val = v
+		case SecretSealed:
+			if hostKey == nil && hostKeyErr == nil {
+				if c.Secrets.HostKeyFile == "" {
+					hostKeyErr = errors.New("sealed value needs secrets.hostKeyFile")
+				} else {
+					hostKey, hostKeyErr = LoadHostKey(c.Secrets.HostKeyFile)
+				}
+			}
+			if err = hostKeyErr; err == nil {
+				var b []byte
+				if b, err = unseal(hostKey, strings.TrimPrefix(s.ref, SecretSealed)); err == nil {
+					s.val = string(b)
+				}
+			}
+		default:
+			s.val = s.ref
+		}
+		switch {
+		case err != nil:
+			p.add(key, "%v", err)
+		case s.val == "":
+			p.add(key, "%s resolves to an empty value", s)
+		}
+	})
+	return p
+}
+
+// walkSecrets calls fn for every Secret under v, descending into lists;
+// keys look like "admin.auth.tokens[0].token".
+func walkSecrets(v reflect.Value, key string, fn func(key string, s *Secret)) {
+	switch {
+	case v.Type() == secretType:
+		fn(key, v.Addr().Interface().(*Secret))
+	case v.Kind() == reflect.Slice:
+		for i := 0; i < v.Len(); i++ {
+			walkSecrets(v.Index(i), fmt.Sprintf("%s[%d]", key, i), fn)
+		}
+	case v.Kind() == reflect.Struct && v.Type() != durationType:
+		t := v.Type()
+		for i := 0; i < t.NumField(); i++ {
+			name := strings.Split(t.Field(i).Tag.Get("yaml"), ",")[0]
+			if !t.Field(i).IsExported() || name == "" || name == "-" {
+				continue
+			}
+			walkSecrets(v.Field(i), joinKey(key, name), fn)
+		}
+	}
+}
+
+// ------------------------------------------------------------
+// Sealed values: X25519 to the host key, AES-256-GCM.
+// Layout (base64): version(1) | ephemeral public key(32) | nonce(12) | ciphertext.
+// ------------------------------------------------------------
+
+const (
+	sealVersion = 1
+	sealInfo    = "slowdrip-miner sealed secret v1"
+	hostKeyPEM  = "PRIVATE KEY"
+)
+
+// NewHostKey creates an X25519 host key at path (mode 0600, never overwritten)
+// and returns its public key, which is what "miner config seal" needs.
+func NewHostKey(path string) (string, error) {
+	k, err := ecdh.X25519().GenerateKey(rand.Reader)
+	if err != nil {
+		return "", err
+	}
+	der, err := x509.MarshalPKCS8PrivateKey(k)
+	if err != nil {
+		return "", err
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o600)
+	if err != nil {
+		return "", fmt.Errorf("host key: %w", err)
+	}
+	if err := pem.Encode(f, &pem.Block{Type: hostKeyPEM, Bytes: der}); err != nil {
+		f.Close()
+		return "", fmt.Errorf("host key: %w", err)
+	}
+	if err := f.Close(); err != nil {
+		return "", fmt.Errorf("host key: %w", err)
+	}
+	return EncodePublicKey(k.PublicKey()), nil
+}
+
+// LoadHostKey reads a PEM (PKCS#8) X25519 key written by NewHostKey.
+func LoadHostKey(path string) (*ecdh.PrivateKey, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("host key: %w", err)
+	}
+	blk, _ := pem.Decode(b)
+	if blk == nil || blk.Type != hostKeyPEM {
+		return nil, fmt.Errorf("host key %s: not a PEM private key", path)
+	}
+	k, err := x509.ParsePKCS8PrivateKey(blk.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("host key %s: %w", path, err)
+	}
+	xk, ok := k.(*ecdh.PrivateKey)
+	if !ok || xk.Curve() != ecdh.X25519() {
+		return nil, fmt.Errorf("host key %s: not an X25519 key", path)
+	}
+	return xk, nil
+}
+
+// EncodePublicKey formats a host public key as base64.
+func EncodePublicKey(pub *ecdh.PublicKey) string {
+	return base64.StdEncoding.EncodeToString(pub.Bytes())
+}
+
+// ParsePublicKey reverses EncodePublicKey.
+func ParsePublicKey(s string) (*ecdh.PublicKey, error) {
+	b, err := base64.StdEncoding.DecodeString(strings.TrimSpace(s))
+	if err != nil {
+		return nil, fmt.Errorf("host public key: %w", err)
+	}
+	pub, err := ecdh.X25519().NewPublicKey(b)
+	if err != nil {
+		return nil, fmt.Errorf("host public key: %w", err)
+	}
+	return pub, nil
+}
+
+// Seal encrypts plaintext to the host public key and returns a "sealed:..."
+// value for miner.yaml. Only the holder of the host key can open it.
+func Seal(pub *ecdh.PublicKey, plaintext []byte) (string, error) {
+	eph, err := ecdh.X25519().GenerateKey(rand.Reader)
+	if err != nil {
+		return "", err
+	}
+	shared, err := eph.ECDH(pub)
+	if err != nil {
+		return "", err
+	}
+	aead, err := sealAEAD(shared, eph.PublicKey().Bytes(), pub.Bytes())
+	if err != nil {
+		return "", err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	out := append([]byte{sealVersion}, eph.PublicKey().Bytes()...)
+	out = append(out, nonce...)
+	out = aead.Seal(out, nonce, plaintext, out[:1])
+	return SecretSealed + base64.StdEncoding.EncodeToString(out), nil
+}
+
+func unseal(k *ecdh.PrivateKey, b64 string) ([]byte, error) {
+	b, err := base64.StdEncoding.DecodeString(strings.TrimSpace(b64))
+	if err != nil {
+		return nil, fmt.Errorf("sealed value: %w", err)
+	}
+	if len(b) < 1+32+12 || b[0] != sealVersion {
+		return nil, errors.New("sealed value: unknown format")
+	}
+	ephBytes := b[1:33]
+	eph, err := ecdh.X25519().NewPublicKey(ephBytes)
+	if err != nil {
+		return nil, fmt.Errorf("sealed value: %w", err)
+	}
+	shared, err := k.ECDH(eph)
+	if err != nil {
+		return nil, fmt.Errorf("sealed value: %w", err)
+	}
+	aead, err := sealAEAD(shared, ephBytes, k.PublicKey().Bytes())
+	if err != nil {
+		return nil, err
+	}
+	nonce := b[33 : 33+aead.NonceSize()]
+	pt, err := aead.Open(nil, nonce, b[33+aead.NonceSize():], b[:1])
+	if err != nil {
+		return nil, errors.New("sealed value: cannot decrypt (sealed to a different host key?)")
+	}
+	return pt, nil
+}
+
+// sealAEAD derives the AES-256-GCM key from the shared secret and both public keys.
+func sealAEAD(shared, ephPub, hostPub []byte) (cipher.AEAD, error) {
+	h := sha256.New()
+	h.Write([]byte(sealInfo))
+	h.Write(shared)
+	h.Write(ephPub)
+	h.Write(hostPub)
+	block, err := aes.NewCipher(h.Sum(nil))
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
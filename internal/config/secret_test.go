@@ -0,0 +1,231 @@
+package config
+
+import (
+	"crypto/ecdh"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+func hostKey(t *testing.T) (path string, k *ecdh.PrivateKey) {
+	t.Helper()
+	path = filepath.Join(t.TempDir(), "host.key")
+	pub, err := NewHostKey(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	k, err = LoadHostKey(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if EncodePublicKey(k.PublicKey()) != pub {
+		t.Fatal("NewHostKey returned a different public key than it wrote")
+	}
+	return path, k
+}
+
+func TestSealRoundTrip(t *testing.T) {
+	_, k := hostKey(t)
+	pub, err := ParsePublicKey(EncodePublicKey(k.PublicKey()) + "\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, pt := range []string{"s3cret", "", strings.Repeat("x", 4096)} {
+		sealed, err := Seal(pub, []byte(pt))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.HasPrefix(sealed, SecretSealed) {
+			t.Fatalf("%q has no %s prefix", sealed, SecretSealed)
+		}
+		got, err := unseal(k, strings.TrimPrefix(sealed, SecretSealed))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(got) != pt {
+			t.Fatalf("unsealed %q, want %q", got, pt)
+		}
+	}
+}
+
+func TestUnsealRejects(t *testing.T) {
+	_, k := hostKey(t)
+	_, other := hostKey(t)
+	sealed, err := Seal(k.PublicKey(), []byte("s3cret"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	raw, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(sealed, SecretSealed))
+	if err != nil {
+		t.Fatal(err)
+	}
+	enc := base64.StdEncoding.EncodeToString
+	wrongVersion := append([]byte{sealVersion + 1}, raw[1:]...)
+	flipped := append([]byte(nil), raw...)
+	flipped[len(flipped)-1] ^= 1
+
+	tests := []struct {
+		name string
+		key  *ecdh.PrivateKey
+		b64  string
+	}{
+		{"wrong host key", other, enc(raw)},
+		{"wrong version", k, enc(wrongVersion)},
+		{"truncated header", k, enc(raw[:40])},
+		{"truncated ciphertext", k, enc(raw[:len(raw)-1])},
+		{"tampered ciphertext", k, enc(flipped)},
+		{"not base64", k, "!!!"},
+		{"empty", k, ""},
+	}
+	for _, tt := range tests {
+		if pt, err := unseal(tt.key, tt.b64); err == nil {
+			t.Errorf("%s: unsealed %q", tt.name, pt)
+		}
+	}
+}
+
+func TestLoadHostKeyRejects(t *testing.T) {
+	dir := t.TempDir()
+	path, _ := hostKey(t)
+	if _, err := NewHostKey(path); err == nil {
+		t.Error("NewHostKey overwrote an existing key")
+	}
+	notPEM := filepath.Join(dir, "junk")
+	os.WriteFile(notPEM, []byte("not a key"), 0o600)
+	for _, p := range []string{notPEM, filepath.Join(dir, "missing")} {
+		if _, err := LoadHostKey(p); err == nil {
+			t.Errorf("%s loaded", p)
+		}
+	}
+}
+
+func TestResolveSecrets(t *testing.T) {
+	keyFile, k := hostKey(t)
+	sealed, err := Seal(k.PublicKey(), []byte("from-seal"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	tokenFile := filepath.Join(dir, "token")
+	if err := os.WriteFile(tokenFile, []byte("from-file\r\n\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	emptyFile := filepath.Join(dir, "empty")
+	if err := os.WriteFile(emptyFile, []byte("\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("MINER_TEST_TOKEN", "from-env")
+
+	var c Config
+	c.Secrets.HostKeyFile = keyFile
+	c.Admin.Auth.Tokens = []AdminToken{
+		{Name: "file", Token: Secret{ref: SecretFile + tokenFile}},
+		{Name: "env", Token: Secret{ref: SecretEnv + "MINER_TEST_TOKEN"}},
+		{Name: "sealed", Token: Secret{ref: sealed}},
+		{Name: "inline", Token: Secret{ref: "inline-token"}},
+	}
+	if p := resolveSecrets(&c); len(p) != 0 {
+		t.Fatalf("problems: %v", p)
+	}
+	for i, want := range []string{"from-file", "from-env", "from-seal", "inline-token"} {
+		if got := c.Admin.Auth.Tokens[i].Token.Value(); got != want {
+			t.Errorf("tokens[%d] = %q, want %q", i, got, want)
+		}
+	}
+
+	var bad Config
+	bad.MediaMTX.Hooks.Token = Secret{ref: SecretEnv + "MINER_TEST_UNSET"}
+	bad.Admission.HookToken = Secret{ref: SecretFile + filepath.Join(dir, "missing")}
+	bad.Admin.Auth.Tokens = []AdminToken{
+		{Name: "empty", Token: Secret{ref: SecretFile + emptyFile}},
+		{Name: "sealed", Token: Secret{ref: sealed}}, // no secrets.hostKeyFile
+	}
+	p := resolveSecrets(&bad)
+	want := map[string]string{
+		"mediamtx.hooks.token":       "env MINER_TEST_UNSET not set",
+		"admission.hookToken":        "no such file",
+		"admin.auth.tokens[0].token": "resolves to an empty value",
+		"admin.auth.tokens[1].token": "needs secrets.hostKeyFile",
+	}
+	if len(p) != len(want) {
+		t.Fatalf("problems: %v", p)
+	}
+	for _, pr := range p {
+		if w, ok := want[pr.Key]; !ok || !strings.Contains(pr.Msg, w) {
+			t.Errorf("%s: %s", pr.Key, pr.Msg)
+		}
+	}
+}
+
+func TestSecretNeverShowsValue(t *testing.T) {
+	const value = "hunter2-resolved"
+	type holder struct {
+		Inline Secret `yaml:"inline" json:"inline"`
+		Env    Secret `yaml:"env" json:"env"`
+	}
+	h := holder{
+		Inline: Secret{ref: value, val: value},
+		Env:    Secret{ref: "env:ADMIN_TOKEN", val: value},
+	}
+
+	j, err := json.Marshal(h)
+	if err != nil {
+		t.Fatal(err)
+	}
+	y, err := yaml.Marshal(h)
+	if err != nil {
+		t.Fatal(err)
+	}
+	outputs := map[string]string{
+		"json": string(j),
+		"yaml": string(y),
+		"%v":   fmt.Sprintf("%v", h),
+		"%+v":  fmt.Sprintf("%+v", h),
+		"%#v":  fmt.Sprintf("%#v", h.Inline) + fmt.Sprintf("%#v", h.Env),
+		"%s":   h.Inline.String() + h.Env.String(),
+	}
+	for name, out := range outputs {
+		if strings.Contains(out, value) {
+			t.Errorf("%s shows the value: %s", name, out)
+		}
+	}
+	var fields map[string]string
+	if err := json.Unmarshal(j, &fields); err != nil {
+		t.Fatal(err)
+	}
+	if fields["inline"] != redacted || fields["env"] != "env:ADMIN_TOKEN" {
+		t.Errorf("json %s: want the reference and %s", j, redacted)
+	}
+	if !strings.Contains(string(y), redacted) || !strings.Contains(string(y), "env:ADMIN_TOKEN") {
+		t.Errorf("yaml %s: want the reference and %s", y, redacted)
+	}
+
+	// Unmarshaling keeps only the reference; nothing is resolved until Load.
+	var back holder
+	if err := yaml.Unmarshal(y, &back); err != nil {
+		t.Fatal(err)
+	}
+	if back.Env.Value() != "" || back.Env.String() != "env:ADMIN_TOKEN" {
+		t.Errorf("round trip: %#v", back.Env)
+	}
+}
+
+// Sealed values are randomised: the same plaintext never seals the same way twice.
+func TestSealIsRandomised(t *testing.T) {
+	k, err := ecdh.X25519().GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	a, _ := Seal(k.PublicKey(), []byte("same"))
+	b, _ := Seal(k.PublicKey(), []byte("same"))
+	if a == b {
+		t.Fatal("identical sealed values")
+	}
+}
@@ -0,0 +1,732 @@
+// internal/config/validate.go
+package config
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Problem is one validation failure, tied to the config key it concerns.
+type Problem struct {
+	Key  string // dotted path, e.g. "miner.tls.minVersion" or "admin.auth.tokens[0].role"
+	File string // set when several files were merged (includes, profiles)
+	Line int    // line in the config file, 0 if the key is not in the file (e.g. a default)
+	Msg  string
+}
+
+func (p Problem) String() string {
+	if p.Line > 0 && p.File != "" {
+		return fmt.Sprintf("%s:%d: %s: %s", p.File, p.Line, p.Key, p.Msg)
+	}
+	if p.Line > 0 {
+		return fmt.Sprintf("line %d: %s: %s", p.Line, p.Key, p.Msg)
+	}
+	return p.Key + ": " + p.Msg
+}
+
+// ValidationError reports every problem found, not just the first.
+type ValidationError struct {
+	Problems []Problem
+}
+
+func (e *ValidationError) Error() string {
+	if len(e.Problems) == 1 {
+		return "invalid config: " + e.Problems[0].String()
+	}
+	lines := make([]string, len(e.Problems))
+	for i, p := range e.Problems {
+		lines[i] = "  - " + p.String()
+	}
+	return fmt.Sprintf("invalid config: %d problems:\n%s", len(e.Problems), strings.Join(lines, "\n"))
+}
+
+// problems collects failures during validate.
+type problems []Problem
+
+func (p *problems) add(key, format string, args ...interface{}) {
+	*p = append(*p, Problem{Key: key, Msg: fmt.Sprintf(format, args...)})
+}
+
+// err resolves file and line numbers against the merged document (may be nil)
+// and returns nil when nothing was found.
+func (p problems) err(d *document) error {
+	if len(p) == 0 {
+		return nil
+	}
+	order := map[string]int{}
+	var root *yaml.Node
+	if d != nil {
+		root = d.root
+		for i, f := range d.files {
+			order[f] = i
+		}
+	}
+	out := make([]Problem, len(p))
+	for i, pr := range p {
+		// Decode problems arrive located: unknown keys are not in the merged document.
+		if n, _ := findKey(root, pr.Key); pr.Line == 0 && n != nil {
+			pr.File, pr.Line = d.fileOf(n), n.Line
+		} else if d == nil || len(d.files) < 2 {
+			pr.File = ""
+		}
+		out[i] = pr
+	}
+	sort.SliceStable(out, func(i, j int) bool {
+		// keys present in the files first, in merge order, then by line
+		li, lj := out[i].Line, out[j].Line
+		if li == 0 || lj == 0 {
+			return li != 0 && lj == 0
+		}
+		if fi, fj := order[out[i].File], order[out[j].File]; fi != fj {
+			return fi < fj
+		}
+		return li < lj
+	})
+	return &ValidationError{Problems: out}
+}
+
+// findKey walks doc along a dotted key ("a.b[2].c") and returns the deepest
+// node reached: the key itself when found, otherwise the closest enclosing key
+// that is present (nil if none).
+func findKey(doc *yaml.Node, key string) (n *yaml.Node, found bool) {
+	if doc == nil {
+		return nil, false
+	}
+	cur := doc
+	if cur.Kind == yaml.DocumentNode && len(cur.Content) > 0 {
+		cur = cur.Content[0]
+	}
+	for _, part := range strings.Split(key, ".") {
+		name, idx := part, -1
+		if i := strings.IndexByte(part, '['); i >= 0 && strings.HasSuffix(part, "]") {
+			name = part[:i]
+			idx, _ = strconv.Atoi(part[i+1 : len(part)-1])
+		}
+		cur = resolveAlias(cur)
+		if cur.Kind != yaml.MappingNode {
+			return n, false
+		}
+		j := mappingIndex(cur, name)
+		if j < 0 {
+			return n, false
+		}
+		n, cur = cur.Content[j], cur.Content[j+1]
+		if idx >= 0 {
+			cur = resolveAlias(cur)
+			if cur.Kind != yaml.SequenceNode || idx >= len(cur.Content) {
+				return n, false
+			}
+			cur = cur.Content[idx]
+			n = cur
+		}
+	}
+	return n, true
+}
+
+// keyAt is the inverse of findKey: the dotted key of the mapping entry on line
+// (named name, or any entry when name is ""), or "" if there is none.
+func keyAt(doc *yaml.Node, line int, name string) string {
+	var walk func(n *yaml.Node, prefix string) string
+	walk = func(n *yaml.Node, prefix string) string {
+		if n == nil {
+			return ""
+		}
+		switch n.Kind {
+		case yaml.DocumentNode:
+			for _, c := range n.Content {
+				if k := walk(c, prefix); k != "" {
+					return k
+				}
+			}
+		case yaml.MappingNode:
+			for i := 0; i+1 < len(n.Content); i += 2 {
+				k, v := n.Content[i], n.Content[i+1]
+				key := k.Value
+				if prefix != "" {
+					key = prefix + "." + key
+				}
+				if k.Line == line && (name == "" || k.Value == name) {
+					return key
+				}
+				if r := walk(v, key); r != "" {
+					return r
+				}
+			}
+		case yaml.SequenceNode:
+			for i, c := range n.Content {
+				if r := walk(c, fmt.Sprintf("%s[%d]", prefix, i)); r != "" {
+					return r
+				}
+			}
+		}
+		return ""
+	}
+	return walk(doc, "")
+}
+
+// validate checks c and reports every problem, after the ones already found
+// while decoding (pre); doc is used only for locations.
+func validate(c *Config, doc *document, pre problems) error {
+	p := append(problems(nil), pre...)
+	validateCore(c, &p)
+	validateTLS(c, &p)
+	validateAdminAuth(c, &p)
+	validateWallet(c, &p)
+	validateChain(c, &p)
+	validateMediaMTX(c, &p)
+	validateAdmission(c, &p)
+	validatePolicy(c, &p)
+	validateSegments(c, &p)
+	validateRecordings(c, &p)
+	return p.err(doc)
+}
+
+func validateCore(c *Config, p *problems) {
+	switch strings.ToLower(c.LogLevel) {
+	case "trace", "debug", "info", "warn", "warning", "error":
+	default:
+		p.add("logLevel", "unknown level %q (use trace, debug, info, warn or error)", c.LogLevel)
+	}
+	if c.Miner.ID == "" {
+		p.add("miner.id", "required")
+	}
+	if err := checkHostPort(c.Miner.Listen); err != nil {
+		p.add("miner.listen", "%v", err)
+	}
+	if hl := c.Miner.HooksListen; hl != "" {
+		if err := checkHostPort(hl); err != nil {
+			p.add("miner.hooksListen", "%v", err)
+		} else if hl == c.Miner.Listen || hl == c.Miner.TLS.PlaintextListen {
+			p.add("miner.hooksListen", "must differ from miner.listen and miner.tls.plaintextListen")
+		}
+	}
+	if err := checkURL(c.MediaMTX.API, "http", "https"); err != nil {
+		p.add("mediamtx.api", "%v", err)
+	}
+	// simple sanity: reasonable poll interval
+	if c.MediaMTX.PollInterval.Duration < 200*time.Millisecond {
+		p.add("mediamtx.pollInterval", "too small: %s", c.MediaMTX.PollInterval.Duration)
+	}
+	if !strings.HasPrefix(c.Metrics.Path, "/") {
+		p.add("metrics.path", "must start with \"/\", got %q", c.Metrics.Path)
+	}
+	if c.Metrics.Role != "public" && !validRole(c.Metrics.Role) {
+		p.add("metrics.role", "unknown role %q", c.Metrics.Role)
+	}
+	if c.Receipts.Dir != "" {
+		if c.Receipts.BatchInterval.Duration < time.Second {
+			p.add("receipts.batchInterval", "too small: %s", c.Receipts.BatchInterval.Duration)
+		}
+		if c.Receipts.MaxBatch < 1 {
+			p.add("receipts.maxBatch", "must be >= 1, got %d", c.Receipts.MaxBatch)
+		}
+	}
+}
+
+// validateMediaMTX checks the settings rendered into the MediaMTX config.
+func validateMediaMTX(c *Config, p *problems) {
+	m := &c.MediaMTX
+	for i, h := range m.PublicHosts {
+		if h == "" || strings.ContainsAny(h, "/: ") && net.ParseIP(h) == nil {
+			p.add(fmt.Sprintf("mediamtx.publicHosts[%d]", i), "not an IP or hostname: %q", h)
+		}
+	}
+	for i, s := range m.ICEServers {
+		key := fmt.Sprintf("mediamtx.iceServers[%d]", i)
+		scheme := s.URL
+		if j := strings.IndexByte(scheme, ':'); j >= 0 {
+			scheme = scheme[:j]
+		}
+		switch scheme {
+		case "stun":
+			if s.Username != "" || s.Password.IsSet() {
+				p.add(key, "STUN servers take no credentials")
+			}
+		case "turn", "turns":
+			if (s.Username == "") != !s.Password.IsSet() {
+				p.add(key, "TURN needs both username and password, or neither")
+			}
+		default:
+			p.add(key+".url", "must start with stun:, turn: or turns:, got %q", s.URL)
+		}
+	}
+	switch m.Auth.Method {
+	case "", "internal":
+	case "jwt":
+		if err := checkURL(m.Auth.JWKS, "http", "https"); err != nil {
+			p.add("mediamtx.auth.jwks", "%v", err)
+		}
+	case "http":
+		if err := checkURL(m.Auth.HTTPAddress, "http", "https"); err != nil {
+			p.add("mediamtx.auth.httpAddress", "%v", err)
+		}
+	default:
+		p.add("mediamtx.auth.method", "unknown %q (use internal, http or jwt)", m.Auth.Method)
+	}
+	switch m.HLS.Variant {
+	case "", "mpegts", "fmp4", "lowLatency":
+	default:
+		p.add("mediamtx.hls.variant", "unknown %q (use mpegts, fmp4 or lowLatency)", m.HLS.Variant)
+	}
+	if m.HLS.SegmentCount < 0 {
+		p.add("mediamtx.hls.segmentCount", "must be >= 0, got %d", m.HLS.SegmentCount)
+	}
+	if m.HLS.PartDuration.Duration > 0 && m.HLS.SegmentDuration.Duration > 0 && m.HLS.PartDuration.Duration >= m.HLS.SegmentDuration.Duration {
+		p.add("mediamtx.hls.partDuration", "must be shorter than segmentDuration")
+	}
+	if m.ReconcileInterval.Duration < time.Second {
+		p.add("mediamtx.reconcileInterval", "too small: %s", m.ReconcileInterval.Duration)
+	}
+	seen := map[string]bool{}
+	for i, mp := range m.Paths {
+		validatePathRule(m.Paths, i, m.Auth.Method, p)
+		key := fmt.Sprintf("mediamtx.paths[%d].name", i)
+		switch {
+		case mp.Name == "":
+			p.add(key, "required")
+		case seen[mp.Name]:
+			p.add(key, "duplicate path %q", mp.Name)
+		case strings.HasPrefix(mp.Name, "~"):
+			if _, err := regexp.Compile(mp.Name[1:]); err != nil {
+				p.add(key, "bad regexp: %v", err)
+			}
+		case strings.HasPrefix(mp.Name, "/") || strings.HasSuffix(mp.Name, "/") || strings.Contains(mp.Name, "//"):
+			p.add(key, "bad path name %q", mp.Name)
+		}
+		seen[mp.Name] = true
+	}
+}
+
+// validatePathRule checks the per-path settings of rule i.
+func validatePathRule(rules []MediaPath, i int, authMethod string, p *problems) {
+	mp := rules[i]
+	key := fmt.Sprintf("mediamtx.paths[%d]", i)
+	if mp.MaxReaders < 0 {
+		p.add(key+".maxReaders", "must be >= 0, got %d", mp.MaxReaders)
+	}
+	a := mp.Auth
+	if (a.PublishUser == "") != !a.PublishPass.IsSet() {
+		p.add(key+".auth", "publishUser and publishPass go together")
+	}
+	if (a.ReadUser == "") != !a.ReadPass.IsSet() {
+		p.add(key+".auth", "readUser and readPass go together")
+	}
+	if a.PublishUser == "" && a.ReadUser == "" {
+		return
+	}
+	if authMethod != "internal" {
+		p.add(key+".auth", "per-path credentials need mediamtx.auth.method: internal")
+	}
+	// MediaMTX grants permissions per pattern: an open regex rule that also
+	// matches this name would let anyone through.
+	if strings.HasPrefix(mp.Name, "~") {
+		return
+	}
+	for _, o := range rules {
+		if !strings.HasPrefix(o.Name, "~") {
+			continue
+		}
+		re, err := regexp.Compile(o.Name[1:])
+		if err != nil || !re.MatchString(mp.Name) {
+			continue
+		}
+		if a.PublishUser != "" && o.Auth.PublishUser == "" {
+			p.add(key+".auth", "%q is also matched by %q, which allows anyone to publish", mp.Name, o.Name)
+		}
+		if a.ReadUser != "" && o.Auth.ReadUser == "" {
+			p.add(key+".auth", "%q is also matched by %q, which allows anyone to read", mp.Name, o.Name)
+		}
+	}
+}
+
+func validateAdmission(c *Config, p *problems) {
+	a := &c.Admission
+	if !a.Enable {
+		return
+	}
+	if c.MediaMTX.Auth.Method != "http" {
+		p.add("admission.enable", "MediaMTX only asks the miner with mediamtx.auth.method: http")
+	}
+	if a.Forward != "" {
+		if err := checkURL(a.Forward, "http", "https"); err != nil {
+			p.add("admission.forward", "%v", err)
+		} else if a.Forward == c.MediaMTX.Auth.HTTPAddress {
+			p.add("admission.forward", "points back at mediamtx.auth.httpAddress")
+		}
+	}
+	for i, act := range a.AllowActions {
+		switch act {
+		case "api", "metrics", "pprof":
+		default:
+			p.add(fmt.Sprintf("admission.allowActions[%d]", i), "unknown %q (use api, metrics or pprof)", act)
+		}
+	}
+	checkLimits("admission", a.MaxReaders, a.MaxEgressMbps, a.MaxPerIdentity, p)
+	if a.Grace.Duration < time.Second {
+		p.add("admission.grace", "too small: %s", a.Grace.Duration)
+	}
+	seen := map[string]bool{}
+	for i, ap := range a.Paths {
+		key := fmt.Sprintf("admission.paths[%d]", i)
+		switch {
+		case ap.Name == "":
+			p.add(key+".name", "required")
+		case seen[ap.Name]:
+			p.add(key+".name", "duplicate path %q", ap.Name)
+		case strings.HasPrefix(ap.Name, "~"):
+			if _, err := regexp.Compile(ap.Name[1:]); err != nil {
+				p.add(key+".name", "bad regexp: %v", err)
+			}
+		}
+		seen[ap.Name] = true
+		checkLimits(key, ap.MaxReaders, ap.MaxEgressMbps, ap.MaxPerIdentity, p)
+	}
+}
+
+func validatePolicy(c *Config, p *problems) {
+	pc := &c.Policy
+	if !pc.Enable {
+		return
+	}
+	if pc.Interval.Duration < time.Second {
+		p.add("policy.interval", "too small: %s", pc.Interval.Duration)
+	}
+	if pc.MaxSessionMbps < 0 {
+		p.add("policy.maxSessionMbps", "must be >= 0, got %g", pc.MaxSessionMbps)
+	}
+	if pc.MaxSessionMbps > 0 && pc.Sustain.Duration < pc.Interval.Duration {
+		p.add("policy.sustain", "%s is shorter than policy.interval (%s)", pc.Sustain.Duration, pc.Interval.Duration)
+	}
+	if pc.MaxIPsPerToken < 0 {
+		p.add("policy.maxIPsPerToken", "must be >= 0, got %d", pc.MaxIPsPerToken)
+	}
+	if pc.MaxIPsPerToken > 0 && len(pc.TokenParams) == 0 {
+		p.add("policy.tokenParams", "maxIPsPerToken needs at least one token parameter")
+	}
+	if pc.ThrottleReaders < 1 {
+		p.add("policy.throttleReaders", "must be >= 1, got %d", pc.ThrottleReaders)
+	}
+	for i, cidr := range pc.Exempt {
+		if _, _, err := net.ParseCIDR(cidr); err != nil {
+			p.add(fmt.Sprintf("policy.exempt[%d]", i), "not a CIDR: %q", cidr)
+		}
+	}
+}
+
+// validateSegments checks the per-segment receipt settings under service.segments.
+func validateSegments(c *Config, p *problems) {
+	sg := &c.Service.Segments
+	if !sg.Enable {
+		return
+	}
+	if !c.Service.Enable {
+		p.add("service.segments.enable", "segment receipts go through the service agent; set service.enable")
+	}
+	if err := checkURL(sg.HLS, "http", "https"); err != nil {
+		p.add("service.segments.hls", "%v", err)
+	}
+	if sg.Interval.Duration < 200*time.Millisecond {
+		p.add("service.segments.interval", "too small: %s", sg.Interval.Duration)
+	}
+	if sg.MaxSegmentMB < 1 || sg.MaxSegmentMB > 1024 {
+		p.add("service.segments.maxSegmentMB", "must be between 1 and 1024, got %d", sg.MaxSegmentMB)
+	}
+	for i, name := range sg.Paths {
+		key := fmt.Sprintf("service.segments.paths[%d]", i)
+		switch {
+		case name == "":
+			p.add(key, "empty path")
+		case strings.HasPrefix(name, "~"):
+			if _, err := regexp.Compile(name[1:]); err != nil {
+				p.add(key, "bad regexp: %v", err)
+			}
+		}
+	}
+	if f := sg.FEC; f.Data != 0 || f.Parity != 0 {
+		switch {
+		case f.Data < 1:
+			p.add("service.segments.fec.data", "must be >= 1, got %d", f.Data)
+		case f.Parity < 1:
+			p.add("service.segments.fec.parity", "must be >= 1, got %d", f.Parity)
+		case f.Data+f.Parity > 64:
+			p.add("service.segments.fec", "%d+%d shards, at most 64 (receipts carry a 64-bit shard mask)", f.Data, f.Parity)
+		}
+		if f.Keep < 1 {
+			p.add("service.segments.fec.keep", "must be >= 1, got %d", f.Keep)
+		}
+	}
+}
+
+func validateRecordings(c *Config, p *problems) {
+	r := &c.Recordings
+	if !r.Enable {
+		return
+	}
+	if r.Dir == "" {
+		p.add("recordings.dir", "required")
+	}
+	switch r.Format {
+	case "fmp4", "mpegts":
+	default:
+		p.add("recordings.format", "unknown %q (use fmp4 or mpegts)", r.Format)
+	}
+	if r.SegmentDuration.Duration < time.Second {
+		p.add("recordings.segmentDuration", "too small: %s", r.SegmentDuration.Duration)
+	}
+	if r.MaxAge.Duration < 0 {
+		p.add("recordings.maxAge", "must be >= 0, got %s", r.MaxAge.Duration)
+	} else if r.MaxAge.Duration > 0 && r.MaxAge.Duration < r.SegmentDuration.Duration {
+		p.add("recordings.maxAge", "shorter than segmentDuration (%s): segments would go while still recording", r.SegmentDuration.Duration)
+	}
+	if r.MaxSizeGB < 0 {
+		p.add("recordings.maxSizeGB", "must be >= 0, got %g", r.MaxSizeGB)
+	}
+	if r.ScanInterval.Duration < time.Second {
+		p.add("recordings.scanInterval", "too small: %s", r.ScanInterval.Duration)
+	}
+	if r.AttestEvery.Duration != 0 && r.AttestEvery.Duration < r.ScanInterval.Duration {
+		p.add("recordings.attestEvery", "shorter than scanInterval (%s)", r.ScanInterval.Duration)
+	}
+}
+
+// checkLimits rejects negative reader, bandwidth and per-identity limits under key.
+func checkLimits(key string, readers int, mbps float64, perIdentity int, p *problems) {
+	if readers < 0 {
+		p.add(key+".maxReaders", "must be >= 0, got %d", readers)
+	}
+	if mbps < 0 {
+		p.add(key+".maxEgressMbps", "must be >= 0, got %g", mbps)
+	}
+	if perIdentity < 0 {
+		p.add(key+".maxPerIdentity", "must be >= 0, got %d", perIdentity)
+	}
+}
+
+func validateChain(c *Config, p *problems) {
+	ch := &c.Chain
+	if !ch.Enable {
+		return
+	}
+	if ch.RPC == "" {
+		p.add("chain.rpc", "required when chain is enabled")
+	} else if !strings.HasPrefix(ch.RPC, "/") { // absolute paths are IPC sockets
+		if err := checkURL(ch.RPC, "http", "https", "ws", "wss"); err != nil {
+			p.add("chain.rpc", "%v", err)
+		}
+	}
+	if !evmAddrRe.MatchString(ch.Contract) {
+		p.add("chain.contract", "not an EVM address: %q", ch.Contract)
+	}
+	if c.Wallet.Source == "" {
+		p.add("chain.enable", "no wallet is configured to sign transactions")
+	}
+	if c.Wallet.Source == "remote" {
+		p.add("chain.enable", "remote signers cannot sign raw transactions; use a local or pkcs11 wallet")
+	}
+	if ch.MaxFeeGwei != 0 && ch.MaxTipGwei > ch.MaxFeeGwei {
+		p.add("chain.maxTipGwei", "%d exceeds maxFeeGwei (%d)", ch.MaxTipGwei, ch.MaxFeeGwei)
+	}
+	if ch.PollInterval.Duration < 200*time.Millisecond {
+		p.add("chain.pollInterval", "too small: %s", ch.PollInterval.Duration)
+	}
+}
+
+func validateWallet(c *Config, p *problems) {
+	w := &c.Wallet
+	if w.ChainID < 0 {
+		p.add("wallet.chainId", "must be >= 0, got %d", w.ChainID)
+	}
+	if w.EpochLength.Duration < time.Minute {
+		p.add("wallet.epochLength", "too small: %s", w.EpochLength.Duration)
+	}
+	if countSet(w.PasswordFile != "", w.PasswordEnv != "", w.Password.IsSet()) > 1 {
+		p.add("wallet.password", "set only one of passwordFile, passwordEnv and password")
+	}
+	hasPassword := w.PasswordFile != "" || w.PasswordEnv != "" || w.Password.IsSet()
+	switch w.Source {
+	case "":
+	case "env":
+	case "keystore-file":
+		if w.KeystorePath == "" {
+			p.add("wallet.keystorePath", "required for source keystore-file")
+		}
+		if !hasPassword {
+			p.add("wallet.source", "keystore-file needs passwordFile, passwordEnv or password")
+		}
+	case "generate":
+		if !w.AllowGenerate {
+			p.add("wallet.allowGenerate", "source generate requires allowGenerate: true")
+		}
+		// Without a file the key would be new (and so would the address) on every boot.
+		if w.KeystorePath == "" {
+			p.add("wallet.keystorePath", "required for source generate")
+		}
+		if !hasPassword {
+			p.add("wallet.source", "generate needs passwordFile, passwordEnv or password")
+		}
+	case "remote":
+		if err := checkURL(w.Remote.URL, "http", "https"); err != nil {
+			p.add("wallet.remote.url", "%v", err)
+		}
+		if !evmAddrRe.MatchString(w.Remote.Address) {
+			p.add("wallet.remote.address", "not an EVM address: %q", w.Remote.Address)
+		}
+		switch w.Remote.TypedDataMethod {
+		case "account_signTypedData", "eth_signTypedData":
+		default:
+			p.add("wallet.remote.typedDataMethod", "unknown %q", w.Remote.TypedDataMethod)
+		}
+	case "pkcs11":
+		k := &w.PKCS11
+		if k.Module == "" {
+			p.add("wallet.pkcs11.module", "required for source pkcs11")
+		}
+		if k.KeyLabel == "" && k.KeyID == "" {
+			p.add("wallet.pkcs11", "set keyLabel or keyID")
+		}
+		if countSet(k.PINFile != "", k.PINEnv != "", k.PIN.IsSet()) != 1 {
+			p.add("wallet.pkcs11", "set exactly one of pinFile, pinEnv and pin")
+		}
+	default:
+		p.add("wallet.source", "unknown %q (use env, keystore-file, generate, remote or pkcs11)", w.Source)
+	}
+}
+
+func validateTLS(c *Config, p *problems) {
+	t := &c.Miner.TLS
+	if !t.Enable {
+		if len(c.Admin.Auth.Certs) > 0 {
+			p.add("admin.auth.certs", "requires miner.tls.enable")
+		}
+		return
+	}
+	if !t.SelfSigned && (t.CertFile == "" || t.KeyFile == "") {
+		p.add("miner.tls", "certFile and keyFile are required (or set selfSigned for dev)")
+	}
+	if t.SelfSigned && (t.CertFile != "" || t.KeyFile != "") {
+		p.add("miner.tls.selfSigned", "cannot be combined with certFile/keyFile")
+	}
+	switch t.MinVersion {
+	case "1.2", "1.3":
+	default:
+		p.add("miner.tls.minVersion", "unsupported %q (use \"1.2\" or \"1.3\")", t.MinVersion)
+	}
+	if t.RequireClientCert && t.ClientCAFile == "" {
+		p.add("miner.tls.requireClientCert", "needs clientCAFile")
+	}
+	if len(c.Admin.Auth.Certs) > 0 && t.ClientCAFile == "" {
+		p.add("admin.auth.certs", "needs miner.tls.clientCAFile to verify client certificates")
+	}
+	switch t.Plaintext {
+	case "refuse", "redirect":
+	default:
+		p.add("miner.tls.plaintext", "unknown mode %q (use refuse or redirect)", t.Plaintext)
+	}
+	if t.PlaintextListen != "" {
+		if err := checkHostPort(t.PlaintextListen); err != nil {
+			p.add("miner.tls.plaintextListen", "%v", err)
+		} else if t.PlaintextListen == c.Miner.Listen {
+			p.add("miner.tls.plaintextListen", "must differ from miner.listen")
+		}
+	}
+}
+
+func countSet(bs ...bool) int {
+	n := 0
+	for _, b := range bs {
+		if b {
+			n++
+		}
+	}
+	return n
+}
+
+var evmAddrRe = regexp.MustCompile(`^0x[0-9a-fA-F]{40}$`)
+
+func validRole(r string) bool {
+	switch r {
+	case "viewer", "operator", "admin":
+		return true
+	}
+	return false
+}
+
+func validateAdminAuth(c *Config, p *problems) {
+	a := &c.Admin.Auth
+	for i, t := range a.Tokens {
+		if !t.Token.IsSet() {
+			p.add(fmt.Sprintf("admin.auth.tokens[%d].token", i), "required")
+		}
+		if !validRole(t.Role) {
+			p.add(fmt.Sprintf("admin.auth.tokens[%d].role", i), "unknown role %q", t.Role)
+		}
+	}
+	for i, s := range a.Signers {
+		if !evmAddrRe.MatchString(s.Address) {
+			p.add(fmt.Sprintf("admin.auth.signers[%d].address", i), "not an EVM address: %q", s.Address)
+		}
+		if !validRole(s.Role) {
+			p.add(fmt.Sprintf("admin.auth.signers[%d].role", i), "unknown role %q", s.Role)
+		}
+	}
+	for i, ct := range a.Certs {
+		if ct.CommonName == "" {
+			p.add(fmt.Sprintf("admin.auth.certs[%d].commonName", i), "required")
+		}
+		if !validRole(ct.Role) {
+			p.add(fmt.Sprintf("admin.auth.certs[%d].role", i), "unknown role %q", ct.Role)
+		}
+	}
+	if a.Enable && len(a.Tokens) == 0 && len(a.Signers) == 0 && len(a.Certs) == 0 {
+		p.add("admin.auth.enable", "set but no tokens, signers or certs are configured")
+	}
+}
+
+// checkHostPort accepts "host:port" and ":port" with a numeric port.
+func checkHostPort(s string) error {
+	if s == "" {
+		return fmt.Errorf("required")
+	}
+	host, port, err := net.SplitHostPort(s)
+	if err != nil {
+		return fmt.Errorf("not host:port: %q", s)
+	}
+	if strings.HasPrefix(s, "[") {
+		if ip := net.ParseIP(strings.SplitN(host, "%", 2)[0]); ip == nil || !strings.Contains(host, ":") {
+			return fmt.Errorf("bracketed host must be an IPv6 address: %q", s)
+		}
+	}
+	n, err := strconv.Atoi(port)
+	if err != nil || n > 65535 || len(port) > 5 || strings.TrimLeft(port, "0123456789") != "" {
+		return fmt.Errorf("bad port in %q", s)
+	}
+	return nil
+}
+
+// checkURL requires an absolute URL with a host and one of schemes.
+func checkURL(s string, schemes ...string) error {
+	if s == "" {
+		return fmt.Errorf("required")
+	}
+	u, err := url.Parse(s)
+	if err != nil {
+		return fmt.Errorf("not a URL: %q", s)
+	}
+	if u.Host == "" {
+		return fmt.Errorf("not an absolute URL with a host: %q", s)
+	}
+	for _, sc := range schemes {
+		if u.Scheme == sc {
+			return nil
+		}
+	}
+	return fmt.Errorf("scheme %q not allowed (use %s)", u.Scheme, strings.Join(schemes, ", "))
+}
@@ -0,0 +1,241 @@
+// internal/events/bus.go
+package events
+
+import (
+	"strings"
+	"sync"
+	"time"
+)
+
+// Type names an event kind. Names are "<source>.<what>" so filters can match a prefix ("path.*").
+type Type string
+
+const (
+	// Watcher (MediaMTX paths/sessions)
+	PathAdded    Type = "path.added"
+	PathRemoved  Type = "path.removed"
+	PathReady    Type = "path.ready"
+	PathNotReady Type = "path.notready"
+	ReaderJoined Type = "session.reader_joined"
+	ReaderLeft   Type = "session.reader_left"
+
+	// Path manager (MediaMTX path configuration)
+	PathConfAdded   Type = "pathconf.added"
+	PathConfPatched Type = "pathconf.patched" // definition changed or drift corrected
+	PathConfDeleted Type = "pathconf.deleted"
+
+	// Policy engine (kicks of abusive readers)
+	SessionKicked Type = "session.kicked"
+
+	// Admission controller (MediaMTX external auth hook)
+	AdmissionRejected Type = "admission.rejected"
+
+	// Service agent
+	ServiceFlush Type = "service.flush"
+
+	// Recordings (MediaMTX recorded segments on disk)
+	RecordingSegment Type = "recording.segment" // a finished segment was hashed
+	RecordingDeleted Type = "recording.deleted" // removed by retention
+	RecordingCorrupt Type = "recording.corrupt" // re-hash no longer matches the index
+
+	// Receipts
+	ReceiptSigned Type = "receipt.signed"
+	BatchClosed   Type = "batch.closed"
+
+	// Presence agent
+	PresenceChallenge Type = "presence.challenge" // the miner key answered a challenge
+	PresenceHeartbeat Type = "presence.heartbeat"
+
+	// Stream control: sent without an ID to a client resuming from an ID that is
+	// no longer buffered, so it knows events were missed.
+	StreamGap Type = "stream.gap"
+)
+
+// Event is a typed, JSON-serialisable miner event. IDs are assigned by the bus
+// and increase monotonically; they double as SSE Last-Event-ID cursors.
+type Event struct {
+	ID   uint64      `json:"id"`
+	Type Type        `json:"type"`
+	Path string      `json:"path,omitempty"`
+	Time time.Time   `json:"ts"`
+	Data interface{} `json:"data,omitempty"`
+}
+
+// Filter selects events by type and path. Empty sets match everything.
+// Types may end in ".*" to match a prefix.
+type Filter struct {
+	Types []string
+	Paths []string
+}
+
+// Match reports whether e passes the filter.
+func (f Filter) Match(e Event) bool {
+	if len(f.Types) > 0 {
+		ok := false
+		for _, t := range f.Types {
+			if strings.HasSuffix(t, ".*") {
+				if strings.HasPrefix(string(e.Type), strings.TrimSuffix(t, "*")) {
+					ok = true
+					break
+				}
+			} else if string(e.Type) == t {
+				ok = true
+				break
+			}
+		}
+		if !ok {
+			return false
+		}
+	}
+	if len(f.Paths) > 0 {
+		for _, p := range f.Paths {
+			if e.Path == p {
+				return true
+			}
+		}
+		return false
+	}
+	return true
+}
+
+// Subscription delivers live events. C is closed when the subscriber falls too far
+// behind or is closed; clients are expected to reconnect with their last seen ID.
+type Subscription struct {
+	C <-chan Event
+
+	c      chan Event
+	filter Filter
+	bus    *Bus
+	once   sync.Once
+}
+
+// Close detaches the subscription from the bus.
+func (s *Subscription) Close() {
+	s.bus.mu.Lock()
+	defer s.bus.mu.Unlock()
+	s.closeLocked()
+}
+
+func (s *Subscription) closeLocked() {
+	s.once.Do(func() {
+		delete(s.bus.subs, s)
+		close(s.c)
+	})
+}
+
+// Bus is an in-process pub/sub with a fixed-size ring buffer for replay.
+type Bus struct {
+	mu     sync.Mutex
+	ring   []Event
+	head   int // next write position
+	filled bool
+	seq    uint64
+	subs   map[*Subscription]struct{}
+}
+
+// subBuffer is how many undelivered events a subscriber may queue before it is dropped.
+const subBuffer = 256
+
+// NewBus creates a bus that retains the last size events for replay.
+func NewBus(size int) *Bus {
+	if size <= 0 {
+		size = 1024
+	}
+	return &Bus{ring: make([]Event, size), subs: make(map[*Subscription]struct{})}
+}
+
+// Default is the process-wide bus used by the watcher, agents and admin API.
+var Default = NewBus(4096)
+
+// Publish sends an event on the Default bus.
+func Publish(t Type, path string, data interface{}) Event {
+	return Default.Publish(t, path, data)
+}
+
+// Publish assigns an ID, stores the event in the ring and fans it out.
+// It never blocks: subscribers that can't keep up are disconnected.
+func (b *Bus) Publish(t Type, path string, data interface{}) Event {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.seq++
+	e := Event{ID: b.seq, Type: t, Path: path, Time: time.Now().UTC(), Data: data}
+
+	b.ring[b.head] = e
+	b.head = (b.head + 1) % len(b.ring)
+	if b.head == 0 {
+		b.filled = true
+	}
+
+	for s := range b.subs {
+		if !s.filter.Match(e) {
+			continue
+		}
+		select {
+		case s.c <- e:
+		default:
+			s.closeLocked()
+		}
+	}
+	return e
+}
+
+// Subscribe registers a live subscription and returns buffered events newer than
+// afterID that match the filter (afterID=0 means no replay). The replay and the
+// live stream do not overlap or leave gaps. gap reports that events after afterID
+// have already left the ring (or afterID is from an earlier process), so the
+// replay is incomplete.
+func (b *Bus) Subscribe(f Filter, afterID uint64) (s *Subscription, backlog []Event, gap bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if afterID > 0 {
+		all := b.ordered()
+		gap = afterID > b.seq || (len(all) > 0 && all[0].ID > afterID+1)
+		for _, e := range all {
+			if e.ID > afterID && f.Match(e) {
+				backlog = append(backlog, e)
+			}
+		}
+	}
+
+	c := make(chan Event, subBuffer)
+	s = &Subscription{C: c, c: c, filter: f, bus: b}
+	b.subs[s] = struct{}{}
+	return s, backlog, gap
+}
+
+// LastID returns the ID of the most recent event (0 before the first).
+func (b *Bus) LastID() uint64 {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.seq
+}
+
+// Recent returns up to n of the most recent events matching f, oldest first.
+func (b *Bus) Recent(f Filter, n int) []Event {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	all := b.ordered()
+	var out []Event
+	for i := len(all) - 1; i >= 0 && len(out) < n; i-- {
+		if f.Match(all[i]) {
+			out = append(out, all[i])
+		}
+	}
+	for i, j := 0, len(out)-1; i < j; i, j = i+1, j-1 {
+		out[i], out[j] = out[j], out[i]
+	}
+	return out
+}
+
+// ordered returns the ring contents oldest first. Caller holds b.mu.
+func (b *Bus) ordered() []Event {
+	if !b.filled {
+		return b.ring[:b.head]
+	}
+	out := make([]Event, 0, len(b.ring))
+	out = append(out, b.ring[b.head:]...)
+	return append(out, b.ring[:b.head]...)
+}
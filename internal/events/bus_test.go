@@ -0,0 +1,144 @@
+package events
+
+import (
+	"fmt"
+	"testing"
+)
+
+func ids(es []Event) []uint64 {
+	out := make([]uint64, len(es))
+	for i, e := range es {
+		out[i] = e.ID
+	}
+	return out
+}
+
+func TestSubscribeReplay(t *testing.T) {
+	// Ring of 4 after 6 events: IDs 3..6 are buffered. Odd IDs are on path "a".
+	tests := []struct {
+		name    string
+		filter  Filter
+		after   uint64
+		backlog []uint64
+		gap     bool
+	}{
+		{name: "no cursor, no replay", after: 0},
+		{name: "resume inside the ring", after: 4, backlog: []uint64{5, 6}},
+		{name: "resume at the oldest buffered", after: 2, backlog: []uint64{3, 4, 5, 6}},
+		{name: "resume at the newest", after: 6},
+		{name: "resume past the ring", after: 1, backlog: []uint64{3, 4, 5, 6}, gap: true},
+		{name: "cursor from an earlier process", after: 99, gap: true},
+		{name: "filter applies to the replay", filter: Filter{Paths: []string{"a"}}, after: 3, backlog: []uint64{5}},
+		{name: "gap is judged on every event, not the filtered ones", filter: Filter{Paths: []string{"a"}}, after: 1, backlog: []uint64{3, 5}, gap: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := NewBus(4)
+			for i := 1; i <= 6; i++ {
+				path := "b"
+				if i%2 == 1 {
+					path = "a"
+				}
+				b.Publish(PathAdded, path, nil)
+			}
+			s, backlog, gap := b.Subscribe(tt.filter, tt.after)
+			defer s.Close()
+			if fmt.Sprint(ids(backlog)) != fmt.Sprint(tt.backlog) {
+				t.Fatalf("backlog %v, want %v", ids(backlog), tt.backlog)
+			}
+			if gap != tt.gap {
+				t.Fatalf("gap = %v, want %v", gap, tt.gap)
+			}
+		})
+	}
+}
+
+func TestReplayThenLive(t *testing.T) {
+	b := NewBus(8)
+	for i := 0; i < 5; i++ {
+		b.Publish(PathAdded, "a", nil)
+	}
+	s, backlog, _ := b.Subscribe(Filter{}, 3)
+	defer s.Close()
+	b.Publish(PathRemoved, "a", nil)
+	b.Publish(PathRemoved, "a", nil)
+	got := ids(backlog)
+	for i := 0; i < 2; i++ {
+		got = append(got, (<-s.C).ID)
+	}
+	if fmt.Sprint(got) != "[4 5 6 7]" {
+		t.Fatalf("events %v, want 4..7 without overlap or gaps", got)
+	}
+}
+
+func TestRingWraparound(t *testing.T) {
+	b := NewBus(3)
+	for i := 0; i < 7; i++ {
+		b.Publish(PathAdded, "a", nil)
+	}
+	if got := ids(b.Recent(Filter{}, 10)); fmt.Sprint(got) != "[5 6 7]" {
+		t.Fatalf("recent %v, want [5 6 7]", got)
+	}
+	if got := ids(b.Recent(Filter{}, 2)); fmt.Sprint(got) != "[6 7]" {
+		t.Fatalf("recent 2 %v, want [6 7]", got)
+	}
+	if b.LastID() != 7 {
+		t.Fatalf("last id %d", b.LastID())
+	}
+}
+
+func TestSlowSubscriber(t *testing.T) {
+	b := NewBus(16)
+	slow, _, _ := b.Subscribe(Filter{}, 0)
+	fast, _, _ := b.Subscribe(Filter{}, 0)
+	defer fast.Close()
+	other, _, _ := b.Subscribe(Filter{Types: []string{"receipt.*"}}, 0)
+	defer other.Close()
+
+	// Publish never blocks; the slow subscriber is dropped once its buffer is full.
+	for i := 0; i < subBuffer+1; i++ {
+		b.Publish(PathAdded, "a", nil)
+		if i < subBuffer {
+			<-fast.C
+		}
+	}
+	<-fast.C
+	n := 0
+	for range slow.C {
+		n++
+	}
+	if n != subBuffer {
+		t.Fatalf("slow subscriber got %d events before being dropped, want %d", n, subBuffer)
+	}
+	slow.Close() // closing again is harmless
+
+	// The others are still attached; the filtered one saw nothing to queue.
+	b.Publish(ReceiptSigned, "a", nil)
+	if e := <-fast.C; e.Type != ReceiptSigned {
+		t.Fatalf("fast got %s", e.Type)
+	}
+	if e := <-other.C; e.Type != ReceiptSigned {
+		t.Fatalf("filtered subscriber got %s", e.Type)
+	}
+}
+
+func TestFilter(t *testing.T) {
+	e := Event{Type: ReaderJoined, Path: "live/a"}
+	tests := []struct {
+		f    Filter
+		want bool
+	}{
+		{Filter{}, true},
+		{Filter{Types: []string{"session.*"}}, true},
+		{Filter{Types: []string{"session.reader_joined"}}, true},
+		{Filter{Types: []string{"session"}}, false},
+		{Filter{Types: []string{"sess.*"}}, false},
+		{Filter{Paths: []string{"live/b", "live/a"}}, true},
+		{Filter{Types: []string{"path.*"}, Paths: []string{"live/a"}}, false},
+	}
+	for _, tt := range tests {
+		if got := tt.f.Match(e); got != tt.want {
+			t.Errorf("%+v: match = %v, want %v", tt.f, got, tt.want)
+		}
+	}
+}
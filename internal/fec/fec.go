@@ -0,0 +1,263 @@
+// internal/fec/fec.go
+package fec
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/klauspost/reedsolomon"
+)
+
+// Domain separation tags for shard leaves and segment commitments.
+const (
+	LeafTag   = "SlowDrip:FEC-Shard:v1"
+	CommitTag = "SlowDrip:FEC-Commit:v1"
+)
+
+// MaxShards bounds Data+Parity so a receipt can name delivered shards in a
+// 64-bit mask.
+const MaxShards = 64
+
+// Params is a Reed-Solomon layout: a segment becomes Data data shards plus
+// Parity parity shards, and any Data of them rebuild it.
+type Params struct {
+	Data   int `json:"data"`
+	Parity int `json:"parity"`
+}
+
+// Total is the number of shards per segment.
+func (p Params) Total() int { return p.Data + p.Parity }
+
+// Mask has a bit set for every shard of the layout.
+func (p Params) Mask() uint64 {
+	if p.Total() >= 64 {
+		return ^uint64(0)
+	}
+	return 1<<uint(p.Total()) - 1
+}
+
+func (p Params) String() string { return fmt.Sprintf("%d+%d", p.Data, p.Parity) }
+
+// Validate checks the layout.
+func (p Params) Validate() error {
+	switch {
+	case p.Data < 1:
+		return fmt.Errorf("fec: need at least 1 data shard, got %d", p.Data)
+	case p.Parity < 1:
+		return fmt.Errorf("fec: need at least 1 parity shard, got %d", p.Parity)
+	case p.Total() > MaxShards:
+		return fmt.Errorf("fec: %d shards, at most %d", p.Total(), MaxShards)
+	}
+	return nil
+}
+
+// Hash is a SHA-256 value, hex in JSON.
+type Hash [32]byte
+
+func (h Hash) Hex() string { return hex.EncodeToString(h[:]) }
+
+func (h Hash) MarshalText() ([]byte, error) { return []byte(h.Hex()), nil }
+
+func (h *Hash) UnmarshalText(b []byte) error {
+	s := strings.TrimPrefix(string(b), "0x")
+	if len(s) != 64 {
+		return fmt.Errorf("fec: hash must be 32 bytes, got %q", b)
+	}
+	_, err := hex.Decode(h[:], []byte(s))
+	return err
+}
+
+// Set is a segment split into shards, with the Merkle tree over them.
+type Set struct {
+	Params
+	Size   int64    // segment bytes; shards are padded to equal length
+	Shards [][]byte // Data data shards, then Parity parity shards
+	Leaves []Hash
+	Root   Hash
+}
+
+// Encode splits seg into p.Total() shards and commits to them.
+func Encode(seg []byte, p Params) (*Set, error) {
+	if err := p.Validate(); err != nil {
+		return nil, err
+	}
+	if len(seg) == 0 {
+		return nil, errors.New("fec: empty segment")
+	}
+	enc, err := reedsolomon.New(p.Data, p.Parity)
+	if err != nil {
+		return nil, fmt.Errorf("fec: %w", err)
+	}
+	// Split may use seg's spare capacity for the shards; don't write into the caller's buffer.
+	shards, err := enc.Split(append([]byte(nil), seg...))
+	if err != nil {
+		return nil, fmt.Errorf("fec: split: %w", err)
+	}
+	if err := enc.Encode(shards); err != nil {
+		return nil, fmt.Errorf("fec: encode: %w", err)
+	}
+	s := &Set{Params: p, Size: int64(len(seg)), Shards: shards, Leaves: make([]Hash, len(shards))}
+	for i, sh := range shards {
+		s.Leaves[i] = Leaf(i, sh)
+	}
+	s.Root = merkleize(s.Leaves)
+	return s, nil
+}
+
+// Commit is the segment commitment receipts carry: it binds the shard root to
+// the layout and the segment size.
+func (s *Set) Commit() [32]byte { return Commitment(s.Params, s.Size, s.Root) }
+
+// Proof returns the shard at index i with what a verifier needs to check it.
+func (s *Set) Proof(i int) (ShardProof, error) {
+	if i < 0 || i >= len(s.Shards) {
+		return ShardProof{}, fmt.Errorf("fec: shard %d out of range (%d shards)", i, len(s.Shards))
+	}
+	return ShardProof{
+		Params: s.Params,
+		Size:   s.Size,
+		Root:   s.Root,
+		Index:  i,
+		Path:   merklePath(s.Leaves, i),
+	}, nil
+}
+
+// Commitment computes H(CommitTag || data || parity || size || root).
+func Commitment(p Params, size int64, root Hash) [32]byte {
+	h := sha256.New()
+	h.Write([]byte(CommitTag))
+	var b [8]byte
+	binary.BigEndian.PutUint16(b[:2], uint16(p.Data))
+	h.Write(b[:2])
+	binary.BigEndian.PutUint16(b[:2], uint16(p.Parity))
+	h.Write(b[:2])
+	binary.BigEndian.PutUint64(b[:], uint64(size))
+	h.Write(b[:])
+	h.Write(root[:])
+	var out [32]byte
+	copy(out[:], h.Sum(nil))
+	return out
+}
+
+// Leaf computes a shard's leaf: H(LeafTag || index || shard).
+func Leaf(index int, shard []byte) Hash {
+	h := sha256.New()
+	h.Write([]byte(LeafTag))
+	var b [2]byte
+	binary.BigEndian.PutUint16(b[:], uint16(index))
+	h.Write(b[:])
+	h.Write(shard)
+	var out Hash
+	copy(out[:], h.Sum(nil))
+	return out
+}
+
+// ShardProof ties one shard to a segment commitment.
+type ShardProof struct {
+	Params
+	Size  int64  `json:"size"`
+	Root  Hash   `json:"root"`
+	Index int    `json:"index"`
+	Path  []Hash `json:"path"` // Merkle siblings, bottom-up
+}
+
+// Verify checks that shard is shard p.Index of the segment committed to by commit.
+func (p ShardProof) Verify(commit [32]byte, shard []byte) error {
+	if err := p.Params.Validate(); err != nil {
+		return err
+	}
+	if p.Index < 0 || p.Index >= p.Total() {
+		return fmt.Errorf("fec: shard %d out of range (%d shards)", p.Index, p.Total())
+	}
+	if Commitment(p.Params, p.Size, p.Root) != commit {
+		return errors.New("fec: layout, size and root do not match the commitment")
+	}
+	cur, idx := Leaf(p.Index, shard), p.Index
+	for _, sib := range p.Path {
+		if idx%2 == 0 {
+			cur = hashPair(cur, sib)
+		} else {
+			cur = hashPair(sib, cur)
+		}
+		idx /= 2
+	}
+	if idx != 0 || cur != p.Root {
+		return fmt.Errorf("fec: shard %d does not hash to the root", p.Index)
+	}
+	return nil
+}
+
+// Reconstruct rebuilds a segment from any p.Data of its shards; missing shards
+// are nil. Shards should be verified against the commitment first.
+func Reconstruct(p Params, size int64, shards [][]byte) ([]byte, error) {
+	if err := p.Validate(); err != nil {
+		return nil, err
+	}
+	if len(shards) != p.Total() {
+		return nil, fmt.Errorf("fec: got %d shard slots, want %d", len(shards), p.Total())
+	}
+	enc, err := reedsolomon.New(p.Data, p.Parity)
+	if err != nil {
+		return nil, fmt.Errorf("fec: %w", err)
+	}
+	if err := enc.ReconstructData(shards); err != nil {
+		return nil, fmt.Errorf("fec: reconstruct: %w", err)
+	}
+	var b bytes.Buffer
+	if err := enc.Join(&b, shards, int(size)); err != nil {
+		return nil, fmt.Errorf("fec: join: %w", err)
+	}
+	return b.Bytes(), nil
+}
+
+// ------------------------
+// Merkle tree over shard leaves
+// ------------------------
+
+// merkleize builds the root the same way receipt batches do: SHA-256 of
+// sibling pairs, duplicating the last node of odd levels.
+func merkleize(leaves []Hash) Hash {
+	level := append([]Hash(nil), leaves...)
+	for len(level) > 1 {
+		level = nextLevel(level)
+	}
+	return level[0]
+}
+
+func merklePath(leaves []Hash, i int) []Hash {
+	level := append([]Hash(nil), leaves...)
+	var path []Hash
+	for len(level) > 1 {
+		if len(level)%2 == 1 {
+			level = append(level, level[len(level)-1])
+		}
+		path = append(path, level[i^1])
+		level, i = nextLevel(level), i/2
+	}
+	return path
+}
+
+func nextLevel(level []Hash) []Hash {
+	if len(level)%2 == 1 {
+		level = append(level, level[len(level)-1])
+	}
+	next := make([]Hash, 0, len(level)/2)
+	for j := 0; j < len(level); j += 2 {
+		next = append(next, hashPair(level[j], level[j+1]))
+	}
+	return next
+}
+
+func hashPair(a, b Hash) Hash {
+	h := sha256.New()
+	h.Write(a[:])
+	h.Write(b[:])
+	var out Hash
+	copy(out[:], h.Sum(nil))
+	return out
+}
@@ -0,0 +1,145 @@
+package fec
+
+import (
+	"bytes"
+	"testing"
+)
+
+func segment(n int) []byte {
+	b := make([]byte, n)
+	for i := range b {
+		b[i] = byte(i*7 + 3)
+	}
+	return b
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		p  Params
+		ok bool
+	}{
+		{Params{Data: 4, Parity: 2}, true},
+		{Params{Data: 1, Parity: 1}, true},
+		{Params{Data: 32, Parity: 32}, true},
+		{Params{Data: 0, Parity: 2}, false},
+		{Params{Data: 4, Parity: 0}, false},
+		{Params{Data: 40, Parity: 25}, false},
+	}
+	for _, tt := range tests {
+		if err := tt.p.Validate(); (err == nil) != tt.ok {
+			t.Errorf("%s: err = %v, want ok %v", tt.p, err, tt.ok)
+		}
+	}
+}
+
+func TestMask(t *testing.T) {
+	tests := []struct {
+		p    Params
+		want uint64
+	}{
+		{Params{Data: 4, Parity: 2}, 0x3f},
+		{Params{Data: 1, Parity: 1}, 0x3},
+		{Params{Data: 32, Parity: 32}, ^uint64(0)},
+	}
+	for _, tt := range tests {
+		if got := tt.p.Mask(); got != tt.want {
+			t.Errorf("%s: mask %#x, want %#x", tt.p, got, tt.want)
+		}
+	}
+}
+
+func TestReconstruct(t *testing.T) {
+	p := Params{Data: 4, Parity: 2}
+	seg := segment(1000)
+	set, err := Encode(seg, p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name string
+		have []int // shard indexes the viewer got
+		ok   bool
+	}{
+		{name: "all shards", have: []int{0, 1, 2, 3, 4, 5}, ok: true},
+		{name: "data shards only", have: []int{0, 1, 2, 3}, ok: true},
+		{name: "parity standing in for data", have: []int{1, 3, 4, 5}, ok: true},
+		{name: "one short", have: []int{0, 2, 5}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			shards := make([][]byte, p.Total())
+			for _, i := range tt.have {
+				shards[i] = append([]byte(nil), set.Shards[i]...)
+			}
+			got, err := Reconstruct(p, set.Size, shards)
+			if !tt.ok {
+				if err == nil {
+					t.Fatal("rebuilt from too few shards")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(got, seg) {
+				t.Fatal("rebuilt segment differs")
+			}
+		})
+	}
+}
+
+func TestProofVerify(t *testing.T) {
+	p := Params{Data: 3, Parity: 2} // odd leaf count: the last node is duplicated
+	set, err := Encode(segment(999), p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	commit := set.Commit()
+	other, err := Encode(segment(998), p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name  string
+		edit  func(*ShardProof, *[]byte, *[32]byte)
+		valid bool
+	}{
+		{name: "untouched", edit: func(*ShardProof, *[]byte, *[32]byte) {}, valid: true},
+		{name: "flipped shard byte", edit: func(_ *ShardProof, sh *[]byte, _ *[32]byte) { (*sh)[0] ^= 1 }},
+		{name: "wrong index", edit: func(pr *ShardProof, _ *[]byte, _ *[32]byte) { pr.Index ^= 1 }},
+		{name: "index out of range", edit: func(pr *ShardProof, _ *[]byte, _ *[32]byte) { pr.Index = p.Total() }},
+		{name: "size changed", edit: func(pr *ShardProof, _ *[]byte, _ *[32]byte) { pr.Size++ }},
+		{name: "layout changed", edit: func(pr *ShardProof, _ *[]byte, _ *[32]byte) { pr.Parity++ }},
+		{name: "another segment's commit", edit: func(_ *ShardProof, _ *[]byte, c *[32]byte) { *c = other.Commit() }},
+	}
+	for i := 0; i < p.Total(); i++ {
+		for _, tt := range tests {
+			pr, err := set.Proof(i)
+			if err != nil {
+				t.Fatal(err)
+			}
+			sh, c := append([]byte(nil), set.Shards[i]...), commit
+			tt.edit(&pr, &sh, &c)
+			if err := pr.Verify(c, sh); (err == nil) != tt.valid {
+				t.Errorf("shard %d, %s: err = %v, want valid %v", i, tt.name, err, tt.valid)
+			}
+		}
+	}
+	if _, err := set.Proof(p.Total()); err == nil {
+		t.Error("proof for a shard past the end")
+	}
+}
+
+func TestEncodeKeepsInput(t *testing.T) {
+	seg := make([]byte, 10, 100) // spare capacity Split could write into
+	copy(seg, "0123456789")
+	if _, err := Encode(seg, Params{Data: 4, Parity: 2}); err != nil {
+		t.Fatal(err)
+	}
+	if got := string(seg[:cap(seg)][10:20]); got != string(make([]byte, 10)) {
+		t.Fatalf("spare capacity written: %q", got)
+	}
+	if _, err := Encode(nil, Params{Data: 4, Parity: 2}); err == nil {
+		t.Fatal("encoded an empty segment")
+	}
+}
@@ -0,0 +1,506 @@
+// internal/hls/observer.go
+package hls
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"slowdrip-miner/internal/fec"
+	"slowdrip-miner/internal/mediamtx"
+	"slowdrip-miner/internal/service"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/rs/zerolog"
+)
+
+// Segment results counted in miner_hls_segments_total.
+const (
+	ResultCommitted = "committed" // hashed (with FEC: its shards delivered) before its deadline
+	ResultLate      = "late"      // hashed or delivered, but after its deadline
+	ResultMissed    = "missed"    // left the playlist before it was fetched
+	ResultFailed    = "failed"    // fetch or read error
+)
+
+// DefaultMaxSegment is Options.MaxSegment when unset.
+const DefaultMaxSegment = 64 << 20
+
+// streamTTL is how long a path may be missing, not ready or unwatched before
+// the observer forgets it. A path back within it resumes its Seq run; one
+// back later starts a new run, as after a miner restart.
+const streamTTL = time.Minute
+
+var (
+	segmentsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "miner_hls_segments_total",
+		Help: "HLS segments seen by the segment observer, by result.",
+	}, []string{"result"})
+	segmentBytes = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "miner_hls_segment_bytes_total",
+		Help: "Bytes of HLS segments hashed into commitments.",
+	})
+)
+
+// Options configures an Observer.
+type Options struct {
+	HLS      string        // MediaMTX HLS server, e.g. http://127.0.0.1:8888
+	Dir      string        // MediaMTX's hlsDirectory; "" = fetch segments over HTTP
+	Interval time.Duration // playlist poll interval
+	Paths    []string      // paths to observe, "~regex" allowed; empty = all
+
+	// MaxSegment caps the bytes read per segment; larger ones fail. Plain
+	// segments are hashed as they stream in, FEC ones are held whole to encode.
+	MaxSegment int64
+
+	// FEC, when set, splits each segment into Reed-Solomon shards: Commit is
+	// then the fec.Commitment over them, and the last Keep sets per path are
+	// served on ShardsPath. A segment's receipt is emitted when its set leaves
+	// the window, with the shards served to viewers by then.
+	FEC  fec.Params
+	Keep int
+}
+
+// Observer follows MediaMTX's HLS playlists for ready paths and turns every new
+// segment into a service.SegmentReceipt: Commit is SHA-256 of the segment
+// bytes (or, with FEC, the commitment over its shards), Size their count, and
+// Seq follows the media sequence number.
+//
+// A segment's deadline is one segment duration after the playlist that first
+// listed it was fetched; receipts are "late" when hashing took longer or, with
+// FEC, when the last shard counted in Shards was served after it.
+type Observer struct {
+	opts     Options
+	base     *url.URL
+	names    map[string]bool
+	res      []*regexp.Regexp
+	client   *http.Client
+	snapshot func() []mediamtx.Path
+	emit     func(service.SegmentReceipt)
+	log      zerolog.Logger
+
+	mu      sync.Mutex
+	streams map[string]*stream // by path
+}
+
+// stream is the observer's position in one path's media playlist.
+type stream struct {
+	playlist *url.URL // media playlist, resolved from index.m3u8
+	started  bool
+	next     uint64 // next media sequence number to commit
+	base     uint64 // added to media sequence numbers; raised when the muxer restarts
+	status   PathStatus
+	sets     []kept    // FEC shard sets of the last Options.Keep segments, oldest first
+	seen     time.Time // last poll that found the path ready and watched
+}
+
+// PathStatus is one observed path in Status.
+type PathStatus struct {
+	Path       string    `json:"path"`
+	Segments   int64     `json:"segments"` // receipts emitted, late ones included
+	Late       int64     `json:"late"`
+	Missed     int64     `json:"missed"`
+	Failed     int64     `json:"failed"`
+	Bytes      int64     `json:"bytes"`
+	LastSeq    uint64    `json:"last_seq"`
+	LastCommit string    `json:"last_commit,omitempty"`
+	LastAt     time.Time `json:"last_at,omitempty"`
+	Error      string    `json:"error,omitempty"`
+}
+
+// Status is the observer's view for /v1/status.
+type Status struct {
+	HLS   string       `json:"hls"`
+	Dir   string       `json:"dir,omitempty"`
+	FEC   string       `json:"fec,omitempty"` // data+parity shards
+	Paths []PathStatus `json:"paths"`
+}
+
+// New creates an observer. snapshot lists MediaMTX's paths (mediamtx.Snapshot);
+// emit receives the receipts (service.AddReceipt).
+func New(o Options, snapshot func() []mediamtx.Path, emit func(service.SegmentReceipt), log zerolog.Logger) (*Observer, error) {
+	base, err := url.Parse(strings.TrimSuffix(o.HLS, "/") + "/")
+	if err != nil {
+		return nil, fmt.Errorf("hls: server: %w", err)
+	}
+	if o.MaxSegment <= 0 {
+		o.MaxSegment = DefaultMaxSegment
+	}
+	ob := &Observer{
+		opts:     o,
+		base:     base,
+		names:    map[string]bool{},
+		client:   &http.Client{Timeout: 10 * time.Second},
+		snapshot: snapshot,
+		emit:     emit,
+		log:      log.With().Str("module", "hls").Logger(),
+		streams:  map[string]*stream{},
+	}
+	for _, name := range o.Paths {
+		if strings.HasPrefix(name, "~") {
+			re, err := regexp.Compile(name[1:])
+			if err != nil {
+				return nil, fmt.Errorf("hls: path %q: %w", name, err)
+			}
+			ob.res = append(ob.res, re)
+			continue
+		}
+		ob.names[name] = true
+	}
+	return ob, nil
+}
+
+// Run polls every Options.Interval until ctx is done, then emits the receipts
+// of the shard sets still held.
+func (ob *Observer) Run(ctx context.Context) {
+	ob.log.Info().Str("hls", ob.base.Redacted()).Str("dir", ob.opts.Dir).Dur("interval", ob.opts.Interval).Msg("hls: observing segments")
+	t := time.NewTicker(ob.opts.Interval)
+	defer t.Stop()
+	for {
+		ob.poll(ctx)
+		select {
+		case <-ctx.Done():
+			ob.evict(time.Now().Add(2 * streamTTL)) // every stream counts as gone
+			return
+		case <-t.C:
+		}
+	}
+}
+
+// Status returns the per-path counters, sorted by path.
+func (ob *Observer) Status() Status {
+	ob.mu.Lock()
+	defer ob.mu.Unlock()
+	st := Status{HLS: ob.base.Redacted(), Dir: ob.opts.Dir, Paths: make([]PathStatus, 0, len(ob.streams))}
+	if ob.fec() {
+		st.FEC = ob.opts.FEC.String()
+	}
+	for _, s := range ob.streams {
+		st.Paths = append(st.Paths, s.status)
+	}
+	sort.Slice(st.Paths, func(i, j int) bool { return st.Paths[i].Path < st.Paths[j].Path })
+	return st
+}
+
+func (ob *Observer) watched(name string) bool {
+	if len(ob.names) == 0 && len(ob.res) == 0 {
+		return true
+	}
+	if ob.names[name] {
+		return true
+	}
+	for _, re := range ob.res {
+		if re.MatchString(name) {
+			return true
+		}
+	}
+	return false
+}
+
+// poll follows every ready, watched path concurrently; one slow path doesn't
+// hold the others back by more than a poll. Paths gone for streamTTL are
+// dropped afterwards.
+func (ob *Observer) poll(ctx context.Context) {
+	now := time.Now()
+	var wg sync.WaitGroup
+	for _, p := range ob.snapshot() {
+		if !p.Ready || !ob.watched(p.Name) {
+			continue
+		}
+		ob.mu.Lock()
+		s := ob.streams[p.Name]
+		if s == nil {
+			s = &stream{status: PathStatus{Path: p.Name}}
+			ob.streams[p.Name] = s
+		}
+		s.seen = now
+		ob.mu.Unlock()
+
+		wg.Add(1)
+		go func(name string, s *stream) {
+			defer wg.Done()
+			err := ob.follow(ctx, name, s)
+			ob.mu.Lock()
+			s.status.Error = ""
+			if err != nil {
+				s.status.Error = err.Error()
+			}
+			ob.mu.Unlock()
+			if err != nil && ctx.Err() == nil {
+				ob.log.Debug().Err(err).Str("path", name).Msg("hls: poll failed")
+			}
+		}(p.Name, s)
+	}
+	wg.Wait()
+	ob.evict(now)
+}
+
+// evict forgets the paths not seen since streamTTL before now. The receipts
+// of their kept shard sets are emitted with what was delivered so far.
+func (ob *Observer) evict(now time.Time) {
+	ob.mu.Lock()
+	gone := map[string]*stream{}
+	for name, s := range ob.streams {
+		if now.Sub(s.seen) > streamTTL {
+			gone[name] = s
+			delete(ob.streams, name)
+		}
+	}
+	ob.mu.Unlock()
+	for name, s := range gone {
+		for _, k := range s.sets {
+			ob.deliver(s, k.receipt(time.Now()))
+		}
+		ob.log.Debug().Str("path", name).Msg("hls: path gone, stream dropped")
+	}
+}
+
+// follow fetches one path's media playlist and commits the segments that are
+// new since the last poll. Only follow's goroutine touches s's position; the
+// status is shared with Status under ob.mu.
+func (ob *Observer) follow(ctx context.Context, name string, s *stream) error {
+	if s.playlist == nil {
+		u, err := ob.mediaPlaylist(ctx, name)
+		if err != nil {
+			return err
+		}
+		s.playlist = u
+	}
+	seen := time.Now()
+	body, err := ob.get(ctx, s.playlist)
+	if err != nil {
+		s.playlist = nil // the muxer may have restarted under a new name
+		return err
+	}
+	pl, err := parsePlaylist(body)
+	if err != nil {
+		return err
+	}
+	newest, ok := pl.newest()
+	if !ok {
+		return nil
+	}
+
+	switch {
+	case !s.started:
+		// Segments listed before the observer came along have no usable
+		// deadline; start with the newest.
+		s.started, s.next = true, newest
+	case newest+1 < s.next:
+		// The sequence went back: MediaMTX restarted the muxer. Keep Seq
+		// increasing across the restart.
+		ob.log.Info().Str("path", name).Uint64("media_seq", newest).Msg("hls: muxer restarted")
+		s.base += s.next
+		s.next = newest
+	}
+	if s.next < pl.seq {
+		missed := pl.seq - s.next
+		ob.count(s, func(st *PathStatus) { st.Missed += int64(missed) })
+		segmentsTotal.WithLabelValues(ResultMissed).Add(float64(missed))
+		ob.log.Warn().Str("path", name).Uint64("missed", missed).Msg("hls: segments left the playlist before they were fetched")
+		s.next = pl.seq
+	}
+
+	for msn := s.next; msn <= newest; msn++ {
+		seg := pl.segments[msn-pl.seq]
+		if err := ob.commit(ctx, name, s, seg, s.base+msn, seen); err != nil {
+			ob.count(s, func(st *PathStatus) { st.Failed++ })
+			segmentsTotal.WithLabelValues(ResultFailed).Inc()
+			if ctx.Err() != nil {
+				return err
+			}
+			ob.log.Warn().Err(err).Str("path", name).Uint64("media_seq", msn).Msg("hls: segment not committed")
+		}
+		s.next = msn + 1
+	}
+	return nil
+}
+
+// commit hashes one segment and emits its receipt. With FEC the receipt waits
+// in the kept set for viewers to fetch shards; commit emits those of the sets
+// that fall out of the window instead.
+func (ob *Observer) commit(ctx context.Context, name string, s *stream, seg segment, seq uint64, seen time.Time) error {
+	ref, err := url.Parse(seg.uri)
+	if err != nil {
+		return fmt.Errorf("hls: segment uri %q: %w", seg.uri, err)
+	}
+	u := s.playlist.ResolveReference(ref)
+	r := service.SegmentReceipt{
+		Path:     name,
+		Seq:      seq,
+		Deadline: seen.Add(seg.dur),
+	}
+	if !ob.fec() {
+		if r.Commit, r.Size, err = ob.hashSegment(ctx, name, u); err != nil {
+			return err
+		}
+		r.Recv = time.Now()
+		r.Meta = r.Deadline.Sub(r.Recv) // margin left; negative when late
+		ob.deliver(s, r)
+		return nil
+	}
+
+	b, err := ob.readSegment(ctx, name, u)
+	if err != nil {
+		return err
+	}
+	set, err := fec.Encode(b, ob.opts.FEC)
+	if err != nil {
+		return err
+	}
+	r.Size, r.Commit, r.DataShards = int64(len(b)), set.Commit(), ob.opts.FEC.Data
+	for _, k := range ob.keep(s, kept{seq: seq, commit: r.Commit, set: set, r: r}) {
+		ob.deliver(s, k.receipt(time.Now()))
+	}
+	return nil
+}
+
+// deliver emits a receipt and counts it.
+func (ob *Observer) deliver(s *stream, r service.SegmentReceipt) {
+	ob.emit(r)
+
+	late := r.Recv.After(r.Deadline)
+	commit := hex.EncodeToString(r.Commit[:])
+	ob.count(s, func(st *PathStatus) {
+		st.Segments++
+		st.Bytes += r.Size
+		st.LastSeq, st.LastCommit, st.LastAt = r.Seq, commit, r.Recv.UTC()
+		if late {
+			st.Late++
+		}
+	})
+	result := ResultCommitted
+	if late {
+		result = ResultLate
+	}
+	segmentsTotal.WithLabelValues(result).Inc()
+	segmentBytes.Add(float64(r.Size))
+	ob.log.Debug().Str("path", r.Path).Uint64("seq", r.Seq).Int64("size", r.Size).Str("commit", commit).Bool("late", late).Msg("hls: segment committed")
+}
+
+func (ob *Observer) count(s *stream, f func(*PathStatus)) {
+	ob.mu.Lock()
+	f(&s.status)
+	ob.mu.Unlock()
+}
+
+// mediaPlaylist resolves the media playlist of a path's first variant from its
+// index.m3u8. MediaMTX muxes audio into that variant for mpegts; with fmp4
+// separate audio renditions are not committed.
+func (ob *Observer) mediaPlaylist(ctx context.Context, name string) (*url.URL, error) {
+	index := ob.base.ResolveReference(&url.URL{Path: name + "/index.m3u8"})
+	body, err := ob.get(ctx, index)
+	if err != nil {
+		return nil, err
+	}
+	pl, err := parsePlaylist(body)
+	if err != nil {
+		return nil, err
+	}
+	if len(pl.variants) == 0 {
+		return index, nil // already a media playlist
+	}
+	ref, err := url.Parse(pl.variants[0])
+	if err != nil {
+		return nil, fmt.Errorf("hls: variant uri %q: %w", pl.variants[0], err)
+	}
+	return index.ResolveReference(ref), nil
+}
+
+// openSegment opens a segment from Options.Dir when it is there, else over HTTP.
+func (ob *Observer) openSegment(ctx context.Context, name string, u *url.URL) (io.ReadCloser, error) {
+	if ob.opts.Dir != "" {
+		f, err := os.Open(filepath.Join(ob.opts.Dir, filepath.FromSlash(name), path.Base(u.Path)))
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return nil, fmt.Errorf("hls: %w", err)
+		}
+		if err == nil {
+			return f, nil
+		}
+	}
+	return ob.open(ctx, u)
+}
+
+// hashSegment streams a segment through SHA-256.
+func (ob *Observer) hashSegment(ctx context.Context, name string, u *url.URL) ([32]byte, int64, error) {
+	var sum [32]byte
+	rc, err := ob.openSegment(ctx, name, u)
+	if err != nil {
+		return sum, 0, err
+	}
+	defer rc.Close()
+	h := sha256.New()
+	n, err := io.Copy(h, io.LimitReader(rc, ob.opts.MaxSegment+1))
+	if err != nil {
+		return sum, 0, fmt.Errorf("hls: read %s: %w", u.Redacted(), err)
+	}
+	if n > ob.opts.MaxSegment {
+		return sum, 0, fmt.Errorf("hls: segment %s larger than %d bytes", u.Redacted(), ob.opts.MaxSegment)
+	}
+	copy(sum[:], h.Sum(nil))
+	return sum, n, nil
+}
+
+// readSegment reads a whole segment for FEC encoding.
+func (ob *Observer) readSegment(ctx context.Context, name string, u *url.URL) ([]byte, error) {
+	rc, err := ob.openSegment(ctx, name, u)
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+	b, err := io.ReadAll(io.LimitReader(rc, ob.opts.MaxSegment+1))
+	if err != nil {
+		return nil, fmt.Errorf("hls: read %s: %w", u.Redacted(), err)
+	}
+	if int64(len(b)) > ob.opts.MaxSegment {
+		return nil, fmt.Errorf("hls: segment %s larger than %d bytes", u.Redacted(), ob.opts.MaxSegment)
+	}
+	return b, nil
+}
+
+// get fetches a playlist.
+func (ob *Observer) get(ctx context.Context, u *url.URL) ([]byte, error) {
+	rc, err := ob.open(ctx, u)
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+	b, err := io.ReadAll(io.LimitReader(rc, 1<<20))
+	if err != nil {
+		return nil, fmt.Errorf("hls: read %s: %w", u.Redacted(), err)
+	}
+	return b, nil
+}
+
+func (ob *Observer) open(ctx context.Context, u *url.URL) (io.ReadCloser, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, fmt.Errorf("hls: %w", err)
+	}
+	if ob.base.User != nil {
+		pass, _ := ob.base.User.Password()
+		req.SetBasicAuth(ob.base.User.Username(), pass)
+	}
+	resp, err := ob.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("hls: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, fmt.Errorf("hls: GET %s: %s", u.Redacted(), resp.Status)
+	}
+	return resp.Body, nil
+}
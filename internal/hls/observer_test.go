@@ -0,0 +1,160 @@
+package hls
+
+import (
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"slowdrip-miner/internal/fec"
+	"slowdrip-miner/internal/mediamtx"
+	"slowdrip-miner/internal/service"
+
+	"github.com/rs/zerolog"
+)
+
+// fakeHLS serves live/index.m3u8 listing segs as seg<i>.ts from media
+// sequence 0.
+type fakeHLS struct {
+	mu   sync.Mutex
+	segs [][]byte
+}
+
+func (f *fakeHLS) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if r.URL.Path == "/live/index.m3u8" {
+		var b strings.Builder
+		b.WriteString("#EXTM3U\n#EXT-X-TARGETDURATION:10\n#EXT-X-MEDIA-SEQUENCE:0\n")
+		for i := range f.segs {
+			fmt.Fprintf(&b, "#EXTINF:10,\nseg%d.ts\n", i)
+		}
+		w.Write([]byte(b.String()))
+		return
+	}
+	var i int
+	if _, err := fmt.Sscanf(r.URL.Path, "/live/seg%d.ts", &i); err != nil || i >= len(f.segs) {
+		http.NotFound(w, r)
+		return
+	}
+	w.Write(f.segs[i])
+}
+
+func (f *fakeHLS) add(seg []byte) {
+	f.mu.Lock()
+	f.segs = append(f.segs, seg)
+	f.mu.Unlock()
+}
+
+func newObserver(t *testing.T, o Options, f *fakeHLS) (*Observer, *[]service.SegmentReceipt) {
+	t.Helper()
+	srv := httptest.NewServer(f)
+	t.Cleanup(srv.Close)
+	o.HLS, o.Interval = srv.URL, time.Second
+	var got []service.SegmentReceipt
+	snap := func() []mediamtx.Path { return []mediamtx.Path{{Name: "live", Ready: true}} }
+	ob, err := New(o, snap, func(r service.SegmentReceipt) { got = append(got, r) }, zerolog.Nop())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return ob, &got
+}
+
+func TestCommitCapsSegments(t *testing.T) {
+	f := &fakeHLS{}
+	ob, got := newObserver(t, Options{MaxSegment: 100}, f)
+	f.add([]byte("first"))
+	ob.poll(context.Background()) // starts at the newest segment
+	f.add([]byte(strings.Repeat("x", 100)))
+	f.add([]byte(strings.Repeat("x", 101)))
+	ob.poll(context.Background())
+
+	if len(*got) != 2 {
+		t.Fatalf("%d receipts, want 2", len(*got))
+	}
+	for i, want := range []string{"first", strings.Repeat("x", 100)} {
+		r := (*got)[i]
+		if r.Seq != uint64(i) || r.Size != int64(len(want)) || r.Commit != sha256.Sum256([]byte(want)) || r.DataShards != 0 {
+			t.Errorf("receipt %d: %+v", i, r)
+		}
+	}
+	if st := ob.Status().Paths[0]; st.Failed != 1 || st.Segments != 2 {
+		t.Fatalf("status %+v, want 2 segments and 1 failed", st)
+	}
+}
+
+func TestShardsDelivered(t *testing.T) {
+	p := fec.Params{Data: 2, Parity: 1}
+	tests := []struct {
+		name   string
+		fetch  []int // shard indexes fetched from ShardsPath, repeats included
+		shards uint64
+		late   bool
+	}{
+		{name: "every shard", fetch: []int{0, 1, 2}, shards: 0x7},
+		{name: "enough to rebuild", fetch: []int{2, 0}, shards: 0x5},
+		{name: "one shard, fetched twice", fetch: []int{1, 1}, shards: 0x2},
+		{name: "nothing fetched", late: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := &fakeHLS{}
+			ob, got := newObserver(t, Options{FEC: p, Keep: 1}, f)
+			seg := []byte(strings.Repeat("segment", 50))
+			f.add(seg)
+			ob.poll(context.Background())
+			if len(*got) != 0 {
+				t.Fatalf("receipt emitted before the set left the window: %+v", *got)
+			}
+			for _, i := range tt.fetch {
+				rec := httptest.NewRecorder()
+				ob.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, fmt.Sprintf("%s?path=live&seq=0&index=%d", ShardsPath, i), nil))
+				if rec.Code != http.StatusOK {
+					t.Fatalf("shard %d: %d %s", i, rec.Code, rec.Body)
+				}
+			}
+			// Late enough for the deadline when nothing was fetched.
+			(*ob.streams["live"]).sets[0].r.Deadline = time.Now().Add(time.Millisecond)
+			time.Sleep(5 * time.Millisecond)
+
+			f.add([]byte("next"))
+			ob.poll(context.Background()) // segment 0 leaves the window
+			if len(*got) != 1 {
+				t.Fatalf("%d receipts, want 1", len(*got))
+			}
+			r := (*got)[0]
+			set, _ := fec.Encode(seg, p)
+			if r.Seq != 0 || r.Commit != set.Commit() || r.DataShards != p.Data || r.Shards != tt.shards {
+				t.Fatalf("receipt %+v, want shards %#x", r, tt.shards)
+			}
+			if late := r.Recv.After(r.Deadline); late != tt.late {
+				t.Fatalf("late = %v, want %v (recv %s, deadline %s)", late, tt.late, r.Recv, r.Deadline)
+			}
+
+			// Stopping emits what is still held.
+			ob.evict(time.Now().Add(2 * streamTTL))
+			if len(*got) != 2 || (*got)[1].Seq != 1 || (*got)[1].Shards != 0 {
+				t.Fatalf("after stop: %+v", *got)
+			}
+		})
+	}
+}
+
+func TestEvict(t *testing.T) {
+	ob, err := New(Options{HLS: "http://127.0.0.1:1", Interval: time.Second}, func() []mediamtx.Path { return nil }, func(service.SegmentReceipt) {}, zerolog.Nop())
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	ob.streams["live"] = &stream{status: PathStatus{Path: "live"}, seen: now.Add(-streamTTL / 2)}
+	ob.streams["gone"] = &stream{status: PathStatus{Path: "gone"}, seen: now.Add(-2 * streamTTL)}
+	ob.evict(now)
+	if st := ob.Status(); len(st.Paths) != 1 || st.Paths[0].Path != "live" {
+		t.Fatalf("paths %+v, want only live", st.Paths)
+	}
+}
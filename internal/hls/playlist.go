@@ -0,0 +1,86 @@
+// internal/hls/playlist.go
+package hls
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// playlist is the part of an m3u8 the observer needs. A multivariant playlist
+// only has variants; a media playlist has the segments.
+type playlist struct {
+	variants []string  // EXT-X-STREAM-INF URIs, in order
+	seq      uint64    // EXT-X-MEDIA-SEQUENCE: media sequence number of segments[0]
+	segments []segment // complete segments; LL-HLS parts are ignored
+}
+
+type segment struct {
+	uri string
+	dur time.Duration // EXTINF
+}
+
+// newest is the media sequence number of the last segment; ok is false when
+// the playlist has none yet.
+func (p *playlist) newest() (msn uint64, ok bool) {
+	if len(p.segments) == 0 {
+		return 0, false
+	}
+	return p.seq + uint64(len(p.segments)) - 1, true
+}
+
+// parsePlaylist reads an m3u8 document. Only the tags MediaMTX writes and the
+// observer uses are understood; the rest are skipped.
+func parsePlaylist(b []byte) (*playlist, error) {
+	sc := bufio.NewScanner(bytes.NewReader(b))
+	sc.Buffer(make([]byte, 64<<10), 1<<20)
+	if !sc.Scan() || strings.TrimSpace(sc.Text()) != "#EXTM3U" {
+		return nil, errors.New("hls: not an m3u8 playlist")
+	}
+
+	p := &playlist{}
+	var (
+		inf     *segment // EXTINF waiting for its URI
+		variant bool     // EXT-X-STREAM-INF waiting for its URI
+	)
+	for sc.Scan() {
+		line := strings.TrimSpace(sc.Text())
+		switch {
+		case line == "":
+		case strings.HasPrefix(line, "#EXT-X-MEDIA-SEQUENCE:"):
+			n, err := strconv.ParseUint(strings.TrimPrefix(line, "#EXT-X-MEDIA-SEQUENCE:"), 10, 64)
+			if err != nil {
+				return nil, errors.New("hls: bad EXT-X-MEDIA-SEQUENCE")
+			}
+			p.seq = n
+		case strings.HasPrefix(line, "#EXTINF:"):
+			v := strings.TrimPrefix(line, "#EXTINF:")
+			if i := strings.IndexByte(v, ','); i >= 0 {
+				v = v[:i]
+			}
+			secs, err := strconv.ParseFloat(v, 64)
+			if err != nil || secs < 0 {
+				return nil, errors.New("hls: bad EXTINF")
+			}
+			inf = &segment{dur: time.Duration(secs * float64(time.Second))}
+		case strings.HasPrefix(line, "#EXT-X-STREAM-INF:"):
+			variant = true
+		case strings.HasPrefix(line, "#"):
+			// other tags, including EXT-X-PART and EXT-X-PRELOAD-HINT
+		case variant:
+			p.variants = append(p.variants, line)
+			variant = false
+		case inf != nil:
+			inf.uri = line
+			p.segments = append(p.segments, *inf)
+			inf = nil
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
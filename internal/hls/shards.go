@@ -0,0 +1,167 @@
+// internal/hls/shards.go
+package hls
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"slowdrip-miner/internal/fec"
+	"slowdrip-miner/internal/service"
+)
+
+// ShardsPath serves the shards of recent segments when FEC is on:
+//
+//	GET /v1/shards?path=live/a&seq=42           commitment, layout and shard leaves
+//	GET /v1/shards?path=live/a&seq=42&index=3   shard 3 with its Merkle proof
+//
+// A viewer that verifies any FEC.Data shards against the receipt's commit can
+// rebuild the segment with fec.Reconstruct. Every shard fetched by index is
+// marked delivered in the segment's receipt.
+const ShardsPath = "/v1/shards"
+
+// kept is a shard set retained for ShardsPath, with its receipt waiting for
+// the shards served.
+type kept struct {
+	seq    uint64
+	commit [32]byte
+	set    *fec.Set
+	r      service.SegmentReceipt
+	served uint64    // bit i: shard i fetched
+	last   time.Time // when the last newly fetched shard went out
+}
+
+// receipt is the segment's receipt with the shards served by now. Recv is when
+// the last of them went out; with none it is now, and the receipt is late.
+func (k kept) receipt(now time.Time) service.SegmentReceipt {
+	r := k.r
+	r.Shards, r.Recv = k.served, k.last
+	if k.served == 0 {
+		r.Recv = now
+	}
+	r.Meta = r.Deadline.Sub(r.Recv) // margin left; negative when late
+	return r
+}
+
+// SetInfo describes a retained shard set.
+type SetInfo struct {
+	Path   string     `json:"path"`
+	Seq    uint64     `json:"seq"`
+	Commit string     `json:"commit"`
+	FEC    fec.Params `json:"fec"`
+	Size   int64      `json:"size"`
+	Root   fec.Hash   `json:"root"`
+	Leaves []fec.Hash `json:"leaves"`
+}
+
+// Shard is one shard with its proof; Data is base64 in JSON.
+type Shard struct {
+	Path   string         `json:"path"`
+	Seq    uint64         `json:"seq"`
+	Commit string         `json:"commit"`
+	Proof  fec.ShardProof `json:"proof"`
+	Data   []byte         `json:"data"`
+}
+
+func (ob *Observer) fec() bool { return ob.opts.FEC.Data > 0 }
+
+// keep retains a segment's shard set and returns the oldest beyond
+// Options.Keep, which it drops.
+func (ob *Observer) keep(s *stream, k kept) []kept {
+	ob.mu.Lock()
+	defer ob.mu.Unlock()
+	s.sets = append(s.sets, k)
+	n := len(s.sets) - ob.opts.Keep
+	if n <= 0 {
+		return nil
+	}
+	out := append([]kept(nil), s.sets[:n]...)
+	s.sets = append(s.sets[:0:0], s.sets[n:]...)
+	return out
+}
+
+func (ob *Observer) lookup(path string, seq uint64) (kept, bool) {
+	ob.mu.Lock()
+	defer ob.mu.Unlock()
+	if s := ob.streams[path]; s != nil {
+		for _, k := range s.sets {
+			if k.seq == seq {
+				return k, true
+			}
+		}
+	}
+	return kept{}, false
+}
+
+// served marks shard i of a retained set delivered.
+func (ob *Observer) served(path string, seq uint64, i int, now time.Time) {
+	ob.mu.Lock()
+	defer ob.mu.Unlock()
+	s := ob.streams[path]
+	if s == nil {
+		return
+	}
+	for j := range s.sets {
+		if k := &s.sets[j]; k.seq == seq && k.served&(1<<uint(i)) == 0 {
+			k.served |= 1 << uint(i)
+			k.last = now
+		}
+	}
+}
+
+// ServeHTTP serves ShardsPath.
+func (ob *Observer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !ob.fec() {
+		http.Error(w, "fec not enabled", http.StatusNotFound)
+		return
+	}
+	q := r.URL.Query()
+	path := q.Get("path")
+	seq, err := strconv.ParseUint(q.Get("seq"), 10, 64)
+	if path == "" || err != nil {
+		http.Error(w, "path and seq required", http.StatusBadRequest)
+		return
+	}
+	k, ok := ob.lookup(path, seq)
+	if !ok {
+		http.Error(w, "segment not retained", http.StatusNotFound)
+		return
+	}
+	commit := hex.EncodeToString(k.commit[:])
+
+	if q.Get("index") == "" {
+		writeJSON(w, SetInfo{
+			Path:   path,
+			Seq:    seq,
+			Commit: commit,
+			FEC:    k.set.Params,
+			Size:   k.set.Size,
+			Root:   k.set.Root,
+			Leaves: k.set.Leaves,
+		})
+		return
+	}
+	i, err := strconv.Atoi(q.Get("index"))
+	if err != nil {
+		http.Error(w, "bad index", http.StatusBadRequest)
+		return
+	}
+	proof, err := k.set.Proof(i)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	ob.served(path, seq, i, time.Now())
+	writeJSON(w, Shard{Path: path, Seq: seq, Commit: commit, Proof: proof, Data: k.set.Shards[i]})
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(v)
+}
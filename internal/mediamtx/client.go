@@ -1,8 +1,16 @@
 package mediamtx
 
 import (
+	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
 	"net/http"
+	"net/url"
+	"sort"
+	"strings"
 	"time"
 
 	"github.com/rs/zerolog"
@@ -43,3 +51,245 @@ func (c *Client) Paths() ([]string, error) {
 	}
 	return names, nil
 }
+
+// Path is the subset of a MediaMTX /v3/paths/list item the miner cares about.
+type Path struct {
+	Name          string    `json:"name"`
+	Ready         bool      `json:"ready"`
+	ReadyTime     time.Time `json:"readyTime"`
+	Source        *Ref      `json:"source"`
+	Readers       []Ref     `json:"readers"`
+	BytesReceived uint64    `json:"bytesReceived"`
+	BytesSent     uint64    `json:"bytesSent"`
+}
+
+// Ref identifies a source or reader session (e.g. {type: "webRTCSession", id: "..."}).
+type Ref struct {
+	Type string `json:"type"`
+	ID   string `json:"id"`
+}
+
+// ListPaths returns full path descriptions, including readers.
+func (c *Client) ListPaths() ([]Path, error) {
+	resp, err := c.http.Get(c.base + "/v3/paths/list")
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("mediamtx: paths/list: %s", resp.Status)
+	}
+
+	var out struct {
+		Items []Path `json:"items"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+		return nil, err
+	}
+	return out.Items, nil
+}
+
+// ------------------------------------------------------------
+// Sessions (/v3/<kind>/list, /v3/<kind>/kick/{id})
+// ------------------------------------------------------------
+
+// SessionKinds are the API collections that hold kickable sessions. HLS has
+// none: its readers share a muxer.
+var SessionKinds = []string{"rtspsessions", "rtspssessions", "rtmpconns", "rtmpsconns", "webrtcsessions", "srtconns"}
+
+// Session is a reader or publisher connection of any protocol.
+type Session struct {
+	Kind          string    `json:"kind"` // one of SessionKinds
+	ID            string    `json:"id"`
+	Created       time.Time `json:"created"`
+	RemoteAddr    string    `json:"remoteAddr"`
+	State         string    `json:"state"` // read | publish | idle
+	Path          string    `json:"path"`
+	Query         string    `json:"query"`
+	BytesReceived uint64    `json:"bytesReceived"`
+	BytesSent     uint64    `json:"bytesSent"`
+}
+
+// SessionListError names the session collections ListSessions could not read.
+// The sessions of the other collections are returned with it.
+type SessionListError struct {
+	Kinds map[string]error
+}
+
+func (e *SessionListError) Error() string {
+	kinds := make([]string, 0, len(e.Kinds))
+	for k := range e.Kinds {
+		kinds = append(kinds, k)
+	}
+	sort.Strings(kinds)
+	msgs := make([]string, len(kinds))
+	for i, k := range kinds {
+		msgs[i] = e.Kinds[k].Error()
+	}
+	return "mediamtx: list sessions: " + strings.Join(msgs, "; ")
+}
+
+// ListSessions returns the sessions of every protocol. Collections of disabled
+// protocols (404) are skipped. A collection that fails otherwise is skipped too
+// and reported in a *SessionListError, so one broken protocol does not hide the
+// sessions of the others.
+func (c *Client) ListSessions(ctx context.Context) ([]Session, error) {
+	var out []Session
+	failed := map[string]error{}
+	for _, kind := range SessionKinds {
+		for page := 0; ; page++ {
+			var resp struct {
+				PageCount int       `json:"pageCount"`
+				Items     []Session `json:"items"`
+			}
+			err := c.do(ctx, http.MethodGet, fmt.Sprintf("/v3/%s/list?page=%d&itemsPerPage=100", kind, page), nil, &resp)
+			var ae *apiError
+			if errors.As(err, &ae) && ae.status == http.StatusNotFound {
+				break
+			}
+			if err != nil {
+				if ctx.Err() != nil {
+					return nil, err
+				}
+				failed[kind] = err
+				break
+			}
+			for _, s := range resp.Items {
+				s.Kind = kind
+				out = append(out, s)
+			}
+			if page+1 >= resp.PageCount {
+				break
+			}
+		}
+	}
+	if len(failed) > 0 {
+		return out, &SessionListError{Kinds: failed}
+	}
+	return out, nil
+}
+
+// KickSession closes a session; kind is its Session.Kind.
+func (c *Client) KickSession(ctx context.Context, kind, id string) error {
+	return c.do(ctx, http.MethodPost, "/v3/"+kind+"/kick/"+url.PathEscape(id), nil, nil)
+}
+
+// ------------------------------------------------------------
+// Config API (/v3/config/...)
+// ------------------------------------------------------------
+
+// ErrPathNotFound is returned by PathConfig for a path MediaMTX has no configuration for.
+var ErrPathNotFound = errors.New("mediamtx: path not configured")
+
+// PatchGlobalConfig changes global settings live; only the fields present in
+// the encoded patch are touched.
+func (c *Client) PatchGlobalConfig(ctx context.Context, patch interface{}) error {
+	return c.do(ctx, http.MethodPatch, "/v3/config/global/patch", patch, nil)
+}
+
+// PathConfig returns the stored configuration of a path (regex names included).
+func (c *Client) PathConfig(ctx context.Context, name string) (map[string]interface{}, error) {
+	var out map[string]interface{}
+	err := c.do(ctx, http.MethodGet, "/v3/config/paths/get/"+escapePath(name), nil, &out)
+	var ae *apiError
+	if errors.As(err, &ae) && ae.status == http.StatusNotFound {
+		return nil, ErrPathNotFound
+	}
+	return out, err
+}
+
+// ListPathConfigs returns every configured path (all pages), keyed by name.
+func (c *Client) ListPathConfigs(ctx context.Context) (map[string]map[string]interface{}, error) {
+	out := map[string]map[string]interface{}{}
+	for page := 0; ; page++ {
+		var resp struct {
+			PageCount int                      `json:"pageCount"`
+			Items     []map[string]interface{} `json:"items"`
+		}
+		if err := c.do(ctx, http.MethodGet, fmt.Sprintf("/v3/config/paths/list?page=%d&itemsPerPage=100", page), nil, &resp); err != nil {
+			return nil, err
+		}
+		for _, it := range resp.Items {
+			if name, _ := it["name"].(string); name != "" {
+				out[name] = it
+			}
+		}
+		if page+1 >= resp.PageCount {
+			return out, nil
+		}
+	}
+}
+
+// AddPathConfig creates a path configuration.
+func (c *Client) AddPathConfig(ctx context.Context, name string, conf interface{}) error {
+	return c.do(ctx, http.MethodPost, "/v3/config/paths/add/"+escapePath(name), conf, nil)
+}
+
+// PatchPathConfig changes the given fields of an existing path configuration.
+func (c *Client) PatchPathConfig(ctx context.Context, name string, conf interface{}) error {
+	return c.do(ctx, http.MethodPatch, "/v3/config/paths/patch/"+escapePath(name), conf, nil)
+}
+
+// DeletePathConfig removes a path configuration.
+func (c *Client) DeletePathConfig(ctx context.Context, name string) error {
+	return c.do(ctx, http.MethodDelete, "/v3/config/paths/delete/"+escapePath(name), nil, nil)
+}
+
+// apiError is a non-2xx reply from the MediaMTX API.
+type apiError struct {
+	method, path string
+	status       int
+	msg          string
+}
+
+func (e *apiError) Error() string {
+	return fmt.Sprintf("mediamtx: %s %s: %s", e.method, e.path, e.msg)
+}
+
+// escapePath escapes a path name for the URL, keeping its "/" separators.
+func escapePath(name string) string {
+	parts := strings.Split(name, "/")
+	for i, p := range parts {
+		parts[i] = url.PathEscape(p)
+	}
+	return strings.Join(parts, "/")
+}
+
+// do sends a JSON request and decodes a JSON reply into out (if non-nil).
+// MediaMTX reports failures as {"error": "..."}.
+func (c *Client) do(ctx context.Context, method, path string, in, out interface{}) error {
+	var body io.Reader
+	if in != nil {
+		b, err := json.Marshal(in)
+		if err != nil {
+			return err
+		}
+		body = bytes.NewReader(b)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, c.base+path, body)
+	if err != nil {
+		return err
+	}
+	if in != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		var e struct {
+			Error string `json:"error"`
+		}
+		_ = json.NewDecoder(io.LimitReader(resp.Body, 4096)).Decode(&e)
+		if e.Error == "" {
+			e.Error = resp.Status
+		}
+		return &apiError{method: method, path: path, status: resp.StatusCode, msg: e.Error}
+	}
+	if out == nil {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
@@ -0,0 +1,353 @@
+// internal/mediamtx/conf.go
+package mediamtx
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ServerConfig is what the miner manages in MediaMTX: a set of global settings
+// and an ordered list of path rules. It can be rendered as mediamtx.yml or
+// applied live through the config API.
+type ServerConfig struct {
+	Global      GlobalConf
+	Paths       []PathRule
+	PrunePaths  bool   // Apply also deletes configured paths that are not in Paths
+	HookCommand string // runOn* command for every path, "{event}" replaced (see HookEvent); "" = none
+	Record      RecordConf
+
+	// Unset names global keys whose miner.yaml value is the default of an
+	// unset ${VAR} (key -> VAR). Render leaves them out of the file instead of
+	// writing the placeholder; MediaMTX then takes them from MTX_<KEY>.
+	Unset map[string]string
+}
+
+// RecordConf sets where and how every path records (when its record flag is
+// on). Empty fields keep MediaMTX's defaults.
+type RecordConf struct {
+	Path            string // recordPath, e.g. "./recordings/%path/%Y-%m-%d_%H-%M-%S-%f"
+	Format          string // fmp4 | mpegts
+	SegmentDuration string
+	DeleteAfter     string // "0s" when the miner enforces retention itself
+}
+
+// GlobalConf is the part of MediaMTX's global configuration the miner owns.
+// Keys are MediaMTX's. Lists are always sent, so an entry removed from
+// miner.yaml is removed from MediaMTX too; empty strings keep MediaMTX's value.
+type GlobalConf struct {
+	WebRTCAdditionalHosts []string    `json:"webrtcAdditionalHosts" yaml:"webrtcAdditionalHosts"`
+	WebRTCICEServers2     []ICEServer `json:"webrtcICEServers2" yaml:"webrtcICEServers2"`
+
+	AuthMethod      string `json:"authMethod,omitempty" yaml:"authMethod,omitempty"`
+	AuthJWTJWKS     string `json:"authJWTJWKS,omitempty" yaml:"authJWTJWKS,omitempty"`
+	AuthJWTClaimKey string `json:"authJWTClaimKey,omitempty" yaml:"authJWTClaimKey,omitempty"`
+	AuthHTTPAddress string `json:"authHTTPAddress,omitempty" yaml:"authHTTPAddress,omitempty"`
+
+	// Built from the path rules when AuthMethod is "internal" (see internalUsers).
+	AuthInternalUsers []AuthInternalUser `json:"authInternalUsers,omitempty" yaml:"authInternalUsers,omitempty"`
+
+	HLSVariant         string `json:"hlsVariant,omitempty" yaml:"hlsVariant,omitempty"`
+	HLSSegmentCount    int    `json:"hlsSegmentCount,omitempty" yaml:"hlsSegmentCount,omitempty"`
+	HLSSegmentDuration string `json:"hlsSegmentDuration,omitempty" yaml:"hlsSegmentDuration,omitempty"`
+	HLSPartDuration    string `json:"hlsPartDuration,omitempty" yaml:"hlsPartDuration,omitempty"`
+	HLSAlwaysRemux     bool   `json:"hlsAlwaysRemux" yaml:"hlsAlwaysRemux"`
+	HLSDirectory       string `json:"hlsDirectory,omitempty" yaml:"hlsDirectory,omitempty"`
+}
+
+// ICEServer is one entry of webrtcICEServers2.
+type ICEServer struct {
+	URL        string `json:"url" yaml:"url"`
+	Username   string `json:"username,omitempty" yaml:"username,omitempty"`
+	Password   string `json:"password,omitempty" yaml:"password,omitempty"`
+	ClientOnly bool   `json:"clientOnly,omitempty" yaml:"clientOnly,omitempty"`
+}
+
+// AuthInternalUser is one entry of authInternalUsers.
+type AuthInternalUser struct {
+	User        string           `json:"user" yaml:"user"`
+	Pass        string           `json:"pass" yaml:"pass"`
+	IPs         []string         `json:"ips" yaml:"ips"`
+	Permissions []AuthPermission `json:"permissions" yaml:"permissions"`
+}
+
+// AuthPermission allows an action (publish, read, playback, api, ...) on a path ("" = any).
+type AuthPermission struct {
+	Action string `json:"action" yaml:"action"`
+	Path   string `json:"path,omitempty" yaml:"path,omitempty"`
+}
+
+// PathRule is a named (or "~regex") path configuration.
+type PathRule struct {
+	Name string
+	Conf PathConf
+	Auth PathAuth // not part of the path config: becomes authInternalUsers entries
+}
+
+// PathAuth holds per-path credentials; an empty user leaves that action open.
+type PathAuth struct {
+	PublishUser, PublishPass string
+	ReadUser, ReadPass       string
+}
+
+// PathConf is the part of a path's configuration the miner sets. Every field is
+// always sent, so a value changed by hand is put back by the next reconcile.
+type PathConf struct {
+	Source         string `json:"source" yaml:"source"`
+	SourceOnDemand bool   `json:"sourceOnDemand" yaml:"sourceOnDemand"`
+	MaxReaders     int    `json:"maxReaders" yaml:"maxReaders"`
+	Record         bool   `json:"record" yaml:"record"`
+
+	// Set from ServerConfig.Record; left alone when empty.
+	RecordPath            string `json:"recordPath,omitempty" yaml:"recordPath,omitempty"`
+	RecordFormat          string `json:"recordFormat,omitempty" yaml:"recordFormat,omitempty"`
+	RecordSegmentDuration string `json:"recordSegmentDuration,omitempty" yaml:"recordSegmentDuration,omitempty"`
+	RecordDeleteAfter     string `json:"recordDeleteAfter,omitempty" yaml:"recordDeleteAfter,omitempty"`
+
+	// Set from ServerConfig.HookCommand. The API always gets them, empty when hooks
+	// are off, so turning hooks off clears commands applied earlier; the rendered
+	// file is written whole and leaves them out.
+	RunOnReady    string `json:"runOnReady" yaml:"runOnReady,omitempty"`
+	RunOnNotReady string `json:"runOnNotReady" yaml:"runOnNotReady,omitempty"`
+	RunOnRead     string `json:"runOnRead" yaml:"runOnRead,omitempty"`
+	RunOnUnread   string `json:"runOnUnread" yaml:"runOnUnread,omitempty"`
+}
+
+// rules returns the path rules with the recording settings and hook commands
+// filled in.
+func (sc ServerConfig) rules() []PathRule {
+	cmd := func(ev string) string {
+		if strings.Contains(sc.HookCommand, "{event}") {
+			return strings.ReplaceAll(sc.HookCommand, "{event}", ev)
+		}
+		return sc.HookCommand + " " + ev
+	}
+	out := make([]PathRule, len(sc.Paths))
+	for i, r := range sc.Paths {
+		r.Conf.RecordPath = sc.Record.Path
+		r.Conf.RecordFormat = sc.Record.Format
+		r.Conf.RecordSegmentDuration = sc.Record.SegmentDuration
+		r.Conf.RecordDeleteAfter = sc.Record.DeleteAfter
+		if sc.HookCommand != "" {
+			r.Conf.RunOnReady = cmd(HookReady)
+			r.Conf.RunOnNotReady = cmd(HookNotReady)
+			r.Conf.RunOnRead = cmd(HookRead)
+			r.Conf.RunOnUnread = cmd(HookUnread)
+		}
+		out[i] = r
+	}
+	return out
+}
+
+// global returns the global settings with authInternalUsers filled in.
+func (sc ServerConfig) global() GlobalConf {
+	g := sc.Global
+	if g.AuthMethod == "internal" {
+		g.AuthInternalUsers = internalUsers(sc.Paths)
+	}
+	if g.WebRTCAdditionalHosts == nil {
+		g.WebRTCAdditionalHosts = []string{}
+	}
+	if g.WebRTCICEServers2 == nil {
+		g.WebRTCICEServers2 = []ICEServer{}
+	}
+	return g
+}
+
+// internalUsers turns path credentials into authInternalUsers: one entry per
+// credential, plus "any" for the actions no credential guards. Every permission
+// is scoped to its own rule's path, and a credentialed action is never opened.
+// Like MediaMTX's default, the API and metrics stay open to localhost only (the
+// miner uses them).
+//
+// MediaMTX grants a permission when any entry's pattern matches, so an open
+// "~regex" rule that also matches a credentialed path name would let anyone
+// through. Such a regex rule loses its open permission for that action instead:
+// every path it covers fails closed. AuthOverlaps reports these.
+func internalUsers(rules []PathRule) []AuthInternalUser {
+	users := []AuthInternalUser{{
+		User:        "any",
+		IPs:         []string{"127.0.0.1", "::1"},
+		Permissions: []AuthPermission{{Action: "api"}, {Action: "metrics"}, {Action: "pprof"}},
+	}}
+	var open []AuthPermission
+	for _, r := range rules {
+		if r.Auth.PublishUser != "" {
+			users = append(users, AuthInternalUser{
+				User: r.Auth.PublishUser, Pass: r.Auth.PublishPass, IPs: []string{},
+				Permissions: []AuthPermission{{Action: "publish", Path: r.Name}},
+			})
+		} else if len(shadowed(r, rules, "publish")) == 0 {
+			open = append(open, AuthPermission{Action: "publish", Path: r.Name})
+		}
+		if r.Auth.ReadUser != "" {
+			users = append(users, AuthInternalUser{
+				User: r.Auth.ReadUser, Pass: r.Auth.ReadPass, IPs: []string{},
+				Permissions: []AuthPermission{{Action: "read", Path: r.Name}, {Action: "playback", Path: r.Name}},
+			})
+		} else if len(shadowed(r, rules, "read")) == 0 {
+			open = append(open, AuthPermission{Action: "read", Path: r.Name}, AuthPermission{Action: "playback", Path: r.Name})
+		}
+	}
+	if len(open) > 0 {
+		users = append(users, AuthInternalUser{User: "any", IPs: []string{}, Permissions: open})
+	}
+	return users
+}
+
+// guarded reports whether r needs a credential for action ("publish" or "read").
+func (r PathRule) guarded(action string) bool {
+	if action == "publish" {
+		return r.Auth.PublishUser != ""
+	}
+	return r.Auth.ReadUser != ""
+}
+
+// shadowed returns the credentialed rules for action that open rule r would
+// also match if its permission were granted: literal names r's regex matches.
+// Only regex rules can shadow; a bad regex shadows nothing (validation rejects it).
+func shadowed(r PathRule, rules []PathRule, action string) []string {
+	if r.guarded(action) || !strings.HasPrefix(r.Name, "~") {
+		return nil
+	}
+	re, err := regexp.Compile(r.Name[1:])
+	if err != nil {
+		return nil
+	}
+	var out []string
+	for _, o := range rules {
+		if o.guarded(action) && !strings.HasPrefix(o.Name, "~") && re.MatchString(o.Name) {
+			out = append(out, o.Name)
+		}
+	}
+	return out
+}
+
+// AuthOverlaps describes path rules whose permissions overlap when per-path
+// credentials are compiled into authInternalUsers: open regex rules closed
+// because they match a credentialed path, and open regex rules next to a
+// credentialed regex rule, which may match the same names (undecidable here).
+// It returns nil unless auth.method is "internal".
+func (sc ServerConfig) AuthOverlaps() []string {
+	if sc.Global.AuthMethod != "internal" {
+		return nil
+	}
+	var out []string
+	for _, r := range sc.Paths {
+		if !strings.HasPrefix(r.Name, "~") {
+			continue
+		}
+		for _, action := range []string{"publish", "read"} {
+			if names := shadowed(r, sc.Paths, action); len(names) > 0 {
+				out = append(out, fmt.Sprintf("%q matches credentialed %s, so nobody may %s on its other paths without a credential", r.Name, strings.Join(names, ", "), action))
+			}
+			if r.guarded(action) {
+				continue
+			}
+			for _, o := range sc.Paths {
+				if o.Name != r.Name && strings.HasPrefix(o.Name, "~") && o.guarded(action) {
+					out = append(out, fmt.Sprintf("%q allows anyone to %s and may also match credentialed %q", r.Name, action, o.Name))
+				}
+			}
+		}
+	}
+	return out
+}
+
+// renderHeader and staticConf open every rendered file. Listener addresses are
+// fixed here (they match docker-compose.yaml) rather than configurable.
+const renderHeader = `# Generated by "miner mediamtx render" from miner.yaml. Do not edit:
+# change miner.yaml and re-render (or let the miner apply it live).
+`
+
+const staticConf = `
+######################## Global ########################
+logLevel: info
+logDestinations: [stdout]
+
+####################### Control API #####################
+api: yes
+apiAddress: ":9997"
+
+####################### Protocol listeners ##############
+rtmp: yes
+rtmpAddress: ":1935"
+rtsp: yes
+rtspAddress: ":8554"
+webrtc: yes
+webrtcAddress: ":8889"
+# All ICE UDP traffic is multiplexed on this one port. MediaMTX has no UDP port
+# range setting (webrtcICEUDPRange is rejected as an unknown key), so this is
+# the only UDP port to publish.
+webrtcLocalUDPAddress: ":8189"
+hls: yes
+hlsAddress: ":8888"
+
+####################### Managed by the miner ############
+`
+
+// Render writes sc as a complete mediamtx.yml.
+func Render(sc ServerConfig) ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteString(renderHeader)
+	buf.WriteString(staticConf)
+
+	var global yaml.Node
+	if err := global.Encode(sc.global()); err != nil {
+		return nil, err
+	}
+	var unset []string
+	for i := 0; i+1 < len(global.Content); {
+		key := global.Content[i].Value
+		if v, ok := sc.Unset[key]; ok {
+			unset = append(unset, fmt.Sprintf("# %s: left out, $%s was unset; set MTX_%s or re-render with it set\n", key, v, strings.ToUpper(key)))
+			global.Content = append(global.Content[:i], global.Content[i+2:]...)
+			continue
+		}
+		i += 2
+	}
+	if err := encodeYAML(&buf, &global); err != nil {
+		return nil, err
+	}
+	for _, l := range unset {
+		buf.WriteString(l)
+	}
+
+	// Paths are a mapping in MediaMTX; keep the rule order from miner.yaml.
+	paths := &yaml.Node{Kind: yaml.MappingNode}
+	for _, p := range sc.rules() {
+		var v yaml.Node
+		if err := v.Encode(p.Conf); err != nil {
+			return nil, err
+		}
+		paths.Content = append(paths.Content, &yaml.Node{Kind: yaml.ScalarNode, Value: p.Name}, &v)
+	}
+	buf.WriteString("\n########################## Paths ########################\n")
+	if err := encodeYAML(&buf, map[string]*yaml.Node{"paths": paths}); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func encodeYAML(w io.Writer, v interface{}) error {
+	enc := yaml.NewEncoder(w)
+	enc.SetIndent(2)
+	if err := enc.Encode(v); err != nil {
+		return err
+	}
+	return enc.Close()
+}
+
+// Apply pushes sc to a running MediaMTX: the global settings are patched, then
+// the path configuration is reconciled against sc.Paths (see ReconcilePaths).
+func Apply(ctx context.Context, c *Client, sc ServerConfig) (PathChanges, error) {
+	if err := c.PatchGlobalConfig(ctx, sc.global()); err != nil {
+		return PathChanges{}, err
+	}
+	return ReconcilePaths(ctx, c, sc.rules(), sc.PrunePaths)
+}
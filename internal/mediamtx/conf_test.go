@@ -0,0 +1,131 @@
+package mediamtx
+
+import (
+	"strings"
+	"testing"
+)
+
+// grants reports whether users let user (with pass) perform action on path,
+// matching permission paths the way MediaMTX does: "" matches every path,
+// "~re" is a regexp, anything else an exact name.
+func grants(t *testing.T, users []AuthInternalUser, user, pass, action, path string) bool {
+	t.Helper()
+	for _, u := range users {
+		if u.User != "any" && (u.User != user || u.Pass != pass) {
+			continue
+		}
+		if len(u.IPs) > 0 { // the localhost-only entry
+			continue
+		}
+		for _, p := range u.Permissions {
+			if p.Action != action {
+				continue
+			}
+			switch {
+			case p.Path == "", p.Path == path:
+				return true
+			case strings.HasPrefix(p.Path, "~"):
+				if mustCompile(t, p.Path[1:]).MatchString(path) {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+func TestInternalUsers(t *testing.T) {
+	studio := PathAuth{PublishUser: "studio", PublishPass: "s3cret"}
+	viewer := PathAuth{ReadUser: "viewer", ReadPass: "v13w"}
+	tests := []struct {
+		name   string
+		rules  []PathRule
+		user   string // "" = anonymous
+		action string
+		path   string
+		want   bool
+	}{
+		{name: "open rule, anyone reads", rules: []PathRule{{Name: "live/a"}}, action: "read", path: "live/a", want: true},
+		{name: "open rule, anyone publishes", rules: []PathRule{{Name: "live/a"}}, action: "publish", path: "live/a", want: true},
+		{name: "open rule is scoped to its path", rules: []PathRule{{Name: "live/a"}}, action: "read", path: "live/b"},
+		{name: "credentialed publish, anonymous", rules: []PathRule{{Name: "room", Auth: studio}}, action: "publish", path: "room"},
+		{name: "credentialed publish, with credential", rules: []PathRule{{Name: "room", Auth: studio}}, user: "studio", action: "publish", path: "room", want: true},
+		{name: "credential only for its action", rules: []PathRule{{Name: "room", Auth: studio}}, user: "studio", action: "read", path: "room", want: true},
+		{name: "credentialed read, anonymous playback", rules: []PathRule{{Name: "room", Auth: viewer}}, action: "playback", path: "room"},
+		{name: "catch-all does not open a credentialed path",
+			rules:  []PathRule{{Name: "room", Auth: studio}, {Name: "~^.*$"}},
+			action: "publish", path: "room"},
+		{name: "catch-all overlapping a credential fails closed",
+			rules:  []PathRule{{Name: "room", Auth: studio}, {Name: "~^.*$"}},
+			action: "publish", path: "other"},
+		{name: "catch-all still open for the unguarded action",
+			rules:  []PathRule{{Name: "room", Auth: studio}, {Name: "~^.*$"}},
+			action: "read", path: "other", want: true},
+		{name: "regex not matching the credentialed name stays open",
+			rules:  []PathRule{{Name: "room", Auth: studio}, {Name: "~^live/"}},
+			action: "publish", path: "live/x", want: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pass := map[string]string{"studio": studio.PublishPass, "viewer": viewer.ReadPass}[tt.user]
+			users := internalUsers(tt.rules)
+			if got := grants(t, users, tt.user, pass, tt.action, tt.path); got != tt.want {
+				t.Fatalf("%s %s as %q: granted %v, want %v (users %+v)", tt.action, tt.path, tt.user, got, tt.want, users)
+			}
+		})
+	}
+}
+
+func TestAuthOverlaps(t *testing.T) {
+	studio := PathAuth{PublishUser: "studio", PublishPass: "s3cret"}
+	tests := []struct {
+		name   string
+		method string
+		rules  []PathRule
+		want   []string // substrings, one per overlap
+	}{
+		{name: "not internal", method: "jwt", rules: []PathRule{{Name: "room", Auth: studio}, {Name: "~^.*$"}}},
+		{name: "no overlap", method: "internal", rules: []PathRule{{Name: "room", Auth: studio}, {Name: "~^live/"}}},
+		{name: "catch-all over a literal", method: "internal",
+			rules: []PathRule{{Name: "room", Auth: studio}, {Name: "~^.*$"}},
+			want:  []string{`"~^.*$" matches credentialed room`}},
+		{name: "open regex next to a credentialed regex", method: "internal",
+			rules: []PathRule{{Name: "~^studio/", Auth: studio}, {Name: "~^.*$"}},
+			want:  []string{`"~^.*$" allows anyone to publish and may also match credentialed "~^studio/"`}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sc := ServerConfig{Global: GlobalConf{AuthMethod: tt.method}, Paths: tt.rules}
+			got := sc.AuthOverlaps()
+			if len(got) != len(tt.want) {
+				t.Fatalf("got %q, want %d overlaps", got, len(tt.want))
+			}
+			for i, w := range tt.want {
+				if !strings.Contains(got[i], w) {
+					t.Errorf("overlap %d = %q, want %q", i, got[i], w)
+				}
+			}
+		})
+	}
+}
+
+func TestRenderLeavesUnsetKeysOut(t *testing.T) {
+	sc := ServerConfig{
+		Global: GlobalConf{AuthMethod: "jwt", AuthJWTJWKS: "https://auth.example.com/jwks", AuthJWTClaimKey: "perms"},
+		Paths:  []PathRule{{Name: "live", Conf: PathConf{Source: "publisher"}}},
+		Unset:  map[string]string{"authJWTJWKS": "MEDIAMTX_JWKS_URL"},
+	}
+	b, err := Render(sc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := string(b)
+	if strings.Contains(out, "auth.example.com") {
+		t.Fatalf("placeholder rendered:\n%s", out)
+	}
+	for _, want := range []string{"authJWTClaimKey: perms", "# authJWTJWKS: left out, $MEDIAMTX_JWKS_URL", "live:"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("missing %q in:\n%s", want, out)
+		}
+	}
+}
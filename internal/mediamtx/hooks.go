@@ -0,0 +1,128 @@
+package mediamtx
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"slowdrip-miner/internal/events"
+)
+
+// Hook events, named after the MediaMTX runOn* settings that send them.
+const (
+	HookReady    = "ready"    // runOnReady
+	HookNotReady = "notready" // runOnNotReady
+	HookRead     = "read"     // runOnRead
+	HookUnread   = "unread"   // runOnUnread
+)
+
+// HookEvent is what a runOn* hook (mtx-hook, or the curl template) posts.
+// Fields carry MediaMTX's MTX_* variables.
+type HookEvent struct {
+	Event  string `json:"event"`            // ready | notready | read | unread
+	Path   string `json:"path"`             // MTX_PATH
+	Source *Ref   `json:"source,omitempty"` // MTX_SOURCE_TYPE, MTX_SOURCE_ID
+	Reader *Ref   `json:"reader,omitempty"` // MTX_READER_TYPE, MTX_READER_ID
+	Query  string `json:"query,omitempty"`  // MTX_QUERY
+}
+
+// ErrNoWatcher is returned by PushHook before StartWatcher has run.
+var ErrNoWatcher = errors.New("mediamtx: watcher not running")
+
+// PushHook applies a hook event to the default watcher (see Watcher.Hook).
+func PushHook(ev HookEvent) error {
+	defaultMu.RLock()
+	w := defaultWatcher
+	defaultMu.RUnlock()
+	if w == nil {
+		return ErrNoWatcher
+	}
+	return w.Hook(ev)
+}
+
+// Hook applies a pushed event to the watcher's state and publishes what changed,
+// without waiting for the next poll. The poll that follows finds nothing new, so
+// events aren't published twice; readers that came and went between two polls
+// are still seen. A poll whose listing was requested before the hook leaves the
+// hooked path alone. Polling remains the fallback for lost hooks.
+func (w *Watcher) Hook(ev HookEvent) error {
+	if ev.Path == "" {
+		return errors.New("mediamtx: hook event without path")
+	}
+	switch ev.Event {
+	case HookReady, HookNotReady:
+	case HookRead, HookUnread:
+		if ev.Reader == nil || ev.Reader.ID == "" {
+			return fmt.Errorf("mediamtx: %s hook without reader", ev.Event)
+		}
+	default:
+		return fmt.Errorf("mediamtx: unknown hook event %q", ev.Event)
+	}
+
+	w.mu.Lock()
+	p, existed := w.paths[ev.Path]
+	if !existed {
+		p = Path{Name: ev.Path}
+	}
+	type pending struct {
+		t    events.Type
+		data interface{}
+	}
+	var publish []pending
+	pub := func(t events.Type, data interface{}) { publish = append(publish, pending{t, data}) }
+
+	switch ev.Event {
+	case HookReady:
+		if !p.Ready {
+			p.Ready, p.ReadyTime, p.Source = true, time.Now().UTC(), ev.Source
+			pub(events.PathReady, pathInfo(p))
+		}
+	case HookNotReady:
+		if p.Ready {
+			p.Ready, p.Source = false, nil
+			pub(events.PathNotReady, pathInfo(p))
+		}
+	case HookRead:
+		if !hasReader(p.Readers, ev.Reader.ID) {
+			// copy: snapshots share the old slice
+			p.Readers = append(append([]Ref(nil), p.Readers...), *ev.Reader)
+			pub(events.ReaderJoined, *ev.Reader)
+		}
+	case HookUnread:
+		kept := make([]Ref, 0, len(p.Readers))
+		for _, r := range p.Readers {
+			if r.ID != ev.Reader.ID {
+				kept = append(kept, r)
+			}
+		}
+		if len(kept) != len(p.Readers) {
+			p.Readers = kept
+			pub(events.ReaderLeft, *ev.Reader)
+		}
+	}
+	if !existed && len(publish) > 0 {
+		publish = append([]pending{{events.PathAdded, pathInfo(p)}}, publish...)
+	}
+	if existed || len(publish) > 0 {
+		w.paths[ev.Path] = p
+	}
+	if len(publish) > 0 {
+		w.gen++
+		w.hooked[ev.Path] = w.gen
+	}
+	w.mu.Unlock()
+
+	for _, e := range publish {
+		w.bus.Publish(e.t, ev.Path, e.data)
+	}
+	return nil
+}
+
+func hasReader(rs []Ref, id string) bool {
+	for _, r := range rs {
+		if r.ID == id {
+			return true
+		}
+	}
+	return false
+}
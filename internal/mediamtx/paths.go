@@ -0,0 +1,230 @@
+// internal/mediamtx/paths.go
+package mediamtx
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"sync"
+	"time"
+
+	"slowdrip-miner/internal/events"
+
+	"github.com/rs/zerolog"
+)
+
+// PathChanges lists what one reconcile changed, by path name.
+type PathChanges struct {
+	Added   []string `json:"added,omitempty"`
+	Patched []string `json:"patched,omitempty"`
+	Deleted []string `json:"deleted,omitempty"`
+}
+
+// Empty reports whether nothing had to change.
+func (pc PathChanges) Empty() bool {
+	return len(pc.Added)+len(pc.Patched)+len(pc.Deleted) == 0
+}
+
+// ReconcilePaths makes MediaMTX's path configuration match rules: missing paths
+// are added and paths whose miner-managed fields differ are patched. With prune,
+// configured paths that are not in rules are deleted. On error the changes made
+// so far are returned with it; a failed call is not listed.
+func ReconcilePaths(ctx context.Context, c *Client, rules []PathRule, prune bool) (PathChanges, error) {
+	var ch PathChanges
+	have, err := c.ListPathConfigs(ctx)
+	if err != nil {
+		return ch, err
+	}
+	want := make(map[string]bool, len(rules))
+	for _, r := range rules {
+		want[r.Name] = true
+		cur, ok := have[r.Name]
+		switch {
+		case !ok:
+			if err := c.AddPathConfig(ctx, r.Name, r.Conf); err != nil {
+				return ch, fmt.Errorf("path %q: %w", r.Name, err)
+			}
+			ch.Added = append(ch.Added, r.Name)
+		case drifted(r.Conf, cur):
+			if err := c.PatchPathConfig(ctx, r.Name, r.Conf); err != nil {
+				return ch, fmt.Errorf("path %q: %w", r.Name, err)
+			}
+			ch.Patched = append(ch.Patched, r.Name)
+		}
+	}
+	if !prune {
+		return ch, nil
+	}
+	extra := make([]string, 0, len(have))
+	for name := range have {
+		if !want[name] {
+			extra = append(extra, name)
+		}
+	}
+	sort.Strings(extra)
+	for _, name := range extra {
+		if err := c.DeletePathConfig(ctx, name); err != nil {
+			return ch, fmt.Errorf("path %q: %w", name, err)
+		}
+		ch.Deleted = append(ch.Deleted, name)
+	}
+	return ch, nil
+}
+
+// drifted reports whether any field the miner sets differs in cur (a path
+// config as returned by the API).
+func drifted(want PathConf, cur map[string]interface{}) bool {
+	b, err := json.Marshal(want)
+	if err != nil {
+		return true
+	}
+	var w map[string]interface{}
+	if err := json.Unmarshal(b, &w); err != nil {
+		return true
+	}
+	for k, v := range w {
+		if !reflect.DeepEqual(cur[k], v) {
+			return true
+		}
+	}
+	return false
+}
+
+// ------------------------------------------------------------
+// PathManager
+// ------------------------------------------------------------
+
+// PathManager keeps MediaMTX in line with a ServerConfig. Every interval it
+// patches the global settings (a no-op when nothing changed) and reconciles the
+// path configuration, so changes made by hand, or lost when MediaMTX restarts
+// without the generated mediamtx.yml, are put back.
+type PathManager struct {
+	client   *Client
+	sc       ServerConfig
+	interval time.Duration
+	bus      *events.Bus
+	log      zerolog.Logger
+
+	mu     sync.RWMutex // guards sc.Paths (see SetRecord) and status
+	status ManagerStatus
+}
+
+// ManagerStatus is the PathManager's view for /v1/status.
+type ManagerStatus struct {
+	Rules       int         `json:"rules"`
+	Prune       bool        `json:"prune"`
+	Synced      bool        `json:"synced"` // a reconcile has succeeded
+	LastRun     time.Time   `json:"last_run,omitempty"`
+	LastChange  time.Time   `json:"last_change,omitempty"`
+	LastChanges PathChanges `json:"last_changes"`
+	Corrections int         `json:"corrections"` // paths patched or deleted after the first sync
+	Error       string      `json:"error,omitempty"`
+}
+
+// NewPathManager creates a manager; bus may be nil to use events.Default.
+func NewPathManager(c *Client, sc ServerConfig, interval time.Duration, bus *events.Bus) *PathManager {
+	if bus == nil {
+		bus = events.Default
+	}
+	return &PathManager{
+		client:   c,
+		sc:       sc,
+		interval: interval,
+		bus:      bus,
+		log:      c.log.With().Str("module", "pathmanager").Logger(),
+		status:   ManagerStatus{Rules: len(sc.Paths), Prune: sc.PrunePaths},
+	}
+}
+
+// Run syncs immediately and then every interval until ctx is done.
+func (m *PathManager) Run(ctx context.Context) {
+	t := time.NewTicker(m.interval)
+	defer t.Stop()
+	for {
+		m.sync(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+		}
+	}
+}
+
+// Status returns a copy of the current status.
+func (m *PathManager) Status() ManagerStatus {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.status
+}
+
+// SetRecord changes the record flag of the rule named name, so reconciles
+// keep what was switched at runtime instead of reverting it. It reports false
+// when no rule has that name. The change lasts until the miner restarts.
+func (m *PathManager) SetRecord(name string, on bool) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for i, r := range m.sc.Paths {
+		if r.Name == name {
+			// copy: a running sync may be reading the old slice
+			paths := append([]PathRule(nil), m.sc.Paths...)
+			paths[i].Conf.Record = on
+			m.sc.Paths = paths
+			return true
+		}
+	}
+	return false
+}
+
+func (m *PathManager) sync(ctx context.Context) {
+	m.mu.RLock()
+	st := m.status
+	sc := m.sc
+	m.mu.RUnlock()
+
+	// Changes found before the first successful sync are the initial setup,
+	// not drift.
+	var ch PathChanges
+	err := m.client.PatchGlobalConfig(ctx, sc.global())
+	if err == nil {
+		ch, err = ReconcilePaths(ctx, m.client, sc.rules(), sc.PrunePaths)
+	}
+	st.LastRun = time.Now().UTC()
+	st.Error = ""
+	if err != nil {
+		st.Error = err.Error()
+		m.log.Warn().Err(err).Msg("mediamtx reconcile failed")
+	}
+	if !ch.Empty() {
+		st.LastChange, st.LastChanges = st.LastRun, ch
+		if st.Synced {
+			st.Corrections += len(ch.Patched) + len(ch.Deleted)
+		}
+		m.publish(ch, st.Synced)
+	}
+	if err == nil {
+		st.Synced = true
+	}
+
+	m.mu.Lock()
+	m.status = st
+	m.mu.Unlock()
+}
+
+func (m *PathManager) publish(ch PathChanges, drift bool) {
+	for _, n := range ch.Added {
+		m.bus.Publish(events.PathConfAdded, n, nil)
+	}
+	for _, n := range ch.Patched {
+		m.bus.Publish(events.PathConfPatched, n, nil)
+	}
+	for _, n := range ch.Deleted {
+		m.bus.Publish(events.PathConfDeleted, n, nil)
+	}
+	ev := m.log.Info()
+	if drift {
+		ev = m.log.Warn() // someone changed MediaMTX behind the miner's back
+	}
+	ev.Strs("added", ch.Added).Strs("patched", ch.Patched).Strs("deleted", ch.Deleted).Msg("path config reconciled")
+}
@@ -0,0 +1,145 @@
+package mediamtx
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"regexp"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/rs/zerolog"
+)
+
+func mustCompile(t *testing.T, re string) *regexp.Regexp {
+	t.Helper()
+	r, err := regexp.Compile(re)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return r
+}
+
+// fakeConfigAPI serves /v3/config/paths/* from an in-memory map. Calls on a
+// name in fail answer 400.
+type fakeConfigAPI struct {
+	mu    sync.Mutex
+	paths map[string]map[string]interface{}
+	fail  map[string]bool
+}
+
+func (f *fakeConfigAPI) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if r.URL.Path == "/v3/config/paths/list" {
+		var items []map[string]interface{}
+		for _, p := range f.paths {
+			items = append(items, p)
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"pageCount": 1, "items": items})
+		return
+	}
+	rest := strings.TrimPrefix(r.URL.Path, "/v3/config/paths/")
+	op, name, _ := strings.Cut(rest, "/")
+	if f.fail[name] {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "refused"})
+		return
+	}
+	var conf map[string]interface{}
+	if r.Body != nil {
+		json.NewDecoder(r.Body).Decode(&conf)
+	}
+	switch op {
+	case "add":
+		conf["name"] = name
+		f.paths[name] = conf
+	case "patch":
+		for k, v := range conf {
+			f.paths[name][k] = v
+		}
+	case "delete":
+		delete(f.paths, name)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func TestReconcilePaths(t *testing.T) {
+	conf := func(src string, maxReaders int) PathConf {
+		return PathConf{Source: src, MaxReaders: maxReaders}
+	}
+	stored := func(name string, c PathConf) map[string]interface{} {
+		b, _ := json.Marshal(c)
+		var m map[string]interface{}
+		json.Unmarshal(b, &m)
+		m["name"] = name
+		return m
+	}
+	tests := []struct {
+		name  string
+		have  map[string]PathConf
+		rules []PathRule
+		prune bool
+		fail  string
+		want  PathChanges
+		err   bool
+	}{
+		{name: "in sync",
+			have:  map[string]PathConf{"a": conf("publisher", 0)},
+			rules: []PathRule{{Name: "a", Conf: conf("publisher", 0)}}},
+		{name: "add and patch",
+			have:  map[string]PathConf{"a": conf("publisher", 5)},
+			rules: []PathRule{{Name: "a", Conf: conf("publisher", 0)}, {Name: "b", Conf: conf("publisher", 0)}},
+			want:  PathChanges{Added: []string{"b"}, Patched: []string{"a"}}},
+		{name: "hooks turned off clear the runOn commands",
+			have:  map[string]PathConf{"a": {Source: "publisher", RunOnReady: "mtx-hook ready", RunOnUnread: "mtx-hook unread"}},
+			rules: []PathRule{{Name: "a", Conf: conf("publisher", 0)}},
+			want:  PathChanges{Patched: []string{"a"}}},
+		{name: "extra path kept without prune",
+			have:  map[string]PathConf{"a": conf("publisher", 0), "x": conf("publisher", 0)},
+			rules: []PathRule{{Name: "a", Conf: conf("publisher", 0)}}},
+		{name: "extra path pruned",
+			have:  map[string]PathConf{"a": conf("publisher", 0), "x": conf("publisher", 0)},
+			rules: []PathRule{{Name: "a", Conf: conf("publisher", 0)}}, prune: true,
+			want: PathChanges{Deleted: []string{"x"}}},
+		{name: "failed add is not reported",
+			have:  map[string]PathConf{"a": conf("publisher", 5)},
+			rules: []PathRule{{Name: "a", Conf: conf("publisher", 0)}, {Name: "b", Conf: conf("publisher", 0)}},
+			fail:  "b", err: true,
+			want: PathChanges{Patched: []string{"a"}}},
+		{name: "failed patch is not reported",
+			have:  map[string]PathConf{"a": conf("publisher", 5)},
+			rules: []PathRule{{Name: "a", Conf: conf("publisher", 0)}},
+			fail:  "a", err: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			api := &fakeConfigAPI{paths: map[string]map[string]interface{}{}, fail: map[string]bool{tt.fail: tt.fail != ""}}
+			for n, c := range tt.have {
+				api.paths[n] = stored(n, c)
+			}
+			srv := httptest.NewServer(api)
+			defer srv.Close()
+
+			got, err := ReconcilePaths(context.Background(), NewClient(srv.URL, zerolog.Nop()), tt.rules, tt.prune)
+			if (err != nil) != tt.err {
+				t.Fatalf("err = %v, want error %v", err, tt.err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("changes %+v, want %+v", got, tt.want)
+			}
+			if tt.err {
+				return
+			}
+			for _, r := range tt.rules {
+				if drifted(r.Conf, api.paths[r.Name]) {
+					t.Errorf("%s still differs: %v", r.Name, api.paths[r.Name])
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,183 @@
+package mediamtx
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"slowdrip-miner/internal/events"
+
+	"github.com/rs/zerolog"
+)
+
+// Watcher polls MediaMTX for paths and their readers, keeps the latest snapshot,
+// and publishes a diff of every change on the event bus.
+type Watcher struct {
+	client   *Client
+	interval time.Duration
+	bus      *events.Bus
+	log      zerolog.Logger
+
+	mu     sync.RWMutex
+	paths  map[string]Path
+	gen    uint64            // bumped by every Hook that changes a path
+	hooked map[string]uint64 // path -> gen of the last Hook that changed it
+}
+
+// NewWatcher creates a watcher; bus may be nil to use events.Default.
+func NewWatcher(c *Client, interval time.Duration, bus *events.Bus) *Watcher {
+	if bus == nil {
+		bus = events.Default
+	}
+	return &Watcher{
+		client:   c,
+		interval: interval,
+		bus:      bus,
+		log:      c.log.With().Str("module", "watcher").Logger(),
+		paths:    make(map[string]Path),
+		hooked:   make(map[string]uint64),
+	}
+}
+
+// defaultWatcher backs StartWatcher/Snapshot for simple wiring from main.
+var (
+	defaultWatcher *Watcher
+	defaultMu      sync.RWMutex
+)
+
+// StartWatcher runs a default watcher until ctx is done.
+// Safe to call in a goroutine: go mediamtx.StartWatcher(ctx, c, interval)
+func StartWatcher(ctx context.Context, c *Client, interval time.Duration) {
+	w := NewWatcher(c, interval, nil)
+	defaultMu.Lock()
+	defaultWatcher = w
+	defaultMu.Unlock()
+	w.Run(ctx)
+}
+
+// Snapshot returns the default watcher's current view (nil before StartWatcher).
+func Snapshot() []Path {
+	defaultMu.RLock()
+	w := defaultWatcher
+	defaultMu.RUnlock()
+	if w == nil {
+		return nil
+	}
+	return w.Snapshot()
+}
+
+// Run polls until ctx is done.
+func (w *Watcher) Run(ctx context.Context) {
+	w.log.Info().Dur("interval", w.interval).Msg("watcher: started")
+	t := time.NewTicker(w.interval)
+	defer t.Stop()
+
+	w.poll()
+	for {
+		select {
+		case <-ctx.Done():
+			w.log.Info().Msg("watcher: stopping")
+			return
+		case <-t.C:
+			w.poll()
+		}
+	}
+}
+
+// Snapshot returns the last polled paths sorted by name.
+func (w *Watcher) Snapshot() []Path {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	out := make([]Path, 0, len(w.paths))
+	for _, p := range w.paths {
+		out = append(out, p)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Name < out[j].Name })
+	return out
+}
+
+func (w *Watcher) poll() {
+	w.mu.RLock()
+	since := w.gen
+	w.mu.RUnlock()
+	items, err := w.client.ListPaths()
+	if err != nil {
+		w.log.Warn().Err(err).Msg("watcher: list paths failed")
+		return
+	}
+	next := make(map[string]Path, len(items))
+	for _, p := range items {
+		next[p.Name] = p
+	}
+	w.apply(next, since)
+}
+
+// apply replaces the current state with next, a listing requested when the hook
+// generation was since, and publishes what changed. Paths a hook changed after
+// that keep their hooked state: the listing may predate the hook, and the next
+// poll catches up.
+func (w *Watcher) apply(next map[string]Path, since uint64) {
+	w.mu.Lock()
+	prev := w.paths
+	for name, gen := range w.hooked {
+		if gen <= since {
+			delete(w.hooked, name)
+			continue
+		}
+		if p, ok := prev[name]; ok {
+			next[name] = p
+		} else {
+			delete(next, name)
+		}
+	}
+	w.paths = next
+	w.mu.Unlock()
+
+	for name, p := range next {
+		old, existed := prev[name]
+		if !existed {
+			w.bus.Publish(events.PathAdded, name, pathInfo(p))
+		}
+		if p.Ready && (!existed || !old.Ready) {
+			w.bus.Publish(events.PathReady, name, pathInfo(p))
+		} else if !p.Ready && existed && old.Ready {
+			w.bus.Publish(events.PathNotReady, name, pathInfo(p))
+		}
+		w.diffReaders(name, old.Readers, p.Readers)
+	}
+	for name, old := range prev {
+		if _, ok := next[name]; ok {
+			continue
+		}
+		w.diffReaders(name, old.Readers, nil)
+		w.bus.Publish(events.PathRemoved, name, nil)
+	}
+}
+
+func (w *Watcher) diffReaders(path string, before, after []Ref) {
+	seen := make(map[string]bool, len(before))
+	for _, r := range before {
+		seen[r.ID] = true
+	}
+	now := make(map[string]bool, len(after))
+	for _, r := range after {
+		now[r.ID] = true
+		if !seen[r.ID] {
+			w.bus.Publish(events.ReaderJoined, path, r)
+		}
+	}
+	for _, r := range before {
+		if !now[r.ID] {
+			w.bus.Publish(events.ReaderLeft, path, r)
+		}
+	}
+}
+
+// pathInfo is the compact event payload for path-level events.
+func pathInfo(p Path) map[string]interface{} {
+	return map[string]interface{}{
+		"ready":   p.Ready,
+		"readers": len(p.Readers),
+	}
+}
@@ -0,0 +1,186 @@
+package mediamtx
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sync"
+	"testing"
+
+	"slowdrip-miner/internal/events"
+
+	"github.com/rs/zerolog"
+)
+
+// fakePathsAPI serves /v3/paths/list. during, if set, runs once after the reply
+// has been taken and before it is sent: a hook landing mid-poll.
+type fakePathsAPI struct {
+	mu     sync.Mutex
+	items  []Path
+	during func()
+}
+
+func (f *fakePathsAPI) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/v3/paths/list" {
+		http.NotFound(w, r)
+		return
+	}
+	f.mu.Lock()
+	body, _ := json.Marshal(map[string]interface{}{"pageCount": 1, "items": f.items})
+	during := f.during
+	f.during = nil
+	f.mu.Unlock()
+	if during != nil {
+		during()
+	}
+	w.Write(body)
+}
+
+func (f *fakePathsAPI) set(items ...Path) {
+	f.mu.Lock()
+	f.items = items
+	f.mu.Unlock()
+}
+
+func newTestWatcher(t *testing.T) (*Watcher, *fakePathsAPI, *events.Bus) {
+	t.Helper()
+	api := &fakePathsAPI{}
+	srv := httptest.NewServer(api)
+	t.Cleanup(srv.Close)
+	bus := events.NewBus(64)
+	return NewWatcher(NewClient(srv.URL, zerolog.Nop()), 0, bus), api, bus
+}
+
+// published lists the types of events after cursor, advancing it.
+func published(bus *events.Bus, cursor *uint64) []events.Type {
+	var out []events.Type
+	for _, e := range bus.Recent(events.Filter{}, 64) {
+		if e.ID > *cursor {
+			out = append(out, e.Type)
+			*cursor = e.ID
+		}
+	}
+	return out
+}
+
+var (
+	cam   = Ref{Type: "rtmpConn", ID: "src"}
+	alice = Ref{Type: "webRTCSession", ID: "alice"}
+)
+
+func TestHookDuringPoll(t *testing.T) {
+	idle := Path{Name: "cam", Ready: true, Source: &cam}
+	watched := Path{Name: "cam", Ready: true, Source: &cam, Readers: []Ref{alice}}
+
+	tests := []struct {
+		name   string
+		before []Path    // MediaMTX state at the first poll
+		stale  []Path    // the listing the racing poll gets
+		hook   HookEvent // lands while that listing is in flight
+		after  []Path    // MediaMTX state at the following poll
+		want   []events.Type
+		ready  bool // state of "cam" after the racing poll: the hook's, not the listing's
+		reads  int
+	}{
+		{name: "reader joins",
+			before: []Path{idle}, stale: []Path{idle}, after: []Path{watched},
+			hook: HookEvent{Event: HookRead, Path: "cam", Reader: &alice},
+			want: []events.Type{events.ReaderJoined}, ready: true, reads: 1},
+		{name: "reader leaves",
+			before: []Path{watched}, stale: []Path{watched}, after: []Path{idle},
+			hook: HookEvent{Event: HookUnread, Path: "cam", Reader: &alice},
+			want: []events.Type{events.ReaderLeft}, ready: true, reads: 0},
+		{name: "path comes up",
+			before: nil, stale: nil, after: []Path{idle},
+			hook: HookEvent{Event: HookReady, Path: "cam", Source: &cam},
+			want: []events.Type{events.PathAdded, events.PathReady}, ready: true},
+		{name: "path goes down",
+			before: []Path{idle}, stale: []Path{idle}, after: []Path{{Name: "cam"}},
+			hook: HookEvent{Event: HookNotReady, Path: "cam"},
+			want: []events.Type{events.PathNotReady}, ready: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w, api, bus := newTestWatcher(t)
+			api.set(tt.before...)
+			w.poll()
+			cursor := bus.LastID()
+
+			api.set(tt.stale...)
+			api.during = func() {
+				if err := w.Hook(tt.hook); err != nil {
+					t.Error(err)
+				}
+			}
+			w.poll()
+			if got := published(bus, &cursor); !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("racing poll: published %v, want %v", got, tt.want)
+			}
+			if snap := w.Snapshot(); len(snap) != 1 || snap[0].Ready != tt.ready || len(snap[0].Readers) != tt.reads {
+				t.Fatalf("racing poll: snapshot %+v", snap)
+			}
+
+			api.set(tt.after...)
+			w.poll()
+			if got := published(bus, &cursor); len(got) != 0 {
+				t.Fatalf("catch-up poll published %v", got)
+			}
+		})
+	}
+}
+
+// A listing requested after a hook is authoritative again: it corrects hooked
+// state, e.g. when the matching unread hook was lost.
+func TestPollAfterHook(t *testing.T) {
+	w, api, bus := newTestWatcher(t)
+	api.set(Path{Name: "cam", Ready: true, Source: &cam})
+	w.poll()
+	if err := w.Hook(HookEvent{Event: HookRead, Path: "cam", Reader: &alice}); err != nil {
+		t.Fatal(err)
+	}
+	cursor := bus.LastID()
+
+	api.set(Path{Name: "cam", Ready: true, Source: &cam})
+	w.poll()
+	if got := published(bus, &cursor); !reflect.DeepEqual(got, []events.Type{events.ReaderLeft}) {
+		t.Fatalf("published %v, want the lost unread", got)
+	}
+	if len(w.hooked) != 0 {
+		t.Fatalf("hook generations not pruned: %v", w.hooked)
+	}
+}
+
+func TestHookNoChange(t *testing.T) {
+	w, api, bus := newTestWatcher(t)
+	api.set(Path{Name: "cam", Ready: true, Source: &cam, Readers: []Ref{alice}})
+	w.poll()
+	cursor := bus.LastID()
+
+	// Already known: nothing is published and the next listing applies as is.
+	for _, ev := range []HookEvent{
+		{Event: HookReady, Path: "cam", Source: &cam},
+		{Event: HookRead, Path: "cam", Reader: &alice},
+		{Event: HookNotReady, Path: "other"},
+	} {
+		if err := w.Hook(ev); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got := published(bus, &cursor); len(got) != 0 {
+		t.Fatalf("published %v", got)
+	}
+	if len(w.hooked) != 0 || len(w.Snapshot()) != 1 {
+		t.Fatalf("state changed: hooked %v, paths %+v", w.hooked, w.Snapshot())
+	}
+
+	for _, ev := range []HookEvent{
+		{Event: HookRead, Path: "cam"},
+		{Event: "publish", Path: "cam"},
+		{Event: HookReady},
+	} {
+		if err := w.Hook(ev); err == nil {
+			t.Errorf("%+v accepted", ev)
+		}
+	}
+}
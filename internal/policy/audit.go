@@ -0,0 +1,98 @@
+// internal/policy/audit.go
+package policy
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog"
+)
+
+// Action results.
+const (
+	ResultKicked = "kicked"
+	ResultDryRun = "dry_run" // would have been kicked
+	ResultFailed = "failed"  // the kick call failed; retried next sweep
+)
+
+// Action is one audited policy decision.
+type Action struct {
+	Time           time.Time  `json:"ts"`
+	Rule           string     `json:"rule"`
+	Result         string     `json:"result"`
+	Kind           string     `json:"kind"` // MediaMTX session collection, e.g. webrtcsessions
+	ID             string     `json:"id"`
+	Path           string     `json:"path"`
+	IP             string     `json:"ip,omitempty"`
+	Token          string     `json:"token,omitempty"` // fingerprint, never the token
+	Detail         string     `json:"detail"`
+	Error          string     `json:"error,omitempty"`
+	BannedUntil    *time.Time `json:"banned_until,omitempty"`
+	ThrottledUntil *time.Time `json:"throttled_until,omitempty"`
+}
+
+// AuditLog records every action as a log line (audit=policy_action) and, when
+// a file is configured, appends it there as a JSON line.
+type AuditLog struct {
+	log zerolog.Logger
+
+	mu sync.Mutex
+	f  *os.File
+}
+
+// OpenAudit opens (creating, mode 0600) the append-only audit file at path;
+// path "" logs only.
+func OpenAudit(path string, log zerolog.Logger) (*AuditLog, error) {
+	a := &AuditLog{log: log.With().Str("module", "policy").Logger()}
+	if path == "" {
+		return a, nil
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("policy: audit log: %w", err)
+	}
+	a.f = f
+	return a, nil
+}
+
+// Record writes a. Write errors are logged; they never stop enforcement.
+func (a *AuditLog) Record(act Action) {
+	ev := a.log.Warn()
+	if act.Result == ResultDryRun {
+		ev = a.log.Info()
+	}
+	ev.Str("audit", "policy_action").
+		Str("rule", act.Rule).
+		Str("result", act.Result).
+		Str("kind", act.Kind).
+		Str("session", act.ID).
+		Str("path", act.Path).
+		Str("ip", act.IP).
+		Str("token", act.Token).
+		Str("error", act.Error).
+		Msg(act.Detail)
+
+	if a.f == nil {
+		return
+	}
+	b, err := json.Marshal(act)
+	if err != nil {
+		return
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if _, err := a.f.Write(append(b, '\n')); err != nil {
+		a.log.Error().Err(err).Msg("policy: audit write failed")
+	}
+}
+
+// Close closes the audit file.
+func (a *AuditLog) Close() error {
+	if a.f == nil {
+		return nil
+	}
+	return a.f.Close()
+}
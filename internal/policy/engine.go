@@ -0,0 +1,450 @@
+// internal/policy/engine.go
+package policy
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"sort"
+	"sync"
+	"time"
+
+	"slowdrip-miner/internal/events"
+	"slowdrip-miner/internal/mediamtx"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/rs/zerolog"
+)
+
+// Rules that can fire on a reader session.
+const (
+	RuleBandwidth  = "bandwidth"   // egress above MaxSessionMbps for Sustain
+	RuleDuration   = "duration"    // open longer than MaxDuration
+	RuleTokenReuse = "token_reuse" // one token read from more than MaxIPsPerToken IPs
+	RuleBanned     = "banned"      // read from an IP still under a ban
+	RuleThrottle   = "throttle"    // a throttled IP reads more than ThrottleReaders streams
+)
+
+// Options configures an Engine; zero limits turn their rule off.
+type Options struct {
+	Interval       time.Duration
+	DryRun         bool
+	MaxSessionMbps float64
+	Sustain        time.Duration
+	MaxDuration    time.Duration
+	MaxIPsPerToken int
+	TokenParams    []string      // query parameters holding reader tokens
+	BanFor         time.Duration // after a bandwidth or token_reuse kick (a repeat one, with throttling)
+	Exempt         []*net.IPNet
+
+	// Throttling is the step before a ban: the first bandwidth or token_reuse
+	// kick limits the IP to ThrottleReaders concurrent reads for ThrottleFor,
+	// and another offence in that time bans it. 0 = ban straight away.
+	ThrottleFor     time.Duration
+	ThrottleReaders int // default 1
+}
+
+var actionsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "miner_policy_actions_total",
+	Help: "Policy actions on reader sessions by rule and result.",
+}, []string{"rule", "result"})
+
+// tracked is what the engine remembers about a reader between polls.
+type tracked struct {
+	kind      string
+	bytesSent uint64
+	at        time.Time
+	overSince time.Time // egress has been above the ceiling since
+}
+
+// verdict is a rule that fired on a session.
+type verdict struct {
+	s      mediamtx.Session
+	ip     string
+	token  string // fingerprint
+	rule   string
+	detail string
+}
+
+// Engine polls MediaMTX's sessions, applies the rules to readers and kicks the
+// ones that break them. Every action goes to the audit log.
+type Engine struct {
+	client *mediamtx.Client
+	opts   Options
+	audit  *AuditLog
+	bus    *events.Bus
+	log    zerolog.Logger
+
+	mu        sync.Mutex
+	sessions  map[string]*tracked // by session ID
+	acted     map[string]string   // session ID -> kind, for sessions already acted on, until they are gone
+	bans      map[string]banEntry // by IP
+	throttles map[string]banEntry // by IP
+	status    Status
+}
+
+// banEntry is a ban or throttle on one IP, after a kick for rule.
+type banEntry struct {
+	until time.Time
+	rule  string
+}
+
+// Status is the engine's view for /v1/status.
+type Status struct {
+	DryRun    bool           `json:"dry_run"`
+	Readers   int            `json:"readers"`
+	LastSweep time.Time      `json:"last_sweep,omitempty"`
+	Error     string         `json:"error,omitempty"`
+	Actions   map[string]int `json:"actions"` // by rule
+	Bans      int            `json:"bans"`
+	Throttles int            `json:"throttles"`
+	Recent    []Action       `json:"recent"` // newest last
+}
+
+const recentActions = 20
+
+// New creates an engine; bus may be nil to use events.Default.
+func New(c *mediamtx.Client, o Options, audit *AuditLog, bus *events.Bus, log zerolog.Logger) *Engine {
+	if bus == nil {
+		bus = events.Default
+	}
+	return &Engine{
+		client:    c,
+		opts:      o,
+		audit:     audit,
+		bus:       bus,
+		log:       log.With().Str("module", "policy").Logger(),
+		sessions:  map[string]*tracked{},
+		acted:     map[string]string{},
+		bans:      map[string]banEntry{},
+		throttles: map[string]banEntry{},
+		status:    Status{DryRun: o.DryRun, Actions: map[string]int{}, Recent: []Action{}},
+	}
+}
+
+// Run sweeps every Options.Interval until ctx is done.
+func (e *Engine) Run(ctx context.Context) {
+	e.log.Info().Dur("interval", e.opts.Interval).Bool("dry_run", e.opts.DryRun).Msg("policy: started")
+	t := time.NewTicker(e.opts.Interval)
+	defer t.Stop()
+	for {
+		e.sweep(ctx, time.Now())
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+		}
+	}
+}
+
+// Blocked reports whether ip is banned after a kick; it makes the engine an
+// admission.Blocklist.
+func (e *Engine) Blocked(ip string, now time.Time) (string, bool) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	b, ok := e.bans[ip]
+	if !ok || !now.Before(b.until) {
+		return "", false
+	}
+	return fmt.Sprintf("kicked for %s, banned until %s", b.rule, b.until.UTC().Format(time.RFC3339)), true
+}
+
+// Throttled reports how many concurrent reads ip is allowed while it is
+// throttled after a kick; it makes the engine an admission.Throttle.
+func (e *Engine) Throttled(ip string, now time.Time) (limit int, why string, throttled bool) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	t, ok := e.throttles[ip]
+	if !ok || !now.Before(t.until) {
+		return 0, "", false
+	}
+	return e.throttleReaders(), fmt.Sprintf("kicked for %s, throttled to %d stream(s) until %s", t.rule, e.throttleReaders(), t.until.UTC().Format(time.RFC3339)), true
+}
+
+func (e *Engine) throttleReaders() int {
+	if e.opts.ThrottleReaders > 0 {
+		return e.opts.ThrottleReaders
+	}
+	return 1
+}
+
+// Status returns a copy of the current status.
+func (e *Engine) Status() Status {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	st := e.status
+	st.Actions = make(map[string]int, len(e.status.Actions))
+	for k, v := range e.status.Actions {
+		st.Actions[k] = v
+	}
+	st.Recent = append([]Action(nil), e.status.Recent...)
+	st.Bans, st.Throttles = 0, 0
+	now := time.Now()
+	for _, b := range e.bans {
+		if now.Before(b.until) {
+			st.Bans++
+		}
+	}
+	for _, t := range e.throttles {
+		if now.Before(t.until) {
+			st.Throttles++
+		}
+	}
+	return st
+}
+
+func (e *Engine) sweep(ctx context.Context, now time.Time) {
+	// With some protocols unreadable, the others are still enforced; what is
+	// remembered about the unreadable ones is kept for the next sweep.
+	all, err := e.client.ListSessions(ctx)
+	var partial *mediamtx.SessionListError
+	if err != nil && !errors.As(err, &partial) {
+		e.log.Warn().Err(err).Msg("policy: list sessions failed")
+		e.mu.Lock()
+		e.status.Error, e.status.LastSweep = err.Error(), now
+		e.mu.Unlock()
+		return
+	}
+	if partial != nil {
+		e.log.Warn().Err(err).Msg("policy: some session lists failed; enforcing the rest")
+	}
+	gone := func(id, kind string, live map[string]bool) bool {
+		return !live[id] && (partial == nil || partial.Kinds[kind] == nil)
+	}
+
+	e.mu.Lock()
+	var readers []mediamtx.Session
+	live := map[string]bool{}
+	for _, s := range all {
+		if s.State != "read" {
+			continue
+		}
+		live[s.ID] = true
+		readers = append(readers, s)
+	}
+	for id, t := range e.sessions {
+		if gone(id, t.kind, live) {
+			delete(e.sessions, id)
+		}
+	}
+	for id, kind := range e.acted {
+		if gone(id, kind, live) {
+			delete(e.acted, id)
+		}
+	}
+	for _, m := range []map[string]banEntry{e.bans, e.throttles} {
+		for ip, b := range m {
+			if !now.Before(b.until) {
+				delete(m, ip)
+			}
+		}
+	}
+	verdicts := e.evaluateLocked(readers, now)
+	e.status.Readers, e.status.LastSweep, e.status.Error = len(readers), now, ""
+	if err != nil {
+		e.status.Error = err.Error()
+	}
+	e.mu.Unlock()
+
+	for _, v := range verdicts {
+		e.act(ctx, v, now)
+	}
+}
+
+// evaluateLocked updates per-session state and returns at most one verdict per
+// session, for sessions not already acted on.
+func (e *Engine) evaluateLocked(readers []mediamtx.Session, now time.Time) []verdict {
+	fired := map[string]verdict{}
+	fire := func(v verdict) {
+		if _, ok := fired[v.s.ID]; !ok && e.acted[v.s.ID] == "" && !e.exempt(v.ip) {
+			fired[v.s.ID] = v
+		}
+	}
+
+	byToken := map[string][]verdict{}
+	byThrottledIP := map[string][]verdict{}
+	for _, s := range readers {
+		v := verdict{s: s, ip: hostOf(s.RemoteAddr), token: e.tokenOf(s.Query)}
+
+		// A ban or throttle holds against sessions admission never saw: ones
+		// opened before the kick, or all of them when admission is off.
+		if b, ok := e.bans[v.ip]; ok && v.ip != "" {
+			v.rule = RuleBanned
+			v.detail = fmt.Sprintf("banned after %s until %s", b.rule, b.until.UTC().Format(time.RFC3339))
+			fire(v)
+		}
+		if _, ok := e.throttles[v.ip]; ok && v.ip != "" {
+			byThrottledIP[v.ip] = append(byThrottledIP[v.ip], v)
+		}
+
+		t, ok := e.sessions[s.ID]
+		if !ok {
+			t = &tracked{kind: s.Kind, bytesSent: s.BytesSent, at: now}
+			e.sessions[s.ID] = t
+		} else if dt := now.Sub(t.at).Seconds(); dt > 0 && s.BytesSent >= t.bytesSent {
+			rate := float64(s.BytesSent-t.bytesSent) * 8 / 1e6 / dt
+			if e.opts.MaxSessionMbps > 0 && rate > e.opts.MaxSessionMbps {
+				if t.overSince.IsZero() {
+					t.overSince = t.at // the rate covers the whole interval
+				}
+				if now.Sub(t.overSince) >= e.opts.Sustain {
+					v.rule = RuleBandwidth
+					v.detail = fmt.Sprintf("%.1f Mbps for %s, ceiling %g Mbps", rate, now.Sub(t.overSince).Round(time.Second), e.opts.MaxSessionMbps)
+					fire(v)
+				}
+			} else {
+				t.overSince = time.Time{}
+			}
+			t.bytesSent, t.at = s.BytesSent, now
+		}
+
+		if age := now.Sub(s.Created); e.opts.MaxDuration > 0 && !s.Created.IsZero() && age > e.opts.MaxDuration {
+			v.rule = RuleDuration
+			v.detail = fmt.Sprintf("open %s, limit %s", age.Round(time.Second), e.opts.MaxDuration)
+			fire(v)
+		}
+		if v.token != "" {
+			byToken[v.token] = append(byToken[v.token], v)
+		}
+	}
+
+	if e.opts.MaxIPsPerToken > 0 {
+		for _, vs := range byToken {
+			ips := map[string]bool{}
+			for _, v := range vs {
+				ips[v.ip] = true
+			}
+			if len(ips) <= e.opts.MaxIPsPerToken {
+				continue
+			}
+			// The IP of the oldest session keeps the token; the others replay it.
+			sort.Slice(vs, func(i, j int) bool { return vs[i].s.Created.Before(vs[j].s.Created) })
+			owner := vs[0].ip
+			for _, v := range vs {
+				if v.ip == owner {
+					continue
+				}
+				v.rule = RuleTokenReuse
+				v.detail = fmt.Sprintf("token used from %d IPs, limit %d (first seen from %s)", len(ips), e.opts.MaxIPsPerToken, owner)
+				fire(v)
+			}
+		}
+	}
+
+	// Last, so an offence by a throttled IP is reported (and bans) as itself.
+	for ip, vs := range byThrottledIP {
+		limit := e.throttleReaders()
+		if len(vs) <= limit {
+			continue
+		}
+		// The oldest streams stay; the newest are over the limit.
+		sort.Slice(vs, func(i, j int) bool { return vs[i].s.Created.Before(vs[j].s.Created) })
+		for _, v := range vs[limit:] {
+			v.rule = RuleThrottle
+			v.detail = fmt.Sprintf("%s reads %d streams, throttled to %d after %s", ip, len(vs), limit, e.throttles[ip].rule)
+			fire(v)
+		}
+	}
+
+	out := make([]verdict, 0, len(fired))
+	for _, v := range fired {
+		out = append(out, v)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].s.ID < out[j].s.ID })
+	return out
+}
+
+// act kicks (or, in dry-run mode, only audits) one session.
+func (e *Engine) act(ctx context.Context, v verdict, now time.Time) {
+	a := Action{
+		Time:   now.UTC(),
+		Rule:   v.rule,
+		Result: ResultKicked,
+		Kind:   v.s.Kind,
+		ID:     v.s.ID,
+		Path:   v.s.Path,
+		IP:     v.ip,
+		Token:  v.token,
+		Detail: v.detail,
+	}
+	switch {
+	case e.opts.DryRun:
+		a.Result = ResultDryRun
+	default:
+		if err := e.client.KickSession(ctx, v.s.Kind, v.s.ID); err != nil {
+			a.Result, a.Error = ResultFailed, err.Error()
+		}
+	}
+
+	e.mu.Lock()
+	// Reconnecting after a duration kick is expected, and banned/throttle kicks
+	// enforce an earlier penalty. A bandwidth or token_reuse kick throttles the
+	// IP, or bans it if it is already throttled (or throttling is off).
+	if a.Result == ResultKicked && (v.rule == RuleBandwidth || v.rule == RuleTokenReuse) && v.ip != "" {
+		t, throttled := e.throttles[v.ip]
+		throttled = throttled && now.Before(t.until)
+		switch {
+		case e.opts.ThrottleFor > 0 && !throttled:
+			until := now.Add(e.opts.ThrottleFor).UTC()
+			a.ThrottledUntil = &until
+			e.throttles[v.ip] = banEntry{until: until, rule: v.rule}
+		case e.opts.BanFor > 0:
+			until := now.Add(e.opts.BanFor).UTC()
+			a.BannedUntil = &until
+			e.bans[v.ip] = banEntry{until: until, rule: v.rule}
+			delete(e.throttles, v.ip)
+		}
+	}
+	if a.Result != ResultFailed {
+		e.acted[v.s.ID] = v.s.Kind // failed kicks are retried next sweep
+	}
+	e.status.Actions[v.rule]++
+	e.status.Recent = append(e.status.Recent, a)
+	if n := len(e.status.Recent); n > recentActions {
+		e.status.Recent = e.status.Recent[n-recentActions:]
+	}
+	e.mu.Unlock()
+
+	actionsTotal.WithLabelValues(a.Rule, a.Result).Inc()
+	e.audit.Record(a)
+	e.bus.Publish(events.SessionKicked, a.Path, a)
+}
+
+func (e *Engine) exempt(ip string) bool {
+	parsed := net.ParseIP(ip)
+	for _, n := range e.opts.Exempt {
+		if parsed != nil && n.Contains(parsed) {
+			return true
+		}
+	}
+	return false
+}
+
+// tokenOf returns a fingerprint of the first token parameter in query, or "".
+// Tokens themselves are never stored or logged.
+func (e *Engine) tokenOf(query string) string {
+	q, err := url.ParseQuery(query)
+	if err != nil {
+		return ""
+	}
+	for _, p := range e.opts.TokenParams {
+		if t := q.Get(p); t != "" {
+			sum := sha256.Sum256([]byte(t))
+			return hex.EncodeToString(sum[:8])
+		}
+	}
+	return ""
+}
+
+// hostOf strips the port from a remote address.
+func hostOf(addr string) string {
+	if h, _, err := net.SplitHostPort(addr); err == nil {
+		return h
+	}
+	return addr
+}
@@ -0,0 +1,247 @@
+package policy
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"slowdrip-miner/internal/events"
+	"slowdrip-miner/internal/mediamtx"
+
+	"github.com/rs/zerolog"
+)
+
+// fakeSessions serves /v3/<kind>/list and /v3/<kind>/kick/<id>. Kinds in down
+// answer 500; kinds in neither map 404 (protocol disabled).
+type fakeSessions struct {
+	mu     sync.Mutex
+	kinds  map[string][]mediamtx.Session
+	down   map[string]bool
+	kicked []string
+}
+
+func (f *fakeSessions) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	parts := strings.Split(strings.TrimPrefix(r.URL.Path, "/v3/"), "/")
+	kind := parts[0]
+	if f.down[kind] {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]string{"error": "boom"})
+		return
+	}
+	list, ok := f.kinds[kind]
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	switch {
+	case len(parts) == 2 && parts[1] == "list":
+		json.NewEncoder(w).Encode(map[string]interface{}{"pageCount": 1, "items": list})
+	case len(parts) == 3 && parts[1] == "kick":
+		for i, s := range list {
+			if s.ID == parts[2] {
+				f.kinds[kind] = append(list[:i:i], list[i+1:]...)
+				f.kicked = append(f.kicked, s.ID)
+				return
+			}
+		}
+		http.NotFound(w, r)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func (f *fakeSessions) set(kind string, ss ...mediamtx.Session) {
+	f.mu.Lock()
+	f.kinds[kind] = ss
+	f.mu.Unlock()
+}
+
+func (f *fakeSessions) takeKicked() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	k := f.kicked
+	f.kicked = nil
+	return k
+}
+
+func newEngine(t *testing.T, o Options) (*Engine, *fakeSessions) {
+	t.Helper()
+	f := &fakeSessions{kinds: map[string][]mediamtx.Session{"rtspsessions": nil, "webrtcsessions": nil}, down: map[string]bool{}}
+	srv := httptest.NewServer(f)
+	t.Cleanup(srv.Close)
+	if o.Interval == 0 {
+		o.Interval = 5 * time.Second
+	}
+	if o.TokenParams == nil {
+		o.TokenParams = []string{"jwt"}
+	}
+	audit, err := OpenAudit("", zerolog.Nop())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return New(mediamtx.NewClient(srv.URL, zerolog.Nop()), o, audit, events.NewBus(16), zerolog.Nop()), f
+}
+
+func reader(id, ip string, created time.Time, bytesSent uint64, query string) mediamtx.Session {
+	return mediamtx.Session{ID: id, RemoteAddr: net.JoinHostPort(ip, "5000"), State: "read", Path: "live", Created: created, BytesSent: bytesSent, Query: query}
+}
+
+func TestSweepRules(t *testing.T) {
+	t0 := time.Now().Add(-time.Minute)
+	_, localhost, _ := net.ParseCIDR("127.0.0.0/8")
+	tests := []struct {
+		name       string
+		opts       Options
+		first      []mediamtx.Session // first sweep, at t0
+		second     []mediamtx.Session // second sweep, 10s later
+		wantKicked []string
+		wantRule   string
+	}{
+		{name: "bandwidth over the ceiling for sustain",
+			opts:  Options{MaxSessionMbps: 1, Sustain: 10 * time.Second},
+			first: []mediamtx.Session{reader("a", "10.0.0.1", t0, 0, ""), reader("b", "10.0.0.2", t0, 0, "")},
+			// a: 2.5 MB in 10s = 2 Mbps; b: 0.8 Mbps
+			second:     []mediamtx.Session{reader("a", "10.0.0.1", t0, 2.5e6, ""), reader("b", "10.0.0.2", t0, 1e6, "")},
+			wantKicked: []string{"a"}, wantRule: RuleBandwidth},
+		{name: "bandwidth not sustained long enough",
+			opts:   Options{MaxSessionMbps: 1, Sustain: time.Minute},
+			first:  []mediamtx.Session{reader("a", "10.0.0.1", t0, 0, "")},
+			second: []mediamtx.Session{reader("a", "10.0.0.1", t0, 2.5e6, "")}},
+		{name: "duration",
+			opts:       Options{MaxDuration: 30 * time.Second},
+			second:     []mediamtx.Session{reader("a", "10.0.0.1", t0, 0, ""), reader("b", "10.0.0.2", time.Now(), 0, "")},
+			wantKicked: []string{"a"}, wantRule: RuleDuration},
+		{name: "token reuse keeps the oldest IP",
+			opts: Options{MaxIPsPerToken: 1},
+			second: []mediamtx.Session{
+				reader("a", "10.0.0.1", t0, 0, "jwt=t1"),
+				reader("b", "10.0.0.2", t0.Add(time.Second), 0, "jwt=t1"),
+				reader("c", "10.0.0.3", t0, 0, "jwt=t2"),
+			},
+			wantKicked: []string{"b"}, wantRule: RuleTokenReuse},
+		{name: "exempt IP is never kicked",
+			opts:   Options{MaxDuration: 30 * time.Second, Exempt: []*net.IPNet{localhost}},
+			second: []mediamtx.Session{reader("a", "127.0.0.1", t0, 0, "")}},
+		{name: "dry run kicks nothing",
+			opts:     Options{MaxDuration: 30 * time.Second, DryRun: true},
+			second:   []mediamtx.Session{reader("a", "10.0.0.1", t0, 0, "")},
+			wantRule: RuleDuration},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e, f := newEngine(t, tt.opts)
+			ctx := context.Background()
+			if tt.first != nil {
+				f.set("rtspsessions", tt.first...)
+				e.sweep(ctx, time.Now().Add(-10*time.Second))
+			}
+			f.set("rtspsessions", tt.second...)
+			e.sweep(ctx, time.Now())
+			if got := strings.Join(f.takeKicked(), ","); got != strings.Join(tt.wantKicked, ",") {
+				t.Fatalf("kicked %q, want %q", got, tt.wantKicked)
+			}
+			st := e.Status()
+			if tt.wantRule == "" {
+				if len(st.Recent) != 0 {
+					t.Fatalf("actions %+v, want none", st.Recent)
+				}
+				return
+			}
+			if len(st.Recent) != 1 || st.Recent[0].Rule != tt.wantRule {
+				t.Fatalf("actions %+v, want one %s", st.Recent, tt.wantRule)
+			}
+		})
+	}
+}
+
+func TestThrottleThenBan(t *testing.T) {
+	e, f := newEngine(t, Options{MaxIPsPerToken: 1, ThrottleFor: time.Hour, ThrottleReaders: 1, BanFor: time.Hour})
+	ctx := context.Background()
+	t0 := time.Now().Add(-time.Minute)
+	owner := reader("o", "10.0.0.1", t0, 0, "jwt=t1")
+
+	// First offence: the replaying IP is kicked and throttled, not banned.
+	f.set("rtspsessions", owner, reader("r1", "10.0.0.9", t0.Add(time.Second), 0, "jwt=t1"))
+	e.sweep(ctx, time.Now())
+	if got := f.takeKicked(); len(got) != 1 || got[0] != "r1" {
+		t.Fatalf("kicked %v, want r1", got)
+	}
+	now := time.Now()
+	if _, ok := e.Blocked("10.0.0.9", now); ok {
+		t.Fatal("banned on the first offence")
+	}
+	if limit, _, ok := e.Throttled("10.0.0.9", now); !ok || limit != 1 {
+		t.Fatalf("throttled = %v (limit %d), want 1 stream", ok, limit)
+	}
+
+	// Within the throttle: two streams of its own, the newer one is kicked.
+	f.set("rtspsessions", owner,
+		reader("x1", "10.0.0.9", t0.Add(2*time.Second), 0, "jwt=t9"),
+		reader("x2", "10.0.0.9", t0.Add(3*time.Second), 0, "jwt=t8"))
+	e.sweep(ctx, time.Now())
+	if got := f.takeKicked(); len(got) != 1 || got[0] != "x2" {
+		t.Fatalf("kicked %v, want x2", got)
+	}
+
+	// Offending again while throttled bans it, and the ban holds against its
+	// remaining stream on the next sweep.
+	f.set("rtspsessions", owner,
+		reader("x1", "10.0.0.9", t0.Add(2*time.Second), 0, "jwt=t9"),
+		reader("r2", "10.0.0.9", t0.Add(4*time.Second), 0, "jwt=t1"))
+	e.sweep(ctx, time.Now())
+	if got := f.takeKicked(); len(got) != 1 || got[0] != "r2" {
+		t.Fatalf("kicked %v, want r2", got)
+	}
+	if _, ok := e.Blocked("10.0.0.9", time.Now()); !ok {
+		t.Fatal("not banned on the second offence")
+	}
+	e.sweep(ctx, time.Now())
+	if got := f.takeKicked(); len(got) != 1 || got[0] != "x1" {
+		t.Fatalf("kicked %v, want x1 (banned IP)", got)
+	}
+	if st := e.Status(); st.Bans != 1 || st.Throttles != 0 || st.Actions[RuleBanned] != 1 || st.Actions[RuleThrottle] != 1 {
+		t.Fatalf("status %+v", st)
+	}
+}
+
+func TestSweepSkipsFailingProtocol(t *testing.T) {
+	e, f := newEngine(t, Options{MaxSessionMbps: 1, Sustain: 10 * time.Second})
+	ctx := context.Background()
+	t0 := time.Now().Add(-time.Minute)
+	start := time.Now().Add(-20 * time.Second)
+
+	f.set("webrtcsessions", reader("w", "10.0.0.2", t0, 0, ""))
+	f.set("rtspsessions", reader("a", "10.0.0.1", t0, 0, ""))
+	e.sweep(ctx, start)
+
+	// RTSP listing breaks: WebRTC is still enforced, and the RTSP reader's
+	// history survives until the listing recovers.
+	f.mu.Lock()
+	f.down["rtspsessions"] = true
+	f.mu.Unlock()
+	f.set("webrtcsessions", reader("w", "10.0.0.2", t0, 2.5e6, ""))
+	e.sweep(ctx, start.Add(10*time.Second))
+	if got := f.takeKicked(); len(got) != 1 || got[0] != "w" {
+		t.Fatalf("kicked %v, want w", got)
+	}
+	if st := e.Status(); !strings.Contains(st.Error, "rtspsessions") {
+		t.Fatalf("status error %q, want the failing collection", st.Error)
+	}
+
+	f.mu.Lock()
+	f.down["rtspsessions"] = false
+	f.mu.Unlock()
+	f.set("rtspsessions", reader("a", "10.0.0.1", t0, 5e6, ""))
+	e.sweep(ctx, start.Add(20*time.Second))
+	if got := f.takeKicked(); len(got) != 1 || got[0] != "a" {
+		t.Fatalf("kicked %v, want a (rate over the whole 20s it was tracked)", got)
+	}
+}
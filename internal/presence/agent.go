@@ -4,6 +4,8 @@ import (
 	"context"
 	"time"
 
+	"slowdrip-miner/internal/events"
+
 	"github.com/rs/zerolog"
 )
 
@@ -16,6 +18,7 @@ func Start(ctx context.Context, log zerolog.Logger) {
 			return
 		case <-t.C:
 			log.Info().Msg("presence: heartbeat ok")
+			events.Publish(events.PresenceHeartbeat, "", map[string]interface{}{"ok": true})
 		}
 	}
 }
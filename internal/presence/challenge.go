@@ -0,0 +1,75 @@
+package presence
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"time"
+
+	"slowdrip-miner/internal/wallet"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+)
+
+// Challenge is a liveness probe: a fresh nonce the miner key must sign before Deadline.
+type Challenge struct {
+	MinerID  string    `json:"miner_id"`
+	Nonce    string    `json:"nonce"` // 32 random bytes, hex
+	Issued   time.Time `json:"issued"`
+	Deadline time.Time `json:"deadline"`
+}
+
+// Response is the miner's answer to a Challenge. At is the responder's own clock
+// and informational only; deadlines are judged by when the verifier receives it.
+type Response struct {
+	Address string    `json:"address"`
+	Sig     string    `json:"sig"` // EIP-191 over Challenge.Message
+	At      time.Time `json:"at"`
+}
+
+// NewChallenge issues a challenge for minerID that must be answered within ttl.
+func NewChallenge(minerID string, ttl time.Duration) (Challenge, error) {
+	var n [32]byte
+	if _, err := rand.Read(n[:]); err != nil {
+		return Challenge{}, fmt.Errorf("presence: nonce: %w", err)
+	}
+	now := time.Now().UTC()
+	return Challenge{MinerID: minerID, Nonce: hex.EncodeToString(n[:]), Issued: now, Deadline: now.Add(ttl)}, nil
+}
+
+// Message is the text the miner signs.
+func (c Challenge) Message() []byte {
+	return []byte(fmt.Sprintf("slowdrip-presence\nminer %s\nnonce %s\nissued %d\ndeadline %d",
+		c.MinerID, c.Nonce, c.Issued.UnixNano(), c.Deadline.UnixNano()))
+}
+
+// Respond signs c with the miner key.
+func Respond(s wallet.Signer, c Challenge) (Response, error) {
+	sig, err := s.SignEIP191(c.Message())
+	if err != nil {
+		return Response{}, fmt.Errorf("presence: sign challenge: %w", err)
+	}
+	return Response{Address: s.Address().Hex(), Sig: hexutil.Encode(sig), At: time.Now().UTC()}, nil
+}
+
+// VerifyResponse checks that r, which the verifier received at received, answers
+// c on time and was signed by expected. The responder's r.At is not trusted.
+func VerifyResponse(c Challenge, r Response, expected common.Address, received time.Time) error {
+	if received.After(c.Deadline) {
+		return fmt.Errorf("presence: answered %s after the deadline", received.Sub(c.Deadline))
+	}
+	sig, err := hexutil.Decode(r.Sig)
+	if err != nil {
+		return fmt.Errorf("presence: bad signature encoding: %w", err)
+	}
+	got, err := wallet.RecoverEIP191(c.Message(), sig)
+	if err != nil {
+		return err
+	}
+	if got != expected {
+		return errors.New("presence: challenge signed by " + got.Hex() + ", expected " + expected.Hex())
+	}
+	return nil
+}
@@ -0,0 +1,67 @@
+package presence
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"slowdrip-miner/internal/wallet"
+)
+
+func TestVerifyResponse(t *testing.T) {
+	k, err := wallet.NewRandom(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer k.Close()
+	other, err := wallet.NewRandom(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer other.Close()
+
+	c, err := NewChallenge("m1", time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r, err := Respond(k, c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name     string
+		resp     func() Response
+		received time.Time
+		wantErr  string
+	}{
+		{name: "on time", resp: func() Response { return r }, received: c.Issued.Add(time.Second)},
+		{name: "late", resp: func() Response { return r }, received: c.Deadline.Add(time.Second), wantErr: "after the deadline"},
+		{name: "backdated At is ignored", resp: func() Response {
+			late := r
+			late.At = c.Issued // a responder claiming it answered in time
+			return late
+		}, received: c.Deadline.Add(time.Second), wantErr: "after the deadline"},
+		{name: "wrong key", resp: func() Response {
+			wrong, err := Respond(other, c)
+			if err != nil {
+				t.Fatal(err)
+			}
+			return wrong
+		}, received: c.Issued, wantErr: "signed by"},
+		{name: "bad encoding", resp: func() Response { bad := r; bad.Sig = "zz"; return bad }, received: c.Issued, wantErr: "encoding"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := VerifyResponse(c, tt.resp(), k.Address(), tt.received)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("VerifyResponse: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("err = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
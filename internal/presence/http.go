@@ -0,0 +1,53 @@
+// internal/presence/http.go
+package presence
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"time"
+
+	"slowdrip-miner/internal/events"
+	"slowdrip-miner/internal/wallet"
+)
+
+// ChallengePath is where verifiers send challenges for the miner key to answer:
+//
+//	POST /v1/presence/challenge   body: Challenge   ->   Response
+const ChallengePath = "/v1/presence/challenge"
+
+// Handler answers challenges addressed to minerID with s and publishes each
+// answer as a presence.challenge event.
+func Handler(minerID string, s wallet.Signer) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		var c Challenge
+		if err := json.NewDecoder(io.LimitReader(r.Body, 4<<10)).Decode(&c); err != nil || c.Nonce == "" {
+			http.Error(w, "want a challenge: {miner_id, nonce, issued, deadline}", http.StatusBadRequest)
+			return
+		}
+		if c.MinerID != minerID {
+			http.Error(w, "challenge is for miner "+c.MinerID, http.StatusBadRequest)
+			return
+		}
+		if time.Now().After(c.Deadline) {
+			http.Error(w, "deadline already passed", http.StatusBadRequest)
+			return
+		}
+		resp, err := Respond(s, c)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		events.Publish(events.PresenceChallenge, "", map[string]interface{}{
+			"nonce":    c.Nonce,
+			"deadline": c.Deadline,
+			"address":  resp.Address,
+		})
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(resp)
+	})
+}
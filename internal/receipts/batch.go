@@ -0,0 +1,211 @@
+// internal/receipts/batch.go
+package receipts
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"slowdrip-miner/internal/wallet"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+)
+
+// BatchVersion is bumped if the batch header layout changes.
+const BatchVersion uint8 = 1
+
+// Hash is a 32-byte digest that encodes as 0x-prefixed hex in JSON.
+type Hash [32]byte
+
+func (h Hash) Hex() string { return "0x" + hex.EncodeToString(h[:]) }
+
+func (h Hash) MarshalText() ([]byte, error) { return []byte(h.Hex()), nil }
+
+func (h *Hash) UnmarshalText(b []byte) error {
+	raw, err := hex.DecodeString(strings.TrimPrefix(string(b), "0x"))
+	if err != nil || len(raw) != 32 {
+		return fmt.Errorf("receipts: bad hash %q", b)
+	}
+	copy(h[:], raw)
+	return nil
+}
+
+// BatchHeader is what the miner key signs (EIP-191) and what gets anchored on-chain.
+// Receipts in the batch are sorted by (Path, Seq, Nonce) before the root is built.
+type BatchHeader struct {
+	Version uint8  `json:"v"`
+	Miner   string `json:"miner"`    // 0x-prefixed EVM address of the signing key
+	ChainID int64  `json:"chain_id"` // 0 = unspecified
+	ID      uint64 `json:"id"`
+	Count   uint32 `json:"count"`
+	Root    Hash   `json:"root"`
+	Opened  int64  `json:"opened_unixnano"`
+	Closed  int64  `json:"closed_unixnano"`
+}
+
+// Message is the canonical text signed with EIP-191 (personal_sign), so the
+// header can be checked with any wallet tooling.
+func (h BatchHeader) Message() []byte {
+	return []byte(fmt.Sprintf("slowdrip-batch\nv%d\nminer %s\nchain %d\nid %d\ncount %d\nroot %s\nopened %d\nclosed %d",
+		h.Version, strings.ToLower(h.Miner), h.ChainID, h.ID, h.Count, h.Root.Hex(), h.Opened, h.Closed))
+}
+
+// Batch is a closed, signed set of receipts as kept in the Store.
+type Batch struct {
+	Header   BatchHeader `json:"header"`
+	Sig      string      `json:"sig"` // 0x hex, 65 bytes, V in {27,28}
+	Receipts []Receipt   `json:"receipts"`
+	Anchor   *AnchorInfo `json:"anchor,omitempty"`
+	Claim    *ClaimInfo  `json:"claim,omitempty"`
+}
+
+// AnchorInfo records that the batch root was submitted on-chain and confirmed.
+type AnchorInfo struct {
+	Tx    string `json:"tx"`
+	Block uint64 `json:"block"`
+	At    int64  `json:"at_unixnano"`
+}
+
+// ClaimInfo records that a batch was claimed (on-chain or via an exported bundle).
+type ClaimInfo struct {
+	Tx string `json:"tx,omitempty"`
+	At int64  `json:"at_unixnano"`
+	By string `json:"by"` // "chain" | "bundle"
+}
+
+// VerifyBatch checks a stored batch on its own: the header signature recovers to
+// header.Miner, every receipt signature is valid, and count and root match the receipts.
+func VerifyBatch(b *Batch) error {
+	h := b.Header
+	sig, err := hexutil.Decode(b.Sig)
+	if err != nil {
+		return fmt.Errorf("receipts: batch %d: bad signature encoding: %w", h.ID, err)
+	}
+	signer, err := wallet.RecoverEIP191(h.Message(), sig)
+	if err != nil {
+		return fmt.Errorf("receipts: batch %d: %w", h.ID, err)
+	}
+	if !common.IsHexAddress(h.Miner) || signer != common.HexToAddress(h.Miner) {
+		return fmt.Errorf("receipts: batch %d: signed by %s, header says %s", h.ID, signer.Hex(), h.Miner)
+	}
+	if int(h.Count) != len(b.Receipts) {
+		return fmt.Errorf("receipts: batch %d: header counts %d receipts, batch has %d", h.ID, h.Count, len(b.Receipts))
+	}
+	for i, r := range b.Receipts {
+		if err := Verify(r); err != nil {
+			return fmt.Errorf("receipts: batch %d receipt %d: %w", h.ID, i, err)
+		}
+	}
+	if Hash(MerkleRoot(b.Receipts)) != h.Root {
+		return fmt.Errorf("receipts: batch %d: receipts do not hash to the header root", h.ID)
+	}
+	return nil
+}
+
+// SortReceipts orders receipts canonically for batching.
+func SortReceipts(rs []Receipt) {
+	sort.Slice(rs, func(i, j int) bool {
+		if rs[i].Path != rs[j].Path {
+			return rs[i].Path < rs[j].Path
+		}
+		if rs[i].Seq != rs[j].Seq {
+			return rs[i].Seq < rs[j].Seq
+		}
+		return rs[i].Nonce < rs[j].Nonce
+	})
+}
+
+// ------------------------
+// Merkle proofs
+// ------------------------
+
+// MerkleProof returns the sibling path for leaf i of the tree MerkleRoot builds
+// over rs (same odd-node duplication), bottom-up. To prove every leaf, build the
+// tree once with NewMerkleTree instead.
+func MerkleProof(rs []Receipt, i int) ([]Hash, error) {
+	return NewMerkleTree(rs).Proof(i)
+}
+
+// MerkleTree keeps every level of the tree over a receipt list, so proofs for
+// all n leaves cost O(n log n) rather than a rebuild per leaf.
+type MerkleTree struct {
+	n      int          // leaves
+	levels [][][32]byte // leaves first; odd levels padded with their last node
+}
+
+// NewMerkleTree hashes rs into a tree with MerkleRoot's layout.
+func NewMerkleTree(rs []Receipt) *MerkleTree {
+	level := make([][32]byte, len(rs))
+	for j := range rs {
+		level[j] = MerkleLeaf(rs[j])
+	}
+	t := &MerkleTree{n: len(rs)}
+	for len(level) > 1 {
+		if len(level)%2 == 1 {
+			level = append(level, level[len(level)-1])
+		}
+		t.levels = append(t.levels, level)
+		next := make([][32]byte, 0, len(level)/2)
+		for j := 0; j < len(level); j += 2 {
+			next = append(next, hashPair(level[j], level[j+1]))
+		}
+		level = next
+	}
+	t.levels = append(t.levels, level)
+	return t
+}
+
+// Root is the tree root (zero for no receipts), equal to MerkleRoot.
+func (t *MerkleTree) Root() Hash {
+	top := t.levels[len(t.levels)-1]
+	if len(top) == 0 {
+		return Hash{}
+	}
+	return Hash(top[0])
+}
+
+// Leaf returns the hash of leaf i.
+func (t *MerkleTree) Leaf(i int) Hash { return Hash(t.levels[0][i]) }
+
+// Proof returns the sibling path for leaf i, bottom-up.
+func (t *MerkleTree) Proof(i int) ([]Hash, error) {
+	if i < 0 || i >= t.n {
+		return nil, fmt.Errorf("receipts: proof index %d out of range (%d leaves)", i, t.n)
+	}
+	proof := make([]Hash, 0, len(t.levels)-1)
+	for _, level := range t.levels[:len(t.levels)-1] {
+		proof = append(proof, Hash(level[i^1]))
+		i /= 2
+	}
+	return proof, nil
+}
+
+// VerifyMerkleProof checks that leaf sits at index under root.
+func VerifyMerkleProof(leaf [32]byte, index uint64, proof []Hash, root Hash) bool {
+	cur := leaf
+	for _, sib := range proof {
+		if index%2 == 0 {
+			cur = hashPair(cur, sib)
+		} else {
+			cur = hashPair(sib, cur)
+		}
+		index /= 2
+	}
+	return index == 0 && cur == root
+}
+
+func hashPair(a, b [32]byte) [32]byte {
+	h := sha256.New()
+	h.Write(a[:])
+	h.Write(b[:])
+	var out [32]byte
+	copy(out[:], h.Sum(nil))
+	return out
+}
+
+// ErrEmptyBatch is returned when closing a batch with no receipts.
+var ErrEmptyBatch = errors.New("receipts: empty batch")
@@ -0,0 +1,199 @@
+// internal/receipts/batcher.go
+package receipts
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"slowdrip-miner/internal/events"
+	"slowdrip-miner/internal/wallet"
+
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/rs/zerolog"
+)
+
+// BatcherOptions configures a Batcher.
+type BatcherOptions struct {
+	ChainID  int64
+	Interval time.Duration // close the open batch this often (if non-empty)
+	MaxSize  int           // ...or as soon as it holds this many receipts
+}
+
+// Batcher collects signed receipts, and periodically closes them into a batch:
+// sorted, Merkle-rooted, header signed by the miner key and persisted in a Store.
+// Each closed batch is announced as events.BatchClosed. Receipts waiting for the
+// next batch are journalled in the Store and reloaded by NewBatcher.
+type Batcher struct {
+	store  *Store
+	signer wallet.Signer
+	opts   BatcherOptions
+	log    zerolog.Logger
+
+	mu      sync.Mutex
+	pending []Receipt
+	opened  time.Time
+	nextID  uint64
+}
+
+// NewBatcher resumes numbering after the highest batch already in store, and
+// reloads the receipts journalled for the batch that was open when it stopped.
+func NewBatcher(store *Store, s wallet.Signer, o BatcherOptions, log zerolog.Logger) (*Batcher, error) {
+	if o.Interval <= 0 {
+		o.Interval = 5 * time.Minute
+	}
+	if o.MaxSize <= 0 {
+		o.MaxSize = 10000
+	}
+	next, err := store.NextID()
+	if err != nil {
+		return nil, err
+	}
+	b := &Batcher{
+		store:  store,
+		signer: s,
+		opts:   o,
+		log:    log.With().Str("module", "batcher").Logger(),
+		nextID: next,
+		opened: time.Now(),
+	}
+	if err := b.recover(); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+// recover reloads journalled receipts. Ones already in the newest batch (a crash
+// between saving it and clearing the journal) are skipped.
+func (b *Batcher) recover() error {
+	rs, err := b.store.LoadPending()
+	if err != nil || len(rs) == 0 {
+		return err
+	}
+	saved := map[string]bool{}
+	if b.nextID > 1 {
+		last, err := b.store.Load(b.nextID - 1)
+		if err != nil {
+			return err
+		}
+		for _, r := range last.Receipts {
+			saved[string(r.Sig)] = true
+		}
+	}
+	for _, r := range rs {
+		if saved[string(r.Sig)] {
+			continue
+		}
+		if err := Verify(r); err != nil {
+			b.log.Warn().Err(err).Str("path", r.Path).Uint64("seq", r.Seq).Msg("batcher: dropping journalled receipt")
+			continue
+		}
+		b.pending = append(b.pending, r)
+	}
+	if len(b.pending) < len(rs) {
+		// Rewrite the journal so it matches pending again.
+		if err := b.store.ClearPending(); err != nil {
+			return err
+		}
+		for _, r := range b.pending {
+			if err := b.store.AppendPending(r); err != nil {
+				return err
+			}
+		}
+	}
+	b.log.Info().Int("receipts", len(b.pending)).Msg("batcher: recovered pending receipts")
+	return nil
+}
+
+// Add queues a signed receipt. Receipts with bad signatures are dropped.
+func (b *Batcher) Add(r Receipt) {
+	if err := Verify(r); err != nil {
+		b.log.Warn().Err(err).Str("path", r.Path).Uint64("seq", r.Seq).Msg("batcher: dropping receipt")
+		return
+	}
+	b.mu.Lock()
+	if err := b.store.AppendPending(r); err != nil {
+		// Still batch it; it is only lost if the miner also stops before the close.
+		b.log.Error().Err(err).Msg("batcher: journal")
+	}
+	b.pending = append(b.pending, r)
+	full := len(b.pending) >= b.opts.MaxSize
+	b.mu.Unlock()
+	if full {
+		if _, err := b.Close(); err != nil {
+			b.log.Error().Err(err).Msg("batcher: close")
+		}
+	}
+}
+
+// Run consumes receipts from in and closes batches every Interval until ctx ends,
+// then closes whatever is still pending.
+func (b *Batcher) Run(ctx context.Context, in <-chan Receipt) {
+	t := time.NewTicker(b.opts.Interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			if _, err := b.Close(); err != nil && err != ErrEmptyBatch {
+				b.log.Error().Err(err).Msg("batcher: final close")
+			}
+			return
+		case r := <-in:
+			b.Add(r)
+		case <-t.C:
+			if _, err := b.Close(); err != nil && err != ErrEmptyBatch {
+				b.log.Error().Err(err).Msg("batcher: close")
+			}
+		}
+	}
+}
+
+// Close seals the pending receipts into a batch now. On failure the receipts
+// stay pending and are retried with the next close.
+func (b *Batcher) Close() (*Batch, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if len(b.pending) == 0 {
+		return nil, ErrEmptyBatch
+	}
+
+	rs := append([]Receipt(nil), b.pending...)
+	SortReceipts(rs)
+	now := time.Now()
+	h := BatchHeader{
+		Version: BatchVersion,
+		Miner:   b.signer.Address().Hex(),
+		ChainID: b.opts.ChainID,
+		ID:      b.nextID,
+		Count:   uint32(len(rs)),
+		Root:    Hash(MerkleRoot(rs)),
+		Opened:  b.opened.UnixNano(),
+		Closed:  now.UnixNano(),
+	}
+	sig, err := b.signer.SignEIP191(h.Message())
+	if err != nil {
+		return nil, fmt.Errorf("receipts: sign batch %d: %w", h.ID, err)
+	}
+	batch := &Batch{Header: h, Sig: hexutil.Encode(sig), Receipts: rs}
+	if err := b.store.Save(batch); err != nil {
+		return nil, err
+	}
+
+	if err := b.store.ClearPending(); err != nil {
+		// NewBatcher skips receipts the newest batch already holds; only a clear
+		// that keeps failing across closes can get older ones batched twice.
+		b.log.Error().Err(err).Msg("batcher: clear journal")
+	}
+	b.pending = b.pending[:0]
+	b.opened = now
+	b.nextID++
+
+	b.log.Info().Uint64("id", h.ID).Uint32("count", h.Count).Str("root", h.Root.Hex()).Msg("batch closed")
+	events.Publish(events.BatchClosed, "", map[string]interface{}{
+		"id":    h.ID,
+		"count": h.Count,
+		"root":  h.Root.Hex(),
+	})
+	return batch, nil
+}
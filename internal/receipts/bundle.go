@@ -0,0 +1,191 @@
+// internal/receipts/bundle.go
+package receipts
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"slowdrip-miner/internal/wallet"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+)
+
+// BundleFormat and BundleVersion identify an offline claim bundle.
+// Version 2 lists every signing key, so one bundle can span a key rotation.
+const (
+	BundleFormat  = "slowdrip-claim-bundle"
+	BundleVersion = 2
+)
+
+// Bundle is a self-contained export of unclaimed batches: each header with the
+// miner's signature, and every receipt with its Merkle proof. It carries no keys
+// and needs no RPC; a separate machine can verify it and submit the claims.
+// Each batch is signed by the key named in its own header; Miners lists them
+// all, in the order they first appear.
+type Bundle struct {
+	Format   string        `json:"format"`
+	Version  int           `json:"version"`
+	Miners   []string      `json:"miners"`
+	ChainID  int64         `json:"chain_id"`
+	Created  time.Time     `json:"created"`
+	Batches  []BundleBatch `json:"batches"`
+	Receipts int           `json:"receipt_count"`
+}
+
+// BundleBatch is one signed batch header and its proved receipts.
+type BundleBatch struct {
+	Header   BatchHeader     `json:"header"`
+	Sig      string          `json:"sig"`
+	Receipts []ProvedReceipt `json:"receipts"`
+}
+
+// ProvedReceipt is a receipt with its position and sibling path in the batch tree.
+type ProvedReceipt struct {
+	Receipt Receipt `json:"receipt"`
+	Index   uint64  `json:"index"`
+	Leaf    Hash    `json:"leaf"`
+	Proof   []Hash  `json:"proof"`
+}
+
+// BuildBundle exports batches (typically Store.Unclaimed) into a bundle.
+// All batches must be on the same chain; they may be signed by different keys.
+func BuildBundle(batches []*Batch) (*Bundle, error) {
+	if len(batches) == 0 {
+		return nil, errors.New("receipts: nothing to bundle")
+	}
+	out := &Bundle{
+		Format:  BundleFormat,
+		Version: BundleVersion,
+		ChainID: batches[0].Header.ChainID,
+		Created: time.Now().UTC(),
+	}
+	for _, b := range batches {
+		if b.Header.ChainID != out.ChainID {
+			return nil, fmt.Errorf("receipts: batch %d is on chain %d, bundle is chain %d",
+				b.Header.ID, b.Header.ChainID, out.ChainID)
+		}
+		if !out.HasMiner(b.Header.Miner) {
+			out.Miners = append(out.Miners, b.Header.Miner)
+		}
+		tree := NewMerkleTree(b.Receipts)
+		bb := BundleBatch{Header: b.Header, Sig: b.Sig, Receipts: make([]ProvedReceipt, len(b.Receipts))}
+		for i, r := range b.Receipts {
+			proof, err := tree.Proof(i)
+			if err != nil {
+				return nil, err
+			}
+			bb.Receipts[i] = ProvedReceipt{Receipt: r, Index: uint64(i), Leaf: tree.Leaf(i), Proof: proof}
+		}
+		out.Batches = append(out.Batches, bb)
+		out.Receipts += len(bb.Receipts)
+	}
+	return out, nil
+}
+
+// HasMiner reports whether addr is one of the bundle's signing keys.
+func (b *Bundle) HasMiner(addr string) bool {
+	for _, m := range b.Miners {
+		if strings.EqualFold(m, addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// WriteBundle encodes b as indented JSON.
+func WriteBundle(w io.Writer, b *Bundle) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(b)
+}
+
+// ReadBundle decodes a bundle and checks its format and version.
+func ReadBundle(r io.Reader) (*Bundle, error) {
+	var b Bundle
+	dec := json.NewDecoder(r)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&b); err != nil {
+		return nil, fmt.Errorf("receipts: decode bundle: %w", err)
+	}
+	if b.Format != BundleFormat {
+		return nil, fmt.Errorf("receipts: not a claim bundle (format %q)", b.Format)
+	}
+	if b.Version != BundleVersion {
+		return nil, fmt.Errorf("receipts: unsupported bundle version %d", b.Version)
+	}
+	return &b, nil
+}
+
+// VerifyBundle checks everything offline: each header's EIP-191 signature
+// recovers to the miner in that header, which the bundle lists, every receipt's
+// ed25519 signature is valid, every Merkle proof leads to its header's root,
+// and the counts add up. It returns the first problem found.
+func VerifyBundle(b *Bundle) error {
+	if len(b.Miners) == 0 {
+		return errors.New("receipts: bundle lists no miners")
+	}
+	for _, m := range b.Miners {
+		if !common.IsHexAddress(m) {
+			return fmt.Errorf("receipts: bundle miner %q is not an address", m)
+		}
+	}
+	total := 0
+	seen := map[uint64]bool{}
+	for _, bb := range b.Batches {
+		h := bb.Header
+		if seen[h.ID] {
+			return fmt.Errorf("receipts: batch %d appears twice", h.ID)
+		}
+		seen[h.ID] = true
+		if h.Version != BatchVersion {
+			return fmt.Errorf("receipts: batch %d: unsupported header version %d", h.ID, h.Version)
+		}
+		if h.ChainID != b.ChainID {
+			return fmt.Errorf("receipts: batch %d: chain %d, bundle is chain %d", h.ID, h.ChainID, b.ChainID)
+		}
+		sig, err := hexutil.Decode(bb.Sig)
+		if err != nil {
+			return fmt.Errorf("receipts: batch %d: bad signature encoding: %w", h.ID, err)
+		}
+		if !common.IsHexAddress(h.Miner) || !b.HasMiner(h.Miner) {
+			return fmt.Errorf("receipts: batch %d: miner %s is not listed in the bundle", h.ID, h.Miner)
+		}
+		signer, err := wallet.RecoverEIP191(h.Message(), sig)
+		if err != nil {
+			return fmt.Errorf("receipts: batch %d: %w", h.ID, err)
+		}
+		if miner := common.HexToAddress(h.Miner); signer != miner {
+			return fmt.Errorf("receipts: batch %d: signed by %s, expected %s", h.ID, signer.Hex(), miner.Hex())
+		}
+		if int(h.Count) != len(bb.Receipts) {
+			return fmt.Errorf("receipts: batch %d: header counts %d receipts, bundle has %d", h.ID, h.Count, len(bb.Receipts))
+		}
+		idx := map[uint64]bool{}
+		for _, pr := range bb.Receipts {
+			if pr.Index >= uint64(h.Count) || idx[pr.Index] {
+				return fmt.Errorf("receipts: batch %d: bad or duplicate receipt index %d", h.ID, pr.Index)
+			}
+			idx[pr.Index] = true
+			if err := Verify(pr.Receipt); err != nil {
+				return fmt.Errorf("receipts: batch %d receipt %d: %w", h.ID, pr.Index, err)
+			}
+			leaf := MerkleLeaf(pr.Receipt)
+			if Hash(leaf) != pr.Leaf {
+				return fmt.Errorf("receipts: batch %d receipt %d: leaf does not match receipt", h.ID, pr.Index)
+			}
+			if !VerifyMerkleProof(leaf, pr.Index, pr.Proof, h.Root) {
+				return fmt.Errorf("receipts: batch %d receipt %d: Merkle proof does not reach root", h.ID, pr.Index)
+			}
+		}
+		total += len(bb.Receipts)
+	}
+	if total != b.Receipts {
+		return fmt.Errorf("receipts: bundle claims %d receipts, has %d", b.Receipts, total)
+	}
+	return nil
+}
@@ -0,0 +1,242 @@
+package receipts
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"slowdrip-miner/internal/service"
+	"slowdrip-miner/internal/wallet"
+
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/rs/zerolog"
+)
+
+// signedReceipts returns n valid receipts for path, signed by a fresh session key.
+func signedReceipts(t *testing.T, path string, n int) []Receipt {
+	t.Helper()
+	ss, err := NewSessionSigner("test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ss.Close()
+	now := time.Now()
+	out := make([]Receipt, n)
+	for i := range out {
+		sr := service.SegmentReceipt{Path: path, Seq: uint64(i + 1), Size: 1000, Deadline: now.Add(time.Second), Recv: now}
+		sr.Commit[0] = byte(i)
+		if out[i], err = BuildAndSign(ss, sr, uint64(i)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return out
+}
+
+func newKey(t *testing.T) *wallet.Keystore {
+	t.Helper()
+	k, err := wallet.NewRandom(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(k.Close)
+	return k
+}
+
+// signedBatch seals rs into batch id signed by k.
+func signedBatch(t *testing.T, k wallet.Signer, id uint64, rs []Receipt) *Batch {
+	t.Helper()
+	rs = append([]Receipt(nil), rs...)
+	SortReceipts(rs)
+	h := BatchHeader{Version: BatchVersion, Miner: k.Address().Hex(), ID: id, Count: uint32(len(rs)), Root: Hash(MerkleRoot(rs))}
+	sig, err := k.SignEIP191(h.Message())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &Batch{Header: h, Sig: hexutil.Encode(sig), Receipts: rs}
+}
+
+func TestMerkleTree(t *testing.T) {
+	for _, n := range []int{1, 2, 3, 4, 5, 7, 8, 9, 33} {
+		t.Run(fmt.Sprint(n), func(t *testing.T) {
+			rs := signedReceipts(t, "live/a", n)
+			tree := NewMerkleTree(rs)
+			root := Hash(MerkleRoot(rs))
+			if tree.Root() != root {
+				t.Fatalf("tree root %s, MerkleRoot %s", tree.Root().Hex(), root.Hex())
+			}
+			for i := range rs {
+				proof, err := tree.Proof(i)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if !VerifyMerkleProof(MerkleLeaf(rs[i]), uint64(i), proof, root) {
+					t.Fatalf("proof for leaf %d does not verify", i)
+				}
+				if i > 0 && VerifyMerkleProof(MerkleLeaf(rs[i]), uint64(i-1), proof, root) {
+					t.Fatalf("proof for leaf %d verifies at index %d", i, i-1)
+				}
+			}
+			for _, bad := range []int{-1, n} {
+				if _, err := tree.Proof(bad); err == nil {
+					t.Fatalf("Proof(%d) succeeded", bad)
+				}
+			}
+		})
+	}
+}
+
+func TestBuildBundleAcrossRotation(t *testing.T) {
+	oldKey, newKey1 := newKey(t), newKey(t)
+	batches := []*Batch{
+		signedBatch(t, oldKey, 1, signedReceipts(t, "live/a", 3)),
+		signedBatch(t, newKey1, 2, signedReceipts(t, "live/b", 5)),
+		signedBatch(t, newKey1, 3, signedReceipts(t, "live/c", 1)),
+	}
+	b, err := BuildBundle(batches)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(b.Miners) != 2 || b.Miners[0] != oldKey.Address().Hex() || b.Miners[1] != newKey1.Address().Hex() {
+		t.Fatalf("miners %v", b.Miners)
+	}
+	if b.Receipts != 9 {
+		t.Fatalf("receipt count %d, want 9", b.Receipts)
+	}
+
+	var buf bytes.Buffer
+	if err := WriteBundle(&buf, b); err != nil {
+		t.Fatal(err)
+	}
+	got, err := ReadBundle(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := VerifyBundle(got); err != nil {
+		t.Fatalf("VerifyBundle: %v", err)
+	}
+}
+
+func TestVerifyBundleRejects(t *testing.T) {
+	k, other := newKey(t), newKey(t)
+	fresh := func() *Bundle {
+		b, err := BuildBundle([]*Batch{
+			signedBatch(t, k, 1, signedReceipts(t, "live/a", 4)),
+			signedBatch(t, k, 2, signedReceipts(t, "live/b", 2)),
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return b
+	}
+	tests := []struct {
+		name    string
+		tamper  func(b *Bundle)
+		wantErr string
+	}{
+		{name: "no miners", tamper: func(b *Bundle) { b.Miners = nil }, wantErr: "no miners"},
+		{name: "unlisted miner", tamper: func(b *Bundle) { b.Miners = []string{other.Address().Hex()} }, wantErr: "not listed"},
+		{name: "header claims another miner", tamper: func(b *Bundle) {
+			b.Miners = append(b.Miners, other.Address().Hex())
+			b.Batches[0].Header.Miner = other.Address().Hex()
+		}, wantErr: "signed by"},
+		{name: "duplicate batch", tamper: func(b *Bundle) { b.Batches[1].Header.ID = 1 }, wantErr: "appears twice"},
+		{name: "wrong chain", tamper: func(b *Bundle) { b.ChainID = 5 }, wantErr: "chain"},
+		{name: "swapped receipt", tamper: func(b *Bundle) {
+			b.Batches[0].Receipts[0].Receipt = b.Batches[1].Receipts[0].Receipt
+		}, wantErr: "leaf does not match"},
+		{name: "bad proof", tamper: func(b *Bundle) { b.Batches[0].Receipts[1].Proof[0][0] ^= 1 }, wantErr: "does not reach root"},
+		{name: "dropped receipt", tamper: func(b *Bundle) { b.Batches[0].Receipts = b.Batches[0].Receipts[1:] }, wantErr: "header counts"},
+		{name: "wrong total", tamper: func(b *Bundle) { b.Receipts++ }, wantErr: "claims"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := fresh()
+			tt.tamper(b)
+			err := VerifyBundle(b)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("err = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestBatcherRecoversJournal(t *testing.T) {
+	k := newKey(t)
+	store, err := OpenStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	rs := signedReceipts(t, "live/a", 5)
+
+	b1, err := NewBatcher(store, k, BatcherOptions{}, zerolog.Nop())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, r := range rs[:3] {
+		b1.Add(r)
+	}
+	if _, err := b1.Close(); err != nil {
+		t.Fatal(err)
+	}
+	for _, r := range rs[3:] {
+		b1.Add(r)
+	}
+	// Simulate a crash between saving batch 1 and clearing the journal: its
+	// receipts are journalled again ahead of the two still pending.
+	pending, err := store.LoadPending()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := store.ClearPending(); err != nil {
+		t.Fatal(err)
+	}
+	for _, r := range append(append([]Receipt(nil), rs[:3]...), pending...) {
+		if err := store.AppendPending(r); err != nil {
+			t.Fatal(err)
+		}
+	}
+	store.journal.WriteString(`{"v":1,"path":"torn`) // cut off mid-write
+
+	// "Restart".
+	store2, err := OpenStore(store.Dir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	b2, err := NewBatcher(store2, k, BatcherOptions{}, zerolog.Nop())
+	if err != nil {
+		t.Fatal(err)
+	}
+	batch, err := b2.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if batch.Header.ID != 2 || batch.Header.Count != 2 {
+		t.Fatalf("batch %d with %d receipts, want batch 2 with the 2 unbatched receipts", batch.Header.ID, batch.Header.Count)
+	}
+	if err := VerifyBatch(batch); err != nil {
+		t.Fatal(err)
+	}
+	left, err := store2.LoadPending()
+	if err != nil || len(left) != 0 {
+		t.Fatalf("journal after close: %d receipts, err %v", len(left), err)
+	}
+}
+
+func TestAggregateAnchorMatchesBatchRoot(t *testing.T) {
+	rs := signedReceipts(t, "live/a", 4)
+	// Same (Path, Seq) twice, told apart only by nonce: both sorts must agree.
+	dup := signedReceipts(t, "live/a", 1)[0]
+	dup.Seq, dup.Nonce = rs[0].Seq, 7
+	rs = append(rs, dup)
+
+	b := signedBatch(t, newKey(t), 1, rs)
+	shuffled := append([]Receipt(nil), b.Receipts...)
+	for i, j := 0, len(shuffled)-1; i < j; i, j = i+1, j-1 {
+		shuffled[i], shuffled[j] = shuffled[j], shuffled[i]
+	}
+	if got, want := AggregateAnchor(shuffled), strings.TrimPrefix(b.Header.Root.Hex(), "0x"); got != want {
+		t.Fatalf("AggregateAnchor %s, batch root %s", got, want)
+	}
+}
@@ -3,20 +3,26 @@ package receipts
 
 import (
 	"context"
-	cryptoRand "crypto/rand"
 	"crypto/ed25519"
+	cryptoRand "crypto/rand"
 	"crypto/sha256"
 	"encoding/binary"
 	"encoding/hex"
 	"errors"
-	"sort"
+	"math/bits"
 	"time"
 
+	"slowdrip-miner/internal/events"
 	"slowdrip-miner/internal/service"
 )
 
-// Versioned receipt format (bump if you change layout)
-const ReceiptVersion uint8 = 1
+// Versioned receipt format (bump if you change layout).
+// v2 adds the FEC shard fields to the digest; v1 receipts still verify.
+// FromSegment only uses v2 for segments with FEC.
+const ReceiptVersion uint8 = 2
+
+// receiptVersionPlain is the format of receipts without FEC shards.
+const receiptVersionPlain uint8 = 1
 
 // Domain separation tag to avoid cross-protocol replay.
 // You can make this configurable if you run testnets.
@@ -26,16 +32,34 @@ const DomainTag = "SlowDrip:PoS-Receipt:v1"
 // It intentionally mirrors fields from service.SegmentReceipt.
 // All times are encoded as UnixNano for canonical hashing.
 type Receipt struct {
-	Version  uint8     `json:"v"`
-	Path     string    `json:"path"`
-	Seq      uint64    `json:"seq"`
-	Size     int64     `json:"size"`
-	Deadline int64     `json:"deadline_unixnano"`
-	Recv     int64     `json:"recv_unixnano"`
-	Commit   [32]byte  `json:"commit"`   // integrity commit for the segment/payload (e.g., H(payload/FEC))
-	Nonce    uint64    `json:"nonce"`    // anti-replay within a session
-	PubKey   []byte    `json:"pubkey"`   // ed25519 public key (ephemeral session key)
-	Sig      []byte    `json:"sig"`      // ed25519 signature over digest
+	Version  uint8    `json:"v"`
+	Path     string   `json:"path"`
+	Seq      uint64   `json:"seq"`
+	Size     int64    `json:"size"`
+	Deadline int64    `json:"deadline_unixnano"`
+	Recv     int64    `json:"recv_unixnano"`
+	Commit   [32]byte `json:"commit"` // integrity commit for the segment/payload (e.g., H(payload/FEC))
+	Nonce    uint64   `json:"nonce"`  // anti-replay within a session
+	PubKey   []byte   `json:"pubkey"` // ed25519 public key (ephemeral session key)
+	Sig      []byte   `json:"sig"`    // ed25519 signature over digest
+
+	// v2: FEC shards delivered (bit i = shard i) and how many rebuild the
+	// segment; Commit is then an fec.Commitment. DataShards 0 = no FEC.
+	Shards     uint64 `json:"shards,omitempty"`
+	DataShards uint16 `json:"data_shards,omitempty"`
+}
+
+// Delivered is the fraction of the segment the receipt pays for: shards
+// delivered over the DataShards needed to rebuild it, at most 1.
+func (r Receipt) Delivered() float64 {
+	if r.DataShards == 0 {
+		return 1
+	}
+	n := float64(bits.OnesCount64(r.Shards)) / float64(r.DataShards)
+	if n > 1 {
+		return 1
+	}
+	return n
 }
 
 // SessionSigner holds an ephemeral keypair used to sign receipts
@@ -67,16 +91,21 @@ func (s *SessionSigner) PublicKey() []byte { return append([]byte(nil), s.pub...
 
 // Close wipes private key material in memory (best-effort).
 func (s *SessionSigner) Close() {
-	for i := range s.pr iv {
+	for i := range s.priv {
 		s.priv[i] = 0
 	}
 }
 
 // FromSegment converts a SegmentReceipt (miner-side observation) into a signable Receipt.
 // The Nonce lets you disambiguate multiple receipts with the same seq in rare replays.
+// Receipts without FEC (DataShards 0) keep the v1 layout.
 func FromSegment(sr service.SegmentReceipt, nonce uint64) Receipt {
+	v := ReceiptVersion
+	if sr.DataShards == 0 {
+		v = receiptVersionPlain
+	}
 	return Receipt{
-		Version:  ReceiptVersion,
+		Version:  v,
 		Path:     sr.Path,
 		Seq:      sr.Seq,
 		Size:     sr.Size,
@@ -84,6 +113,9 @@ func FromSegment(sr service.SegmentReceipt, nonce uint64) Receipt {
 		Recv:     sr.Recv.UnixNano(),
 		Commit:   sr.Commit,
 		Nonce:    nonce,
+
+		Shards:     sr.Shards,
+		DataShards: uint16(sr.DataShards),
 	}
 }
 
@@ -119,6 +151,7 @@ func Verify(r Receipt) error {
 
 // digest computes a canonical domain-separated hash of the receipt fields.
 // Layout: H( DomainTag || v || L(path)||path || seq || size || deadline || recv || commit || nonce )
+// v2 appends: || shards (u64) || data_shards (u16)
 func digest(r Receipt) [32]byte {
 	h := sha256.New()
 
@@ -155,6 +188,13 @@ func digest(r Receipt) [32]byte {
 	putU64(b8[:], r.Nonce)
 	h.Write(b8[:])
 
+	if r.Version >= 2 {
+		putU64(b8[:], r.Shards)
+		h.Write(b8[:])
+		binary.BigEndian.PutUint16(lb[:], r.DataShards)
+		h.Write(lb[:])
+	}
+
 	var out [32]byte
 	copy(out[:], h.Sum(nil))
 	return out
@@ -243,17 +283,13 @@ func signAndReturn(s *SessionSigner, r Receipt) (Receipt, error) {
 }
 
 // AggregateAnchor creates a deterministic "anchor" over a set of receipts.
-// Sorts by (Path, Seq) to avoid order ambiguity, then returns hex(root).
+// Sorts with SortReceipts, the order batches use, then returns hex(root); a
+// batch's receipts therefore anchor to its header root.
 func AggregateAnchor(rs []Receipt) string {
 	if len(rs) == 0 {
 		return ""
 	}
-	sort.Slice(rs, func(i, j int) bool {
-		if rs[i].Path == rs[j].Path {
-			return rs[i].Seq < rs[j].Seq
-		}
-		return rs[i].Path < rs[j].Path
-	})
+	SortReceipts(rs)
 	root := MerkleRoot(rs)
 	return hex.EncodeToString(root[:])
 }
@@ -274,6 +310,12 @@ func Pump(ctx context.Context, s *SessionSigner, in <-chan service.SegmentReceip
 			if err := s.Sign(&r); err != nil {
 				return err
 			}
+			events.Publish(events.ReceiptSigned, r.Path, map[string]interface{}{
+				"seq":       r.Seq,
+				"size":      r.Size,
+				"commit":    hex.EncodeToString(r.Commit[:]),
+				"delivered": r.Delivered(),
+			})
 			out <- r
 		}
 	}
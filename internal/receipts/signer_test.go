@@ -0,0 +1,56 @@
+package receipts
+
+import (
+	"testing"
+	"time"
+
+	"slowdrip-miner/internal/service"
+)
+
+func TestFECReceipts(t *testing.T) {
+	ss, err := NewSessionSigner("test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ss.Close()
+	now := time.Now()
+	tests := []struct {
+		name       string
+		shards     uint64
+		dataShards int
+		version    uint8
+		delivered  float64
+	}{
+		{name: "no fec", version: 1, delivered: 1},
+		{name: "every shard", shards: 0x3f, dataShards: 4, version: 2, delivered: 1},
+		{name: "exactly data shards", shards: 0x33, dataShards: 4, version: 2, delivered: 1},
+		{name: "half the data shards", shards: 0x21, dataShards: 4, version: 2, delivered: 0.5},
+		{name: "none delivered", dataShards: 4, version: 2, delivered: 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sr := service.SegmentReceipt{Path: "live", Seq: 7, Size: 1000, Deadline: now, Recv: now, Shards: tt.shards, DataShards: tt.dataShards}
+			r, err := BuildAndSign(ss, sr, 1)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if r.Version != tt.version {
+				t.Fatalf("version %d, want %d", r.Version, tt.version)
+			}
+			if got := r.Delivered(); got != tt.delivered {
+				t.Fatalf("delivered %v, want %v", got, tt.delivered)
+			}
+			if err := Verify(r); err != nil {
+				t.Fatal(err)
+			}
+			if tt.version < 2 {
+				return
+			}
+			// The shard fields are signed from v2 on.
+			r.Shards ^= 1 << 10
+			if err := Verify(r); err == nil {
+				t.Fatal("changed shard mask still verifies")
+			}
+		})
+	}
+}
@@ -0,0 +1,256 @@
+// internal/receipts/store.go
+package receipts
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Store keeps closed batches as one JSON file each ("batch-<id>.json") in a directory.
+// Files are written atomically, so a crash never leaves a half-written batch.
+//
+// Receipts not yet in a batch are appended to a journal ("pending.jsonl") as they
+// arrive, so a restart picks them up again instead of losing the open batch.
+type Store struct {
+	dir string
+	mu  sync.Mutex
+
+	jmu      sync.Mutex
+	journal  *os.File
+	lastSync time.Time
+}
+
+// pendingFile is the journal of receipts waiting for the next batch.
+const pendingFile = "pending.jsonl"
+
+// journalSyncEvery bounds how much of the journal a power loss can take.
+const journalSyncEvery = time.Second
+
+// ErrBatchNotFound is returned by Load/MarkAnchored/MarkClaimed for unknown IDs.
+var ErrBatchNotFound = errors.New("receipts: batch not found")
+
+// OpenStore creates dir if needed.
+func OpenStore(dir string) (*Store, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, fmt.Errorf("receipts: store dir: %w", err)
+	}
+	return &Store{dir: dir}, nil
+}
+
+// Dir returns the store directory.
+func (s *Store) Dir() string { return s.dir }
+
+func (s *Store) path(id uint64) string {
+	return filepath.Join(s.dir, fmt.Sprintf("batch-%020d.json", id))
+}
+
+// Save writes b, replacing any previous file for the same ID.
+func (s *Store) Save(b *Batch) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.saveLocked(b)
+}
+
+func (s *Store) saveLocked(b *Batch) error {
+	blob, err := json.MarshalIndent(b, "", "  ")
+	if err != nil {
+		return err
+	}
+	p := s.path(b.Header.ID)
+	f, err := os.CreateTemp(s.dir, "."+filepath.Base(p)+".tmp-*")
+	if err != nil {
+		return fmt.Errorf("receipts: create temp: %w", err)
+	}
+	tmp := f.Name()
+	defer os.Remove(tmp) // no-op after a successful rename
+	if _, err := f.Write(blob); err != nil {
+		f.Close()
+		return fmt.Errorf("receipts: write batch: %w", err)
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return fmt.Errorf("receipts: sync batch: %w", err)
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, p); err != nil {
+		return fmt.Errorf("receipts: rename batch: %w", err)
+	}
+	return nil
+}
+
+// Load reads one batch.
+func (s *Store) Load(id uint64) (*Batch, error) {
+	blob, err := os.ReadFile(s.path(id))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("%w: %d", ErrBatchNotFound, id)
+	}
+	if err != nil {
+		return nil, err
+	}
+	var b Batch
+	if err := json.Unmarshal(blob, &b); err != nil {
+		return nil, fmt.Errorf("receipts: batch %d: %w", id, err)
+	}
+	return &b, nil
+}
+
+// IDs lists stored batch IDs in ascending order.
+func (s *Store) IDs() ([]uint64, error) {
+	ents, err := os.ReadDir(s.dir)
+	if err != nil {
+		return nil, err
+	}
+	var ids []uint64
+	for _, e := range ents {
+		n := e.Name()
+		if e.IsDir() || !strings.HasPrefix(n, "batch-") || !strings.HasSuffix(n, ".json") {
+			continue
+		}
+		var id uint64
+		if _, err := fmt.Sscanf(strings.TrimSuffix(strings.TrimPrefix(n, "batch-"), ".json"), "%d", &id); err != nil {
+			continue
+		}
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	return ids, nil
+}
+
+// NextID is one past the highest stored ID (1 for an empty store).
+func (s *Store) NextID() (uint64, error) {
+	ids, err := s.IDs()
+	if err != nil {
+		return 0, err
+	}
+	if len(ids) == 0 {
+		return 1, nil
+	}
+	return ids[len(ids)-1] + 1, nil
+}
+
+// Unclaimed returns every batch without a Claim, oldest first.
+func (s *Store) Unclaimed() ([]*Batch, error) {
+	ids, err := s.IDs()
+	if err != nil {
+		return nil, err
+	}
+	var out []*Batch
+	for _, id := range ids {
+		b, err := s.Load(id)
+		if err != nil {
+			return nil, err
+		}
+		if b.Claim == nil {
+			out = append(out, b)
+		}
+	}
+	return out, nil
+}
+
+// MarkAnchored records the confirmed on-chain submission of batch id's root.
+func (s *Store) MarkAnchored(id uint64, tx string, block uint64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	b, err := s.Load(id)
+	if err != nil {
+		return err
+	}
+	b.Anchor = &AnchorInfo{Tx: tx, Block: block, At: time.Now().UnixNano()}
+	return s.saveLocked(b)
+}
+
+// MarkClaimed records a claim for batch id.
+func (s *Store) MarkClaimed(id uint64, by, tx string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	b, err := s.Load(id)
+	if err != nil {
+		return err
+	}
+	b.Claim = &ClaimInfo{Tx: tx, At: time.Now().UnixNano(), By: by}
+	return s.saveLocked(b)
+}
+
+// ------------------------
+// Pending journal
+// ------------------------
+
+// AppendPending journals r until the batch holding it is saved. Writes reach the
+// OS at once (surviving a process crash) and disk at least every journalSyncEvery.
+func (s *Store) AppendPending(r Receipt) error {
+	line, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+	s.jmu.Lock()
+	defer s.jmu.Unlock()
+	if s.journal == nil {
+		f, err := os.OpenFile(filepath.Join(s.dir, pendingFile), os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o600)
+		if err != nil {
+			return fmt.Errorf("receipts: open journal: %w", err)
+		}
+		s.journal = f
+	}
+	if _, err := s.journal.Write(append(line, '\n')); err != nil {
+		return fmt.Errorf("receipts: write journal: %w", err)
+	}
+	if now := time.Now(); now.Sub(s.lastSync) >= journalSyncEvery {
+		s.lastSync = now
+		if err := s.journal.Sync(); err != nil {
+			return fmt.Errorf("receipts: sync journal: %w", err)
+		}
+	}
+	return nil
+}
+
+// LoadPending returns the journalled receipts. A torn last line (crash mid-write)
+// is skipped; any other undecodable line is an error.
+func (s *Store) LoadPending() ([]Receipt, error) {
+	blob, err := os.ReadFile(filepath.Join(s.dir, pendingFile))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	lines := strings.Split(string(blob), "\n")
+	var out []Receipt
+	for i, l := range lines {
+		if l == "" {
+			continue
+		}
+		var r Receipt
+		if err := json.Unmarshal([]byte(l), &r); err != nil {
+			if i == len(lines)-1 {
+				break // no trailing newline: the write was cut short
+			}
+			return nil, fmt.Errorf("receipts: journal line %d: %w", i+1, err)
+		}
+		out = append(out, r)
+	}
+	return out, nil
+}
+
+// ClearPending empties the journal once its receipts are saved in a batch.
+func (s *Store) ClearPending() error {
+	s.jmu.Lock()
+	defer s.jmu.Unlock()
+	if s.journal != nil {
+		s.journal.Close()
+		s.journal = nil
+	}
+	err := os.Remove(filepath.Join(s.dir, pendingFile))
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("receipts: clear journal: %w", err)
+	}
+	return nil
+}
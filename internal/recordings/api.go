@@ -0,0 +1,128 @@
+// internal/recordings/api.go
+package recordings
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"sort"
+	"time"
+
+	"slowdrip-miner/internal/mediamtx"
+)
+
+// API routes. ListPath is read-only; RecordPath switches recording and needs
+// the operator role.
+const (
+	ListPath   = "/v1/recordings"        // GET [?path=live/a]
+	RecordPath = "/v1/recordings/record" // POST {"path": "live/a", "record": true}
+)
+
+// PathSummary is one recorded path in the listing.
+type PathSummary struct {
+	Path      string    `json:"path"`
+	Segments  int       `json:"segments"`
+	Bytes     int64     `json:"bytes"`
+	First     time.Time `json:"first"`     // start of the oldest segment
+	Last      time.Time `json:"last"`      // start of the newest segment
+	Recording bool      `json:"recording"` // a segment is still being written
+}
+
+// Paths summarises the index by path, sorted by path.
+func (m *Manager) Paths() []PathSummary {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	by := map[string]*PathSummary{}
+	for _, s := range m.index {
+		p := by[s.Path]
+		if p == nil {
+			p = &PathSummary{Path: s.Path, First: s.Start, Last: s.Start}
+			by[s.Path] = p
+		}
+		p.Segments++
+		p.Bytes += s.Size
+		if s.Start.Before(p.First) {
+			p.First = s.Start
+		}
+		if s.Start.After(p.Last) {
+			p.Last = s.Start
+		}
+		if !s.Complete {
+			p.Recording = true
+		}
+	}
+	out := make([]PathSummary, 0, len(by))
+	for _, p := range by {
+		out = append(out, *p)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Path < out[j].Path })
+	return out
+}
+
+// Segments returns the segments of one path, oldest first.
+func (m *Manager) Segments(path string) []Segment {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	out := []Segment{}
+	for _, s := range m.index {
+		if s.Path == path {
+			out = append(out, *s)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Start.Before(out[j].Start) })
+	return out
+}
+
+// ServeHTTP serves ListPath: the per-path summary, or with ?path= that path's
+// segments and their hashes.
+func (m *Manager) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if p := r.URL.Query().Get("path"); p != "" {
+		writeJSON(w, http.StatusOK, map[string]interface{}{"path": p, "segments": m.Segments(p)})
+		return
+	}
+	st := m.Status()
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"dir":      st.Dir,
+		"segments": st.Segments,
+		"bytes":    st.Bytes,
+		"paths":    m.Paths(),
+	})
+}
+
+// RecordHandler serves RecordPath.
+func (m *Manager) RecordHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		var req struct {
+			Path   string `json:"path"`
+			Record *bool  `json:"record"`
+		}
+		if err := json.NewDecoder(io.LimitReader(r.Body, 4<<10)).Decode(&req); err != nil || req.Path == "" || req.Record == nil {
+			http.Error(w, `want {"path": "...", "record": true|false}`, http.StatusBadRequest)
+			return
+		}
+		pinned, err := m.SetRecord(r.Context(), req.Path, *req.Record)
+		switch {
+		case errors.Is(err, mediamtx.ErrPathNotFound):
+			http.Error(w, err.Error(), http.StatusNotFound)
+		case err != nil:
+			http.Error(w, err.Error(), http.StatusBadGateway)
+		default:
+			writeJSON(w, http.StatusOK, map[string]interface{}{"path": req.Path, "record": *req.Record, "pinned": pinned})
+		}
+	})
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,172 @@
+// internal/recordings/index.go
+package recordings
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// IndexVersion is the format of the index file.
+const IndexVersion = 1
+
+// startLayout is how MediaMTX's "%Y-%m-%d_%H-%M-%S-%f" names a segment.
+const startLayout = "2006-01-02_15-04-05-000000"
+
+// settle is how long a segment must stay unchanged to count as complete when
+// no newer segment of its path exists yet (MediaMTX flushes parts every second).
+const settle = 30 * time.Second
+
+// Segment is one recorded file.
+type Segment struct {
+	Path     string    `json:"path"` // MediaMTX path, e.g. live/a
+	File     string    `json:"file"` // relative to the recordings dir, "/"-separated
+	Start    time.Time `json:"start"`
+	Size     int64     `json:"size"`
+	Modified time.Time `json:"modified"`
+	Complete bool      `json:"complete"`          // MediaMTX has moved on; only complete segments are hashed or deleted
+	SHA256   string    `json:"sha256,omitempty"`  // hex, set once complete
+	Hashed   time.Time `json:"hashed,omitempty"`  // last hashed (or re-attested)
+	Corrupt  bool      `json:"corrupt,omitempty"` // bytes no longer match SHA256; never attested again
+}
+
+type indexFile struct {
+	Version  int       `json:"version"`
+	Segments []Segment `json:"segments"`
+}
+
+// loadIndex reads the index file; a missing file is an empty index.
+func loadIndex(path string) (map[string]*Segment, error) {
+	idx := map[string]*Segment{}
+	if path == "" {
+		return idx, nil
+	}
+	b, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return idx, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("recordings: index: %w", err)
+	}
+	var f indexFile
+	if err := json.Unmarshal(b, &f); err != nil {
+		return nil, fmt.Errorf("recordings: index %s: %w", path, err)
+	}
+	if f.Version != IndexVersion {
+		return nil, fmt.Errorf("recordings: index %s: unsupported version %d", path, f.Version)
+	}
+	for i := range f.Segments {
+		s := f.Segments[i]
+		idx[s.File] = &s
+	}
+	return idx, nil
+}
+
+// saveIndex writes the index atomically: a synced 0600 temp file renamed over
+// the old one.
+func saveIndex(path string, idx map[string]*Segment) error {
+	f := indexFile{Version: IndexVersion, Segments: make([]Segment, 0, len(idx))}
+	for _, s := range idx {
+		f.Segments = append(f.Segments, *s)
+	}
+	sort.Slice(f.Segments, func(i, j int) bool { return f.Segments[i].File < f.Segments[j].File })
+	b, err := json.MarshalIndent(f, "", "  ")
+	if err != nil {
+		return err
+	}
+	tf, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return fmt.Errorf("recordings: index: %w", err)
+	}
+	tmp := tf.Name()
+	defer os.Remove(tmp) // no-op after a successful rename
+	if _, err := tf.Write(b); err != nil {
+		tf.Close()
+		return fmt.Errorf("recordings: write index: %w", err)
+	}
+	if err := tf.Sync(); err != nil {
+		tf.Close()
+		return fmt.Errorf("recordings: sync index: %w", err)
+	}
+	if err := tf.Close(); err != nil {
+		return fmt.Errorf("recordings: index: %w", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return fmt.Errorf("recordings: rename index: %w", err)
+	}
+	return nil
+}
+
+// walk lists the recorded files under dir: <path>/<start>.<mp4|ts>, as laid
+// out by the recordPath the miner sets.
+func walk(dir string) (map[string]Segment, error) {
+	found := map[string]Segment{}
+	err := filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if p == dir {
+				return err
+			}
+			return nil // a directory removed mid-walk
+		}
+		name := d.Name()
+		if strings.HasPrefix(name, ".") && p != dir {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		ext := filepath.Ext(name)
+		if d.IsDir() || (ext != ".mp4" && ext != ".ts") {
+			return nil
+		}
+		rel, err := filepath.Rel(dir, p)
+		if err != nil {
+			return nil
+		}
+		rel = filepath.ToSlash(rel)
+		path := filepath.ToSlash(filepath.Dir(filepath.FromSlash(rel)))
+		if path == "." {
+			return nil // not under a path directory
+		}
+		info, err := d.Info()
+		if err != nil {
+			return nil
+		}
+		start, err := time.ParseInLocation(startLayout, strings.TrimSuffix(name, ext), time.Local)
+		if err != nil {
+			start = info.ModTime() // named by a different recordPath
+		}
+		found[rel] = Segment{
+			Path:     path,
+			File:     rel,
+			Start:    start.UTC(),
+			Size:     info.Size(),
+			Modified: info.ModTime().UTC(),
+		}
+		return nil
+	})
+	return found, err
+}
+
+// hashFile returns the hex SHA-256 of a file.
+func hashFile(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
@@ -0,0 +1,411 @@
+// internal/recordings/manager.go
+package recordings
+
+import (
+	"context"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"slowdrip-miner/internal/events"
+	"slowdrip-miner/internal/mediamtx"
+	"slowdrip-miner/internal/service"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/rs/zerolog"
+)
+
+// Reasons a segment is deleted.
+const (
+	ReasonAge  = "age"  // older than Options.MaxAge
+	ReasonSize = "size" // the oldest while the total exceeds Options.MaxBytes
+)
+
+// ReceiptPrefix starts the receipt path of a recording ("rec:live/a"), so
+// stored-content receipts never mix with live ones of the same path.
+const ReceiptPrefix = "rec:"
+
+// ReattestPrefix starts the receipt path of a re-attestation
+// ("reattest:live/a"). Its Seq is the attestation time in Unix milliseconds,
+// unique per manager, so a re-hash never repeats the Path and Seq of the
+// segment's first receipt or of an earlier round.
+const ReattestPrefix = "reattest:"
+
+var (
+	bytesGauge = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "miner_recordings_bytes",
+		Help: "Bytes of recorded segments on disk.",
+	})
+	segmentsGauge = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "miner_recordings_segments",
+		Help: "Recorded segments on disk.",
+	})
+	deletedTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "miner_recordings_deleted_total",
+		Help: "Recorded segments deleted by retention, by reason.",
+	}, []string{"reason"})
+	hashedTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "miner_recordings_hashed_total",
+		Help: "Recorded segments hashed into receipts, by result (ok, mismatch, error).",
+	}, []string{"result"})
+)
+
+// Options configures a Manager.
+type Options struct {
+	Dir          string        // recordings root as the miner sees it
+	IndexFile    string        // "" = index in memory only
+	MaxAge       time.Duration // 0 = keep
+	MaxBytes     int64         // 0 = unlimited
+	ScanInterval time.Duration
+	AttestEvery  time.Duration // 0 = hash each segment once
+}
+
+// Recorder keeps a runtime record switch across reconciles; *mediamtx.PathManager is one.
+type Recorder interface {
+	SetRecord(name string, on bool) bool
+}
+
+// Manager indexes what MediaMTX records under Options.Dir. Every finished
+// segment is hashed once into a service.SegmentReceipt (Commit = SHA-256 of the
+// file, Seq = its start in Unix milliseconds) and, with AttestEvery, re-hashed
+// into ReattestPrefix receipts while it is kept. Retention deletes the oldest
+// segments by age and size.
+type Manager struct {
+	opts   Options
+	client *mediamtx.Client
+	pins   Recorder // may be nil
+	emit   func(service.SegmentReceipt)
+	bus    *events.Bus
+	log    zerolog.Logger
+
+	mu       sync.Mutex
+	index    map[string]*Segment // by File
+	status   Status
+	attested uint64 // Seq of the last re-attestation
+}
+
+// Status is the manager's view for /v1/status.
+type Status struct {
+	Dir      string         `json:"dir"`
+	Segments int            `json:"segments"`
+	Bytes    int64          `json:"bytes"`
+	MaxBytes int64          `json:"max_bytes,omitempty"`
+	MaxAge   string         `json:"max_age,omitempty"`
+	LastScan time.Time      `json:"last_scan,omitempty"`
+	Hashed   int64          `json:"hashed"`
+	Corrupt  int64          `json:"corrupt"`
+	Deleted  map[string]int `json:"deleted"` // by reason
+	Error    string         `json:"error,omitempty"`
+}
+
+// New loads the index and creates a manager. pins may be nil (no PathManager);
+// bus may be nil to use events.Default.
+func New(o Options, c *mediamtx.Client, pins Recorder, emit func(service.SegmentReceipt), bus *events.Bus, log zerolog.Logger) (*Manager, error) {
+	if bus == nil {
+		bus = events.Default
+	}
+	idx, err := loadIndex(o.IndexFile)
+	if err != nil {
+		return nil, err
+	}
+	st := Status{Dir: o.Dir, MaxBytes: o.MaxBytes, Deleted: map[string]int{}}
+	if o.MaxAge > 0 {
+		st.MaxAge = o.MaxAge.String()
+	}
+	return &Manager{
+		opts:   o,
+		client: c,
+		pins:   pins,
+		emit:   emit,
+		bus:    bus,
+		log:    log.With().Str("module", "recordings").Logger(),
+		index:  idx,
+		status: st,
+	}, nil
+}
+
+// Run scans every Options.ScanInterval until ctx is done.
+func (m *Manager) Run(ctx context.Context) {
+	m.log.Info().Str("dir", m.opts.Dir).Int("indexed", len(m.index)).Msg("recordings: started")
+	t := time.NewTicker(m.opts.ScanInterval)
+	defer t.Stop()
+	for {
+		m.scan(ctx, time.Now())
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+		}
+	}
+}
+
+// Status returns a copy of the current status.
+func (m *Manager) Status() Status {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	st := m.status
+	st.Deleted = make(map[string]int, len(m.status.Deleted))
+	for k, v := range m.status.Deleted {
+		st.Deleted[k] = v
+	}
+	return st
+}
+
+// SetRecord switches recording of a configured path (a name or "~regex" rule)
+// through the MediaMTX config API. pinned reports whether the PathManager
+// keeps the switch; otherwise a reconcile or restart may undo it.
+func (m *Manager) SetRecord(ctx context.Context, name string, on bool) (pinned bool, err error) {
+	if _, err := m.client.PathConfig(ctx, name); err != nil {
+		return false, err
+	}
+	if err := m.client.PatchPathConfig(ctx, name, map[string]bool{"record": on}); err != nil {
+		return false, err
+	}
+	if m.pins != nil {
+		pinned = m.pins.SetRecord(name, on)
+	}
+	m.log.Info().Str("audit", "recording_switched").Str("path", name).Bool("record", on).Bool("pinned", pinned).Msg("recordings: record switched")
+	return pinned, nil
+}
+
+// scan refreshes the index from disk, hashes finished segments and applies
+// retention.
+func (m *Manager) scan(ctx context.Context, now time.Time) {
+	found, err := walk(m.opts.Dir)
+	if err != nil {
+		m.log.Warn().Err(err).Msg("recordings: scan failed")
+		m.mu.Lock()
+		m.status.Error, m.status.LastScan = err.Error(), now.UTC()
+		m.mu.Unlock()
+		return
+	}
+
+	m.mu.Lock()
+	for file := range m.index {
+		if _, ok := found[file]; !ok {
+			delete(m.index, file) // removed behind our back
+		}
+	}
+	for file, f := range found {
+		s, ok := m.index[file]
+		if ok && s.Size == f.Size && s.Modified.Equal(f.Modified) {
+			continue
+		}
+		f := f
+		if ok && s.SHA256 != "" {
+			// A finished segment was rewritten: keep the hash it was attested with.
+			if !s.Corrupt {
+				m.corruptLocked(s, "changed on disk after it was hashed")
+			}
+			f.SHA256, f.Hashed, f.Corrupt = s.SHA256, s.Hashed, true
+		}
+		m.index[file] = &f
+	}
+	markComplete(m.index, now)
+
+	var todo []Segment
+	for _, s := range m.index {
+		if !s.Complete || s.Corrupt {
+			continue
+		}
+		if s.SHA256 == "" || (m.opts.AttestEvery > 0 && now.Sub(s.Hashed) >= m.opts.AttestEvery) {
+			todo = append(todo, *s)
+		}
+	}
+	m.mu.Unlock()
+
+	sort.Slice(todo, func(i, j int) bool { return todo[i].Start.Before(todo[j].Start) })
+	for _, s := range todo {
+		if ctx.Err() != nil {
+			return
+		}
+		m.hash(s)
+	}
+
+	m.retain(time.Now())
+
+	m.mu.Lock()
+	var total int64
+	for _, s := range m.index {
+		total += s.Size
+	}
+	n := len(m.index)
+	m.status.Segments, m.status.Bytes = n, total
+	m.status.LastScan, m.status.Error = now.UTC(), ""
+	if m.opts.IndexFile != "" {
+		if err := saveIndex(m.opts.IndexFile, m.index); err != nil {
+			m.status.Error = err.Error()
+			m.log.Warn().Err(err).Msg("recordings: save index failed")
+		}
+	}
+	m.mu.Unlock()
+	bytesGauge.Set(float64(total))
+	segmentsGauge.Set(float64(n))
+}
+
+// markComplete flags segments MediaMTX is done with: a newer segment of the
+// same path exists, or the file has not changed for settle.
+func markComplete(idx map[string]*Segment, now time.Time) {
+	newest := map[string]time.Time{}
+	for _, s := range idx {
+		if s.Start.After(newest[s.Path]) {
+			newest[s.Path] = s.Start
+		}
+	}
+	for _, s := range idx {
+		s.Complete = s.Start.Before(newest[s.Path]) || now.Sub(s.Modified) >= settle
+	}
+}
+
+// hash hashes one complete segment and emits its receipt. A re-hash that no
+// longer matches is reported instead of attested.
+func (m *Manager) hash(s Segment) {
+	sum, err := hashFile(filepath.Join(m.opts.Dir, filepath.FromSlash(s.File)))
+	now := time.Now()
+	if err != nil {
+		hashedTotal.WithLabelValues("error").Inc()
+		m.log.Warn().Err(err).Str("file", s.File).Msg("recordings: hash failed")
+		return
+	}
+
+	m.mu.Lock()
+	cur, ok := m.index[s.File]
+	if !ok || cur.Size != s.Size || !cur.Modified.Equal(s.Modified) {
+		m.mu.Unlock()
+		return // changed while hashing; the next scan picks it up
+	}
+	if cur.SHA256 != "" && cur.SHA256 != sum {
+		m.corruptLocked(cur, "re-hash does not match the index")
+		cur.Corrupt = true
+		m.mu.Unlock()
+		return
+	}
+	first := cur.SHA256 == ""
+	cur.SHA256, cur.Hashed = sum, now.UTC()
+	m.status.Hashed++
+	path, seq := ReceiptPrefix+s.Path, uint64(s.Start.UnixMilli())
+	if !first {
+		m.attested++
+		if ms := uint64(now.UnixMilli()); ms > m.attested {
+			m.attested = ms
+		}
+		path, seq = ReattestPrefix+s.Path, m.attested
+	}
+	m.mu.Unlock()
+
+	hashedTotal.WithLabelValues("ok").Inc()
+	var commit [32]byte
+	b, _ := hex.DecodeString(sum)
+	copy(commit[:], b)
+	m.emit(service.SegmentReceipt{
+		Path:     path,
+		Seq:      seq,
+		Size:     s.Size,
+		Deadline: now,
+		Recv:     now,
+		Commit:   commit,
+	})
+	if first {
+		m.bus.Publish(events.RecordingSegment, s.Path, map[string]interface{}{
+			"file":   s.File,
+			"start":  s.Start,
+			"size":   s.Size,
+			"sha256": sum,
+		})
+	}
+	m.log.Debug().Str("file", s.File).Int64("size", s.Size).Str("sha256", sum).Bool("first", first).Msg("recordings: segment hashed")
+}
+
+func (m *Manager) corruptLocked(s *Segment, why string) {
+	m.status.Corrupt++
+	hashedTotal.WithLabelValues("mismatch").Inc()
+	m.log.Error().Str("file", s.File).Str("sha256", s.SHA256).Msg("recordings: " + why)
+	m.bus.Publish(events.RecordingCorrupt, s.Path, map[string]interface{}{
+		"file":   s.File,
+		"sha256": s.SHA256,
+		"detail": why,
+	})
+}
+
+// retain deletes complete segments older than MaxAge, then the oldest ones
+// while the total is above MaxBytes. Segments still being written are kept.
+func (m *Manager) retain(now time.Time) {
+	if m.opts.MaxAge <= 0 && m.opts.MaxBytes <= 0 {
+		return
+	}
+	m.mu.Lock()
+	var (
+		total int64
+		done  []*Segment
+	)
+	for _, s := range m.index {
+		total += s.Size
+		if s.Complete {
+			done = append(done, s)
+		}
+	}
+	sort.Slice(done, func(i, j int) bool { return done[i].Start.Before(done[j].Start) })
+	type victim struct {
+		s      Segment
+		reason string
+	}
+	var victims []victim
+	for _, s := range done {
+		switch {
+		case m.opts.MaxAge > 0 && now.Sub(s.Modified) > m.opts.MaxAge:
+			victims = append(victims, victim{*s, ReasonAge})
+		case m.opts.MaxBytes > 0 && total > m.opts.MaxBytes:
+			victims = append(victims, victim{*s, ReasonSize})
+		default:
+			continue
+		}
+		total -= s.Size
+	}
+	m.mu.Unlock()
+
+	for _, v := range victims {
+		full := filepath.Join(m.opts.Dir, filepath.FromSlash(v.s.File))
+		if err := os.Remove(full); err != nil && !os.IsNotExist(err) {
+			m.log.Warn().Err(err).Str("file", v.s.File).Msg("recordings: delete failed")
+			continue
+		}
+		removeEmptyDirs(m.opts.Dir, filepath.Dir(full))
+
+		m.mu.Lock()
+		delete(m.index, v.s.File)
+		m.status.Deleted[v.reason]++
+		m.mu.Unlock()
+
+		deletedTotal.WithLabelValues(v.reason).Inc()
+		m.log.Info().
+			Str("audit", "recording_deleted").
+			Str("reason", v.reason).
+			Str("path", v.s.Path).
+			Str("file", v.s.File).
+			Int64("size", v.s.Size).
+			Str("sha256", v.s.SHA256).
+			Msg(fmt.Sprintf("recordings: deleted (%s)", v.reason))
+		m.bus.Publish(events.RecordingDeleted, v.s.Path, map[string]interface{}{
+			"file":   v.s.File,
+			"reason": v.reason,
+			"size":   v.s.Size,
+			"sha256": v.s.SHA256,
+		})
+	}
+}
+
+// removeEmptyDirs removes dir and its parents up to (not including) root while
+// they are empty.
+func removeEmptyDirs(root, dir string) {
+	root = filepath.Clean(root)
+	for dir = filepath.Clean(dir); dir != root && len(dir) > len(root); dir = filepath.Dir(dir) {
+		if os.Remove(dir) != nil {
+			return // not empty (or gone)
+		}
+	}
+}
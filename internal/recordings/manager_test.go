@@ -0,0 +1,226 @@
+package recordings
+
+import (
+	"context"
+	"crypto/sha256"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"slowdrip-miner/internal/events"
+	"slowdrip-miner/internal/service"
+
+	"github.com/rs/zerolog"
+)
+
+// recording is a file under the recordings dir, last written age ago.
+type recording struct {
+	file string
+	data string
+	age  time.Duration
+}
+
+func write(t *testing.T, dir string, r recording) {
+	t.Helper()
+	full := filepath.Join(dir, filepath.FromSlash(r.file))
+	if err := os.MkdirAll(filepath.Dir(full), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(full, []byte(r.data), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	mt := time.Now().Add(-r.age)
+	if err := os.Chtimes(full, mt, mt); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func newManager(t *testing.T, o Options, files ...recording) (*Manager, *[]service.SegmentReceipt) {
+	t.Helper()
+	o.Dir = t.TempDir()
+	for _, r := range files {
+		write(t, o.Dir, r)
+	}
+	var got []service.SegmentReceipt
+	m, err := New(o, nil, nil, func(r service.SegmentReceipt) { got = append(got, r) }, events.NewBus(16), zerolog.Nop())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return m, &got
+}
+
+func TestScan(t *testing.T) {
+	const (
+		seg1 = "live/a/2024-05-01_10-00-00-000000.mp4"
+		seg2 = "live/a/2024-05-01_10-01-00-000000.mp4"
+		solo = "cam/2024-05-01_09-00-00-000000.ts"
+	)
+	tests := []struct {
+		name   string
+		files  []recording
+		hashed []string // files with a receipt, in start order
+	}{
+		{name: "newer segment completes the older one",
+			files:  []recording{{seg1, "one", 0}, {seg2, "two", 0}},
+			hashed: []string{seg1}},
+		{name: "settled segment is complete alone",
+			files:  []recording{{solo, "solo", time.Minute}},
+			hashed: []string{solo}},
+		{name: "segment still being written",
+			files: []recording{{solo, "solo", time.Second}}},
+		{name: "other files and root files are ignored",
+			files: []recording{{"live/a/notes.txt", "x", time.Hour}, {"2024-05-01_09-00-00-000000.mp4", "x", time.Hour}, {"live/.hidden/x.mp4", "x", time.Hour}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m, got := newManager(t, Options{}, tt.files...)
+			m.scan(context.Background(), time.Now())
+			if len(*got) != len(tt.hashed) {
+				t.Fatalf("%d receipts, want %d: %+v", len(*got), len(tt.hashed), *got)
+			}
+			data := map[string]string{}
+			for _, f := range tt.files {
+				data[f.file] = f.data
+			}
+			for i, file := range tt.hashed {
+				r := (*got)[i]
+				start, _ := time.ParseInLocation(startLayout, strings.TrimSuffix(filepath.Base(file), filepath.Ext(file)), time.Local)
+				if r.Path != ReceiptPrefix+filepath.ToSlash(filepath.Dir(file)) || r.Seq != uint64(start.UnixMilli()) || r.Commit != sha256.Sum256([]byte(data[file])) {
+					t.Errorf("receipt %d: %+v", i, r)
+				}
+			}
+			if st := m.Status(); st.Segments != len(m.index) || st.Hashed != int64(len(tt.hashed)) {
+				t.Errorf("status %+v", st)
+			}
+		})
+	}
+}
+
+func TestReattest(t *testing.T) {
+	m, got := newManager(t, Options{AttestEvery: time.Hour}, recording{"live/2024-05-01_10-00-00-000000.mp4", "kept", time.Minute})
+	ctx := context.Background()
+	now := time.Now()
+	m.scan(ctx, now)
+	m.scan(ctx, now) // not due yet
+	for i := 1; i <= 2; i++ {
+		m.scan(ctx, now.Add(time.Duration(i)*2*time.Hour))
+	}
+	if len(*got) != 3 {
+		t.Fatalf("%d receipts, want 3", len(*got))
+	}
+	first := (*got)[0]
+	if first.Path != "rec:live" {
+		t.Fatalf("first receipt path %q", first.Path)
+	}
+	for i, r := range (*got)[1:] {
+		prev := (*got)[i]
+		if r.Path != "reattest:live" || r.Commit != first.Commit {
+			t.Fatalf("re-attestation %d: %+v", i+1, r)
+		}
+		if prev.Path == r.Path && r.Seq <= prev.Seq {
+			t.Fatalf("re-attestation %d: seq %d after %d", i+1, r.Seq, prev.Seq)
+		}
+	}
+}
+
+func TestCorrupt(t *testing.T) {
+	file := "live/2024-05-01_10-00-00-000000.mp4"
+	m, got := newManager(t, Options{AttestEvery: time.Hour}, recording{file, "original", time.Minute})
+	ctx := context.Background()
+	m.scan(ctx, time.Now())
+	write(t, m.opts.Dir, recording{file, "tampered", time.Minute})
+	m.scan(ctx, time.Now().Add(2*time.Hour))
+	if len(*got) != 1 {
+		t.Fatalf("%d receipts, want only the first", len(*got))
+	}
+	s := m.index[file]
+	if !s.Corrupt || s.SHA256 == "" {
+		t.Fatalf("segment %+v, want corrupt with the original hash", s)
+	}
+	if st := m.Status(); st.Corrupt != 1 {
+		t.Fatalf("status %+v", st)
+	}
+}
+
+func TestRetain(t *testing.T) {
+	files := []recording{
+		{"live/2024-05-01_10-00-00-000000.mp4", strings.Repeat("a", 100), 3 * time.Hour},
+		{"live/2024-05-01_11-00-00-000000.mp4", strings.Repeat("b", 100), 2 * time.Hour},
+		{"live/2024-05-01_12-00-00-000000.mp4", strings.Repeat("c", 100), time.Hour},
+		{"live/2024-05-01_13-00-00-000000.mp4", strings.Repeat("d", 100), time.Second}, // still being written
+	}
+	tests := []struct {
+		name    string
+		opts    Options
+		left    []string // start hours of the segments kept
+		deleted map[string]int
+	}{
+		{name: "no limits", left: []string{"10", "11", "12", "13"}, deleted: map[string]int{}},
+		{name: "by age", opts: Options{MaxAge: 90 * time.Minute},
+			left: []string{"12", "13"}, deleted: map[string]int{ReasonAge: 2}},
+		{name: "by size, oldest first", opts: Options{MaxBytes: 250},
+			left: []string{"12", "13"}, deleted: map[string]int{ReasonSize: 2}},
+		{name: "the open segment is never deleted", opts: Options{MaxBytes: 50},
+			left: []string{"13"}, deleted: map[string]int{ReasonSize: 3}},
+		{name: "age then size", opts: Options{MaxAge: 150 * time.Minute, MaxBytes: 250},
+			left: []string{"12", "13"}, deleted: map[string]int{ReasonAge: 1, ReasonSize: 1}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m, _ := newManager(t, tt.opts, files...)
+			m.scan(context.Background(), time.Now())
+			var left []string
+			for file := range m.index {
+				left = append(left, strings.SplitN(filepath.Base(file), "_", 2)[1][:2])
+			}
+			sort.Strings(left)
+			if strings.Join(left, ",") != strings.Join(tt.left, ",") {
+				t.Fatalf("kept %v, want %v", left, tt.left)
+			}
+			st := m.Status()
+			for reason, n := range tt.deleted {
+				if st.Deleted[reason] != n {
+					t.Errorf("deleted %v, want %v", st.Deleted, tt.deleted)
+				}
+			}
+			entries, _ := os.ReadDir(filepath.Join(m.opts.Dir, "live"))
+			if len(entries) != len(tt.left) {
+				t.Errorf("%d files on disk, want %d", len(entries), len(tt.left))
+			}
+		})
+	}
+}
+
+func TestSaveIndex(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "index.json")
+	idx := map[string]*Segment{
+		"live/b.mp4": {Path: "live", File: "live/b.mp4", Size: 2, Complete: true, SHA256: "bb"},
+		"live/a.mp4": {Path: "live", File: "live/a.mp4", Size: 1},
+	}
+	for i := 0; i < 2; i++ { // the second save replaces the first
+		if err := saveIndex(path, idx); err != nil {
+			t.Fatal(err)
+		}
+	}
+	fi, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if mode := fi.Mode().Perm(); mode != 0o600 {
+		t.Fatalf("mode %v, want 0600", mode)
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 1 {
+		t.Fatalf("%d files in the index dir, want only the index", len(entries))
+	}
+	back, err := loadIndex(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(back) != 2 || back["live/b.mp4"].SHA256 != "bb" || back["live/a.mp4"].Size != 1 {
+		t.Fatalf("reloaded %+v", back)
+	}
+}
@@ -9,9 +9,18 @@ import (
 	"sync"
 	"time"
 
+	"slowdrip-miner/internal/events"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
 	"github.com/rs/zerolog"
 )
 
+var tapDropped = promauto.NewCounter(prometheus.CounterOpts{
+	Name: "miner_service_tap_dropped_total",
+	Help: "On-time receipts not forwarded for signing because the tap channel was full.",
+})
+
 // SegmentReceipt is a minimal placeholder for a per-segment "useful work" unit.
 // In the real PoS pipeline, the client (viewer) signs acceptance over something like:
 // (seg_id, size, deadline, recv_time, seg_commit). Here we just model it.
@@ -21,8 +30,13 @@ type SegmentReceipt struct {
 	Size     int64         // bytes delivered for this segment
 	Deadline time.Time     // delivery deadline
 	Recv     time.Time     // when we actually delivered
-	Commit   [32]byte      // integrity commit placeholder (e.g., H(payload or FEC))
+	Commit   [32]byte      // integrity commit, e.g. SHA-256 of the HLS segment (internal/hls)
 	Meta     time.Duration // optional extra: observed jitter or render margin
+
+	// With FEC (internal/fec), Commit covers the segment's shards and these say
+	// which of them were delivered; any DataShards of them rebuild the segment.
+	Shards     uint64 // bit i set: shard i delivered
+	DataShards int    // 0 = no FEC, the whole segment
 }
 
 // streamStats aggregates basic QoS stats per path.
@@ -30,11 +44,13 @@ type streamStats struct {
 	Accepted int64  // on-time segments
 	Late     int64  // late segments
 	Bytes    int64  // accepted bytes (on-time only)
+	Dropped  int64  // on-time receipts the tap had no room for (never signed)
 	LastSeq  uint64 // highest seen seq (for sanity/logging)
 	// Rolling hash of accepted receipts as a cheap "root" placeholder
 	rolling [32]byte
 	// Buffer some recent accepted receipts to seed future Merkle construction if needed
 	recentCommits [][32]byte
+	droppedWindow int64 // drops since the last flush
 }
 
 // Agent is the PoS stub that collects receipts and periodically logs aggregates.
@@ -52,8 +68,17 @@ type Agent struct {
 
 // defaultAgent provides a simple singleton for early wiring.
 // You can also construct your own via New and pass it around explicitly.
-var defaultAgent *Agent
-var once sync.Once
+var (
+	defaultMu    sync.RWMutex
+	defaultAgent *Agent
+)
+
+// current returns the default agent, or nil before Start.
+func current() *Agent {
+	defaultMu.RLock()
+	defer defaultMu.RUnlock()
+	return defaultAgent
+}
 
 // New creates a Service Agent with sane defaults.
 func New(log zerolog.Logger) *Agent {
@@ -68,21 +93,25 @@ func New(log zerolog.Logger) *Agent {
 // Start runs a default singleton agent with a periodic flush loop.
 // Safe to call in a goroutine: go service.Start(ctx, log)
 func Start(ctx context.Context, log zerolog.Logger) {
-	once.Do(func() {
+	defaultMu.Lock()
+	if defaultAgent == nil {
 		defaultAgent = New(log)
 		defaultAgent.lastFlush = time.Now()
-	})
-	defaultAgent.log.Info().Msg("service agent: started (stub)")
-	t := time.NewTicker(defaultAgent.flushInterval)
+	}
+	a := defaultAgent
+	defaultMu.Unlock()
+
+	a.log.Info().Msg("service agent: started (stub)")
+	t := time.NewTicker(a.flushInterval)
 	defer t.Stop()
 
 	for {
 		select {
 		case <-ctx.Done():
-			defaultAgent.log.Info().Msg("service agent: stopping")
+			a.log.Info().Msg("service agent: stopping")
 			return
 		case <-t.C:
-			defaultAgent.flush(ctx)
+			a.flush(ctx)
 		}
 	}
 }
@@ -90,11 +119,67 @@ func Start(ctx context.Context, log zerolog.Logger) {
 // AddReceipt records a single segment receipt (call from your watcher or player callbacks).
 // It classifies on-time vs late using r.Recv <= r.Deadline.
 func AddReceipt(r SegmentReceipt) {
-	if defaultAgent == nil {
+	a := current()
+	if a == nil {
 		// In early bring-up, Start() may not be running; drop safely.
 		return
 	}
-	defaultAgent.add(r)
+	a.add(r)
+}
+
+var (
+	tapMu sync.RWMutex
+	tap   chan<- SegmentReceipt
+)
+
+// Tap forwards every on-time receipt to ch (e.g. into receipts.Pump for signing
+// and batching). Sends never block the agent: if ch is full the receipt is
+// dropped, counted per path and in miner_service_tap_dropped_total, and logged
+// with the next flush. Pass nil to stop.
+func Tap(ch chan<- SegmentReceipt) {
+	tapMu.Lock()
+	tap = ch
+	tapMu.Unlock()
+}
+
+// PathStats is a read-only view of one path's QoS counters.
+type PathStats struct {
+	Path     string `json:"path"`
+	Accepted int64  `json:"accepted"`
+	Late     int64  `json:"late"`
+	Bytes    int64  `json:"bytes"`
+	Dropped  int64  `json:"dropped,omitempty"`
+	LastSeq  uint64 `json:"last_seq"`
+	Anchor   string `json:"anchor"`
+}
+
+// Snapshot returns current per-path stats of the default agent, sorted by path.
+func Snapshot() []PathStats {
+	a := current()
+	if a == nil {
+		return nil
+	}
+	return a.Snapshot()
+}
+
+// Snapshot returns current per-path stats, sorted by path.
+func (a *Agent) Snapshot() []PathStats {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	out := make([]PathStats, 0, len(a.perPath))
+	for p, st := range a.perPath {
+		out = append(out, PathStats{
+			Path:     p,
+			Accepted: st.Accepted,
+			Late:     st.Late,
+			Bytes:    st.Bytes,
+			Dropped:  st.Dropped,
+			LastSeq:  st.LastSeq,
+			Anchor:   hex.EncodeToString(st.rolling[:]),
+		})
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Path < out[j].Path })
+	return out
 }
 
 // ---- internals ----
@@ -147,11 +232,31 @@ func (a *Agent) add(r SegmentReceipt) {
 		if len(st.recentCommits) > a.maxRecent {
 			st.recentCommits = st.recentCommits[len(st.recentCommits)-a.maxRecent:]
 		}
+		if !forward(r) {
+			st.Dropped++
+			st.droppedWindow++
+		}
 	} else {
 		st.Late++
 	}
 }
 
+// forward hands r to the tap. It reports false if the tap was full.
+func forward(r SegmentReceipt) bool {
+	tapMu.RLock()
+	defer tapMu.RUnlock()
+	if tap == nil {
+		return true
+	}
+	select {
+	case tap <- r:
+		return true
+	default:
+		tapDropped.Inc()
+		return false
+	}
+}
+
 // flush logs a compact snapshot of per-path stats and a global hash "anchor".
 func (a *Agent) flush(ctx context.Context) {
 	a.mu.Lock()
@@ -182,6 +287,22 @@ func (a *Agent) flush(ctx context.Context) {
 			Int64("bytes", st.Bytes).
 			Str("anchor", pathAnchor).
 			Msg("service: qos window")
+		events.Publish(events.ServiceFlush, p, map[string]interface{}{
+			"last_seq": st.LastSeq,
+			"accepted": st.Accepted,
+			"late":     st.Late,
+			"bytes":    st.Bytes,
+			"dropped":  st.Dropped,
+			"anchor":   pathAnchor,
+		})
+		if st.droppedWindow > 0 {
+			a.log.Warn().
+				Str("path", p).
+				Int64("dropped", st.droppedWindow).
+				Int64("dropped_total", st.Dropped).
+				Msg("service: receipt tap full; receipts dropped before signing")
+			st.droppedWindow = 0
+		}
 
 		// Mix into global anchor
 		global.Write([]byte(p))
@@ -0,0 +1,236 @@
+// internal/tlsutil/tlsutil.go
+package tlsutil
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"errors"
+	"fmt"
+	"math/big"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"slowdrip-miner/internal/config"
+
+	"github.com/rs/zerolog"
+)
+
+// ServerConfig builds the admin API's *tls.Config from miner.tls.
+// For file-backed certs it also returns a reloader; run its Watch loop to pick up renewals.
+func ServerConfig(c config.TLS, hosts []string, log zerolog.Logger) (*tls.Config, *CertReloader, error) {
+	minVer, err := ParseVersion(c.MinVersion)
+	if err != nil {
+		return nil, nil, err
+	}
+	tc := &tls.Config{MinVersion: minVer}
+
+	var rl *CertReloader
+	if c.SelfSigned {
+		cert, err := SelfSigned(hosts, 30*24*time.Hour)
+		if err != nil {
+			return nil, nil, err
+		}
+		log.Warn().Strs("hosts", hosts).Msg("tls: using a self-signed certificate (dev only)")
+		tc.Certificates = []tls.Certificate{cert}
+	} else {
+		rl, err = NewCertReloader(c.CertFile, c.KeyFile, log)
+		if err != nil {
+			return nil, nil, err
+		}
+		tc.GetCertificate = rl.GetCertificate
+	}
+
+	if c.ClientCAFile != "" {
+		pem, err := os.ReadFile(c.ClientCAFile)
+		if err != nil {
+			return nil, nil, fmt.Errorf("tls: read client CA: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, nil, fmt.Errorf("tls: no certificates in %s", c.ClientCAFile)
+		}
+		tc.ClientCAs = pool
+		tc.ClientAuth = tls.VerifyClientCertIfGiven
+		if c.RequireClientCert {
+			tc.ClientAuth = tls.RequireAndVerifyClientCert
+		}
+	}
+	return tc, rl, nil
+}
+
+// ParseVersion maps "1.2"/"1.3" to the crypto/tls constant.
+func ParseVersion(s string) (uint16, error) {
+	switch s {
+	case "", "1.2":
+		return tls.VersionTLS12, nil
+	case "1.3":
+		return tls.VersionTLS13, nil
+	}
+	return 0, fmt.Errorf("tls: unsupported min version %q", s)
+}
+
+// ------------------------
+// Cert reloading
+// ------------------------
+
+// CertReloader serves a cert/key pair from disk and reloads it when either file changes.
+type CertReloader struct {
+	certFile, keyFile string
+	log               zerolog.Logger
+
+	mu      sync.RWMutex
+	cert    *tls.Certificate
+	certMod time.Time
+	keyMod  time.Time
+}
+
+// NewCertReloader loads the pair once; a bad pair at startup is an error.
+func NewCertReloader(certFile, keyFile string, log zerolog.Logger) (*CertReloader, error) {
+	r := &CertReloader{certFile: certFile, keyFile: keyFile, log: log.With().Str("module", "tls").Logger()}
+	if _, err := r.reload(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// GetCertificate implements tls.Config.GetCertificate.
+func (r *CertReloader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.cert, nil
+}
+
+// Watch polls the files every interval until ctx is done. A broken pair on disk is
+// logged and the previous certificate keeps serving.
+func (r *CertReloader) Watch(ctx context.Context, interval time.Duration) {
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+			changed, err := r.reload()
+			if err != nil {
+				r.log.Error().Err(err).Msg("tls: reload failed; keeping previous certificate")
+			} else if changed {
+				r.log.Info().Str("cert", r.certFile).Msg("tls: certificate reloaded")
+			}
+		}
+	}
+}
+
+func (r *CertReloader) reload() (bool, error) {
+	cs, err := os.Stat(r.certFile)
+	if err != nil {
+		return false, fmt.Errorf("tls: stat cert: %w", err)
+	}
+	ks, err := os.Stat(r.keyFile)
+	if err != nil {
+		return false, fmt.Errorf("tls: stat key: %w", err)
+	}
+
+	r.mu.RLock()
+	same := r.cert != nil && cs.ModTime().Equal(r.certMod) && ks.ModTime().Equal(r.keyMod)
+	r.mu.RUnlock()
+	if same {
+		return false, nil
+	}
+
+	cert, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+	if err != nil {
+		return false, fmt.Errorf("tls: load key pair: %w", err)
+	}
+	r.mu.Lock()
+	r.cert = &cert
+	r.certMod = cs.ModTime()
+	r.keyMod = ks.ModTime()
+	r.mu.Unlock()
+	return true, nil
+}
+
+// ------------------------
+// Self-signed bootstrap
+// ------------------------
+
+// SelfSigned generates an in-memory ECDSA P-256 certificate for hosts (IPs or DNS names).
+// localhost and 127.0.0.1 are always included. Never use this in production.
+func SelfSigned(hosts []string, validFor time.Duration) (tls.Certificate, error) {
+	priv, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return tls.Certificate{}, fmt.Errorf("tls: generate key: %w", err)
+	}
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return tls.Certificate{}, fmt.Errorf("tls: serial: %w", err)
+	}
+
+	now := time.Now()
+	tmpl := x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               pkix.Name{CommonName: "slowdrip-miner (self-signed)"},
+		NotBefore:             now.Add(-5 * time.Minute),
+		NotAfter:              now.Add(validFor),
+		KeyUsage:              x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+	}
+	for _, h := range append([]string{"localhost", "127.0.0.1"}, hosts...) {
+		if h == "" {
+			continue
+		}
+		if ip := net.ParseIP(h); ip != nil {
+			tmpl.IPAddresses = append(tmpl.IPAddresses, ip)
+		} else {
+			tmpl.DNSNames = append(tmpl.DNSNames, h)
+		}
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, &tmpl, &tmpl, &priv.PublicKey, priv)
+	if err != nil {
+		return tls.Certificate{}, fmt.Errorf("tls: create certificate: %w", err)
+	}
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: priv}, nil
+}
+
+// ------------------------
+// Plaintext handling
+// ------------------------
+
+// PlaintextHandler is served on miner.tls.plaintextListen.
+// mode "redirect" sends clients to the HTTPS listener; "refuse" answers 426.
+func PlaintextHandler(mode, tlsListen string) (http.Handler, error) {
+	_, port, err := net.SplitHostPort(tlsListen)
+	if err != nil {
+		return nil, fmt.Errorf("tls: bad listen address %q: %w", tlsListen, err)
+	}
+	switch mode {
+	case "redirect":
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			// Host may carry no port: "example.com", or "[::1]" whose brackets
+			// JoinHostPort would add again.
+			host := strings.Trim(r.Host, "[]")
+			if h, _, err := net.SplitHostPort(r.Host); err == nil {
+				host = h
+			}
+			target := "https://" + net.JoinHostPort(host, port) + r.URL.RequestURI()
+			http.Redirect(w, r, target, http.StatusPermanentRedirect)
+		}), nil
+	case "refuse":
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Upgrade", "TLS/1.2, HTTP/1.1")
+			w.Header().Set("Connection", "Upgrade")
+			http.Error(w, "TLS required", http.StatusUpgradeRequired)
+		}), nil
+	}
+	return nil, errors.New("tls: plaintext mode must be refuse or redirect")
+}
@@ -0,0 +1,227 @@
+package tlsutil
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+)
+
+func TestParseVersion(t *testing.T) {
+	tests := []struct {
+		in   string
+		want uint16
+		ok   bool
+	}{
+		{"", tls.VersionTLS12, true},
+		{"1.2", tls.VersionTLS12, true},
+		{"1.3", tls.VersionTLS13, true},
+		{"1.1", 0, false},
+		{"tls1.3", 0, false},
+	}
+	for _, tt := range tests {
+		got, err := ParseVersion(tt.in)
+		if (err == nil) != tt.ok || got != tt.want {
+			t.Errorf("ParseVersion(%q) = %#x, %v", tt.in, got, err)
+		}
+	}
+}
+
+func TestSelfSignedSANs(t *testing.T) {
+	cert, err := SelfSigned([]string{"miner.example", "10.1.2.3", "::1", ""}, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	leaf, err := x509.ParseCertificate(cert.Certificate[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"localhost", "miner.example"} {
+		if err := leaf.VerifyHostname(name); err != nil {
+			t.Errorf("%s: %v", name, err)
+		}
+	}
+	for _, ip := range []string{"127.0.0.1", "10.1.2.3", "::1"} {
+		if err := leaf.VerifyHostname(ip); err != nil {
+			t.Errorf("%s: %v", ip, err)
+		}
+	}
+	if len(leaf.DNSNames) != 2 || len(leaf.IPAddresses) != 3 {
+		t.Errorf("SANs %v %v", leaf.DNSNames, leaf.IPAddresses)
+	}
+	if err := leaf.VerifyHostname("other.example"); err == nil {
+		t.Error("verified a name it wasn't issued for")
+	}
+	if d := time.Until(leaf.NotAfter); d > time.Hour || d < 50*time.Minute {
+		t.Errorf("valid until %s", leaf.NotAfter)
+	}
+}
+
+// writePair writes a self-signed cert/key pair for cn and sets both files'
+// modification time to mod.
+func writePair(t *testing.T, certFile, keyFile, cn string, mod time.Time) {
+	t.Helper()
+	priv, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := x509.Certificate{SerialNumber: big.NewInt(1), Subject: pkix.Name{CommonName: cn}, NotBefore: time.Now(), NotAfter: time.Now().Add(time.Hour)}
+	der, err := x509.CreateCertificate(rand.Reader, &tmpl, &tmpl, &priv.PublicKey, priv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	kder, err := x509.MarshalECPrivateKey(priv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: kder}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	for _, f := range []string{certFile, keyFile} {
+		if err := os.Chtimes(f, mod, mod); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func servedCN(t *testing.T, r *CertReloader) string {
+	t.Helper()
+	c, err := r.GetCertificate(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	leaf, err := x509.ParseCertificate(c.Certificate[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	return leaf.Subject.CommonName
+}
+
+func TestCertReloader(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := filepath.Join(dir, "tls.crt"), filepath.Join(dir, "tls.key")
+	t0 := time.Now().Add(-time.Hour)
+	writePair(t, certFile, keyFile, "first", t0)
+
+	r, err := NewCertReloader(certFile, keyFile, zerolog.Nop())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cn := servedCN(t, r); cn != "first" {
+		t.Fatalf("serving %q", cn)
+	}
+	if changed, err := r.reload(); changed || err != nil {
+		t.Fatalf("unchanged files: changed %v, err %v", changed, err)
+	}
+
+	// A renewal is picked up.
+	writePair(t, certFile, keyFile, "second", t0.Add(time.Minute))
+	if changed, err := r.reload(); !changed || err != nil {
+		t.Fatalf("renewal: changed %v, err %v", changed, err)
+	}
+	if cn := servedCN(t, r); cn != "second" {
+		t.Fatalf("serving %q after renewal", cn)
+	}
+
+	// A half-written pair (new cert, old key) is rejected and the last good
+	// certificate keeps serving.
+	writePair(t, certFile, filepath.Join(dir, "other.key"), "third", t0.Add(2*time.Minute))
+	if changed, err := r.reload(); changed || err == nil {
+		t.Fatalf("mismatched pair: changed %v, err %v", changed, err)
+	}
+	if cn := servedCN(t, r); cn != "second" {
+		t.Fatalf("serving %q after a broken reload", cn)
+	}
+	os.Remove(keyFile)
+	if _, err := r.reload(); err == nil {
+		t.Fatal("missing key not reported")
+	}
+	if cn := servedCN(t, r); cn != "second" {
+		t.Fatalf("serving %q with the key gone", cn)
+	}
+
+	if _, err := NewCertReloader(certFile, keyFile, zerolog.Nop()); err == nil {
+		t.Fatal("a broken pair at startup must be an error")
+	}
+}
+
+func TestPlaintextHandler(t *testing.T) {
+	tests := []struct {
+		name     string
+		mode     string
+		listen   string
+		host     string
+		uri      string
+		status   int
+		location string
+	}{
+		{name: "redirect keeps the path and query", mode: "redirect", listen: ":8443", host: "miner.example:8081", uri: "/v1/status?x=1",
+			status: http.StatusPermanentRedirect, location: "https://miner.example:8443/v1/status?x=1"},
+		{name: "redirect from a host without port", mode: "redirect", listen: ":8443", host: "miner.example", uri: "/",
+			status: http.StatusPermanentRedirect, location: "https://miner.example:8443/"},
+		{name: "redirect from an IPv6 host with port", mode: "redirect", listen: "[::]:8443", host: "[::1]:8081", uri: "/healthz",
+			status: http.StatusPermanentRedirect, location: "https://[::1]:8443/healthz"},
+		{name: "redirect from an IPv6 host without port", mode: "redirect", listen: ":8443", host: "[::1]", uri: "/healthz",
+			status: http.StatusPermanentRedirect, location: "https://[::1]:8443/healthz"},
+		{name: "refuse", mode: "refuse", listen: ":8443", host: "miner.example", uri: "/", status: http.StatusUpgradeRequired},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h, err := PlaintextHandler(tt.mode, tt.listen)
+			if err != nil {
+				t.Fatal(err)
+			}
+			req := httptest.NewRequest(http.MethodGet, tt.uri, nil)
+			req.Host = tt.host
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, req)
+			if rec.Code != tt.status {
+				t.Fatalf("status %d, want %d", rec.Code, tt.status)
+			}
+			if loc := rec.Header().Get("Location"); loc != tt.location {
+				t.Fatalf("location %q, want %q", loc, tt.location)
+			}
+			if tt.mode == "refuse" && rec.Header().Get("Upgrade") == "" {
+				t.Fatal("no Upgrade header")
+			}
+		})
+	}
+	if _, err := PlaintextHandler("drop", ":8443"); err == nil {
+		t.Error("unknown mode accepted")
+	}
+	if _, err := PlaintextHandler("redirect", "8443"); err == nil {
+		t.Error("listen address without a port accepted")
+	}
+}
+
+// The redirect target must still parse as a URL with the right host.
+func TestRedirectTargetParses(t *testing.T) {
+	h, _ := PlaintextHandler("redirect", ":8443")
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Host = "[fe80::1]"
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	u, err := http.NewRequest(http.MethodGet, rec.Header().Get("Location"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if host, port, _ := net.SplitHostPort(u.URL.Host); host != "fe80::1" || port != "8443" {
+		t.Fatalf("host %q port %q", host, port)
+	}
+}
@@ -0,0 +1,142 @@
+// internal/wallet/keydir.go
+package wallet
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	gethks "github.com/ethereum/go-ethereum/accounts/keystore"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// KeyDir manages Web3 keystore (V3) files in one directory, using geth's
+// "UTC--<timestamp>--<address>" naming so files stay interchangeable with geth/clef.
+// Passwords are always passed in by the caller (see ReadPassword); nothing here
+// takes them from command-line arguments.
+type KeyDir struct {
+	Dir     string
+	ScryptN int
+	ScryptP int
+}
+
+// Account is a keystore file found in a KeyDir.
+type Account struct {
+	Address common.Address `json:"address"`
+	Path    string         `json:"path"`
+}
+
+// ErrAccountNotFound is returned by KeyDir.Find/Load when no file holds the address.
+var ErrAccountNotFound = errors.New("wallet: account not found in keystore dir")
+
+// NewKeyDir uses geth's standard scrypt parameters.
+func NewKeyDir(dir string) *KeyDir {
+	return &KeyDir{Dir: dir, ScryptN: gethks.StandardScryptN, ScryptP: gethks.StandardScryptP}
+}
+
+// Write stores w in the directory and returns the exact file path written.
+func (d *KeyDir) Write(w *Keystore, password string) (string, error) {
+	if err := os.MkdirAll(d.Dir, 0o700); err != nil {
+		return "", fmt.Errorf("wallet: mkdir: %w", err)
+	}
+	path := filepath.Join(d.Dir, keyFileName(w.Address(), time.Now()))
+	if err := WriteKeystoreFile(path, w, password, d.ScryptN, d.ScryptP); err != nil {
+		return "", err
+	}
+	return path, nil
+}
+
+// List returns every keystore file in the directory, sorted by file name.
+// Files that are not keystore JSON are skipped.
+func (d *KeyDir) List() ([]Account, error) {
+	ents, err := os.ReadDir(d.Dir)
+	if err != nil {
+		return nil, fmt.Errorf("wallet: read keystore dir: %w", err)
+	}
+	var out []Account
+	for _, e := range ents {
+		name := e.Name()
+		if e.IsDir() || strings.HasPrefix(name, ".") || strings.HasSuffix(name, "~") {
+			continue
+		}
+		path := filepath.Join(d.Dir, name)
+		addr, err := KeystoreAddress(path)
+		if err != nil {
+			continue
+		}
+		out = append(out, Account{Address: addr, Path: path})
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Path < out[j].Path })
+	return out, nil
+}
+
+// Find returns the keystore file for addr (the newest, if there are several).
+func (d *KeyDir) Find(addr common.Address) (Account, error) {
+	accs, err := d.List()
+	if err != nil {
+		return Account{}, err
+	}
+	for i := len(accs) - 1; i >= 0; i-- {
+		if accs[i].Address == addr {
+			return accs[i], nil
+		}
+	}
+	return Account{}, ErrAccountNotFound
+}
+
+// Load decrypts the keystore file for addr.
+func (d *KeyDir) Load(addr common.Address, password string, chainID *big.Int) (*Keystore, error) {
+	acc, err := d.Find(addr)
+	if err != nil {
+		return nil, err
+	}
+	return LoadKeystoreFile(acc.Path, password, chainID)
+}
+
+// ChangePassword re-encrypts the keystore at path under newPassword, in place.
+func ChangePassword(path, oldPassword, newPassword string) error {
+	w, err := LoadKeystoreFile(path, oldPassword, nil)
+	if err != nil {
+		return err
+	}
+	defer w.Close()
+	return WriteKeystoreFile(path, w, newPassword, gethks.StandardScryptN, gethks.StandardScryptP)
+}
+
+// --------------------------
+// internals
+// --------------------------
+
+// KeystoreAddress reads the "address" field of a keystore file without decrypting it.
+func KeystoreAddress(path string) (common.Address, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return common.Address{}, err
+	}
+	var kf struct {
+		Address string `json:"address"`
+	}
+	if err := json.Unmarshal(b, &kf); err != nil {
+		return common.Address{}, err
+	}
+	raw, err := hex.DecodeString(strings.TrimPrefix(kf.Address, "0x"))
+	if err != nil || len(raw) != common.AddressLength {
+		return common.Address{}, fmt.Errorf("wallet: %s: bad address field", path)
+	}
+	return common.BytesToAddress(raw), nil
+}
+
+// keyFileName matches geth: UTC--<ISO8601 with dashes>--<hex address>.
+func keyFileName(addr common.Address, t time.Time) string {
+	t = t.UTC()
+	ts := fmt.Sprintf("%04d-%02d-%02dT%02d-%02d-%02d.%09dZ",
+		t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), t.Nanosecond())
+	return fmt.Sprintf("UTC--%s--%s", ts, hex.EncodeToString(addr[:]))
+}
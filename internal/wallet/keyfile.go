@@ -0,0 +1,101 @@
+// internal/wallet/keyfile.go
+package wallet
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strings"
+
+	gethks "github.com/ethereum/go-ethereum/accounts/keystore"
+	"github.com/google/uuid"
+)
+
+// LoadKeystoreFile decrypts a single keystore JSON file.
+func LoadKeystoreFile(path, password string, chainID *big.Int) (*Keystore, error) {
+	blob, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("wallet: read keystore: %w", err)
+	}
+	return FromKeystoreJSON(blob, password, chainID)
+}
+
+// WriteKeystoreFile encrypts w to exactly path. The file is written to a temp file
+// in the same directory with 0600 perms, synced, then renamed into place, so readers
+// never see a partial keystore.
+func WriteKeystoreFile(path string, w *Keystore, password string, scryptN, scryptP int) error {
+	w.mu.RLock()
+	priv := w.priv
+	w.mu.RUnlock()
+	if priv == nil {
+		return errors.New("wallet: closed")
+	}
+	key := &gethks.Key{Id: uuid.New(), Address: w.Address(), PrivateKey: priv}
+	blob, err := gethks.EncryptKey(key, password, scryptN, scryptP)
+	if err != nil {
+		return fmt.Errorf("wallet: encrypt key: %w", err)
+	}
+	return atomicWrite(path, blob)
+}
+
+// ReadPassword reads a keystore password from a file (trailing newline ignored)
+// or from an env var. Exactly one of file/env should be set.
+func ReadPassword(file, env string) (string, error) {
+	switch {
+	case file != "" && env != "":
+		return "", errors.New("wallet: set only one of password file and password env")
+	case file != "":
+		b, err := os.ReadFile(file)
+		if err != nil {
+			return "", fmt.Errorf("wallet: read password file: %w", err)
+		}
+		return strings.TrimRight(string(b), "\r\n"), nil
+	case env != "":
+		v, ok := os.LookupEnv(env)
+		if !ok {
+			return "", fmt.Errorf("wallet: password env %s not set", env)
+		}
+		return v, nil
+	}
+	return "", errors.New("wallet: no password source configured")
+}
+
+// --------------------------
+// internals
+// --------------------------
+
+// atomicWrite writes data to path through a synced 0600 temp file and a rename.
+func atomicWrite(path string, data []byte) error {
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return fmt.Errorf("wallet: mkdir: %w", err)
+	}
+	f, err := os.CreateTemp(dir, "."+filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return fmt.Errorf("wallet: create temp: %w", err)
+	}
+	tmp := f.Name()
+	defer os.Remove(tmp) // no-op after a successful rename
+
+	if err := f.Chmod(0o600); err != nil {
+		f.Close()
+		return fmt.Errorf("wallet: chmod: %w", err)
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return fmt.Errorf("wallet: write: %w", err)
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return fmt.Errorf("wallet: sync: %w", err)
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("wallet: close: %w", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return fmt.Errorf("wallet: rename: %w", err)
+	}
+	return nil
+}
@@ -9,11 +9,9 @@ import (
 	"fmt"
 	"math/big"
 	"os"
-	"path/filepath"
 	"strings"
 	"sync"
 
-	"github.com/ethereum/go-ethereum/accounts"
 	gethks "github.com/ethereum/go-ethereum/accounts/keystore"
 	"github.com/ethereum/go-ethereum/common"
 	gethcrypto "github.com/ethereum/go-ethereum/crypto"
@@ -21,6 +19,7 @@ import (
 
 // Keystore holds a single secp256k1 key for signing EVM digests (EIP-191 / EIP-712 / raw 32B).
 // It is intentionally minimal: no RPC, no tx assembly — just keys & signatures.
+// Transactions are built and submitted by internal/chain.
 type Keystore struct {
 	mu      sync.RWMutex
 	priv    *ecdsa.PrivateKey
@@ -65,24 +64,13 @@ func FromKeystoreJSON(jsonBlob []byte, password string, chainID *big.Int) (*Keys
 	return &Keystore{priv: key.PrivateKey, addr: key.Address, chainID: copyBig(chainID)}, nil
 }
 
-// SaveAsKeystore writes the private key as a Web3 JSON keystore file.
-// Useful for dev/ops; not required for runtime.
+// SaveAsKeystore writes the private key as a Web3 JSON keystore file in dir
+// and returns the exact path written (geth-style UTC--... name).
 func (w *Keystore) SaveAsKeystore(dir, password string) (string, error) {
 	if w == nil || w.priv == nil {
 		return "", errors.New("wallet: nil")
 	}
-	ks := gethks.NewKeyStore(dir, gethks.StandardScryptN, gethks.StandardScryptP)
-	acc, err := ks.ImportECDSA(w.priv, password)
-	if err != nil {
-		return "", fmt.Errorf("wallet: import ecdsa: %w", err)
-	}
-	// Unlock once to ensure it’s usable (no-op for file import)
-	if err := ks.Unlock(acc, password); err != nil {
-		return "", fmt.Errorf("wallet: unlock: %w", err)
-	}
-	// Return the path we expect it to be saved at (best-effort)
-	path := filepath.Join(dir, accounts.KeyFilename(acc.Address))
-	return path, nil
+	return NewKeyDir(dir).Write(w, password)
 }
 
 // Address returns the EVM address for this key.
@@ -159,9 +147,7 @@ func (w *Keystore) SignHash(digest32 []byte) ([]byte, error) {
 // SignEIP191 signs a human-readable message per EIP-191 ("personal_sign").
 // digest = keccak256("\x19Ethereum Signed Message:\n" + len(msg) + msg)
 func (w *Keystore) SignEIP191(msg []byte) ([]byte, error) {
-	prefix := fmt.Sprintf("\x19Ethereum Signed Message:\n%d", len(msg))
-	digest := gethcrypto.Keccak256([]byte(prefix), msg)
-	return w.SignHash(digest)
+	return w.SignHash(eip191Digest(msg))
 }
 
 // SignEIP712Digest signs a prebuilt EIP-712 digest (already domain-separated and hashed).
@@ -192,12 +178,37 @@ func (w *Keystore) VerifySig(digest32, sig []byte) (bool, error) {
 	return strings.EqualFold(recAddr.Hex(), w.Address().Hex()), nil
 }
 
+// RecoverEIP191 returns the address that produced sig over msg per EIP-191 ("personal_sign").
+// Accepts sig with V in {27,28} or {0,1}.
+func RecoverEIP191(msg, sig []byte) (common.Address, error) {
+	if len(sig) != 65 {
+		return common.Address{}, errors.New("wallet: signature must be 65 bytes")
+	}
+	vsig := make([]byte, 65)
+	copy(vsig, sig)
+	if vsig[64] >= 27 {
+		vsig[64] -= 27
+	}
+	pubkey, err := gethcrypto.SigToPub(eip191Digest(msg), vsig)
+	if err != nil {
+		return common.Address{}, fmt.Errorf("wallet: recover pub: %w", err)
+	}
+	return gethcrypto.PubkeyToAddress(*pubkey), nil
+}
+
+// eip191Digest = keccak256("\x19Ethereum Signed Message:\n" + len(msg) + msg)
+func eip191Digest(msg []byte) []byte {
+	prefix := fmt.Sprintf("\x19Ethereum Signed Message:\n%d", len(msg))
+	return gethcrypto.Keccak256([]byte(prefix), msg)
+}
+
 // --------------------------
 // Utilities
 // --------------------------
 
 // LoadHexFromEnv tries to construct a wallet from ENV var (e.g., SLOWDRIP_MINER_KEY).
-// If the var is empty and allowGenerate is true, it generates a random key and stores it at writePath (keystore JSON).
+// If the var is empty and allowGenerate is true, it generates a random key and, if
+// writeKeystorePath is set, stores it as keystore JSON at exactly that path.
 func LoadHexFromEnv(envName string, chainID *big.Int, allowGenerate bool, writeKeystorePath string, keystorePass string) (*Keystore, error) {
 	if hexKey := strings.TrimSpace(os.Getenv(envName)); hexKey != "" {
 		return FromHex(hexKey, chainID)
@@ -210,11 +221,7 @@ func LoadHexFromEnv(envName string, chainID *big.Int, allowGenerate bool, writeK
 		return nil, err
 	}
 	if writeKeystorePath != "" {
-		dir := filepath.Dir(writeKeystorePath)
-		if err := os.MkdirAll(dir, 0o755); err != nil {
-			return nil, fmt.Errorf("wallet: mkdir: %w", err)
-		}
-		if _, err := w.SaveAsKeystore(dir, keystorePass); err != nil {
+		if err := WriteKeystoreFile(writeKeystorePath, w, keystorePass, gethks.StandardScryptN, gethks.StandardScryptP); err != nil {
 			return nil, fmt.Errorf("wallet: save keystore: %w", err)
 		}
 	}
@@ -0,0 +1,105 @@
+// internal/wallet/open.go
+package wallet
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"strings"
+
+	gethks "github.com/ethereum/go-ethereum/accounts/keystore"
+)
+
+// Key sources accepted by Open (mirrors wallet.source in miner.yaml).
+const (
+	SourceEnv          = "env"
+	SourceKeystoreFile = "keystore-file"
+	SourceGenerate     = "generate"
+	SourceRemote       = "remote" // see RemoteSigner; not handled by Open
+	SourcePKCS11       = "pkcs11" // see OpenPKCS11; not handled by Open
+)
+
+// Options describes where the miner key comes from.
+type Options struct {
+	Source        string
+	Env           string // env var with a hex key (SourceEnv)
+	KeystorePath  string // keystore JSON file (SourceKeystoreFile, SourceGenerate)
+	PasswordFile  string
+	PasswordEnv   string
+	Password      string // used as is when set, instead of PasswordFile/PasswordEnv
+	ChainID       *big.Int
+	AllowGenerate bool
+}
+
+// Open loads (or, for SourceGenerate on first boot, creates) the miner key.
+// generated reports whether a new key was created by this call.
+func Open(o Options) (w *Keystore, generated bool, err error) {
+	switch o.Source {
+	case SourceEnv:
+		hexKey := strings.TrimSpace(os.Getenv(o.Env))
+		if hexKey == "" {
+			return nil, false, fmt.Errorf("wallet: env %s is empty", o.Env)
+		}
+		w, err = FromHex(hexKey, o.ChainID)
+		return w, false, err
+
+	case SourceKeystoreFile:
+		w, err = openKeystoreFile(o)
+		return w, false, err
+
+	case SourceGenerate:
+		if o.KeystorePath == "" {
+			return nil, false, errors.New("wallet: source generate needs a keystore path")
+		}
+		if _, err := os.Stat(o.KeystorePath); err == nil {
+			w, err = openKeystoreFile(o)
+			return w, false, err
+		} else if !errors.Is(err, os.ErrNotExist) {
+			return nil, false, fmt.Errorf("wallet: stat keystore: %w", err)
+		}
+		if !o.AllowGenerate {
+			return nil, false, errors.New("wallet: key generation not allowed")
+		}
+		w, err = NewRandom(o.ChainID)
+		if err != nil {
+			return nil, false, err
+		}
+		pass, err := o.password()
+		if err != nil {
+			w.Close()
+			return nil, false, err
+		}
+		if err := WriteKeystoreFile(o.KeystorePath, w, pass, gethks.StandardScryptN, gethks.StandardScryptP); err != nil {
+			w.Close()
+			return nil, false, err
+		}
+		return w, true, nil
+	}
+	return nil, false, fmt.Errorf("wallet: unknown source %q", o.Source)
+}
+
+func (o Options) password() (string, error) {
+	if o.Password != "" {
+		return o.Password, nil
+	}
+	return ReadPassword(o.PasswordFile, o.PasswordEnv)
+}
+
+func openKeystoreFile(o Options) (*Keystore, error) {
+	pass, err := o.password()
+	if err != nil {
+		return nil, err
+	}
+	return LoadKeystoreFile(o.KeystorePath, pass, o.ChainID)
+}
+
+// PKCS11Options selects a secp256k1 key pair on a PKCS#11 token.
+// Build with -tags pkcs11 (needs cgo) to enable OpenPKCS11.
+type PKCS11Options struct {
+	Module     string // path to the PKCS#11 module, e.g. /usr/lib/softhsm/libsofthsm2.so
+	TokenLabel string // "" = first token present
+	KeyLabel   string // CKA_LABEL of the key pair
+	KeyID      string // CKA_ID (hex), used when labels are ambiguous
+	PIN        string // user PIN; read it with ReadPassword, never from argv
+}
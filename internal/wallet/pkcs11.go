@@ -0,0 +1,264 @@
+//go:build pkcs11
+
+// internal/wallet/pkcs11.go
+package wallet
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"encoding/asn1"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/common"
+	gethcrypto "github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/signer/core/apitypes"
+	"github.com/miekg/pkcs11"
+)
+
+// PKCS11Available reports whether this binary was built with PKCS#11 support.
+const PKCS11Available = true
+
+// secp256k1 curve OID (1.3.132.0.10), DER-encoded as CKA_EC_PARAMS.
+var secp256k1OID = []byte{0x06, 0x05, 0x2b, 0x81, 0x04, 0x00, 0x0a}
+
+var (
+	secp256k1N     = gethcrypto.S256().Params().N
+	secp256k1HalfN = new(big.Int).Rsh(secp256k1N, 1)
+)
+
+var _ Signer = (*PKCS11Signer)(nil)
+
+// PKCS11Signer signs with a secp256k1 key held in an HSM or token. The private key
+// never leaves the token; the address is derived from the token's public key.
+// Tokens return plain (r, s) signatures, so S is normalised to the low half of the
+// curve order and the recovery ID is computed by trial recovery.
+type PKCS11Signer struct {
+	mu      sync.Mutex // PKCS#11 sessions are not safe for concurrent use
+	ctx     *pkcs11.Ctx
+	session pkcs11.SessionHandle
+	key     pkcs11.ObjectHandle
+	pub     []byte // uncompressed 65-byte public key
+	addr    common.Address
+}
+
+// OpenPKCS11 loads the module, logs in to the token and locates the key pair.
+func OpenPKCS11(o PKCS11Options) (*PKCS11Signer, error) {
+	if o.KeyLabel == "" && o.KeyID == "" {
+		return nil, errors.New("wallet: pkcs11 needs keyLabel or keyID")
+	}
+	ctx := pkcs11.New(o.Module)
+	if ctx == nil {
+		return nil, fmt.Errorf("wallet: pkcs11: cannot load module %s", o.Module)
+	}
+	if err := ctx.Initialize(); err != nil {
+		ctx.Destroy()
+		return nil, fmt.Errorf("wallet: pkcs11 initialize: %w", err)
+	}
+	s := &PKCS11Signer{ctx: ctx}
+	if err := s.open(o); err != nil {
+		s.Close()
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *PKCS11Signer) open(o PKCS11Options) error {
+	slot, err := findSlot(s.ctx, o.TokenLabel)
+	if err != nil {
+		return err
+	}
+	s.session, err = s.ctx.OpenSession(slot, pkcs11.CKF_SERIAL_SESSION)
+	if err != nil {
+		return fmt.Errorf("wallet: pkcs11 open session: %w", err)
+	}
+	if err := s.ctx.Login(s.session, pkcs11.CKU_USER, o.PIN); err != nil {
+		var pe pkcs11.Error
+		if !errors.As(err, &pe) || pe != pkcs11.CKR_USER_ALREADY_LOGGED_IN {
+			return fmt.Errorf("wallet: pkcs11 login: %w", err)
+		}
+	}
+
+	var id []byte
+	if o.KeyID != "" {
+		if id, err = hex.DecodeString(strings.TrimPrefix(o.KeyID, "0x")); err != nil {
+			return fmt.Errorf("wallet: pkcs11 keyID: %w", err)
+		}
+	}
+	s.key, err = s.findObject(pkcs11.CKO_PRIVATE_KEY, o.KeyLabel, id)
+	if err != nil {
+		return err
+	}
+	pubObj, err := s.findObject(pkcs11.CKO_PUBLIC_KEY, o.KeyLabel, id)
+	if err != nil {
+		return err
+	}
+
+	attrs, err := s.ctx.GetAttributeValue(s.session, pubObj, []*pkcs11.Attribute{
+		pkcs11.NewAttribute(pkcs11.CKA_EC_PARAMS, nil),
+		pkcs11.NewAttribute(pkcs11.CKA_EC_POINT, nil),
+	})
+	if err != nil {
+		return fmt.Errorf("wallet: pkcs11 read public key: %w", err)
+	}
+	var params, point []byte
+	for _, a := range attrs {
+		switch a.Type {
+		case pkcs11.CKA_EC_PARAMS:
+			params = a.Value
+		case pkcs11.CKA_EC_POINT:
+			point = a.Value
+		}
+	}
+	if !bytes.Equal(params, secp256k1OID) {
+		return errors.New("wallet: pkcs11 key is not on secp256k1")
+	}
+	pub, err := parseECPoint(point)
+	if err != nil {
+		return err
+	}
+	s.pub = gethcrypto.FromECDSAPub(pub)
+	s.addr = gethcrypto.PubkeyToAddress(*pub)
+	return nil
+}
+
+// Address is derived from the token's public key.
+func (s *PKCS11Signer) Address() common.Address { return s.addr }
+
+// SignHash signs a 32-byte digest on the token and returns [R || S || V], V in {27,28}.
+func (s *PKCS11Signer) SignHash(digest32 []byte) ([]byte, error) {
+	if len(digest32) != 32 {
+		return nil, errors.New("wallet: SignHash expects 32-byte digest")
+	}
+	s.mu.Lock()
+	if s.ctx == nil {
+		s.mu.Unlock()
+		return nil, errors.New("wallet: closed")
+	}
+	err := s.ctx.SignInit(s.session, []*pkcs11.Mechanism{pkcs11.NewMechanism(pkcs11.CKM_ECDSA, nil)}, s.key)
+	var rs []byte
+	if err == nil {
+		rs, err = s.ctx.Sign(s.session, digest32)
+	}
+	s.mu.Unlock()
+	if err != nil {
+		return nil, fmt.Errorf("wallet: pkcs11 sign: %w", err)
+	}
+	if len(rs) != 64 {
+		return nil, fmt.Errorf("wallet: pkcs11 returned %d-byte signature, want 64", len(rs))
+	}
+
+	// Normalise to low-S (EIP-2): s' = N - s when s > N/2.
+	sv := new(big.Int).SetBytes(rs[32:])
+	if sv.Cmp(secp256k1HalfN) > 0 {
+		sv.Sub(secp256k1N, sv)
+	}
+	sig := make([]byte, 65)
+	copy(sig[:32], rs[:32])
+	sv.FillBytes(sig[32:64])
+
+	// The token doesn't tell us V; find the one that recovers our key.
+	for v := byte(0); v < 2; v++ {
+		sig[64] = v
+		rec, err := gethcrypto.Ecrecover(digest32, sig)
+		if err == nil && bytes.Equal(rec, s.pub) {
+			sig[64] += 27
+			return sig, nil
+		}
+	}
+	return nil, errors.New("wallet: pkcs11 signature does not recover to token key")
+}
+
+// SignEIP191 signs msg as an EIP-191 personal message.
+func (s *PKCS11Signer) SignEIP191(msg []byte) ([]byte, error) {
+	return s.SignHash(eip191Digest(msg))
+}
+
+// SignTypedData hashes td per EIP-712 and signs the digest on the token.
+func (s *PKCS11Signer) SignTypedData(td apitypes.TypedData) ([]byte, error) {
+	digest, _, err := apitypes.TypedDataAndHash(td)
+	if err != nil {
+		return nil, fmt.Errorf("wallet: typed data hash: %w", err)
+	}
+	return s.SignHash(digest)
+}
+
+// Close logs out and unloads the module.
+func (s *PKCS11Signer) Close() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.ctx == nil {
+		return
+	}
+	if s.session != 0 {
+		_ = s.ctx.Logout(s.session)
+		_ = s.ctx.CloseSession(s.session)
+	}
+	_ = s.ctx.Finalize()
+	s.ctx.Destroy()
+	s.ctx = nil
+}
+
+func findSlot(ctx *pkcs11.Ctx, label string) (uint, error) {
+	slots, err := ctx.GetSlotList(true)
+	if err != nil {
+		return 0, fmt.Errorf("wallet: pkcs11 slots: %w", err)
+	}
+	for _, sl := range slots {
+		ti, err := ctx.GetTokenInfo(sl)
+		if err != nil {
+			continue
+		}
+		if label == "" || strings.TrimRight(ti.Label, " \x00") == label {
+			return sl, nil
+		}
+	}
+	return 0, fmt.Errorf("wallet: pkcs11 token %q not found", label)
+}
+
+func (s *PKCS11Signer) findObject(class uint, label string, id []byte) (pkcs11.ObjectHandle, error) {
+	tmpl := []*pkcs11.Attribute{
+		pkcs11.NewAttribute(pkcs11.CKA_CLASS, class),
+		pkcs11.NewAttribute(pkcs11.CKA_KEY_TYPE, pkcs11.CKK_EC),
+	}
+	if label != "" {
+		tmpl = append(tmpl, pkcs11.NewAttribute(pkcs11.CKA_LABEL, label))
+	}
+	if len(id) > 0 {
+		tmpl = append(tmpl, pkcs11.NewAttribute(pkcs11.CKA_ID, id))
+	}
+	if err := s.ctx.FindObjectsInit(s.session, tmpl); err != nil {
+		return 0, fmt.Errorf("wallet: pkcs11 find: %w", err)
+	}
+	objs, _, err := s.ctx.FindObjects(s.session, 2)
+	_ = s.ctx.FindObjectsFinal(s.session)
+	if err != nil {
+		return 0, fmt.Errorf("wallet: pkcs11 find: %w", err)
+	}
+	switch len(objs) {
+	case 0:
+		return 0, errors.New("wallet: pkcs11 key not found")
+	case 1:
+		return objs[0], nil
+	}
+	return 0, errors.New("wallet: pkcs11 key selector matches several keys; set keyID")
+}
+
+// parseECPoint accepts CKA_EC_POINT as a DER OCTET STRING (per spec) or raw
+// uncompressed bytes (some tokens).
+func parseECPoint(v []byte) (*ecdsa.PublicKey, error) {
+	raw := v
+	var inner []byte
+	if rest, err := asn1.Unmarshal(v, &inner); err == nil && len(rest) == 0 {
+		raw = inner
+	}
+	pub, err := gethcrypto.UnmarshalPubkey(raw)
+	if err != nil {
+		return nil, fmt.Errorf("wallet: pkcs11 public key: %w", err)
+	}
+	return pub, nil
+}
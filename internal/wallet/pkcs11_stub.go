@@ -0,0 +1,28 @@
+//go:build !pkcs11
+
+// internal/wallet/pkcs11_stub.go
+package wallet
+
+import (
+	"errors"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/signer/core/apitypes"
+)
+
+// PKCS11Available reports whether this binary was built with PKCS#11 support.
+const PKCS11Available = false
+
+var errNoPKCS11 = errors.New("wallet: built without PKCS#11 support (rebuild with -tags pkcs11)")
+
+// PKCS11Signer is unavailable in this build; see pkcs11.go.
+type PKCS11Signer struct{}
+
+// OpenPKCS11 always fails in builds without the pkcs11 tag.
+func OpenPKCS11(o PKCS11Options) (*PKCS11Signer, error) { return nil, errNoPKCS11 }
+
+func (s *PKCS11Signer) Address() common.Address                          { return common.Address{} }
+func (s *PKCS11Signer) SignHash([]byte) ([]byte, error)                  { return nil, errNoPKCS11 }
+func (s *PKCS11Signer) SignEIP191([]byte) ([]byte, error)                { return nil, errNoPKCS11 }
+func (s *PKCS11Signer) SignTypedData(apitypes.TypedData) ([]byte, error) { return nil, errNoPKCS11 }
+func (s *PKCS11Signer) Close()                                           {}
@@ -0,0 +1,177 @@
+//go:build pkcs11
+
+package wallet
+
+import (
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/miekg/pkcs11"
+)
+
+// softHSMModules are where distributions install SoftHSMv2; SOFTHSM2_MODULE overrides.
+var softHSMModules = []string{
+	"/usr/lib/softhsm/libsofthsm2.so",
+	"/usr/lib/x86_64-linux-gnu/softhsm/libsofthsm2.so",
+	"/usr/lib64/pkcs11/libsofthsm2.so",
+	"/usr/local/lib/softhsm/libsofthsm2.so",
+	"/opt/homebrew/lib/softhsm/libsofthsm2.so",
+}
+
+const (
+	testTokenLabel = "slowdrip-test"
+	testKeyLabel   = "miner"
+	testPIN        = "1234"
+)
+
+// softHSM initialises a throwaway SoftHSMv2 token holding one secp256k1 key pair
+// and returns the module path. The test is skipped when SoftHSMv2 is missing.
+func softHSM(t *testing.T) string {
+	t.Helper()
+	module := os.Getenv("SOFTHSM2_MODULE")
+	if module == "" {
+		for _, m := range softHSMModules {
+			if _, err := os.Stat(m); err == nil {
+				module = m
+				break
+			}
+		}
+	}
+	if module == "" {
+		t.Skip("SoftHSMv2 not installed (set SOFTHSM2_MODULE)")
+	}
+
+	dir := t.TempDir()
+	conf := filepath.Join(dir, "softhsm2.conf")
+	tokens := filepath.Join(dir, "tokens")
+	if err := os.Mkdir(tokens, 0o700); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(conf, []byte(fmt.Sprintf("directories.tokendir = %s\nobjectstore.backend = file\n", tokens)), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("SOFTHSM2_CONF", conf)
+
+	ctx := pkcs11.New(module)
+	if ctx == nil {
+		t.Skipf("cannot load %s", module)
+	}
+	defer ctx.Destroy()
+	must := func(err error) {
+		t.Helper()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	must(ctx.Initialize())
+	defer ctx.Finalize()
+
+	slots, err := ctx.GetSlotList(false)
+	must(err)
+	if len(slots) == 0 {
+		t.Fatal("softhsm: no slots")
+	}
+	must(ctx.InitToken(slots[0], "so-pin", testTokenLabel))
+
+	// Initialising a token re-numbers the slots; look it up by label.
+	slot, err := findSlot(ctx, testTokenLabel)
+	must(err)
+	sess, err := ctx.OpenSession(slot, pkcs11.CKF_SERIAL_SESSION|pkcs11.CKF_RW_SESSION)
+	must(err)
+	defer ctx.CloseSession(sess)
+	must(ctx.Login(sess, pkcs11.CKU_SO, "so-pin"))
+	must(ctx.InitPIN(sess, testPIN))
+	must(ctx.Logout(sess))
+	must(ctx.Login(sess, pkcs11.CKU_USER, testPIN))
+
+	pubTmpl := []*pkcs11.Attribute{
+		pkcs11.NewAttribute(pkcs11.CKA_TOKEN, true),
+		pkcs11.NewAttribute(pkcs11.CKA_VERIFY, true),
+		pkcs11.NewAttribute(pkcs11.CKA_EC_PARAMS, secp256k1OID),
+		pkcs11.NewAttribute(pkcs11.CKA_LABEL, testKeyLabel),
+		pkcs11.NewAttribute(pkcs11.CKA_ID, []byte{0x01}),
+	}
+	privTmpl := []*pkcs11.Attribute{
+		pkcs11.NewAttribute(pkcs11.CKA_TOKEN, true),
+		pkcs11.NewAttribute(pkcs11.CKA_PRIVATE, true),
+		pkcs11.NewAttribute(pkcs11.CKA_SIGN, true),
+		pkcs11.NewAttribute(pkcs11.CKA_SENSITIVE, true),
+		pkcs11.NewAttribute(pkcs11.CKA_LABEL, testKeyLabel),
+		pkcs11.NewAttribute(pkcs11.CKA_ID, []byte{0x01}),
+	}
+	if _, _, err := ctx.GenerateKeyPair(sess, []*pkcs11.Mechanism{pkcs11.NewMechanism(pkcs11.CKM_EC_KEY_PAIR_GEN, nil)}, pubTmpl, privTmpl); err != nil {
+		t.Skipf("softhsm cannot generate secp256k1 keys (OpenSSL without the curve?): %v", err)
+	}
+	return module
+}
+
+func TestPKCS11SoftHSM(t *testing.T) {
+	module := softHSM(t)
+
+	s, err := OpenPKCS11(PKCS11Options{Module: module, TokenLabel: testTokenLabel, KeyLabel: testKeyLabel, PIN: testPIN})
+	if err != nil {
+		t.Fatalf("OpenPKCS11: %v", err)
+	}
+	defer s.Close()
+
+	for i := 0; i < 8; i++ { // several signatures so both recovery IDs turn up
+		msg := []byte(fmt.Sprintf("slowdrip pkcs11 test %d", i))
+		sig, err := s.SignEIP191(msg)
+		if err != nil {
+			t.Fatalf("SignEIP191: %v", err)
+		}
+		if v := sig[64]; v != 27 && v != 28 {
+			t.Fatalf("V = %d, want 27 or 28", v)
+		}
+		if sv := new(big.Int).SetBytes(sig[32:64]); sv.Cmp(secp256k1HalfN) > 0 {
+			t.Fatal("S is not normalised to the lower half of the curve order")
+		}
+		got, err := RecoverEIP191(msg, sig)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != s.Address() {
+			t.Fatalf("recovered %s, want %s", got.Hex(), s.Address().Hex())
+		}
+	}
+}
+
+func TestPKCS11SoftHSMErrors(t *testing.T) {
+	module := softHSM(t)
+
+	tests := []struct {
+		name    string
+		opts    PKCS11Options
+		wantErr string
+	}{
+		{name: "wrong pin", opts: PKCS11Options{TokenLabel: testTokenLabel, KeyLabel: testKeyLabel, PIN: "0000"}, wantErr: "login"},
+		{name: "unknown token", opts: PKCS11Options{TokenLabel: "nope", KeyLabel: testKeyLabel, PIN: testPIN}, wantErr: "not found"},
+		{name: "unknown key", opts: PKCS11Options{TokenLabel: testTokenLabel, KeyLabel: "nope", PIN: testPIN}, wantErr: "key not found"},
+		{name: "no key selector", opts: PKCS11Options{TokenLabel: testTokenLabel, PIN: testPIN}, wantErr: "keyLabel or keyID"},
+		{name: "by id", opts: PKCS11Options{TokenLabel: testTokenLabel, KeyID: "01", PIN: testPIN}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.opts.Module = module
+			s, err := OpenPKCS11(tt.opts)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("OpenPKCS11: %v", err)
+				}
+				s.Close()
+				return
+			}
+			if err == nil {
+				s.Close()
+				t.Fatal("OpenPKCS11 succeeded")
+			}
+			if !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("err = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
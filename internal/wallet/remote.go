@@ -0,0 +1,179 @@
+// internal/wallet/remote.go
+package wallet
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/signer/core/apitypes"
+)
+
+// RemoteOptions configures a RemoteSigner.
+type RemoteOptions struct {
+	URL     string         // JSON-RPC endpoint, e.g. http://127.0.0.1:8550 (clef --http)
+	Address common.Address // account the signer holds for this miner
+	Timeout time.Duration  // per request; signers may wait for manual approval
+	// TypedDataMethod is the RPC used for EIP-712: "account_signTypedData" (Clef, default)
+	// or "eth_signTypedData" (web3signer and similar).
+	TypedDataMethod string
+}
+
+// RemoteSigner signs through an external signer over HTTP JSON-RPC (Clef-compatible).
+// Policy (rules, rate limits, approvals) is enforced by the signer, not here.
+type RemoteSigner struct {
+	url     string
+	addr    common.Address
+	typed   string
+	http    *http.Client
+	nextID  atomic.Uint64
+	timeout time.Duration
+}
+
+// NewRemoteSigner creates a client without contacting the signer.
+func NewRemoteSigner(o RemoteOptions) *RemoteSigner {
+	if o.Timeout <= 0 {
+		o.Timeout = 30 * time.Second
+	}
+	if o.TypedDataMethod == "" {
+		o.TypedDataMethod = "account_signTypedData"
+	}
+	return &RemoteSigner{
+		url:     o.URL,
+		addr:    o.Address,
+		typed:   o.TypedDataMethod,
+		http:    &http.Client{Timeout: o.Timeout},
+		timeout: o.Timeout,
+	}
+}
+
+// DialRemote creates a RemoteSigner and checks (account_list) that the signer
+// actually holds o.Address.
+func DialRemote(ctx context.Context, o RemoteOptions) (*RemoteSigner, error) {
+	s := NewRemoteSigner(o)
+	var accounts []common.Address
+	if err := s.call(ctx, "account_list", nil, &accounts); err != nil {
+		return nil, err
+	}
+	for _, a := range accounts {
+		if a == o.Address {
+			return s, nil
+		}
+	}
+	return nil, fmt.Errorf("wallet: remote signer does not hold %s", o.Address.Hex())
+}
+
+// Address returns the account this signer signs for.
+func (s *RemoteSigner) Address() common.Address { return s.addr }
+
+// SignHash is not offered by Clef-style signers: they only sign data they can show
+// to a rules engine or a human.
+func (s *RemoteSigner) SignHash(digest32 []byte) ([]byte, error) {
+	return nil, ErrRawHashUnsupported
+}
+
+// SignEIP191 uses account_signData with content type text/plain.
+func (s *RemoteSigner) SignEIP191(msg []byte) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), s.timeout)
+	defer cancel()
+	var sig string
+	params := []interface{}{"text/plain", s.addr, "0x" + hex.EncodeToString(msg)}
+	if err := s.call(ctx, "account_signData", params, &sig); err != nil {
+		return nil, err
+	}
+	return decodeRemoteSig(sig)
+}
+
+// SignTypedData uses account_signTypedData (or the configured eth_signTypedData).
+func (s *RemoteSigner) SignTypedData(td apitypes.TypedData) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), s.timeout)
+	defer cancel()
+	var sig string
+	if err := s.call(ctx, s.typed, []interface{}{s.addr, td}, &sig); err != nil {
+		return nil, err
+	}
+	return decodeRemoteSig(sig)
+}
+
+// --------------------------
+// JSON-RPC
+// --------------------------
+
+type rpcRequest struct {
+	JSONRPC string      `json:"jsonrpc"`
+	ID      uint64      `json:"id"`
+	Method  string      `json:"method"`
+	Params  interface{} `json:"params"`
+}
+
+type rpcResponse struct {
+	ID     uint64          `json:"id"`
+	Result json.RawMessage `json:"result"`
+	Error  *struct {
+		Code    int    `json:"code"`
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+func (s *RemoteSigner) call(ctx context.Context, method string, params interface{}, out interface{}) error {
+	if params == nil {
+		params = []interface{}{}
+	}
+	body, err := json.Marshal(rpcRequest{JSONRPC: "2.0", ID: s.nextID.Add(1), Method: method, Params: params})
+	if err != nil {
+		return fmt.Errorf("wallet: remote %s: encode: %w", method, err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.url, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("wallet: remote %s: %w", method, err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := s.http.Do(req)
+	if err != nil {
+		return fmt.Errorf("wallet: remote %s: %w", method, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("wallet: remote %s: http %s", method, resp.Status)
+	}
+
+	var rr rpcResponse
+	if err := json.NewDecoder(resp.Body).Decode(&rr); err != nil {
+		return fmt.Errorf("wallet: remote %s: decode: %w", method, err)
+	}
+	if rr.Error != nil {
+		// Clef reports policy denials as errors (e.g. "Request denied").
+		return fmt.Errorf("wallet: remote %s: %s (code %d)", method, rr.Error.Message, rr.Error.Code)
+	}
+	if len(rr.Result) == 0 || string(rr.Result) == "null" {
+		return fmt.Errorf("wallet: remote %s: empty result", method)
+	}
+	if err := json.Unmarshal(rr.Result, out); err != nil {
+		return fmt.Errorf("wallet: remote %s: result: %w", method, err)
+	}
+	return nil
+}
+
+// decodeRemoteSig parses a 0x-hex signature and normalises V to {27,28}.
+func decodeRemoteSig(s string) ([]byte, error) {
+	sig, err := hex.DecodeString(strings.TrimPrefix(s, "0x"))
+	if err != nil {
+		return nil, fmt.Errorf("wallet: remote signature: %w", err)
+	}
+	if len(sig) != 65 {
+		return nil, errors.New("wallet: remote signature must be 65 bytes")
+	}
+	if sig[64] < 27 {
+		sig[64] += 27
+	}
+	return sig, nil
+}
@@ -0,0 +1,187 @@
+package wallet
+
+import (
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// fakeClef is a stand-in for a Clef-compatible signer holding one key.
+type fakeClef struct {
+	key     *Keystore
+	deny    bool // answer signing with a JSON-RPC error, like a rules denial
+	status  int  // non-zero: reply with this HTTP status
+	badSig  bool // return a truncated signature
+	rawV    bool // return V as 0/1 instead of 27/28
+	methods []string
+}
+
+func (f *fakeClef) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if f.status != 0 {
+		w.WriteHeader(f.status)
+		return
+	}
+	var req struct {
+		ID     uint64            `json:"id"`
+		Method string            `json:"method"`
+		Params []json.RawMessage `json:"params"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	f.methods = append(f.methods, req.Method)
+	reply := func(result interface{}, rpcErr string) {
+		out := map[string]interface{}{"jsonrpc": "2.0", "id": req.ID}
+		if rpcErr != "" {
+			out["error"] = map[string]interface{}{"code": -32000, "message": rpcErr}
+		} else {
+			out["result"] = result
+		}
+		_ = json.NewEncoder(w).Encode(out)
+	}
+	switch req.Method {
+	case "account_list":
+		reply([]common.Address{f.key.Address()}, "")
+	case "account_signData":
+		if f.deny {
+			reply(nil, "Request denied")
+			return
+		}
+		var data string
+		_ = json.Unmarshal(req.Params[2], &data)
+		msg, err := hex.DecodeString(strings.TrimPrefix(data, "0x"))
+		if err != nil {
+			reply(nil, "bad data")
+			return
+		}
+		sig, err := f.key.SignEIP191(msg)
+		if err != nil {
+			reply(nil, err.Error())
+			return
+		}
+		if f.rawV {
+			sig[64] -= 27
+		}
+		if f.badSig {
+			sig = sig[:64]
+		}
+		reply("0x"+hex.EncodeToString(sig), "")
+	default:
+		reply(nil, "method not found")
+	}
+}
+
+func newFakeClef(t *testing.T) (*fakeClef, *httptest.Server) {
+	t.Helper()
+	key, err := NewRandom(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(key.Close)
+	f := &fakeClef{key: key}
+	srv := httptest.NewServer(f)
+	t.Cleanup(srv.Close)
+	return f, srv
+}
+
+func TestDialRemote(t *testing.T) {
+	f, srv := newFakeClef(t)
+	other, err := NewRandom(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer other.Close()
+
+	tests := []struct {
+		name    string
+		url     string
+		addr    common.Address
+		wantErr string
+	}{
+		{name: "held address", url: srv.URL, addr: f.key.Address()},
+		{name: "address mismatch", url: srv.URL, addr: other.Address(), wantErr: "does not hold"},
+		{name: "unreachable", url: "http://127.0.0.1:1", addr: f.key.Address(), wantErr: "account_list"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			s, err := DialRemote(ctx, RemoteOptions{URL: tt.url, Address: tt.addr, Timeout: 2 * time.Second})
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("DialRemote: %v", err)
+				}
+				if s.Address() != tt.addr {
+					t.Fatalf("address %s, want %s", s.Address().Hex(), tt.addr.Hex())
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("err = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestRemoteSignEIP191(t *testing.T) {
+	msg := []byte("slowdrip-presence\nminer m1\nnonce 00")
+	tests := []struct {
+		name    string
+		setup   func(f *fakeClef)
+		wantErr string
+	}{
+		{name: "signs", setup: func(f *fakeClef) {}},
+		{name: "normalises raw V", setup: func(f *fakeClef) { f.rawV = true }},
+		{name: "denied by rules", setup: func(f *fakeClef) { f.deny = true }, wantErr: "Request denied"},
+		{name: "http error", setup: func(f *fakeClef) { f.status = http.StatusBadGateway }, wantErr: "http 502"},
+		{name: "short signature", setup: func(f *fakeClef) { f.badSig = true }, wantErr: "65 bytes"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f, srv := newFakeClef(t)
+			tt.setup(f)
+			s := NewRemoteSigner(RemoteOptions{URL: srv.URL, Address: f.key.Address(), Timeout: 2 * time.Second})
+
+			sig, err := s.SignEIP191(msg)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("err = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("SignEIP191: %v", err)
+			}
+			if v := sig[64]; v != 27 && v != 28 {
+				t.Fatalf("V = %d, want 27 or 28", v)
+			}
+			got, err := RecoverEIP191(msg, sig)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != f.key.Address() {
+				t.Fatalf("recovered %s, want %s", got.Hex(), f.key.Address().Hex())
+			}
+		})
+	}
+}
+
+func TestRemoteSignHashUnsupported(t *testing.T) {
+	f, srv := newFakeClef(t)
+	s := NewRemoteSigner(RemoteOptions{URL: srv.URL, Address: f.key.Address()})
+	if _, err := s.SignHash(make([]byte, 32)); !errors.Is(err, ErrRawHashUnsupported) {
+		t.Fatalf("err = %v, want ErrRawHashUnsupported", err)
+	}
+	if len(f.methods) != 0 {
+		t.Fatalf("SignHash contacted the signer: %v", f.methods)
+	}
+}
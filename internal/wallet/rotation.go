@@ -0,0 +1,345 @@
+// internal/wallet/rotation.go
+package wallet
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	gethks "github.com/ethereum/go-ethereum/accounts/keystore"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/rs/zerolog"
+)
+
+// HandoverVersion is bumped if the handover message layout changes.
+const HandoverVersion uint8 = 1
+
+// Handover moves the miner identity from Old to New starting at Epoch.
+// It is signed by the OLD key with EIP-191 over Message(), so anyone holding the
+// JSON can check it with any personal_sign verifier (see VerifyHandover).
+type Handover struct {
+	Version uint8          `json:"v"`
+	ChainID int64          `json:"chain_id"`
+	Old     common.Address `json:"old"`
+	New     common.Address `json:"new"`
+	Epoch   uint64         `json:"effective_epoch"`
+	Reason  string         `json:"reason"`
+	Sig     string         `json:"sig"` // 0x-hex, 65 bytes, V in {27,28}
+}
+
+// Message is the exact text signed by the old key.
+func (h Handover) Message() []byte {
+	return []byte(fmt.Sprintf("SlowDrip miner key handover v%d\nchain: %d\nold: %s\nnew: %s\nepoch: %d\nreason: %s",
+		h.Version, h.ChainID, h.Old.Hex(), h.New.Hex(), h.Epoch, h.Reason))
+}
+
+// SignHandover builds and signs a handover from old to newAddr.
+func SignHandover(old *Keystore, newAddr common.Address, epoch uint64, reason string) (Handover, error) {
+	if strings.ContainsAny(reason, "\r\n") {
+		return Handover{}, errors.New("wallet: handover reason must be a single line")
+	}
+	h := Handover{
+		Version: HandoverVersion,
+		Old:     old.Address(),
+		New:     newAddr,
+		Epoch:   epoch,
+		Reason:  reason,
+	}
+	if id := old.ChainID(); id != nil {
+		h.ChainID = id.Int64()
+	}
+	sig, err := old.SignEIP191(h.Message())
+	if err != nil {
+		return Handover{}, err
+	}
+	h.Sig = "0x" + hex.EncodeToString(sig)
+	return h, nil
+}
+
+// VerifyHandover checks that h was signed by h.Old.
+func VerifyHandover(h Handover) error {
+	if h.Version != HandoverVersion {
+		return fmt.Errorf("wallet: unsupported handover version %d", h.Version)
+	}
+	if h.Old == h.New {
+		return errors.New("wallet: handover old and new addresses are equal")
+	}
+	sig, err := hex.DecodeString(strings.TrimPrefix(h.Sig, "0x"))
+	if err != nil {
+		return fmt.Errorf("wallet: handover sig: %w", err)
+	}
+	rec, err := RecoverEIP191(h.Message(), sig)
+	if err != nil {
+		return err
+	}
+	if rec != h.Old {
+		return fmt.Errorf("wallet: handover signed by %s, expected %s", rec.Hex(), h.Old.Hex())
+	}
+	return nil
+}
+
+// --------------------------
+// Epochs
+// --------------------------
+
+// EpochClock maps wall time to fixed-length epochs counted from the Unix epoch.
+type EpochClock struct {
+	Length time.Duration
+	Now    func() time.Time // nil = time.Now
+}
+
+// Current returns the epoch number for now.
+func (c EpochClock) Current() uint64 {
+	now := time.Now
+	if c.Now != nil {
+		now = c.Now
+	}
+	return c.At(now())
+}
+
+// At returns the epoch number containing t.
+func (c EpochClock) At(t time.Time) uint64 {
+	if c.Length <= 0 || t.Unix() < 0 {
+		return 0
+	}
+	return uint64(t.UnixNano() / int64(c.Length))
+}
+
+// Start returns when epoch e begins.
+func (c EpochClock) Start(e uint64) time.Time {
+	return time.Unix(0, int64(e)*int64(c.Length)).UTC()
+}
+
+// --------------------------
+// Rotator
+// --------------------------
+
+// Rotator holds the active miner key and, during a rotation, the next one.
+// Until the handover epoch it signs with the old key; from the boundary on it signs
+// with the new key. A pending rotation is persisted next to the keystore:
+//
+//	<keystore>.next            new key (same password)
+//	<keystore>.handover.json   signed handover
+//
+// At the boundary the old file is copied to <keystore>.retired-<old address> (it may
+// still be needed to claim rewards), then <keystore>.next is renamed over <keystore>.
+// Each step leaves a state NewRotator can resume from.
+type Rotator struct {
+	mu       sync.Mutex
+	cur      *Keystore
+	next     *Keystore
+	pending  *Handover
+	clock    EpochClock
+	path     string // keystore file; "" = rotation unavailable
+	password string
+	log      zerolog.Logger
+}
+
+// NewRotator wraps cur. If keystorePath is set, a rotation persisted by a previous
+// run is resumed (and verified) here.
+func NewRotator(cur *Keystore, clock EpochClock, keystorePath, password string, log zerolog.Logger) (*Rotator, error) {
+	r := &Rotator{
+		cur:      cur,
+		clock:    clock,
+		path:     keystorePath,
+		password: password,
+		log:      log.With().Str("module", "wallet").Logger(),
+	}
+	if keystorePath == "" {
+		return r, nil
+	}
+
+	b, err := os.ReadFile(handoverPath(keystorePath))
+	if errors.Is(err, os.ErrNotExist) {
+		return r, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("wallet: read handover: %w", err)
+	}
+	var h Handover
+	if err := json.Unmarshal(b, &h); err != nil {
+		return nil, fmt.Errorf("wallet: parse handover: %w", err)
+	}
+	if err := VerifyHandover(h); err != nil {
+		return nil, err
+	}
+	if h.Old != cur.Address() {
+		if h.New == cur.Address() {
+			// Stopped after the new key was renamed into place: only archiving is left.
+			r.archiveHandover(h)
+			return r, nil
+		}
+		return nil, fmt.Errorf("wallet: pending handover is from %s but active key is %s", h.Old.Hex(), cur.Address().Hex())
+	}
+	next, err := LoadKeystoreFile(nextKeyPath(keystorePath), password, cur.ChainID())
+	if err != nil {
+		return nil, fmt.Errorf("wallet: load next key: %w", err)
+	}
+	if next.Address() != h.New {
+		next.Close()
+		return nil, errors.New("wallet: next keystore does not match pending handover")
+	}
+	r.next, r.pending = next, &h
+	r.log.Info().Str("new", h.New.Hex()).Uint64("epoch", h.Epoch).Msg("wallet: resumed pending rotation")
+	return r, nil
+}
+
+// Rotate generates a new key, has the current key sign a handover effective at
+// epoch, and persists both. Only one rotation may be pending at a time.
+func (r *Rotator) Rotate(epoch uint64, reason string) (Handover, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.promoteLocked()
+
+	if r.path == "" {
+		return Handover{}, errors.New("wallet: rotation needs a keystore-backed wallet")
+	}
+	if r.pending != nil {
+		return Handover{}, fmt.Errorf("wallet: rotation to %s already pending (epoch %d)", r.pending.New.Hex(), r.pending.Epoch)
+	}
+	if now := r.clock.Current(); epoch <= now {
+		return Handover{}, fmt.Errorf("wallet: handover epoch %d must be after current epoch %d", epoch, now)
+	}
+
+	next, err := NewRandom(r.cur.ChainID())
+	if err != nil {
+		return Handover{}, err
+	}
+	h, err := SignHandover(r.cur, next.Address(), epoch, reason)
+	if err != nil {
+		next.Close()
+		return Handover{}, err
+	}
+
+	// Key first, then handover: a handover file on disk always has its key.
+	if err := WriteKeystoreFile(nextKeyPath(r.path), next, r.password, gethks.StandardScryptN, gethks.StandardScryptP); err != nil {
+		next.Close()
+		return Handover{}, err
+	}
+	hb, err := json.MarshalIndent(h, "", "  ")
+	if err != nil {
+		next.Close()
+		return Handover{}, err
+	}
+	if err := atomicWrite(handoverPath(r.path), hb); err != nil {
+		next.Close()
+		return Handover{}, err
+	}
+
+	r.next, r.pending = next, &h
+	r.log.Warn().
+		Str("old", h.Old.Hex()).
+		Str("new", h.New.Hex()).
+		Uint64("epoch", epoch).
+		Str("reason", reason).
+		Msg("wallet: key rotation scheduled")
+	return h, nil
+}
+
+// Pending returns the scheduled handover, if any.
+func (r *Rotator) Pending() *Handover {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.promoteLocked()
+	if r.pending == nil {
+		return nil
+	}
+	h := *r.pending
+	return &h
+}
+
+// WithActive calls fn with the key that signs right now. The rotator stays locked
+// while fn runs, so the key can't be promoted away (and wiped) underneath it; fn
+// must not keep the key after returning.
+func (r *Rotator) WithActive(fn func(k *Keystore) error) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.promoteLocked()
+	return fn(r.cur)
+}
+
+// Address of the active key.
+func (r *Rotator) Address() common.Address {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.promoteLocked()
+	return r.cur.Address()
+}
+
+// ChainID of the active key.
+func (r *Rotator) ChainID() *big.Int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.promoteLocked()
+	return r.cur.ChainID()
+}
+
+// SignHash signs with the key active for the current epoch.
+func (r *Rotator) SignHash(digest32 []byte) ([]byte, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.promoteLocked()
+	return r.cur.SignHash(digest32)
+}
+
+// SignEIP191 signs with the key active for the current epoch.
+func (r *Rotator) SignEIP191(msg []byte) ([]byte, error) {
+	return r.SignHash(eip191Digest(msg))
+}
+
+// Close wipes all held keys.
+func (r *Rotator) Close() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.cur.Close()
+	if r.next != nil {
+		r.next.Close()
+	}
+}
+
+// promoteLocked switches to the next key once the handover epoch is reached.
+func (r *Rotator) promoteLocked() {
+	if r.pending == nil || r.clock.Current() < r.pending.Epoch {
+		return
+	}
+	old := r.cur
+	r.cur, r.next = r.next, nil
+	h := *r.pending
+	r.pending = nil
+
+	// Copy the old key out first, then rename the new one over it: <keystore> is
+	// always a complete key, and a crash in between is resumed by NewRotator.
+	if b, err := os.ReadFile(r.path); err != nil {
+		r.log.Error().Err(err).Msg("wallet: could not read old keystore")
+	} else if err := atomicWrite(retiredPath(r.path, h.Old), b); err != nil {
+		r.log.Error().Err(err).Msg("wallet: could not retire old keystore")
+	} else if err := os.Rename(nextKeyPath(r.path), r.path); err != nil {
+		r.log.Error().Err(err).Msg("wallet: could not promote next keystore")
+	} else {
+		r.archiveHandover(h)
+	}
+	old.Close()
+
+	r.log.Warn().Str("old", h.Old.Hex()).Str("new", h.New.Hex()).Uint64("epoch", h.Epoch).Msg("wallet: key rotated")
+}
+
+// archiveHandover moves the handover next to the retired key once the new key is
+// in place.
+func (r *Rotator) archiveHandover(h Handover) {
+	if err := os.Rename(handoverPath(r.path), retiredPath(r.path, h.Old)+".handover.json"); err != nil {
+		r.log.Error().Err(err).Msg("wallet: could not archive handover")
+	}
+}
+
+func retiredPath(keystorePath string, old common.Address) string {
+	return keystorePath + ".retired-" + strings.ToLower(old.Hex())
+}
+
+func handoverPath(keystorePath string) string { return keystorePath + ".handover.json" }
+func nextKeyPath(keystorePath string) string  { return keystorePath + ".next" }
@@ -0,0 +1,291 @@
+package wallet
+
+import (
+	"encoding/json"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	gethks "github.com/ethereum/go-ethereum/accounts/keystore"
+	"github.com/rs/zerolog"
+)
+
+const testPassword = "correct horse"
+
+// testScryptN keeps keystore encryption cheap; the files are never kept.
+const testScryptN = 1 << 4
+
+var testChainID = big.NewInt(1337)
+
+func randomKey(t *testing.T) *Keystore {
+	t.Helper()
+	k, err := NewRandom(testChainID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(k.Close)
+	return k
+}
+
+func writeKey(t *testing.T, path string, k *Keystore) {
+	t.Helper()
+	if err := WriteKeystoreFile(path, k, testPassword, testScryptN, gethks.LightScryptP); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func writeHandover(t *testing.T, keystorePath string, h Handover) {
+	t.Helper()
+	b, err := json.Marshal(h)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(handoverPath(keystorePath), b, 0o600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func loadKey(t *testing.T, path string) *Keystore {
+	t.Helper()
+	k, err := LoadKeystoreFile(path, testPassword, testChainID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return k
+}
+
+// flipHex returns a different hex digit than c.
+func flipHex(c byte) string {
+	if c == '0' {
+		return "1"
+	}
+	return "0"
+}
+
+func exists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
+
+// testClock is an hour-long EpochClock whose time the test moves by hand.
+func testClock(now *time.Time) EpochClock {
+	return EpochClock{Length: time.Hour, Now: func() time.Time { return *now }}
+}
+
+// pendingRotation lays out what Rotate persists: <keystore> holding old,
+// <keystore>.next holding a fresh key, and the handover old signed for epoch.
+func pendingRotation(t *testing.T, epoch uint64) (path string, old *Keystore, next *Keystore, h Handover) {
+	t.Helper()
+	path = filepath.Join(t.TempDir(), "miner.json")
+	old, next = randomKey(t), randomKey(t)
+	writeKey(t, path, old)
+	writeKey(t, nextKeyPath(path), next)
+	h, err := SignHandover(old, next.Address(), epoch, "scheduled")
+	if err != nil {
+		t.Fatal(err)
+	}
+	writeHandover(t, path, h)
+	return path, old, next, h
+}
+
+func TestHandoverRoundTrip(t *testing.T) {
+	old, next := randomKey(t), randomKey(t)
+	h, err := SignHandover(old, next.Address(), 42, "laptop stolen")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if h.ChainID != testChainID.Int64() || h.Old != old.Address() || h.New != next.Address() {
+		t.Fatalf("handover %+v", h)
+	}
+	// It survives the JSON it is persisted and published as.
+	b, _ := json.Marshal(h)
+	var back Handover
+	if err := json.Unmarshal(b, &back); err != nil {
+		t.Fatal(err)
+	}
+	if err := VerifyHandover(back); err != nil {
+		t.Fatal(err)
+	}
+
+	stranger := randomKey(t)
+	tamper := map[string]func(*Handover){
+		"epoch":    func(h *Handover) { h.Epoch++ },
+		"new":      func(h *Handover) { h.New = stranger.Address() },
+		"old":      func(h *Handover) { h.Old = stranger.Address() },
+		"reason":   func(h *Handover) { h.Reason = "routine" },
+		"chain":    func(h *Handover) { h.ChainID = 1 },
+		"version":  func(h *Handover) { h.Version = 2 },
+		"same key": func(h *Handover) { h.New = h.Old },
+		"sig":      func(h *Handover) { h.Sig = h.Sig[:10] + flipHex(h.Sig[10]) + h.Sig[11:] },
+		"not hex":  func(h *Handover) { h.Sig = "0xzz" },
+	}
+	for name, f := range tamper {
+		bad := h
+		f(&bad)
+		if err := VerifyHandover(bad); err == nil {
+			t.Errorf("%s: tampered handover verified", name)
+		}
+	}
+
+	if _, err := SignHandover(old, next.Address(), 42, "two\nlines"); err == nil {
+		t.Error("multi-line reason accepted")
+	}
+}
+
+func TestRotatorPromotesAtEpoch(t *testing.T) {
+	const epoch = 500_000
+	path, old, next, _ := pendingRotation(t, epoch)
+	clock := EpochClock{Length: time.Hour}
+	now := clock.Start(epoch).Add(-time.Nanosecond)
+	clock = testClock(&now)
+
+	cur := loadKey(t, path)
+	r, err := NewRotator(cur, clock, path, testPassword, zerolog.Nop())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	if p := r.Pending(); p == nil || p.New != next.Address() || p.Epoch != epoch {
+		t.Fatalf("pending %+v", p)
+	}
+	if a := r.Address(); a != old.Address() {
+		t.Fatalf("signing as %s one tick before the epoch", a.Hex())
+	}
+
+	now = clock.Start(epoch)
+	if a := r.Address(); a != next.Address() {
+		t.Fatalf("signing as %s at the epoch", a.Hex())
+	}
+	if r.Pending() != nil {
+		t.Fatal("handover still pending after promotion")
+	}
+	sig, err := r.SignEIP191([]byte("hello"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if signer, _ := RecoverEIP191([]byte("hello"), sig); signer != next.Address() {
+		t.Fatalf("signed by %s", signer.Hex())
+	}
+
+	if a, err := KeystoreAddress(path); err != nil || a != next.Address() {
+		t.Fatalf("keystore holds %s, %v", a.Hex(), err)
+	}
+	retired := retiredPath(path, old.Address())
+	if a, err := KeystoreAddress(retired); err != nil || a != old.Address() {
+		t.Fatalf("retired key holds %s, %v", a.Hex(), err)
+	}
+	if !exists(retired + ".handover.json") {
+		t.Error("handover not archived")
+	}
+	if exists(nextKeyPath(path)) || exists(handoverPath(path)) {
+		t.Error("rotation files left behind")
+	}
+}
+
+func TestNewRotatorResume(t *testing.T) {
+	const epoch = 500_000
+	clock := EpochClock{Length: time.Hour}
+	before := clock.Start(epoch).Add(-time.Minute)
+
+	t.Run("next key and handover, before the epoch", func(t *testing.T) {
+		path, old, next, _ := pendingRotation(t, epoch)
+		now := before
+		r, err := NewRotator(loadKey(t, path), testClock(&now), path, testPassword, zerolog.Nop())
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer r.Close()
+		if r.Pending() == nil || r.Address() != old.Address() {
+			t.Fatal("pending rotation not resumed")
+		}
+		now = clock.Start(epoch)
+		if r.Address() != next.Address() {
+			t.Fatal("resumed rotation not promoted at the epoch")
+		}
+	})
+
+	t.Run("next key and handover, epoch already passed", func(t *testing.T) {
+		// Includes a crash after the old key was copied out but before the rename.
+		path, old, next, _ := pendingRotation(t, epoch)
+		writeKey(t, retiredPath(path, old.Address()), old)
+		now := clock.Start(epoch + 3)
+		r, err := NewRotator(loadKey(t, path), testClock(&now), path, testPassword, zerolog.Nop())
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer r.Close()
+		if r.Address() != next.Address() {
+			t.Fatal("overdue rotation not promoted on first use")
+		}
+		if a, _ := KeystoreAddress(path); a != next.Address() {
+			t.Fatalf("keystore holds %s", a.Hex())
+		}
+	})
+
+	t.Run("new key renamed, handover not archived", func(t *testing.T) {
+		path, old, next, _ := pendingRotation(t, epoch)
+		writeKey(t, retiredPath(path, old.Address()), old)
+		if err := os.Rename(nextKeyPath(path), path); err != nil {
+			t.Fatal(err)
+		}
+		now := clock.Start(epoch + 1)
+		r, err := NewRotator(loadKey(t, path), testClock(&now), path, testPassword, zerolog.Nop())
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer r.Close()
+		if r.Pending() != nil || r.Address() != next.Address() {
+			t.Fatal("finished rotation treated as pending")
+		}
+		if exists(handoverPath(path)) || !exists(retiredPath(path, old.Address())+".handover.json") {
+			t.Fatal("handover not archived on resume")
+		}
+	})
+
+	t.Run("handover from another key", func(t *testing.T) {
+		path, _, _, _ := pendingRotation(t, epoch)
+		other := randomKey(t)
+		now := before
+		if _, err := NewRotator(other, testClock(&now), path, testPassword, zerolog.Nop()); err == nil {
+			t.Fatal("foreign handover accepted")
+		}
+	})
+
+	t.Run("next key does not match the handover", func(t *testing.T) {
+		path, _, _, _ := pendingRotation(t, epoch)
+		writeKey(t, nextKeyPath(path), randomKey(t))
+		now := before
+		_, err := NewRotator(loadKey(t, path), testClock(&now), path, testPassword, zerolog.Nop())
+		if err == nil || !strings.Contains(err.Error(), "does not match") {
+			t.Fatalf("err %v", err)
+		}
+	})
+
+	t.Run("forged handover", func(t *testing.T) {
+		path, _, _, h := pendingRotation(t, epoch)
+		h.Epoch = epoch - 100
+		writeHandover(t, path, h)
+		now := before
+		if _, err := NewRotator(loadKey(t, path), testClock(&now), path, testPassword, zerolog.Nop()); err == nil {
+			t.Fatal("handover with a bad signature accepted")
+		}
+	})
+
+	t.Run("no handover", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "miner.json")
+		k := randomKey(t)
+		writeKey(t, path, k)
+		now := before
+		r, err := NewRotator(loadKey(t, path), testClock(&now), path, testPassword, zerolog.Nop())
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer r.Close()
+		if r.Pending() != nil || r.Address() != k.Address() {
+			t.Fatal("rotation invented from nothing")
+		}
+	})
+}
@@ -0,0 +1,292 @@
+// internal/wallet/shamir.go
+package wallet
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+	"sync"
+
+	gethcrypto "github.com/ethereum/go-ethereum/crypto"
+	"github.com/tyler-smith/go-bip39/wordlists"
+)
+
+// ShareVersion is bumped if the share layout changes.
+const ShareVersion uint8 = 1
+
+// Share is one M-of-N Shamir share of the miner private key.
+//
+// Encoded layout (43 bytes), rendered as 32 BIP-39 English words (11 bits each):
+//
+//	v(1) | threshold(1) | index(1) | setID(4) | data(32) | checksum(4)
+//
+// setID is the first 4 bytes of the miner address, so shares from different keys
+// can't be mixed; checksum = sha256(preceding 39 bytes)[:4] catches typos.
+type Share struct {
+	Version   uint8
+	Threshold uint8
+	Index     uint8 // x coordinate, 1..N
+	SetID     [4]byte
+	Data      [32]byte
+}
+
+const (
+	shareBytes = 1 + 1 + 1 + 4 + 32 + 4
+	shareWords = (shareBytes*8 + 10) / 11
+)
+
+// Backup splits the private key into n shares, any k of which rebuild it.
+func (w *Keystore) Backup(n, k int) ([]Share, error) {
+	if k < 2 || n < k || n > 255 {
+		return nil, fmt.Errorf("wallet: need 2 <= threshold <= shares <= 255, got %d-of-%d", k, n)
+	}
+	w.mu.RLock()
+	if w.priv == nil {
+		w.mu.RUnlock()
+		return nil, errors.New("wallet: closed")
+	}
+	secret := gethcrypto.FromECDSA(w.priv)
+	addr := w.addr
+	w.mu.RUnlock()
+	defer wipe(secret)
+
+	ys, err := shamirSplit(secret, n, k)
+	if err != nil {
+		return nil, err
+	}
+	shares := make([]Share, n)
+	for i := range shares {
+		s := Share{Version: ShareVersion, Threshold: uint8(k), Index: uint8(i + 1)}
+		copy(s.SetID[:], addr[:4])
+		copy(s.Data[:], ys[i])
+		wipe(ys[i])
+		shares[i] = s
+	}
+	return shares, nil
+}
+
+// Restore rebuilds the key from at least Threshold distinct shares of one set and
+// checks that the rebuilt key matches the set's address prefix.
+func Restore(shares []Share, chainID *big.Int) (*Keystore, error) {
+	if len(shares) == 0 {
+		return nil, errors.New("wallet: no shares")
+	}
+	first := shares[0]
+	seen := map[uint8]bool{}
+	var xs []byte
+	var ys [][]byte
+	for _, s := range shares {
+		if s.Version != ShareVersion {
+			return nil, fmt.Errorf("wallet: share %d: unsupported version %d", s.Index, s.Version)
+		}
+		if s.SetID != first.SetID || s.Threshold != first.Threshold {
+			return nil, fmt.Errorf("wallet: share %d belongs to a different backup set", s.Index)
+		}
+		if s.Index == 0 || seen[s.Index] {
+			return nil, fmt.Errorf("wallet: duplicate or invalid share index %d", s.Index)
+		}
+		seen[s.Index] = true
+		xs = append(xs, s.Index)
+		d := s.Data
+		ys = append(ys, d[:])
+	}
+	if len(shares) < int(first.Threshold) {
+		return nil, fmt.Errorf("wallet: need %d shares, have %d", first.Threshold, len(shares))
+	}
+
+	secret := shamirCombine(xs, ys)
+	defer wipe(secret)
+	priv, err := gethcrypto.ToECDSA(secret)
+	if err != nil {
+		return nil, fmt.Errorf("wallet: rebuilt key invalid: %w", err)
+	}
+	addr := gethcrypto.PubkeyToAddress(priv.PublicKey)
+	if !bytes.Equal(addr[:4], first.SetID[:]) {
+		return nil, errors.New("wallet: rebuilt key does not match the backup set (wrong or corrupted shares)")
+	}
+	return &Keystore{priv: priv, addr: addr, chainID: copyBig(chainID)}, nil
+}
+
+// --------------------------
+// Encoding
+// --------------------------
+
+// Words renders the share as BIP-39 English words.
+func (s Share) Words() string {
+	b := s.bytes()
+	words := make([]string, 0, shareWords)
+	var acc uint32
+	var bits uint
+	for _, c := range b {
+		acc = acc<<8 | uint32(c)
+		bits += 8
+		for bits >= 11 {
+			bits -= 11
+			words = append(words, wordlists.English[(acc>>bits)&0x7ff])
+		}
+	}
+	if bits > 0 {
+		words = append(words, wordlists.English[(acc<<(11-bits))&0x7ff])
+	}
+	return strings.Join(words, " ")
+}
+
+// ParseShare decodes a share from its words, verifying the checksum.
+func ParseShare(text string) (Share, error) {
+	fields := strings.Fields(strings.ToLower(text))
+	if len(fields) != shareWords {
+		return Share{}, fmt.Errorf("wallet: share must have %d words, got %d", shareWords, len(fields))
+	}
+	index := wordIndex()
+	out := make([]byte, 0, shareBytes+2)
+	var acc uint32
+	var bits uint
+	for _, f := range fields {
+		v, ok := index[f]
+		if !ok {
+			return Share{}, fmt.Errorf("wallet: unknown share word %q", f)
+		}
+		acc = acc<<11 | uint32(v)
+		bits += 11
+		for bits >= 8 {
+			bits -= 8
+			out = append(out, byte(acc>>bits))
+		}
+	}
+	return shareFromBytes(out[:shareBytes])
+}
+
+func (s Share) bytes() []byte {
+	b := make([]byte, 0, shareBytes)
+	b = append(b, s.Version, s.Threshold, s.Index)
+	b = append(b, s.SetID[:]...)
+	b = append(b, s.Data[:]...)
+	sum := sha256.Sum256(b)
+	return append(b, sum[:4]...)
+}
+
+func shareFromBytes(b []byte) (Share, error) {
+	if len(b) != shareBytes {
+		return Share{}, errors.New("wallet: bad share length")
+	}
+	sum := sha256.Sum256(b[:shareBytes-4])
+	if !bytes.Equal(sum[:4], b[shareBytes-4:]) {
+		return Share{}, errors.New("wallet: share checksum mismatch (typo?)")
+	}
+	var s Share
+	s.Version, s.Threshold, s.Index = b[0], b[1], b[2]
+	copy(s.SetID[:], b[3:7])
+	copy(s.Data[:], b[7:39])
+	return s, nil
+}
+
+var (
+	englishOnce  sync.Once
+	englishIndex map[string]int
+)
+
+func wordIndex() map[string]int {
+	englishOnce.Do(func() {
+		m := make(map[string]int, len(wordlists.English))
+		for i, w := range wordlists.English {
+			m[w] = i
+		}
+		englishIndex = m
+	})
+	return englishIndex
+}
+
+// --------------------------
+// GF(256) Shamir
+// --------------------------
+
+// Arithmetic in GF(2^8) with the AES polynomial x^8+x^4+x^3+x+1, generator 3.
+var gfExp, gfLog = func() (exp [512]byte, log [256]byte) {
+	x := byte(1)
+	for i := 0; i < 255; i++ {
+		exp[i] = x
+		log[x] = byte(i)
+		// x *= 3
+		hi := x & 0x80
+		x2 := x << 1
+		if hi != 0 {
+			x2 ^= 0x1b
+		}
+		x ^= x2
+	}
+	for i := 255; i < 512; i++ {
+		exp[i] = exp[i-255]
+	}
+	return
+}()
+
+func gfMul(a, b byte) byte {
+	if a == 0 || b == 0 {
+		return 0
+	}
+	return gfExp[int(gfLog[a])+int(gfLog[b])]
+}
+
+func gfDiv(a, b byte) byte {
+	if a == 0 {
+		return 0
+	}
+	return gfExp[int(gfLog[a])+255-int(gfLog[b])]
+}
+
+// shamirSplit returns n shares (y values for x = 1..n) of secret with threshold k.
+func shamirSplit(secret []byte, n, k int) ([][]byte, error) {
+	ys := make([][]byte, n)
+	for i := range ys {
+		ys[i] = make([]byte, len(secret))
+	}
+	coef := make([]byte, k)
+	defer wipe(coef)
+	for j, sb := range secret {
+		coef[0] = sb
+		if _, err := rand.Read(coef[1:]); err != nil {
+			return nil, fmt.Errorf("wallet: random: %w", err)
+		}
+		for i := 0; i < n; i++ {
+			x := byte(i + 1)
+			// Horner: y = (((c[k-1])x + c[k-2])x + ...)x + c0
+			var y byte
+			for d := k - 1; d >= 0; d-- {
+				y = gfMul(y, x) ^ coef[d]
+			}
+			ys[i][j] = y
+		}
+	}
+	return ys, nil
+}
+
+// shamirCombine interpolates the polynomial at x=0 (Lagrange).
+func shamirCombine(xs []byte, ys [][]byte) []byte {
+	out := make([]byte, len(ys[0]))
+	for j := range out {
+		var acc byte
+		for i := range xs {
+			li := byte(1)
+			for m := range xs {
+				if m == i {
+					continue
+				}
+				// l_i(0) = prod x_m / (x_m - x_i); subtraction is XOR in GF(2^8)
+				li = gfMul(li, gfDiv(xs[m], xs[m]^xs[i]))
+			}
+			acc ^= gfMul(ys[i][j], li)
+		}
+		out[j] = acc
+	}
+	return out
+}
+
+func wipe(b []byte) {
+	for i := range b {
+		b[i] = 0
+	}
+}
@@ -0,0 +1,123 @@
+package wallet
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestBackupRestore(t *testing.T) {
+	key := randomKey(t)
+	for _, tc := range []struct{ n, k int }{
+		{2, 2}, {3, 2}, {5, 3}, {5, 5}, {255, 2}, {255, 255},
+	} {
+		shares, err := key.Backup(tc.n, tc.k)
+		if err != nil {
+			t.Fatalf("%d-of-%d: %v", tc.k, tc.n, err)
+		}
+		if len(shares) != tc.n {
+			t.Fatalf("%d-of-%d: got %d shares", tc.k, tc.n, len(shares))
+		}
+		// The first k, the last k and all n shares each rebuild the key.
+		for _, set := range [][]Share{shares[:tc.k], shares[tc.n-tc.k:], shares} {
+			got, err := Restore(set, testChainID)
+			if err != nil {
+				t.Fatalf("%d-of-%d from %d shares: %v", tc.k, tc.n, len(set), err)
+			}
+			if got.PrivateKeyHex() != key.PrivateKeyHex() {
+				t.Fatalf("%d-of-%d: rebuilt a different key", tc.k, tc.n)
+			}
+			if got.ChainID().Cmp(testChainID) != 0 {
+				t.Fatalf("chain id %s", got.ChainID())
+			}
+			got.Close()
+		}
+		// k-1 shares are refused before interpolating.
+		if _, err := Restore(shares[:tc.k-1], testChainID); err == nil {
+			t.Fatalf("%d-of-%d restored from %d shares", tc.k, tc.n, tc.k-1)
+		}
+	}
+
+	for _, tc := range []struct{ n, k int }{{3, 1}, {2, 3}, {256, 2}, {0, 0}} {
+		if _, err := key.Backup(tc.n, tc.k); err == nil {
+			t.Errorf("%d-of-%d accepted", tc.k, tc.n)
+		}
+	}
+}
+
+func TestRestoreRejectsBadSets(t *testing.T) {
+	key, other := randomKey(t), randomKey(t)
+	shares, err := key.Backup(5, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	foreign, err := other.Backup(5, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wider, err := key.Backup(5, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	corrupt := shares[2]
+	corrupt.Data[0] ^= 1
+	badVersion := shares[2]
+	badVersion.Version = ShareVersion + 1
+
+	tests := map[string][]Share{
+		"none":             nil,
+		"mixed keys":       {shares[0], shares[1], foreign[2]},
+		"mixed thresholds": {shares[0], shares[1], wider[2]},
+		"duplicate index":  {shares[0], shares[1], shares[1]},
+		"index zero":       {shares[0], shares[1], {Version: ShareVersion, Threshold: 3, SetID: shares[0].SetID}},
+		"corrupted data":   {shares[0], shares[1], corrupt},
+		"unknown version":  {shares[0], shares[1], badVersion},
+		"below threshold":  {shares[3], shares[4]},
+	}
+	for name, set := range tests {
+		if _, err := Restore(set, testChainID); err == nil {
+			t.Errorf("%s: restored", name)
+		}
+	}
+}
+
+func TestShareWords(t *testing.T) {
+	shares, err := randomKey(t).Backup(3, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range shares {
+		words := s.Words()
+		if n := len(strings.Fields(words)); n != shareWords {
+			t.Fatalf("%d words", n)
+		}
+		// Case and spacing don't matter when typing a share back in.
+		got, err := ParseShare("  " + strings.ToUpper(strings.ReplaceAll(words, " ", "\n ")) + "\n")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != s {
+			t.Fatalf("round trip: %+v != %+v", got, s)
+		}
+	}
+
+	words := strings.Fields(shares[0].Words())
+	typo := append([]string(nil), words...)
+	// Any other valid word in the middle of the share breaks the checksum.
+	if typo[10] == "abandon" {
+		typo[10] = "ability"
+	} else {
+		typo[10] = "abandon"
+	}
+	if _, err := ParseShare(strings.Join(typo, " ")); err == nil || !strings.Contains(err.Error(), "checksum") {
+		t.Errorf("typo: %v", err)
+	}
+	if _, err := ParseShare(strings.Join(words[1:], " ")); err == nil {
+		t.Error("short share accepted")
+	}
+	unknown := append([]string(nil), words...)
+	unknown[0] = "slowdrip"
+	if _, err := ParseShare(strings.Join(unknown, " ")); err == nil {
+		t.Error("non-BIP-39 word accepted")
+	}
+}
@@ -0,0 +1,53 @@
+// internal/wallet/signer.go
+package wallet
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/signer/core/apitypes"
+)
+
+// Signer is the signing surface the rest of the miner depends on. Keystore (and
+// Rotator around it) sign in-process; RemoteSigner forwards to an external signer
+// so the hot key does not have to live on the edge box.
+//
+// All signatures are 65 bytes [R || S || V] with V in {27,28}.
+type Signer interface {
+	Address() common.Address
+	// SignHash signs a raw 32-byte digest. Remote signers that refuse blind hash
+	// signing (Clef does, by design) return ErrRawHashUnsupported.
+	SignHash(digest32 []byte) ([]byte, error)
+	// SignEIP191 signs msg as an EIP-191 personal message.
+	SignEIP191(msg []byte) ([]byte, error)
+	// SignTypedData signs EIP-712 typed data.
+	SignTypedData(td apitypes.TypedData) ([]byte, error)
+}
+
+// ErrRawHashUnsupported is returned by signers that only sign structured data.
+var ErrRawHashUnsupported = errors.New("wallet: signer does not sign raw hashes")
+
+var (
+	_ Signer = (*Keystore)(nil)
+	_ Signer = (*Rotator)(nil)
+	_ Signer = (*RemoteSigner)(nil)
+)
+
+// SignTypedData hashes td per EIP-712 and signs the digest.
+func (w *Keystore) SignTypedData(td apitypes.TypedData) ([]byte, error) {
+	digest, _, err := apitypes.TypedDataAndHash(td)
+	if err != nil {
+		return nil, fmt.Errorf("wallet: typed data hash: %w", err)
+	}
+	return w.SignHash(digest)
+}
+
+// SignTypedData signs with the key active for the current epoch.
+func (r *Rotator) SignTypedData(td apitypes.TypedData) ([]byte, error) {
+	digest, _, err := apitypes.TypedDataAndHash(td)
+	if err != nil {
+		return nil, fmt.Errorf("wallet: typed data hash: %w", err)
+	}
+	return r.SignHash(digest)
+}